@@ -11,6 +11,7 @@ import (
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
 
 // This is a compile-time assertion to ensure that this generated file
@@ -26,6 +27,13 @@ type ProductInfoClient interface {
 	AddProduct(ctx context.Context, in *Product, opts ...grpc.CallOption) (*ProductID, error)
 	// 基于商品ID获取商品的远程方法。
 	GetProduct(ctx context.Context, in *ProductID, opts ...grpc.CallOption) (*Product, error)
+	// 更新商品，只修改updateMask中列出的字段，updateMask为空时修改所有可以修改的字段。
+	// product.etag不为空并且与商品当前的etag不同时返回ABORTED，商品不存在时返回NOT_FOUND。
+	UpdateProduct(ctx context.Context, in *UpdateProductRequest, opts ...grpc.CallOption) (*Product, error)
+	// 删除商品，etag不为空并且与商品当前的etag不同时返回ABORTED。
+	DeleteProduct(ctx context.Context, in *DeleteProductRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// 分页列出商品，可以按名称、描述和价格过滤，并指定排序方式。
+	ListProducts(ctx context.Context, in *ListProductsRequest, opts ...grpc.CallOption) (*ListProductsResponse, error)
 }
 
 type productInfoClient struct {
@@ -54,6 +62,33 @@ func (c *productInfoClient) GetProduct(ctx context.Context, in *ProductID, opts
 	return out, nil
 }
 
+func (c *productInfoClient) UpdateProduct(ctx context.Context, in *UpdateProductRequest, opts ...grpc.CallOption) (*Product, error) {
+	out := new(Product)
+	err := c.cc.Invoke(ctx, "/ecommerce.ProductInfo/updateProduct", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productInfoClient) DeleteProduct(ctx context.Context, in *DeleteProductRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/ecommerce.ProductInfo/deleteProduct", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productInfoClient) ListProducts(ctx context.Context, in *ListProductsRequest, opts ...grpc.CallOption) (*ListProductsResponse, error) {
+	out := new(ListProductsResponse)
+	err := c.cc.Invoke(ctx, "/ecommerce.ProductInfo/listProducts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ProductInfoServer is the server API for ProductInfo service.
 // All implementations must embed UnimplementedProductInfoServer
 // for forward compatibility
@@ -62,6 +97,13 @@ type ProductInfoServer interface {
 	AddProduct(context.Context, *Product) (*ProductID, error)
 	// 基于商品ID获取商品的远程方法。
 	GetProduct(context.Context, *ProductID) (*Product, error)
+	// 更新商品，只修改updateMask中列出的字段，updateMask为空时修改所有可以修改的字段。
+	// product.etag不为空并且与商品当前的etag不同时返回ABORTED，商品不存在时返回NOT_FOUND。
+	UpdateProduct(context.Context, *UpdateProductRequest) (*Product, error)
+	// 删除商品，etag不为空并且与商品当前的etag不同时返回ABORTED。
+	DeleteProduct(context.Context, *DeleteProductRequest) (*emptypb.Empty, error)
+	// 分页列出商品，可以按名称、描述和价格过滤，并指定排序方式。
+	ListProducts(context.Context, *ListProductsRequest) (*ListProductsResponse, error)
 	mustEmbedUnimplementedProductInfoServer()
 }
 
@@ -75,6 +117,15 @@ func (UnimplementedProductInfoServer) AddProduct(context.Context, *Product) (*Pr
 func (UnimplementedProductInfoServer) GetProduct(context.Context, *ProductID) (*Product, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetProduct not implemented")
 }
+func (UnimplementedProductInfoServer) UpdateProduct(context.Context, *UpdateProductRequest) (*Product, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateProduct not implemented")
+}
+func (UnimplementedProductInfoServer) DeleteProduct(context.Context, *DeleteProductRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteProduct not implemented")
+}
+func (UnimplementedProductInfoServer) ListProducts(context.Context, *ListProductsRequest) (*ListProductsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListProducts not implemented")
+}
 func (UnimplementedProductInfoServer) mustEmbedUnimplementedProductInfoServer() {}
 
 // UnsafeProductInfoServer may be embedded to opt out of forward compatibility for this service.
@@ -124,6 +175,60 @@ func _ProductInfo_GetProduct_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ProductInfo_UpdateProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductInfoServer).UpdateProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.ProductInfo/updateProduct",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductInfoServer).UpdateProduct(ctx, req.(*UpdateProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductInfo_DeleteProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductInfoServer).DeleteProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.ProductInfo/deleteProduct",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductInfoServer).DeleteProduct(ctx, req.(*DeleteProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductInfo_ListProducts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListProductsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductInfoServer).ListProducts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.ProductInfo/listProducts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductInfoServer).ListProducts(ctx, req.(*ListProductsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ProductInfo_ServiceDesc is the grpc.ServiceDesc for ProductInfo service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -139,6 +244,18 @@ var ProductInfo_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "getProduct",
 			Handler:    _ProductInfo_GetProduct_Handler,
 		},
+		{
+			MethodName: "updateProduct",
+			Handler:    _ProductInfo_UpdateProduct_Handler,
+		},
+		{
+			MethodName: "deleteProduct",
+			Handler:    _ProductInfo_DeleteProduct_Handler,
+		},
+		{
+			MethodName: "listProducts",
+			Handler:    _ProductInfo_ListProducts_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "product_info.proto",
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+
+	pb "basic-authentication/server/ecommerce"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	// defaultProductPageSize 是listProducts没有指定pageSize时每页的商品数。
+	defaultProductPageSize = 50
+	maxProductPageSize     = 1000
+)
+
+// updatableProductPaths 是updateProduct可以修改的字段，id和etag由服务器端维护。
+var updatableProductPaths = []string{"name", "description", "price"}
+
+// productPageToken 是listProducts的分页令牌，记录上一页最后一个商品的排序键。
+// 令牌经过base64编码，对客户端是不透明的。
+type productPageToken struct {
+	// Query 是过滤条件和排序方式的摘要，防止令牌被用于不同的查询。
+	Query     string  `json:"q"`
+	LastID    string  `json:"id"`
+	LastName  string  `json:"n,omitempty"`
+	LastPrice float32 `json:"p,omitempty"`
+}
+
+// productETag 根据商品的内容计算etag，商品的任意字段变化后etag随之变化。
+func productETag(product *pb.Product) string {
+	p := proto.Clone(product).(*pb.Product)
+	p.Etag = ""
+	data, _ := proto.MarshalOptions{Deterministic: true}.Marshal(p)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// withETag 返回带有当前etag的商品副本。
+func withETag(product *pb.Product) *pb.Product {
+	p := proto.Clone(product).(*pb.Product)
+	p.Etag = productETag(product)
+	return p
+}
+
+// checkETag 在etag不为空并且与商品当前的etag不同时返回Aborted。
+func checkETag(product *pb.Product, etag string) error {
+	if etag != "" && etag != productETag(product) {
+		return status.Errorf(codes.Aborted, "Product %s was modified, etag %q is out of date.", product.Id, etag)
+	}
+	return nil
+}
+
+// UpdateProduct implements ecommerce.UpdateProduct
+// UpdateProduct 方法只修改updateMask中列出的字段，updateMask为空时修改所有可以修改的字段。
+// 检查etag和修改商品在同一个锁内完成，两个客户端基于同一个etag的更新只有一个会成功。
+func (s *server) UpdateProduct(ctx context.Context, in *pb.UpdateProductRequest) (*pb.Product, error) {
+	patch := in.GetProduct()
+	if patch.GetId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Product ID must not be empty.")
+	}
+	paths := in.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		paths = updatableProductPaths
+	}
+	for _, path := range paths {
+		if !containsPath(updatableProductPaths, path) {
+			return nil, status.Errorf(codes.InvalidArgument, "Field %q cannot be updated.", path)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	product, exists := s.productMap[patch.Id]
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "Product does not exist. : %s", patch.Id)
+	}
+	if err := checkETag(product, patch.Etag); err != nil {
+		return nil, err
+	}
+	updated := proto.Clone(product).(*pb.Product)
+	for _, path := range paths {
+		switch path {
+		case "name":
+			updated.Name = patch.Name
+		case "description":
+			updated.Description = patch.Description
+		case "price":
+			updated.Price = patch.Price
+		}
+	}
+	s.productMap[updated.Id] = updated
+	log.Printf("Product %v : %v - Updated %v.", updated.Id, updated.Name, paths)
+	return withETag(updated), nil
+}
+
+// DeleteProduct implements ecommerce.DeleteProduct
+func (s *server) DeleteProduct(ctx context.Context, in *pb.DeleteProductRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	product, exists := s.productMap[in.Id]
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "Product does not exist. : %s", in.Id)
+	}
+	if err := checkETag(product, in.Etag); err != nil {
+		return nil, err
+	}
+	delete(s.productMap, in.Id)
+	log.Printf("Product %v : %v - Deleted.", product.Id, product.Name)
+	return &emptypb.Empty{}, nil
+}
+
+// ListProducts implements ecommerce.ListProducts
+// ListProducts 方法按排序方式返回一页商品。分页基于上一页最后一个商品的排序键，
+// 翻页期间新增或删除商品不会导致结果重复或遗漏。
+func (s *server) ListProducts(ctx context.Context, in *pb.ListProductsRequest) (*pb.ListProductsResponse, error) {
+	if in.PageSize < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Page size must not be negative : %d", in.PageSize)
+	}
+	if in.MinPrice != nil && in.MaxPrice != nil && in.MinPrice.Value > in.MaxPrice.Value {
+		return nil, status.Errorf(codes.InvalidArgument, "Min price %v is greater than max price %v.", in.MinPrice.Value, in.MaxPrice.Value)
+	}
+	digest := listQueryDigest(in)
+	less := productLess(in.OrderBy)
+	var after *pb.Product
+	if in.PageToken != "" {
+		token, err := decodeProductPageToken(in.PageToken)
+		if err != nil || token.Query != digest {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid page token.")
+		}
+		after = &pb.Product{Id: token.LastID, Name: token.LastName, Price: token.LastPrice}
+	}
+	pageSize := int(in.PageSize)
+	if pageSize == 0 {
+		pageSize = defaultProductPageSize
+	}
+	if pageSize > maxProductPageSize {
+		pageSize = maxProductPageSize
+	}
+
+	s.mu.Lock()
+	var matches []*pb.Product
+	for _, product := range s.productMap {
+		if matchesProduct(product, in) && (after == nil || less(after, product)) {
+			matches = append(matches, withETag(product))
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(matches, func(i, j int) bool { return less(matches[i], matches[j]) })
+
+	resp := &pb.ListProductsResponse{Products: matches}
+	if len(matches) > pageSize {
+		resp.Products = matches[:pageSize]
+		last := resp.Products[pageSize-1]
+		resp.NextPageToken = encodeProductPageToken(&productPageToken{Query: digest, LastID: last.Id, LastName: last.Name, LastPrice: last.Price})
+	}
+	return resp, nil
+}
+
+func matchesProduct(product *pb.Product, in *pb.ListProductsRequest) bool {
+	if in.Query != "" {
+		query := strings.ToLower(in.Query)
+		if !strings.Contains(strings.ToLower(product.Name), query) && !strings.Contains(strings.ToLower(product.Description), query) {
+			return false
+		}
+	}
+	if in.MinPrice != nil && product.Price < in.MinPrice.Value {
+		return false
+	}
+	if in.MaxPrice != nil && product.Price > in.MaxPrice.Value {
+		return false
+	}
+	return true
+}
+
+// productLess 返回给定排序方式下的比较函数，排序键相同时按商品ID排序，保证顺序是全序的。
+func productLess(order pb.ProductSortOrder) func(a, b *pb.Product) bool {
+	return func(a, b *pb.Product) bool {
+		switch order {
+		case pb.ProductSortOrder_PRODUCT_NAME_ASC, pb.ProductSortOrder_PRODUCT_NAME_DESC:
+			if a.Name != b.Name {
+				return (a.Name < b.Name) == (order == pb.ProductSortOrder_PRODUCT_NAME_ASC)
+			}
+		case pb.ProductSortOrder_PRODUCT_PRICE_ASC, pb.ProductSortOrder_PRODUCT_PRICE_DESC:
+			if a.Price != b.Price {
+				return (a.Price < b.Price) == (order == pb.ProductSortOrder_PRODUCT_PRICE_ASC)
+			}
+		}
+		return a.Id < b.Id
+	}
+}
+
+// listQueryDigest 对去掉分页字段后的请求计算摘要。
+func listQueryDigest(in *pb.ListProductsRequest) string {
+	query := proto.Clone(in).(*pb.ListProductsRequest)
+	query.PageSize = 0
+	query.PageToken = ""
+	data, _ := proto.MarshalOptions{Deterministic: true}.Marshal(query)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+func encodeProductPageToken(token *productPageToken) string {
+	data, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeProductPageToken(s string) (*productPageToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	token := &productPageToken{}
+	if err := json.Unmarshal(data, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
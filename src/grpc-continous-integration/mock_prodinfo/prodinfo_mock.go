@@ -11,6 +11,7 @@ import (
 
 	gomock "github.com/golang/mock/gomock"
 	grpc "google.golang.org/grpc"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
 	wrapperspb "google.golang.org/protobuf/types/known/wrapperspb"
 )
 
@@ -57,6 +58,26 @@ func (mr *MockProductInfoClientMockRecorder) AddProduct(arg0, arg1 interface{},
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddProduct", reflect.TypeOf((*MockProductInfoClient)(nil).AddProduct), varargs...)
 }
 
+// DeleteProduct mocks base method.
+func (m *MockProductInfoClient) DeleteProduct(arg0 context.Context, arg1 *proto_gen.DeleteProductRequest, arg2 ...grpc.CallOption) (*emptypb.Empty, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DeleteProduct", varargs...)
+	ret0, _ := ret[0].(*emptypb.Empty)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteProduct indicates an expected call of DeleteProduct.
+func (mr *MockProductInfoClientMockRecorder) DeleteProduct(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteProduct", reflect.TypeOf((*MockProductInfoClient)(nil).DeleteProduct), varargs...)
+}
+
 // GetProduct mocks base method.
 func (m *MockProductInfoClient) GetProduct(arg0 context.Context, arg1 *wrapperspb.StringValue, arg2 ...grpc.CallOption) (*proto_gen.Product, error) {
 	m.ctrl.T.Helper()
@@ -76,3 +97,43 @@ func (mr *MockProductInfoClientMockRecorder) GetProduct(arg0, arg1 interface{},
 	varargs := append([]interface{}{arg0, arg1}, arg2...)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProduct", reflect.TypeOf((*MockProductInfoClient)(nil).GetProduct), varargs...)
 }
+
+// ListProducts mocks base method.
+func (m *MockProductInfoClient) ListProducts(arg0 context.Context, arg1 *proto_gen.ListProductsRequest, arg2 ...grpc.CallOption) (*proto_gen.ListProductsResponse, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ListProducts", varargs...)
+	ret0, _ := ret[0].(*proto_gen.ListProductsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListProducts indicates an expected call of ListProducts.
+func (mr *MockProductInfoClientMockRecorder) ListProducts(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListProducts", reflect.TypeOf((*MockProductInfoClient)(nil).ListProducts), varargs...)
+}
+
+// UpdateProduct mocks base method.
+func (m *MockProductInfoClient) UpdateProduct(arg0 context.Context, arg1 *proto_gen.UpdateProductRequest, arg2 ...grpc.CallOption) (*proto_gen.Product, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UpdateProduct", varargs...)
+	ret0, _ := ret[0].(*proto_gen.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateProduct indicates an expected call of UpdateProduct.
+func (mr *MockProductInfoClientMockRecorder) UpdateProduct(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProduct", reflect.TypeOf((*MockProductInfoClient)(nil).UpdateProduct), varargs...)
+}
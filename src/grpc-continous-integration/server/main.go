@@ -7,15 +7,17 @@ package main
 
 import (
 	"context"
-	"errors"
 	"log"
 	"net"
+	"sync"
 
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/google/uuid"
 	pb "grpc-continous-integration/proto_gen"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -24,6 +26,8 @@ const (
 
 // server is used to implement ecommerce/product_info.
 type server struct {
+	// mu 保护productMap，updateProduct和deleteProduct在同一个锁内检查etag并修改商品。
+	mu         sync.Mutex
 	productMap map[string]*pb.Product
 	pb.UnimplementedProductInfoServer
 }
@@ -35,6 +39,8 @@ func (s *server) AddProduct(ctx context.Context, in *pb.Product) (*wrapper.Strin
 		log.Fatal(err)
 	}
 	in.Id = out.String()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.productMap == nil {
 		s.productMap = make(map[string]*pb.Product)
 	}
@@ -45,13 +51,16 @@ func (s *server) AddProduct(ctx context.Context, in *pb.Product) (*wrapper.Strin
 
 // GetProduct implements ecommerce.GetProduct
 func (s *server) GetProduct(ctx context.Context, in *wrapper.StringValue) (*pb.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	value, exists := s.productMap[in.Value]
 	if exists {
 		log.Printf("New product retrieved - ID : %s", in)
-		return value, nil
+		// 返回带有etag的副本，客户端更新或删除商品时用它检查商品是否已经被修改。
+		return withETag(value), nil
 	}
 
-	return nil, errors.New("Product does not exist for the ID" + in.Value)
+	return nil, status.Errorf(codes.NotFound, "Product does not exist. : %s", in.Value)
 }
 
 func main() {
@@ -2,10 +2,15 @@ package main
 
 import (
 	"context"
+	"fmt"
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	pb "grpc-continous-integration/proto_gen"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"log"
 	"net"
 	"testing"
@@ -114,3 +119,75 @@ func TestServer_AddProductBufConn(t *testing.T) {
 	}
 	log.Printf(r.Value)
 }
+
+// 更新和删除商品时检查etag，列出商品时按排序方式分页。
+func TestServer_UpdateListDeleteBufConn(t *testing.T) {
+	initGRPCServerBuffConn()
+	conn, err := grpc.DialContext(context.Background(), "bufnet", grpc.WithContextDialer(getBufDialer(listener)), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	c := pb.NewProductInfoClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var ids []string
+	for i, price := range []float32{300, 100, 200} {
+		r, err := c.AddProduct(ctx, &pb.Product{Name: fmt.Sprintf("Phone %d", i), Price: price})
+		if err != nil {
+			t.Fatalf("Could not add product: %v", err)
+		}
+		ids = append(ids, r.Value)
+	}
+
+	product, err := c.GetProduct(ctx, &wrapper.StringValue{Value: ids[0]})
+	if err != nil || product.Etag == "" {
+		t.Fatalf("GetProduct = %v, %v", product, err)
+	}
+	updated, err := c.UpdateProduct(ctx, &pb.UpdateProductRequest{
+		Product:    &pb.Product{Id: ids[0], Name: "ignored", Price: 50, Etag: product.Etag},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"price"}},
+	})
+	if err != nil || updated.Name != "Phone 0" || updated.Price != 50 || updated.Etag == product.Etag {
+		t.Fatalf("UpdateProduct = %v, %v", updated, err)
+	}
+	// 使用过期的etag更新或删除商品都会失败。
+	_, err = c.UpdateProduct(ctx, &pb.UpdateProductRequest{Product: &pb.Product{Id: ids[0], Name: "Phone", Etag: product.Etag}})
+	if status.Code(err) != codes.Aborted {
+		t.Fatalf("update with stale etag returned %v, want Aborted", err)
+	}
+	_, err = c.UpdateProduct(ctx, &pb.UpdateProductRequest{Product: &pb.Product{Id: ids[0]}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"id"}}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("update of id returned %v, want InvalidArgument", err)
+	}
+	if _, err := c.DeleteProduct(ctx, &pb.DeleteProductRequest{Id: ids[0], Etag: product.Etag}); status.Code(err) != codes.Aborted {
+		t.Fatalf("delete with stale etag returned %v, want Aborted", err)
+	}
+
+	var listed []string
+	req := &pb.ListProductsRequest{Query: "phone", OrderBy: pb.ProductSortOrder_PRODUCT_PRICE_ASC, PageSize: 2}
+	for {
+		page, err := c.ListProducts(ctx, req)
+		if err != nil {
+			t.Fatalf("ListProducts failed: %v", err)
+		}
+		for _, p := range page.Products {
+			listed = append(listed, p.Id)
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		req.PageToken = page.NextPageToken
+	}
+	if want := []string{ids[0], ids[1], ids[2]}; fmt.Sprint(listed) != fmt.Sprint(want) {
+		t.Fatalf("listed %v, want %v", listed, want)
+	}
+
+	if _, err := c.DeleteProduct(ctx, &pb.DeleteProductRequest{Id: ids[0], Etag: updated.Etag}); err != nil {
+		t.Fatalf("DeleteProduct failed: %v", err)
+	}
+	if _, err := c.GetProduct(ctx, &wrapper.StringValue{Value: ids[0]}); status.Code(err) != codes.NotFound {
+		t.Fatalf("GetProduct of a deleted product returned %v, want NotFound", err)
+	}
+}
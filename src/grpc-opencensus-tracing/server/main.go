@@ -11,6 +11,7 @@ import (
 	"google.golang.org/grpc/status"
 	"log"
 	"net"
+	"sync"
 
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/google/uuid"
@@ -27,6 +28,8 @@ const (
 
 // server is used to implement ecommerce/product_info.
 type server struct {
+	// mu 保护productMap，updateProduct和deleteProduct在同一个锁内检查etag并修改商品。
+	mu         sync.Mutex
 	productMap map[string]*pb.Product
 	pb.UnimplementedProductInfoServer
 }
@@ -40,6 +43,8 @@ func (s *server) AddProduct(ctx context.Context, in *pb.Product) (*wrapper.Strin
 		log.Fatal(err)
 	}
 	in.Id = out.String()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.productMap == nil {
 		s.productMap = make(map[string]*pb.Product)
 	}
@@ -53,11 +58,14 @@ func (s *server) GetProduct(ctx context.Context, in *wrapper.StringValue) (*pb.P
 	ctx, span := trace.StartSpan(ctx, "ecommerce.GetProduct")
 	// 当所有的事情完成后，停止该span.
 	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	value, exists := s.productMap[in.Value]
 	if exists {
-		return value, status.New(codes.OK, "").Err()
+		// 返回带有etag的副本，客户端更新或删除商品时用它检查商品是否已经被修改。
+		return withETag(value), status.New(codes.OK, "").Err()
 	}
-	return nil, status.Errorf(codes.NotFound, "Product does not exist.", in.Value)
+	return nil, status.Errorf(codes.NotFound, "Product does not exist. : %s", in.Value)
 }
 
 func main() {
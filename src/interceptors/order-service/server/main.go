@@ -19,39 +19,41 @@ const (
 	orderBatchSize = 3
 )
 
-var orderMap = make(map[string]pb.Order)
-
 type server struct {
-	orderMap map[string]*pb.Order
+	store OrderStore
 	pb.UnimplementedOrderManagementServer
 }
 
 // Simple RPC
 func (s *server) AddOrder(ctx context.Context, orderReq *pb.Order) (*wrappers.StringValue, error) {
-	orderMap[orderReq.Id] = *orderReq
+	s.store.Put(orderReq)
 	log.Println("Order : ",  orderReq.Id, " -> Added")
 	return &wrapper.StringValue{Value: "Order Added: " + orderReq.Id}, nil
 }
 
 // Simple RPC
 func (s *server) GetOrder(ctx context.Context, orderId *wrapper.StringValue) (*pb.Order, error) {
-	ord := orderMap[orderId.Value]
-	return &ord, nil
+	ord, exists := s.store.Get(orderId.Value)
+	if !exists {
+		ord = &pb.Order{}
+	}
+	return ord, nil
 }
 
 // Server-side Streaming RPC
 func (s *server) SearchOrders(searchQuery *wrappers.StringValue, stream pb.OrderManagement_SearchOrdersServer) error {
 
-	for key, order := range orderMap {
+	s.store.Scan(func(order *pb.Order) bool {
 		for _, itemStr := range order.Items {
 			if strings.Contains(itemStr, searchQuery.Value) {
 				// Send the matching orders in a stream
-				log.Print("Matching Order Found : " + key, " -> Writing Order to the stream ... ")
-				stream.Send(&order)
+				log.Print("Matching Order Found : " + order.Id, " -> Writing Order to the stream ... ")
+				stream.Send(order)
 				break
 			}
 		}
-	}
+		return true
+	})
 	return nil
 }
 
@@ -66,9 +68,9 @@ func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) erro
 			return stream.SendAndClose(&wrapper.StringValue{Value: "Orders processed " + ordersStr})
 		}
 		// Update order
-		orderMap[order.Id] = *order
+		s.store.Put(order)
 
-		log.Printf("Order ID ", order.Id, ": Updated")
+		log.Println("Order ID ", order.Id, ": Updated")
 		ordersStr += order.Id + ", "
 	}
 }
@@ -77,7 +79,7 @@ func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) erro
 func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) error {
 
 	batchMarker := 1
-	var combinedShipmentMap = make(map[string]pb.CombinedShipment)
+	var combinedShipmentMap = make(map[string]*pb.CombinedShipment)
 	for {
 		orderId, err := stream.Recv()
 		log.Println("Reading Proc order ... ", orderId)
@@ -88,7 +90,7 @@ func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) er
 			log.Println("EOF ", orderId)
 
 			for _, comb := range combinedShipmentMap {
-				stream.Send(&comb)
+				stream.Send(comb)
 			}
 			return nil
 		}
@@ -97,17 +99,18 @@ func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) er
 			return err
 		}
 
-		destination := orderMap[orderId.GetValue()].Destination
+		ord, exists := s.store.Get(orderId.GetValue())
+		if !exists {
+			ord = &pb.Order{}
+		}
+		destination := ord.Destination
 		shipment, found := combinedShipmentMap[destination]
 
 		if found {
-			ord := orderMap[orderId.GetValue()]
-			shipment.OrdersList = append(shipment.OrdersList, &ord)
-			combinedShipmentMap[destination] = shipment
+			shipment.OrdersList = append(shipment.OrdersList, ord)
 		} else {
-			comShip := pb.CombinedShipment{Id: "cmb - " + (orderMap[orderId.GetValue()].Destination), Status: "Processed!", }
-			ord := orderMap[orderId.GetValue()]
-			comShip.OrdersList = append(shipment.OrdersList, &ord)
+			comShip := &pb.CombinedShipment{Id: "cmb - " + destination, Status: "Processed!"}
+			comShip.OrdersList = append(comShip.OrdersList, ord)
 			combinedShipmentMap[destination] = comShip
 			log.Print(len(comShip.OrdersList), comShip.GetId())
 		}
@@ -115,10 +118,10 @@ func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) er
 		if batchMarker == orderBatchSize {
 			for _, comb := range combinedShipmentMap {
 				log.Print("Shipping : " , comb.Id, " -> ", len(comb.OrdersList))
-				stream.Send(&comb)
+				stream.Send(comb)
 			}
 			batchMarker = 0
-			combinedShipmentMap = make(map[string]pb.CombinedShipment)
+			combinedShipmentMap = make(map[string]*pb.CombinedShipment)
 		} else {
 			batchMarker++
 		}
@@ -191,7 +194,8 @@ func orderServerStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *g
 }
 
 func main() {
-	initSampleData()
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
 	lis, err := net.Listen("tcp", port)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
@@ -199,7 +203,7 @@ func main() {
 	s := grpc.NewServer(
 		grpc.UnaryInterceptor(orderUnaryServerInterceptor),   // 使用gRPC服务器端注册元拦截器。
 		grpc.StreamInterceptor(orderServerStreamInterceptor)) // 注册拦截器。
-	pb.RegisterOrderManagementServer(s, &server{})
+	pb.RegisterOrderManagementServer(s, &server{store: store})
 	// Register reflection service on gRPC server.
 	reflection.Register(s)
 	if err := s.Serve(lis); err != nil {
@@ -207,10 +211,10 @@ func main() {
 	}
 }
 
-func initSampleData() {
-	orderMap["102"] = pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA", Price: 1800.00}
-	orderMap["103"] = pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 400.00}
-	orderMap["104"] = pb.Order{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}, Destination: "Mountain View, CA", Price: 400.00}
-	orderMap["105"] = pb.Order{Id: "105", Items: []string{"Amazon Echo"}, Destination: "San Jose, CA", Price: 30.00}
-	orderMap["106"] = pb.Order{Id: "106", Items: []string{"Amazon Echo", "Apple iPhone XS"}, Destination: "Mountain View, CA", Price: 30.00}
+func initSampleData(store OrderStore) {
+	store.Put(&pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA", Price: 1800.00})
+	store.Put(&pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 400.00})
+	store.Put(&pb.Order{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}, Destination: "Mountain View, CA", Price: 400.00})
+	store.Put(&pb.Order{Id: "105", Items: []string{"Amazon Echo"}, Destination: "San Jose, CA", Price: 30.00})
+	store.Put(&pb.Order{Id: "106", Items: []string{"Amazon Echo", "Apple iPhone XS"}, Destination: "Mountain View, CA", Price: 30.00})
 }
@@ -22,16 +22,14 @@ const (
 	orderBatchSize = 3
 )
 
-var orderMap = make(map[string]pb.Order)
-
 type server struct {
-	orderMap map[string]*pb.Order
+	store OrderStore
 	pb.UnimplementedOrderManagementServer
 }
 
 // Simple RPC
 func (s *server) AddOrder(ctx context.Context, orderReq *pb.Order) (*wrappers.StringValue, error) {
-	orderMap[orderReq.Id] = *orderReq
+	s.store.Put(orderReq)
 	log.Println("Order : ", orderReq.Id, " -> Added")
 
 	// ***** Reading Metadata from Client *****
@@ -80,28 +78,30 @@ func (s *server) SearchOrders(searchQuery *wrappers.StringValue, stream pb.Order
 	// 和流的trailer一起发送元数据。
 	stream.SetTrailer(trailer)
 
-	for key, order := range orderMap {
+	s.store.Scan(func(order *pb.Order) bool {
 		for _, itemStr := range order.Items {
 			if strings.Contains(itemStr, searchQuery.Value) {
 				// Send the matching orders in a stream
-				log.Print("Matching Order Found : "+key, " -> Writing Order to the stream ... ")
-				stream.Send(&order)
+				log.Print("Matching Order Found : "+order.Id, " -> Writing Order to the stream ... ")
+				stream.Send(order)
 				break
 			}
 		}
-	}
+		return true
+	})
 
 	return nil
 }
 
 func main() {
-	initSampleData()
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
 	lis, err := net.Listen("tcp", port)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 	s := grpc.NewServer()
-	pb.RegisterOrderManagementServer(s, &server{})
+	pb.RegisterOrderManagementServer(s, &server{store: store})
 	// Register reflection service on gRPC server.
 	reflection.Register(s)
 	if err := s.Serve(lis); err != nil {
@@ -109,10 +109,10 @@ func main() {
 	}
 }
 
-func initSampleData() {
-	orderMap["102"] = pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA", Price: 1800.00}
-	orderMap["103"] = pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 400.00}
-	orderMap["104"] = pb.Order{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}, Destination: "Mountain View, CA", Price: 400.00}
-	orderMap["105"] = pb.Order{Id: "105", Items: []string{"Amazon Echo"}, Destination: "San Jose, CA", Price: 30.00}
-	orderMap["106"] = pb.Order{Id: "106", Items: []string{"Amazon Echo", "Apple iPhone XS"}, Destination: "Mountain View, CA", Price: 30.00}
+func initSampleData(store OrderStore) {
+	store.Put(&pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA", Price: 1800.00})
+	store.Put(&pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 400.00})
+	store.Put(&pb.Order{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}, Destination: "Mountain View, CA", Price: 400.00})
+	store.Put(&pb.Order{Id: "105", Items: []string{"Amazon Echo"}, Destination: "San Jose, CA", Price: 30.00})
+	store.Put(&pb.Order{Id: "106", Items: []string{"Amazon Echo", "Apple iPhone XS"}, Destination: "Mountain View, CA", Price: 30.00})
 }
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"hash/fnv"
+	"sort"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	pb "metadata/order-service/order-service-gen"
+)
+
+const defaultShardCount = 16
+
+// errKeyNotInTxn 表示事务访问了未在Txn调用中声明的订单ID。
+var errKeyNotInTxn = errors.New("order id not declared in transaction")
+
+// OrderStore 是OrderManagement服务读写订单所依赖的存储抽象。
+// 实现必须是并发安全的，Get和Scan返回的订单是副本，调用方可以随意修改。
+type OrderStore interface {
+	// Get 返回给定ID的订单副本。
+	Get(id string) (*pb.Order, bool)
+	// Put 写入（新增或覆盖）订单。
+	Put(order *pb.Order)
+	// Delete 删除订单，返回订单之前是否存在。
+	Delete(id string) bool
+	// Scan 按订单ID升序遍历所有订单，fn返回false时停止遍历。
+	Scan(fn func(order *pb.Order) bool)
+	// Txn 锁定ids涉及的所有订单并在fn中原子地读写它们。
+	// fn返回错误时，事务中的所有写入都会被丢弃。
+	Txn(ids []string, fn func(tx OrderTxn) error) error
+}
+
+// OrderTxn 是在OrderStore.Txn中可见的事务视图，只能访问声明过的订单ID。
+type OrderTxn interface {
+	Get(id string) (*pb.Order, bool)
+	Put(order *pb.Order) error
+	Delete(id string) error
+}
+
+// orderShard 是内存存储中的一个分片，由自己的读写锁保护。
+type orderShard struct {
+	mu     sync.RWMutex
+	orders map[string]*pb.Order
+}
+
+// memoryOrderStore 是按订单ID哈希分片、由互斥锁保护的内存OrderStore实现。
+type memoryOrderStore struct {
+	shards []*orderShard
+}
+
+func newMemoryOrderStore(shardCount int) *memoryOrderStore {
+	if shardCount <= 0 {
+		shardCount = defaultShardCount
+	}
+	s := &memoryOrderStore{shards: make([]*orderShard, shardCount)}
+	for i := range s.shards {
+		s.shards[i] = &orderShard{orders: make(map[string]*pb.Order)}
+	}
+	return s
+}
+
+func (s *memoryOrderStore) shardIndex(id string) int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() % uint32(len(s.shards)))
+}
+
+func (s *memoryOrderStore) shard(id string) *orderShard {
+	return s.shards[s.shardIndex(id)]
+}
+
+func (s *memoryOrderStore) Get(id string) (*pb.Order, bool) {
+	sh := s.shard(id)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	order, ok := sh.orders[id]
+	if !ok {
+		return nil, false
+	}
+	return cloneOrder(order), true
+}
+
+func (s *memoryOrderStore) Put(order *pb.Order) {
+	sh := s.shard(order.Id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.orders[order.Id] = cloneOrder(order)
+}
+
+func (s *memoryOrderStore) Delete(id string) bool {
+	sh := s.shard(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	_, ok := sh.orders[id]
+	delete(sh.orders, id)
+	return ok
+}
+
+func (s *memoryOrderStore) Scan(fn func(order *pb.Order) bool) {
+	// 先在读锁下复制所有订单，再在锁外回调，避免fn（例如写入流）长时间持有锁。
+	var orders []*pb.Order
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for _, order := range sh.orders {
+			orders = append(orders, cloneOrder(order))
+		}
+		sh.mu.RUnlock()
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].Id < orders[j].Id })
+	for _, order := range orders {
+		if !fn(order) {
+			return
+		}
+	}
+}
+
+func (s *memoryOrderStore) Txn(ids []string, fn func(tx OrderTxn) error) error {
+	// 按分片下标升序加锁，保证并发事务之间不会死锁。
+	indexes := make(map[int]bool)
+	for _, id := range ids {
+		indexes[s.shardIndex(id)] = true
+	}
+	locked := make([]int, 0, len(indexes))
+	for i := range indexes {
+		locked = append(locked, i)
+	}
+	sort.Ints(locked)
+	for _, i := range locked {
+		s.shards[i].mu.Lock()
+	}
+	defer func() {
+		for _, i := range locked {
+			s.shards[i].mu.Unlock()
+		}
+	}()
+
+	tx := &memoryOrderTxn{store: s, declared: make(map[string]bool), writes: make(map[string]*pb.Order)}
+	for _, id := range ids {
+		tx.declared[id] = true
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	for id, order := range tx.writes {
+		sh := s.shard(id)
+		if order == nil {
+			delete(sh.orders, id)
+		} else {
+			sh.orders[id] = order
+		}
+	}
+	return nil
+}
+
+// memoryOrderTxn 缓存事务中的写入，直到事务函数成功返回才提交到分片。
+// 值为nil的写入表示删除。
+type memoryOrderTxn struct {
+	store    *memoryOrderStore
+	declared map[string]bool
+	writes   map[string]*pb.Order
+}
+
+func (tx *memoryOrderTxn) Get(id string) (*pb.Order, bool) {
+	if !tx.declared[id] {
+		return nil, false
+	}
+	if order, ok := tx.writes[id]; ok {
+		if order == nil {
+			return nil, false
+		}
+		return cloneOrder(order), true
+	}
+	order, ok := tx.store.shard(id).orders[id]
+	if !ok {
+		return nil, false
+	}
+	return cloneOrder(order), true
+}
+
+func (tx *memoryOrderTxn) Put(order *pb.Order) error {
+	if !tx.declared[order.Id] {
+		return errKeyNotInTxn
+	}
+	tx.writes[order.Id] = cloneOrder(order)
+	return nil
+}
+
+func (tx *memoryOrderTxn) Delete(id string) error {
+	if !tx.declared[id] {
+		return errKeyNotInTxn
+	}
+	tx.writes[id] = nil
+	return nil
+}
+
+func cloneOrder(order *pb.Order) *pb.Order {
+	return proto.Clone(order).(*pb.Order)
+}
@@ -19,8 +19,6 @@ const (
 	orderBatchSize = 3
 )
 
-var orderMap = make(map[string]ordermgt_pb.Order)
-
 type helloServer struct {
 	hello_pb.UnimplementedGreeterServer
 }
@@ -32,13 +30,13 @@ func (s *helloServer) SayHello(ctx context.Context, in *hello_pb.HelloRequest) (
 }
 
 type orderMgtServer struct {
-	orderMap map[string]*ordermgt_pb.Order
+	store OrderStore
 	ordermgt_pb.UnimplementedOrderManagementServer
 }
 
 // Simple RPC
 func (s *orderMgtServer) AddOrder(ctx context.Context, orderReq *ordermgt_pb.Order) (*wrappers.StringValue, error) {
-	orderMap[orderReq.Id] = *orderReq
+	s.store.Put(orderReq)
 
 	log.Printf("Order Management Service - AddOrder RPC")
 
@@ -48,23 +46,27 @@ func (s *orderMgtServer) AddOrder(ctx context.Context, orderReq *ordermgt_pb.Ord
 
 // Simple RPC
 func (s *orderMgtServer) GetOrder(ctx context.Context, orderId *wrapper.StringValue) (*ordermgt_pb.Order, error) {
-	ord := orderMap[orderId.Value]
-	return &ord, nil
+	ord, exists := s.store.Get(orderId.Value)
+	if !exists {
+		ord = &ordermgt_pb.Order{}
+	}
+	return ord, nil
 }
 
 // Server-side Streaming RPC
 func (s *orderMgtServer) SearchOrders(searchQuery *wrappers.StringValue, stream ordermgt_pb.OrderManagement_SearchOrdersServer) error {
 
-	for key, order := range orderMap {
+	s.store.Scan(func(order *ordermgt_pb.Order) bool {
 		for _, itemStr := range order.Items {
 			if strings.Contains(itemStr, searchQuery.Value) {
 				// Send the matching orders in a stream
-				log.Print("Matching Order Found : "+key, " -> Writing Order to the stream ... ")
-				stream.Send(&order)
+				log.Print("Matching Order Found : "+order.Id, " -> Writing Order to the stream ... ")
+				stream.Send(order)
 				break
 			}
 		}
-	}
+		return true
+	})
 
 	return nil
 }
@@ -80,9 +82,9 @@ func (s *orderMgtServer) UpdateOrders(stream ordermgt_pb.OrderManagement_UpdateO
 			return stream.SendAndClose(&wrapper.StringValue{Value: "Orders processed " + ordersStr})
 		}
 		// Update order
-		orderMap[order.Id] = *order
+		s.store.Put(order)
 
-		log.Printf("Order ID ", order.Id, ": Updated")
+		log.Println("Order ID ", order.Id, ": Updated")
 		ordersStr += order.Id + ", "
 	}
 }
@@ -91,7 +93,7 @@ func (s *orderMgtServer) UpdateOrders(stream ordermgt_pb.OrderManagement_UpdateO
 func (s *orderMgtServer) ProcessOrders(stream ordermgt_pb.OrderManagement_ProcessOrdersServer) error {
 
 	batchMarker := 1
-	var combinedShipmentMap = make(map[string]ordermgt_pb.CombinedShipment)
+	var combinedShipmentMap = make(map[string]*ordermgt_pb.CombinedShipment)
 	for {
 		orderId, err := stream.Recv()
 		log.Println("Reading Proc order ... ", orderId)
@@ -102,7 +104,7 @@ func (s *orderMgtServer) ProcessOrders(stream ordermgt_pb.OrderManagement_Proces
 			log.Println("EOF ", orderId)
 
 			for _, comb := range combinedShipmentMap {
-				stream.Send(&comb)
+				stream.Send(comb)
 			}
 			return nil
 		}
@@ -111,17 +113,18 @@ func (s *orderMgtServer) ProcessOrders(stream ordermgt_pb.OrderManagement_Proces
 			return err
 		}
 
-		destination := orderMap[orderId.GetValue()].Destination
+		ord, exists := s.store.Get(orderId.GetValue())
+		if !exists {
+			ord = &ordermgt_pb.Order{}
+		}
+		destination := ord.Destination
 		shipment, found := combinedShipmentMap[destination]
 
 		if found {
-			ord := orderMap[orderId.GetValue()]
-			shipment.OrdersList = append(shipment.OrdersList, &ord)
-			combinedShipmentMap[destination] = shipment
+			shipment.OrdersList = append(shipment.OrdersList, ord)
 		} else {
-			comShip := ordermgt_pb.CombinedShipment{Id: "cmb - " + (orderMap[orderId.GetValue()].Destination), Status: "Processed!",}
-			ord := orderMap[orderId.GetValue()]
-			comShip.OrdersList = append(shipment.OrdersList, &ord)
+			comShip := &ordermgt_pb.CombinedShipment{Id: "cmb - " + destination, Status: "Processed!"}
+			comShip.OrdersList = append(comShip.OrdersList, ord)
 			combinedShipmentMap[destination] = comShip
 			log.Print(len(comShip.OrdersList), comShip.GetId())
 		}
@@ -129,10 +132,10 @@ func (s *orderMgtServer) ProcessOrders(stream ordermgt_pb.OrderManagement_Proces
 		if batchMarker == orderBatchSize {
 			for _, comb := range combinedShipmentMap {
 				log.Print("Shipping : ", comb.Id, " -> ", len(comb.OrdersList))
-				stream.Send(&comb)
+				stream.Send(comb)
 			}
 			batchMarker = 0
-			combinedShipmentMap = make(map[string]ordermgt_pb.CombinedShipment)
+			combinedShipmentMap = make(map[string]*ordermgt_pb.CombinedShipment)
 		} else {
 			batchMarker++
 		}
@@ -140,7 +143,8 @@ func (s *orderMgtServer) ProcessOrders(stream ordermgt_pb.OrderManagement_Proces
 }
 
 func main() {
-	initSampleData()
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
 	lis, err := net.Listen("tcp", port)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
@@ -151,7 +155,7 @@ func main() {
 
 	// Register Order Management service on gRPC orderMgtServer
 	// 在gRPC服务器端注册OrderManagement服务。
-	ordermgt_pb.RegisterOrderManagementServer(grpcServer, &orderMgtServer{})
+	ordermgt_pb.RegisterOrderManagementServer(grpcServer, &orderMgtServer{store: store})
 
 	// Register Greeter Service on gRPC orderMgtServer
 	// 在同一个gRPC服务器端注册Hello服务。
@@ -164,10 +168,10 @@ func main() {
 	}
 }
 
-func initSampleData() {
-	orderMap["102"] = ordermgt_pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA", Price: 1800.00}
-	orderMap["103"] = ordermgt_pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 400.00}
-	orderMap["104"] = ordermgt_pb.Order{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}, Destination: "Mountain View, CA", Price: 400.00}
-	orderMap["105"] = ordermgt_pb.Order{Id: "105", Items: []string{"Amazon Echo"}, Destination: "San Jose, CA", Price: 30.00}
-	orderMap["106"] = ordermgt_pb.Order{Id: "106", Items: []string{"Amazon Echo", "Apple iPhone XS"}, Destination: "Mountain View, CA", Price: 30.00}
+func initSampleData(store OrderStore) {
+	store.Put(&ordermgt_pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA", Price: 1800.00})
+	store.Put(&ordermgt_pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 400.00})
+	store.Put(&ordermgt_pb.Order{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}, Destination: "Mountain View, CA", Price: 400.00})
+	store.Put(&ordermgt_pb.Order{Id: "105", Items: []string{"Amazon Echo"}, Destination: "San Jose, CA", Price: 30.00})
+	store.Put(&ordermgt_pb.Order{Id: "106", Items: []string{"Amazon Echo", "Apple iPhone XS"}, Destination: "Mountain View, CA", Price: 30.00})
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	pb "multiplexing/order-service/order-service-gen"
+)
+
+// orderIDs 按Scan的顺序返回所有订单的ID。
+func orderIDs(store OrderStore) string {
+	var ids []string
+	store.Scan(func(order *pb.Order) bool {
+		ids = append(ids, order.Id)
+		return true
+	})
+	return fmt.Sprint(ids)
+}
+
+func TestMemoryOrderStore_Txn(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	order := &pb.Order{Id: "102", Items: []string{"Google Pixel 3A"}, Price: 1800}
+	store.Put(order)
+	// 存储保存的是副本，调用方之后的修改不影响存储中的订单。
+	order.Items[0] = "Mac Book Pro"
+	if got, _ := store.Get("102"); got.Items[0] != "Google Pixel 3A" {
+		t.Fatalf("stored order changed with the caller's copy: %v", got)
+	}
+	store.Put(&pb.Order{Id: "101"})
+
+	// 事务只能访问声明过的订单。
+	err := store.Txn([]string{"101"}, func(tx OrderTxn) error {
+		if _, ok := tx.Get("102"); ok {
+			t.Errorf("undeclared order is visible in the transaction")
+		}
+		tx.Delete("101")
+		return tx.Put(&pb.Order{Id: "103"})
+	})
+	if err != errKeyNotInTxn {
+		t.Fatalf("Txn writing an undeclared order returned %v, want errKeyNotInTxn", err)
+	}
+	// 返回错误的事务的写入全部被丢弃。
+	store.Txn([]string{"101", "102"}, func(tx OrderTxn) error {
+		tx.Delete("101")
+		return errors.New("abort")
+	})
+	if got := orderIDs(store); got != "[101 102]" {
+		t.Fatalf("orders after aborted transactions = %s", got)
+	}
+	store.Txn([]string{"101", "102", "103"}, func(tx OrderTxn) error {
+		tx.Delete("101")
+		tx.Put(&pb.Order{Id: "103", Price: 30})
+		// 事务中读到自己的写入。
+		if _, ok := tx.Get("101"); ok {
+			t.Errorf("deleted order is still visible in the transaction")
+		}
+		if got, ok := tx.Get("103"); !ok || got.Price != 30 {
+			t.Errorf("Get(103) in the transaction = %v, %v", got, ok)
+		}
+		return nil
+	})
+	if got := orderIDs(store); got != "[102 103]" {
+		t.Fatalf("orders after commit = %s", got)
+	}
+}
+
+// 并发事务在订单之间转移金额，加锁顺序不同的事务之间不会死锁，总金额保持不变。使用-race运行。
+func TestMemoryOrderStore_ConcurrentTxn(t *testing.T) {
+	const orders, workers, transfers = 8, 16, 200
+	// 分片少于订单，多个订单落在同一个分片中。
+	store := newMemoryOrderStore(3)
+	for i := 0; i < orders; i++ {
+		store.Put(&pb.Order{Id: fmt.Sprintf("%d", 100+i), Price: 100})
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < transfers; i++ {
+				from, to := fmt.Sprintf("%d", 100+(w+i)%orders), fmt.Sprintf("%d", 100+(w+2*i+1)%orders)
+				if from == to {
+					continue
+				}
+				err := store.Txn([]string{from, to}, func(tx OrderTxn) error {
+					a, _ := tx.Get(from)
+					b, _ := tx.Get(to)
+					a.Price--
+					b.Price++
+					tx.Put(a)
+					return tx.Put(b)
+				})
+				if err != nil {
+					t.Errorf("transfer from %s to %s failed: %v", from, to, err)
+				}
+			}
+		}(w)
+		// 读取与事务并发进行。
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < transfers; i++ {
+				store.Get("100")
+				store.Scan(func(order *pb.Order) bool { return true })
+			}
+		}()
+	}
+	wg.Wait()
+
+	var total float32
+	store.Scan(func(order *pb.Order) bool {
+		total += order.Price
+		return true
+	})
+	if total != orders*100 {
+		t.Fatalf("total price after concurrent transfers = %v, want %d", total, orders*100)
+	}
+}
@@ -7,9 +7,12 @@
 package ecommerce
 
 import (
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
 	wrappers "github.com/golang/protobuf/ptypes/wrappers"
+	status "google.golang.org/genproto/googleapis/rpc/status"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	fieldmaskpb "google.golang.org/protobuf/types/known/fieldmaskpb"
 	reflect "reflect"
 	sync "sync"
 )
@@ -21,6 +24,591 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// 订单的生命周期状态。
+// 合法的迁移为：PENDING -> CONFIRMED -> PACKED -> SHIPPED -> DELIVERED，
+// 在发货(SHIPPED)之前的任何状态都可以迁移到CANCELLED。
+type OrderStatus int32
+
+const (
+	OrderStatus_PENDING   OrderStatus = 0
+	OrderStatus_CONFIRMED OrderStatus = 1
+	OrderStatus_PACKED    OrderStatus = 2
+	OrderStatus_SHIPPED   OrderStatus = 3
+	OrderStatus_DELIVERED OrderStatus = 4
+	OrderStatus_CANCELLED OrderStatus = 5
+)
+
+// Enum value maps for OrderStatus.
+var (
+	OrderStatus_name = map[int32]string{
+		0: "PENDING",
+		1: "CONFIRMED",
+		2: "PACKED",
+		3: "SHIPPED",
+		4: "DELIVERED",
+		5: "CANCELLED",
+	}
+	OrderStatus_value = map[string]int32{
+		"PENDING":   0,
+		"CONFIRMED": 1,
+		"PACKED":    2,
+		"SHIPPED":   3,
+		"DELIVERED": 4,
+		"CANCELLED": 5,
+	}
+)
+
+func (x OrderStatus) Enum() *OrderStatus {
+	p := new(OrderStatus)
+	*p = x
+	return p
+}
+
+func (x OrderStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (OrderStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_order_management_proto_enumTypes[0].Descriptor()
+}
+
+func (OrderStatus) Type() protoreflect.EnumType {
+	return &file_order_management_proto_enumTypes[0]
+}
+
+func (x OrderStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use OrderStatus.Descriptor instead.
+func (OrderStatus) EnumDescriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{0}
+}
+
+// 订单的库存预留状态。
+type StockState int32
+
+const (
+	// 订单没有预留库存，例如服务器端没有配置ProductInfo，或者订单通过updateOrders创建。
+	StockState_STOCK_NONE StockState = 0
+	// 库存已经预留，等待订单发货或取消。
+	StockState_STOCK_RESERVED StockState = 1
+	// 订单已经发货，预留已经确认。
+	StockState_STOCK_COMMITTED StockState = 2
+	// 订单已经取消，预留的库存已经释放。
+	StockState_STOCK_RELEASED StockState = 3
+)
+
+// Enum value maps for StockState.
+var (
+	StockState_name = map[int32]string{
+		0: "STOCK_NONE",
+		1: "STOCK_RESERVED",
+		2: "STOCK_COMMITTED",
+		3: "STOCK_RELEASED",
+	}
+	StockState_value = map[string]int32{
+		"STOCK_NONE":      0,
+		"STOCK_RESERVED":  1,
+		"STOCK_COMMITTED": 2,
+		"STOCK_RELEASED":  3,
+	}
+)
+
+func (x StockState) Enum() *StockState {
+	p := new(StockState)
+	*p = x
+	return p
+}
+
+func (x StockState) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (StockState) Descriptor() protoreflect.EnumDescriptor {
+	return file_order_management_proto_enumTypes[1].Descriptor()
+}
+
+func (StockState) Type() protoreflect.EnumType {
+	return &file_order_management_proto_enumTypes[1]
+}
+
+func (x StockState) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use StockState.Descriptor instead.
+func (StockState) EnumDescriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{1}
+}
+
+// 订单的优先级。按优先级合并时，加急订单不会与普通订单合并到同一个发货组合。
+type OrderPriority int32
+
+const (
+	OrderPriority_STANDARD OrderPriority = 0
+	OrderPriority_EXPRESS  OrderPriority = 1
+)
+
+// Enum value maps for OrderPriority.
+var (
+	OrderPriority_name = map[int32]string{
+		0: "STANDARD",
+		1: "EXPRESS",
+	}
+	OrderPriority_value = map[string]int32{
+		"STANDARD": 0,
+		"EXPRESS":  1,
+	}
+)
+
+func (x OrderPriority) Enum() *OrderPriority {
+	p := new(OrderPriority)
+	*p = x
+	return p
+}
+
+func (x OrderPriority) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (OrderPriority) Descriptor() protoreflect.EnumDescriptor {
+	return file_order_management_proto_enumTypes[2].Descriptor()
+}
+
+func (OrderPriority) Type() protoreflect.EnumType {
+	return &file_order_management_proto_enumTypes[2]
+}
+
+func (x OrderPriority) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use OrderPriority.Descriptor instead.
+func (OrderPriority) EnumDescriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{2}
+}
+
+// 发货组合的状态。
+type ShipmentStatus int32
+
+const (
+	// 发货组合已创建，还在等待同一批次的订单。
+	ShipmentStatus_SHIPMENT_OPEN ShipmentStatus = 0
+	// 发货组合已经发出，其中的订单都进入了SHIPPED状态。
+	ShipmentStatus_SHIPMENT_SHIPPED ShipmentStatus = 1
+)
+
+// Enum value maps for ShipmentStatus.
+var (
+	ShipmentStatus_name = map[int32]string{
+		0: "SHIPMENT_OPEN",
+		1: "SHIPMENT_SHIPPED",
+	}
+	ShipmentStatus_value = map[string]int32{
+		"SHIPMENT_OPEN":    0,
+		"SHIPMENT_SHIPPED": 1,
+	}
+)
+
+func (x ShipmentStatus) Enum() *ShipmentStatus {
+	p := new(ShipmentStatus)
+	*p = x
+	return p
+}
+
+func (x ShipmentStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ShipmentStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_order_management_proto_enumTypes[3].Descriptor()
+}
+
+func (ShipmentStatus) Type() protoreflect.EnumType {
+	return &file_order_management_proto_enumTypes[3]
+}
+
+func (x ShipmentStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ShipmentStatus.Descriptor instead.
+func (ShipmentStatus) EnumDescriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{3}
+}
+
+// searchOrders的排序方式。无论哪种方式，都以订单ID作为最后的排序依据，保证分页结果稳定。
+type SortOrder int32
+
+const (
+	SortOrder_ID_ASC SortOrder = 0
+	// 按精确价格排序，不同货币的订单按货币代码分组；没有exactPrice的旧订单按USD换算price。
+	SortOrder_PRICE_ASC        SortOrder = 1
+	SortOrder_PRICE_DESC       SortOrder = 2
+	SortOrder_CREATE_TIME_ASC  SortOrder = 3
+	SortOrder_CREATE_TIME_DESC SortOrder = 4
+)
+
+// Enum value maps for SortOrder.
+var (
+	SortOrder_name = map[int32]string{
+		0: "ID_ASC",
+		1: "PRICE_ASC",
+		2: "PRICE_DESC",
+		3: "CREATE_TIME_ASC",
+		4: "CREATE_TIME_DESC",
+	}
+	SortOrder_value = map[string]int32{
+		"ID_ASC":           0,
+		"PRICE_ASC":        1,
+		"PRICE_DESC":       2,
+		"CREATE_TIME_ASC":  3,
+		"CREATE_TIME_DESC": 4,
+	}
+)
+
+func (x SortOrder) Enum() *SortOrder {
+	p := new(SortOrder)
+	*p = x
+	return p
+}
+
+func (x SortOrder) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SortOrder) Descriptor() protoreflect.EnumDescriptor {
+	return file_order_management_proto_enumTypes[4].Descriptor()
+}
+
+func (SortOrder) Type() protoreflect.EnumType {
+	return &file_order_management_proto_enumTypes[4]
+}
+
+func (x SortOrder) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SortOrder.Descriptor instead.
+func (SortOrder) EnumDescriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{4}
+}
+
+type OrderEventType int32
+
+const (
+	OrderEventType_ADDED   OrderEventType = 0
+	OrderEventType_UPDATED OrderEventType = 1
+	OrderEventType_DELETED OrderEventType = 2
+)
+
+// Enum value maps for OrderEventType.
+var (
+	OrderEventType_name = map[int32]string{
+		0: "ADDED",
+		1: "UPDATED",
+		2: "DELETED",
+	}
+	OrderEventType_value = map[string]int32{
+		"ADDED":   0,
+		"UPDATED": 1,
+		"DELETED": 2,
+	}
+)
+
+func (x OrderEventType) Enum() *OrderEventType {
+	p := new(OrderEventType)
+	*p = x
+	return p
+}
+
+func (x OrderEventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (OrderEventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_order_management_proto_enumTypes[5].Descriptor()
+}
+
+func (OrderEventType) Type() protoreflect.EnumType {
+	return &file_order_management_proto_enumTypes[5]
+}
+
+func (x OrderEventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use OrderEventType.Descriptor instead.
+func (OrderEventType) EnumDescriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{5}
+}
+
+// patchOrder修改items字段的方式。
+type ItemsPatchMode int32
+
+const (
+	// 用请求中的items替换订单的全部条目。
+	ItemsPatchMode_REPLACE ItemsPatchMode = 0
+	// 把请求中的items追加到订单现有的条目之后。
+	ItemsPatchMode_APPEND ItemsPatchMode = 1
+)
+
+// Enum value maps for ItemsPatchMode.
+var (
+	ItemsPatchMode_name = map[int32]string{
+		0: "REPLACE",
+		1: "APPEND",
+	}
+	ItemsPatchMode_value = map[string]int32{
+		"REPLACE": 0,
+		"APPEND":  1,
+	}
+)
+
+func (x ItemsPatchMode) Enum() *ItemsPatchMode {
+	p := new(ItemsPatchMode)
+	*p = x
+	return p
+}
+
+func (x ItemsPatchMode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ItemsPatchMode) Descriptor() protoreflect.EnumDescriptor {
+	return file_order_management_proto_enumTypes[6].Descriptor()
+}
+
+func (ItemsPatchMode) Type() protoreflect.EnumType {
+	return &file_order_management_proto_enumTypes[6]
+}
+
+func (x ItemsPatchMode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ItemsPatchMode.Descriptor instead.
+func (ItemsPatchMode) EnumDescriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{6}
+}
+
+// 导入导出订单时使用的文件格式。
+type OrderFileFormat int32
+
+const (
+	// 每行一个JSON编码的订单，字段名与Order消息相同。
+	OrderFileFormat_JSONL OrderFileFormat = 0
+	// 第一行为列名的CSV文件，items列是JSON编码的字符串数组。
+	OrderFileFormat_CSV OrderFileFormat = 1
+)
+
+// Enum value maps for OrderFileFormat.
+var (
+	OrderFileFormat_name = map[int32]string{
+		0: "JSONL",
+		1: "CSV",
+	}
+	OrderFileFormat_value = map[string]int32{
+		"JSONL": 0,
+		"CSV":   1,
+	}
+)
+
+func (x OrderFileFormat) Enum() *OrderFileFormat {
+	p := new(OrderFileFormat)
+	*p = x
+	return p
+}
+
+func (x OrderFileFormat) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (OrderFileFormat) Descriptor() protoreflect.EnumDescriptor {
+	return file_order_management_proto_enumTypes[7].Descriptor()
+}
+
+func (OrderFileFormat) Type() protoreflect.EnumType {
+	return &file_order_management_proto_enumTypes[7]
+}
+
+func (x OrderFileFormat) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use OrderFileFormat.Descriptor instead.
+func (OrderFileFormat) EnumDescriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{7}
+}
+
+// 导入的订单与已有订单的ID相同时的处理方式。
+type ImportConflictPolicy int32
+
+const (
+	// 存在任何冲突或无效的记录时不写入任何订单。
+	ImportConflictPolicy_CONFLICT_FAIL ImportConflictPolicy = 0
+	// 跳过冲突的记录，保留已有的订单。
+	ImportConflictPolicy_CONFLICT_SKIP ImportConflictPolicy = 1
+	// 用导入的记录覆盖已有的订单。
+	ImportConflictPolicy_CONFLICT_OVERWRITE ImportConflictPolicy = 2
+)
+
+// Enum value maps for ImportConflictPolicy.
+var (
+	ImportConflictPolicy_name = map[int32]string{
+		0: "CONFLICT_FAIL",
+		1: "CONFLICT_SKIP",
+		2: "CONFLICT_OVERWRITE",
+	}
+	ImportConflictPolicy_value = map[string]int32{
+		"CONFLICT_FAIL":      0,
+		"CONFLICT_SKIP":      1,
+		"CONFLICT_OVERWRITE": 2,
+	}
+)
+
+func (x ImportConflictPolicy) Enum() *ImportConflictPolicy {
+	p := new(ImportConflictPolicy)
+	*p = x
+	return p
+}
+
+func (x ImportConflictPolicy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ImportConflictPolicy) Descriptor() protoreflect.EnumDescriptor {
+	return file_order_management_proto_enumTypes[8].Descriptor()
+}
+
+func (ImportConflictPolicy) Type() protoreflect.EnumType {
+	return &file_order_management_proto_enumTypes[8]
+}
+
+func (x ImportConflictPolicy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ImportConflictPolicy.Descriptor instead.
+func (ImportConflictPolicy) EnumDescriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{8}
+}
+
+// 审计记录中调用方身份的来源。
+type AuditActorSource int32
+
+const (
+	// 服务器端自己发起的变更，例如全局合并的发货组合到期发出。
+	AuditActorSource_ACTOR_SYSTEM AuditActorSource = 0
+	// 经过校验的TLS客户端证书。
+	AuditActorSource_ACTOR_TLS AuditActorSource = 1
+	// 客户端在元数据caller-id中声明的身份，没有经过校验。
+	AuditActorSource_ACTOR_METADATA AuditActorSource = 2
+	// 无法确定调用方的身份。
+	AuditActorSource_ACTOR_ANONYMOUS AuditActorSource = 3
+)
+
+// Enum value maps for AuditActorSource.
+var (
+	AuditActorSource_name = map[int32]string{
+		0: "ACTOR_SYSTEM",
+		1: "ACTOR_TLS",
+		2: "ACTOR_METADATA",
+		3: "ACTOR_ANONYMOUS",
+	}
+	AuditActorSource_value = map[string]int32{
+		"ACTOR_SYSTEM":    0,
+		"ACTOR_TLS":       1,
+		"ACTOR_METADATA":  2,
+		"ACTOR_ANONYMOUS": 3,
+	}
+)
+
+func (x AuditActorSource) Enum() *AuditActorSource {
+	p := new(AuditActorSource)
+	*p = x
+	return p
+}
+
+func (x AuditActorSource) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AuditActorSource) Descriptor() protoreflect.EnumDescriptor {
+	return file_order_management_proto_enumTypes[9].Descriptor()
+}
+
+func (AuditActorSource) Type() protoreflect.EnumType {
+	return &file_order_management_proto_enumTypes[9]
+}
+
+func (x AuditActorSource) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AuditActorSource.Descriptor instead.
+func (AuditActorSource) EnumDescriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{9}
+}
+
+// 领域事件的类型。
+type DomainEventType int32
+
+const (
+	// 新订单被写入。
+	DomainEventType_ORDER_PLACED DomainEventType = 0
+	// 已有的订单被修改，包括状态的迁移。
+	DomainEventType_ORDER_UPDATED DomainEventType = 1
+	// 订单被删除。
+	DomainEventType_ORDER_DELETED DomainEventType = 2
+	// 发货组合已经确定并发出。
+	DomainEventType_SHIPMENT_CREATED DomainEventType = 3
+)
+
+// Enum value maps for DomainEventType.
+var (
+	DomainEventType_name = map[int32]string{
+		0: "ORDER_PLACED",
+		1: "ORDER_UPDATED",
+		2: "ORDER_DELETED",
+		3: "SHIPMENT_CREATED",
+	}
+	DomainEventType_value = map[string]int32{
+		"ORDER_PLACED":     0,
+		"ORDER_UPDATED":    1,
+		"ORDER_DELETED":    2,
+		"SHIPMENT_CREATED": 3,
+	}
+)
+
+func (x DomainEventType) Enum() *DomainEventType {
+	p := new(DomainEventType)
+	*p = x
+	return p
+}
+
+func (x DomainEventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (DomainEventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_order_management_proto_enumTypes[10].Descriptor()
+}
+
+func (DomainEventType) Type() protoreflect.EnumType {
+	return &file_order_management_proto_enumTypes[10]
+}
+
+func (x DomainEventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use DomainEventType.Descriptor instead.
+func (DomainEventType) EnumDescriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{10}
+}
+
 // 定义order类型。
 type Order struct {
 	state         protoimpl.MessageState
@@ -31,8 +619,37 @@ type Order struct {
 	// 使用repeated表明这个字段在消息中可以重复出现任意次，包括0次。在这里，一条订单消息可以有任意数量的条目。
 	Items       []string `protobuf:"bytes,2,rep,name=items,proto3" json:"items,omitempty"`
 	Description string   `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
-	Price       float32  `protobuf:"fixed32,4,opt,name=price,proto3" json:"price,omitempty"`
-	Destination string   `protobuf:"bytes,5,opt,name=destination,proto3" json:"destination,omitempty"`
+	// 已废弃：float会产生舍入误差，请使用exactPrice。
+	Price       float32 `protobuf:"fixed32,4,opt,name=price,proto3" json:"price,omitempty"`
+	Destination string  `protobuf:"bytes,5,opt,name=destination,proto3" json:"destination,omitempty"`
+	// 订单当前的生命周期状态，只能通过transitionOrder和processOrders修改。
+	Status OrderStatus `protobuf:"varint,6,opt,name=status,proto3,enum=ecommerce.OrderStatus" json:"status,omitempty"`
+	// 订单的创建时间，由服务器端在addOrder时设置。
+	CreateTime *timestamp.Timestamp `protobuf:"bytes,7,opt,name=createTime,proto3" json:"createTime,omitempty"`
+	// 订单的版本，由服务器端在每次写入时加1。
+	// 在updateOrders中设置为非0值时，表示只有当前版本与之相同时才写入，否则返回Aborted。
+	Version int64 `protobuf:"varint,8,opt,name=version,proto3" json:"version,omitempty"`
+	// 订单被取消的原因，只在status为CANCELLED时设置。
+	CancelReason string `protobuf:"bytes,9,opt,name=cancelReason,proto3" json:"cancelReason,omitempty"`
+	// 订单的精确价格。旧客户端只设置price时，服务器端按USD换算出exactPrice；
+	// 设置了exactPrice时，服务器端用它覆盖price，供仍在读取price的旧客户端使用。
+	ExactPrice *Money `protobuf:"bytes,10,opt,name=exactPrice,proto3" json:"exactPrice,omitempty"`
+	// 订单的重量，单位为克，用于按重量限制发货组合。
+	WeightGrams int64         `protobuf:"varint,11,opt,name=weightGrams,proto3" json:"weightGrams,omitempty"`
+	Priority    OrderPriority `protobuf:"varint,12,opt,name=priority,proto3,enum=ecommerce.OrderPriority" json:"priority,omitempty"`
+	// items中每个条目对应的商品ID，由服务器端通过ProductInfo解析，客户端设置的值会被忽略。
+	// 服务器端没有配置ProductInfo时为空。
+	ProductIds []string `protobuf:"bytes,13,rep,name=productIds,proto3" json:"productIds,omitempty"`
+	// 在ProductInfo中为订单条目预留库存时使用的预留ID，由服务器端在addOrder时设置。
+	ReservationId string `protobuf:"bytes,14,opt,name=reservationId,proto3" json:"reservationId,omitempty"`
+	// 库存预留的状态，由服务器端维护：订单发货后确认预留，订单取消或删除后释放预留。
+	// 预留了库存的订单不能通过updateOrders或patchOrder修改items，返回FAILED_PRECONDITION。
+	Stock StockState `protobuf:"varint,15,opt,name=stock,proto3,enum=ecommerce.StockState" json:"stock,omitempty"`
+	// 订单所属的租户，由服务器端按照调用方的租户设置，默认租户为空。
+	TenantId string `protobuf:"bytes,16,opt,name=tenantId,proto3" json:"tenantId,omitempty"`
+	// 只在searchOrders返回的订单中设置。流在中途断开时，把最后收到的订单的searchCursor放在
+	// SearchOrdersRequest.resumeCursor中重新调用，服务器端从这个订单之后的结果继续发送。
+	SearchCursor string `protobuf:"bytes,17,opt,name=searchCursor,proto3" json:"searchCursor,omitempty"`
 }
 
 func (x *Order) Reset() {
@@ -102,33 +719,121 @@ func (x *Order) GetDestination() string {
 	return ""
 }
 
-// CombinedShipment 消息的结构。
-type CombinedShipment struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Id         string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Status     string   `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
-	OrdersList []*Order `protobuf:"bytes,3,rep,name=ordersList,proto3" json:"ordersList,omitempty"`
+func (x *Order) GetStatus() OrderStatus {
+	if x != nil {
+		return x.Status
+	}
+	return OrderStatus_PENDING
 }
 
-func (x *CombinedShipment) Reset() {
-	*x = CombinedShipment{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_order_management_proto_msgTypes[1]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
+func (x *Order) GetCreateTime() *timestamp.Timestamp {
+	if x != nil {
+		return x.CreateTime
 	}
+	return nil
 }
 
-func (x *CombinedShipment) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *Order) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
 }
 
-func (*CombinedShipment) ProtoMessage() {}
+func (x *Order) GetCancelReason() string {
+	if x != nil {
+		return x.CancelReason
+	}
+	return ""
+}
 
-func (x *CombinedShipment) ProtoReflect() protoreflect.Message {
+func (x *Order) GetExactPrice() *Money {
+	if x != nil {
+		return x.ExactPrice
+	}
+	return nil
+}
+
+func (x *Order) GetWeightGrams() int64 {
+	if x != nil {
+		return x.WeightGrams
+	}
+	return 0
+}
+
+func (x *Order) GetPriority() OrderPriority {
+	if x != nil {
+		return x.Priority
+	}
+	return OrderPriority_STANDARD
+}
+
+func (x *Order) GetProductIds() []string {
+	if x != nil {
+		return x.ProductIds
+	}
+	return nil
+}
+
+func (x *Order) GetReservationId() string {
+	if x != nil {
+		return x.ReservationId
+	}
+	return ""
+}
+
+func (x *Order) GetStock() StockState {
+	if x != nil {
+		return x.Stock
+	}
+	return StockState_STOCK_NONE
+}
+
+func (x *Order) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *Order) GetSearchCursor() string {
+	if x != nil {
+		return x.SearchCursor
+	}
+	return ""
+}
+
+// 精确的金额，避免float在二进制舍入时产生误差。
+// 金额等于units + nanos / 10^9，units和nanos的符号必须相同。
+type Money struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// ISO 4217 货币代码，例如"USD"。
+	CurrencyCode string `protobuf:"bytes,1,opt,name=currencyCode,proto3" json:"currencyCode,omitempty"`
+	// 金额的整数部分。
+	Units int64 `protobuf:"varint,2,opt,name=units,proto3" json:"units,omitempty"`
+	// 金额的小数部分，单位为10^-9，取值范围为[-999999999, 999999999]。
+	Nanos int32 `protobuf:"varint,3,opt,name=nanos,proto3" json:"nanos,omitempty"`
+}
+
+func (x *Money) Reset() {
+	*x = Money{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Money) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Money) ProtoMessage() {}
+
+func (x *Money) ProtoReflect() protoreflect.Message {
 	mi := &file_order_management_proto_msgTypes[1]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -140,32 +845,1749 @@ func (x *CombinedShipment) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CombinedShipment.ProtoReflect.Descriptor instead.
-func (*CombinedShipment) Descriptor() ([]byte, []int) {
-	return file_order_management_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use Money.ProtoReflect.Descriptor instead.
+func (*Money) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Money) GetCurrencyCode() string {
+	if x != nil {
+		return x.CurrencyCode
+	}
+	return ""
+}
+
+func (x *Money) GetUnits() int64 {
+	if x != nil {
+		return x.Units
+	}
+	return 0
+}
+
+func (x *Money) GetNanos() int32 {
+	if x != nil {
+		return x.Nanos
+	}
+	return 0
+}
+
+// CombinedShipment 消息的结构。
+type CombinedShipment struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 发货组合的唯一ID，由服务器端在创建时生成。
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// 已废弃：请使用shipmentStatus。
+	Status         string               `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	OrdersList     []*Order             `protobuf:"bytes,3,rep,name=ordersList,proto3" json:"ordersList,omitempty"`
+	Destination    string               `protobuf:"bytes,4,opt,name=destination,proto3" json:"destination,omitempty"`
+	ShipmentStatus ShipmentStatus       `protobuf:"varint,5,opt,name=shipmentStatus,proto3,enum=ecommerce.ShipmentStatus" json:"shipmentStatus,omitempty"`
+	CreateTime     *timestamp.Timestamp `protobuf:"bytes,6,opt,name=createTime,proto3" json:"createTime,omitempty"`
+	UpdateTime     *timestamp.Timestamp `protobuf:"bytes,7,opt,name=updateTime,proto3" json:"updateTime,omitempty"`
+	// 发货组合的版本，由服务器端在每次变化时加1。
+	Version int64 `protobuf:"varint,8,opt,name=version,proto3" json:"version,omitempty"`
+	// 发货组合所属的租户，发货组合中只有同一个租户的订单。
+	TenantId string `protobuf:"bytes,9,opt,name=tenantId,proto3" json:"tenantId,omitempty"`
+}
+
+func (x *CombinedShipment) Reset() {
+	*x = CombinedShipment{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CombinedShipment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CombinedShipment) ProtoMessage() {}
+
+func (x *CombinedShipment) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CombinedShipment.ProtoReflect.Descriptor instead.
+func (*CombinedShipment) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CombinedShipment) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *CombinedShipment) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *CombinedShipment) GetOrdersList() []*Order {
+	if x != nil {
+		return x.OrdersList
+	}
+	return nil
+}
+
+func (x *CombinedShipment) GetDestination() string {
+	if x != nil {
+		return x.Destination
+	}
+	return ""
+}
+
+func (x *CombinedShipment) GetShipmentStatus() ShipmentStatus {
+	if x != nil {
+		return x.ShipmentStatus
+	}
+	return ShipmentStatus_SHIPMENT_OPEN
+}
+
+func (x *CombinedShipment) GetCreateTime() *timestamp.Timestamp {
+	if x != nil {
+		return x.CreateTime
+	}
+	return nil
+}
+
+func (x *CombinedShipment) GetUpdateTime() *timestamp.Timestamp {
+	if x != nil {
+		return x.UpdateTime
+	}
+	return nil
+}
+
+func (x *CombinedShipment) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *CombinedShipment) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+// processOrders中单个订单ID的处理结果。
+type OrderResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	OrderId string `protobuf:"bytes,1,opt,name=orderId,proto3" json:"orderId,omitempty"`
+	// Types that are assignable to Result:
+	//	*OrderResult_ShipmentId
+	//	*OrderResult_Error
+	Result isOrderResult_Result `protobuf_oneof:"result"`
+}
+
+func (x *OrderResult) Reset() {
+	*x = OrderResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OrderResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrderResult) ProtoMessage() {}
+
+func (x *OrderResult) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrderResult.ProtoReflect.Descriptor instead.
+func (*OrderResult) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *OrderResult) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+func (m *OrderResult) GetResult() isOrderResult_Result {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func (x *OrderResult) GetShipmentId() string {
+	if x, ok := x.GetResult().(*OrderResult_ShipmentId); ok {
+		return x.ShipmentId
+	}
+	return ""
+}
+
+func (x *OrderResult) GetError() *status.Status {
+	if x, ok := x.GetResult().(*OrderResult_Error); ok {
+		return x.Error
+	}
+	return nil
+}
+
+type isOrderResult_Result interface {
+	isOrderResult_Result()
+}
+
+type OrderResult_ShipmentId struct {
+	// 订单被分配到的发货组合的ID。
+	ShipmentId string `protobuf:"bytes,2,opt,name=shipmentId,proto3,oneof"`
+}
+
+type OrderResult_Error struct {
+	// 订单无法处理的原因，例如订单不存在(NotFound)或者订单ID、目的地无效(InvalidArgument)，
+	// details中包含ResourceInfo或BadRequest等错误详情。
+	Error *status.Status `protobuf:"bytes,3,opt,name=error,proto3,oneof"`
+}
+
+func (*OrderResult_ShipmentId) isOrderResult_Result() {}
+
+func (*OrderResult_Error) isOrderResult_Result() {}
+
+// processOrders流中的消息，是单个订单的处理结果或者一个要发送的发货组合。
+type ProcessOrdersResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Response:
+	//	*ProcessOrdersResponse_Result
+	//	*ProcessOrdersResponse_Shipment
+	Response isProcessOrdersResponse_Response `protobuf_oneof:"response"`
+}
+
+func (x *ProcessOrdersResponse) Reset() {
+	*x = ProcessOrdersResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ProcessOrdersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProcessOrdersResponse) ProtoMessage() {}
+
+func (x *ProcessOrdersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProcessOrdersResponse.ProtoReflect.Descriptor instead.
+func (*ProcessOrdersResponse) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{4}
+}
+
+func (m *ProcessOrdersResponse) GetResponse() isProcessOrdersResponse_Response {
+	if m != nil {
+		return m.Response
+	}
+	return nil
+}
+
+func (x *ProcessOrdersResponse) GetResult() *OrderResult {
+	if x, ok := x.GetResponse().(*ProcessOrdersResponse_Result); ok {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *ProcessOrdersResponse) GetShipment() *CombinedShipment {
+	if x, ok := x.GetResponse().(*ProcessOrdersResponse_Shipment); ok {
+		return x.Shipment
+	}
+	return nil
+}
+
+type isProcessOrdersResponse_Response interface {
+	isProcessOrdersResponse_Response()
+}
+
+type ProcessOrdersResponse_Result struct {
+	Result *OrderResult `protobuf:"bytes,1,opt,name=result,proto3,oneof"`
+}
+
+type ProcessOrdersResponse_Shipment struct {
+	Shipment *CombinedShipment `protobuf:"bytes,2,opt,name=shipment,proto3,oneof"`
+}
+
+func (*ProcessOrdersResponse_Result) isProcessOrdersResponse_Response() {}
+
+func (*ProcessOrdersResponse_Shipment) isProcessOrdersResponse_Response() {}
+
+// searchOrders的查询条件，所有设置了的过滤条件必须同时满足。
+type SearchOrdersRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 按订单条目和描述进行文本查询，忽略大小写。查询中的每个词都必须是订单中某个词的子串，
+	// 例如"mac bo"可以匹配"Mac Book Pro"，"phone"可以匹配"iPhone"。字段编号与旧版本使用的google.protobuf.StringValue相同，
+	// 因此旧客户端的请求会被解析为按条目搜索。
+	Item string `protobuf:"bytes,1,opt,name=item,proto3" json:"item,omitempty"`
+	// 目的地，忽略大小写的完全匹配。
+	Destination string `protobuf:"bytes,2,opt,name=destination,proto3" json:"destination,omitempty"`
+	// 价格范围（包含两端），按订单的exactPrice精确比较。只设置float价格的旧客户端的价格按USD换算，
+	// 不能与对应的minExactPrice或maxExactPrice同时设置。
+	MinPrice *wrappers.FloatValue `protobuf:"bytes,3,opt,name=minPrice,proto3" json:"minPrice,omitempty"`
+	MaxPrice *wrappers.FloatValue `protobuf:"bytes,4,opt,name=maxPrice,proto3" json:"maxPrice,omitempty"`
+	// 订单状态，为空时返回除CANCELLED以外的所有订单。只有明确指定CANCELLED时才返回已取消的订单。
+	Statuses []OrderStatus `protobuf:"varint,5,rep,packed,name=statuses,proto3,enum=ecommerce.OrderStatus" json:"statuses,omitempty"`
+	// 只返回在这个时间之后创建的订单。
+	CreatedAfter *timestamp.Timestamp `protobuf:"bytes,6,opt,name=createdAfter,proto3" json:"createdAfter,omitempty"`
+	SortOrder    SortOrder            `protobuf:"varint,7,opt,name=sortOrder,proto3,enum=ecommerce.SortOrder" json:"sortOrder,omitempty"`
+	// 每页的最大订单数，为0时返回所有结果。
+	PageSize int32 `protobuf:"varint,8,opt,name=pageSize,proto3" json:"pageSize,omitempty"`
+	// 上一页返回的next-page-token，为空时从第一页开始。
+	PageToken string `protobuf:"bytes,9,opt,name=pageToken,proto3" json:"pageToken,omitempty"`
+	// 断线前最后收到的订单的searchCursor，其他查询条件必须与原始请求相同，不能与pageToken同时设置。
+	// 服务器端在原始请求的结果快照上从下一个订单继续，到原始请求的这一页结束为止，流结束后的next-page-token
+	// 与原始请求的相同。快照过期后按游标中的排序键在当前的订单上继续。
+	ResumeCursor string `protobuf:"bytes,10,opt,name=resumeCursor,proto3" json:"resumeCursor,omitempty"`
+	// 精确的价格范围（包含两端），两端的货币必须相同，只返回exactPrice是这种货币的订单。
+	MinExactPrice *Money `protobuf:"bytes,11,opt,name=minExactPrice,proto3" json:"minExactPrice,omitempty"`
+	MaxExactPrice *Money `protobuf:"bytes,12,opt,name=maxExactPrice,proto3" json:"maxExactPrice,omitempty"`
+}
+
+func (x *SearchOrdersRequest) Reset() {
+	*x = SearchOrdersRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SearchOrdersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchOrdersRequest) ProtoMessage() {}
+
+func (x *SearchOrdersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchOrdersRequest.ProtoReflect.Descriptor instead.
+func (*SearchOrdersRequest) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SearchOrdersRequest) GetItem() string {
+	if x != nil {
+		return x.Item
+	}
+	return ""
+}
+
+func (x *SearchOrdersRequest) GetDestination() string {
+	if x != nil {
+		return x.Destination
+	}
+	return ""
+}
+
+func (x *SearchOrdersRequest) GetMinPrice() *wrappers.FloatValue {
+	if x != nil {
+		return x.MinPrice
+	}
+	return nil
+}
+
+func (x *SearchOrdersRequest) GetMaxPrice() *wrappers.FloatValue {
+	if x != nil {
+		return x.MaxPrice
+	}
+	return nil
+}
+
+func (x *SearchOrdersRequest) GetStatuses() []OrderStatus {
+	if x != nil {
+		return x.Statuses
+	}
+	return nil
+}
+
+func (x *SearchOrdersRequest) GetCreatedAfter() *timestamp.Timestamp {
+	if x != nil {
+		return x.CreatedAfter
+	}
+	return nil
+}
+
+func (x *SearchOrdersRequest) GetSortOrder() SortOrder {
+	if x != nil {
+		return x.SortOrder
+	}
+	return SortOrder_ID_ASC
+}
+
+func (x *SearchOrdersRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *SearchOrdersRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *SearchOrdersRequest) GetResumeCursor() string {
+	if x != nil {
+		return x.ResumeCursor
+	}
+	return ""
+}
+
+func (x *SearchOrdersRequest) GetMinExactPrice() *Money {
+	if x != nil {
+		return x.MinExactPrice
+	}
+	return nil
+}
+
+func (x *SearchOrdersRequest) GetMaxExactPrice() *Money {
+	if x != nil {
+		return x.MaxExactPrice
+	}
+	return nil
+}
+
+type TransitionOrderRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// 订单要迁移到的目标状态。
+	Status OrderStatus `protobuf:"varint,2,opt,name=status,proto3,enum=ecommerce.OrderStatus" json:"status,omitempty"`
+	// 期望的订单当前版本，不为0且与当前版本不同时返回Aborted。
+	ExpectedVersion int64 `protobuf:"varint,3,opt,name=expectedVersion,proto3" json:"expectedVersion,omitempty"`
+}
+
+func (x *TransitionOrderRequest) Reset() {
+	*x = TransitionOrderRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TransitionOrderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransitionOrderRequest) ProtoMessage() {}
+
+func (x *TransitionOrderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransitionOrderRequest.ProtoReflect.Descriptor instead.
+func (*TransitionOrderRequest) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *TransitionOrderRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *TransitionOrderRequest) GetStatus() OrderStatus {
+	if x != nil {
+		return x.Status
+	}
+	return OrderStatus_PENDING
+}
+
+func (x *TransitionOrderRequest) GetExpectedVersion() int64 {
+	if x != nil {
+		return x.ExpectedVersion
+	}
+	return 0
+}
+
+type WatchOrdersRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 客户端最后收到的事件的revision，服务器端从下一个revision开始发送。
+	// 为0时只发送订阅之后发生的事件。
+	ResumeRevision int64 `protobuf:"varint,1,opt,name=resumeRevision,proto3" json:"resumeRevision,omitempty"`
+	// 为true时忽略resumeRevision，从服务器端保留的最早的事件开始发送。
+	FromOldest bool `protobuf:"varint,2,opt,name=fromOldest,proto3" json:"fromOldest,omitempty"`
+}
+
+func (x *WatchOrdersRequest) Reset() {
+	*x = WatchOrdersRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WatchOrdersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchOrdersRequest) ProtoMessage() {}
+
+func (x *WatchOrdersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchOrdersRequest.ProtoReflect.Descriptor instead.
+func (*WatchOrdersRequest) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WatchOrdersRequest) GetResumeRevision() int64 {
+	if x != nil {
+		return x.ResumeRevision
+	}
+	return 0
+}
+
+func (x *WatchOrdersRequest) GetFromOldest() bool {
+	if x != nil {
+		return x.FromOldest
+	}
+	return false
+}
+
+// 订单的变更事件。revision在服务器端单调递增，每次变更加1。
+type OrderEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Revision int64          `protobuf:"varint,1,opt,name=revision,proto3" json:"revision,omitempty"`
+	Type     OrderEventType `protobuf:"varint,2,opt,name=type,proto3,enum=ecommerce.OrderEventType" json:"type,omitempty"`
+	// 变更后的订单。对于DELETED事件，是订单被删除前的状态。
+	Order *Order `protobuf:"bytes,3,opt,name=order,proto3" json:"order,omitempty"`
+}
+
+func (x *OrderEvent) Reset() {
+	*x = OrderEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OrderEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrderEvent) ProtoMessage() {}
+
+func (x *OrderEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrderEvent.ProtoReflect.Descriptor instead.
+func (*OrderEvent) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *OrderEvent) GetRevision() int64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+func (x *OrderEvent) GetType() OrderEventType {
+	if x != nil {
+		return x.Type
+	}
+	return OrderEventType_ADDED
+}
+
+func (x *OrderEvent) GetOrder() *Order {
+	if x != nil {
+		return x.Order
+	}
+	return nil
+}
+
+type CancelOrderRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// 取消原因，不能为空。
+	Reason string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	// 期望的订单当前版本，不为0且与当前版本不同时返回Aborted。
+	ExpectedVersion int64 `protobuf:"varint,3,opt,name=expectedVersion,proto3" json:"expectedVersion,omitempty"`
+}
+
+func (x *CancelOrderRequest) Reset() {
+	*x = CancelOrderRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CancelOrderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelOrderRequest) ProtoMessage() {}
+
+func (x *CancelOrderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelOrderRequest.ProtoReflect.Descriptor instead.
+func (*CancelOrderRequest) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *CancelOrderRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *CancelOrderRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *CancelOrderRequest) GetExpectedVersion() int64 {
+	if x != nil {
+		return x.ExpectedVersion
+	}
+	return 0
+}
+
+type DeleteOrderRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// 删除原因，记录在墓碑中。
+	Reason string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	// 期望的订单当前版本，不为0且与当前版本不同时返回Aborted。
+	ExpectedVersion int64 `protobuf:"varint,3,opt,name=expectedVersion,proto3" json:"expectedVersion,omitempty"`
+}
+
+func (x *DeleteOrderRequest) Reset() {
+	*x = DeleteOrderRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteOrderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteOrderRequest) ProtoMessage() {}
+
+func (x *DeleteOrderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteOrderRequest.ProtoReflect.Descriptor instead.
+func (*DeleteOrderRequest) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DeleteOrderRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DeleteOrderRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *DeleteOrderRequest) GetExpectedVersion() int64 {
+	if x != nil {
+		return x.ExpectedVersion
+	}
+	return 0
+}
+
+type PatchOrderRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 要修改的订单，id必须设置，只有updateMask中列出的字段会被使用。
+	Order *Order `protobuf:"bytes,1,opt,name=order,proto3" json:"order,omitempty"`
+	// 要修改的字段路径，例如["destination"]。不能为空。
+	UpdateMask *fieldmaskpb.FieldMask `protobuf:"bytes,2,opt,name=updateMask,proto3" json:"updateMask,omitempty"`
+	// updateMask包含items时，items的修改方式。
+	ItemsMode ItemsPatchMode `protobuf:"varint,3,opt,name=itemsMode,proto3,enum=ecommerce.ItemsPatchMode" json:"itemsMode,omitempty"`
+	// 期望的订单当前版本，不为0且与当前版本不同时返回Aborted。
+	ExpectedVersion int64 `protobuf:"varint,4,opt,name=expectedVersion,proto3" json:"expectedVersion,omitempty"`
+}
+
+func (x *PatchOrderRequest) Reset() {
+	*x = PatchOrderRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PatchOrderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PatchOrderRequest) ProtoMessage() {}
+
+func (x *PatchOrderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PatchOrderRequest.ProtoReflect.Descriptor instead.
+func (*PatchOrderRequest) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *PatchOrderRequest) GetOrder() *Order {
+	if x != nil {
+		return x.Order
+	}
+	return nil
+}
+
+func (x *PatchOrderRequest) GetUpdateMask() *fieldmaskpb.FieldMask {
+	if x != nil {
+		return x.UpdateMask
+	}
+	return nil
+}
+
+func (x *PatchOrderRequest) GetItemsMode() ItemsPatchMode {
+	if x != nil {
+		return x.ItemsMode
+	}
+	return ItemsPatchMode_REPLACE
+}
+
+func (x *PatchOrderRequest) GetExpectedVersion() int64 {
+	if x != nil {
+		return x.ExpectedVersion
+	}
+	return 0
+}
+
+// updateOrdersV2中单个订单的确认。
+type UpdateOrderAck struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 订单在请求流中的位置，从0开始，用于对应订单ID为空等无法用ID区分的请求。
+	Index   int32  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	OrderId string `protobuf:"bytes,2,opt,name=orderId,proto3" json:"orderId,omitempty"`
+	// 更新成功时为订单的新版本，失败时为0。
+	Version int64 `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	// 更新的结果。code为OK表示成功，否则details中包含BadRequest或PreconditionFailure等错误详情。
+	Status *status.Status `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+}
+
+func (x *UpdateOrderAck) Reset() {
+	*x = UpdateOrderAck{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UpdateOrderAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateOrderAck) ProtoMessage() {}
+
+func (x *UpdateOrderAck) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateOrderAck.ProtoReflect.Descriptor instead.
+func (*UpdateOrderAck) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *UpdateOrderAck) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *UpdateOrderAck) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+func (x *UpdateOrderAck) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *UpdateOrderAck) GetStatus() *status.Status {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+type ListShipmentsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 目的地，忽略大小写的完全匹配，为空时不按目的地过滤。
+	Destination string `protobuf:"bytes,1,opt,name=destination,proto3" json:"destination,omitempty"`
+	// 发货组合的状态，为空时返回所有状态的发货组合。
+	Statuses []ShipmentStatus `protobuf:"varint,2,rep,packed,name=statuses,proto3,enum=ecommerce.ShipmentStatus" json:"statuses,omitempty"`
+}
+
+func (x *ListShipmentsRequest) Reset() {
+	*x = ListShipmentsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListShipmentsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListShipmentsRequest) ProtoMessage() {}
+
+func (x *ListShipmentsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListShipmentsRequest.ProtoReflect.Descriptor instead.
+func (*ListShipmentsRequest) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ListShipmentsRequest) GetDestination() string {
+	if x != nil {
+		return x.Destination
+	}
+	return ""
+}
+
+func (x *ListShipmentsRequest) GetStatuses() []ShipmentStatus {
+	if x != nil {
+		return x.Statuses
+	}
+	return nil
+}
+
+type ExportOrdersRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Format OrderFileFormat `protobuf:"varint,1,opt,name=format,proto3,enum=ecommerce.OrderFileFormat" json:"format,omitempty"`
+}
+
+func (x *ExportOrdersRequest) Reset() {
+	*x = ExportOrdersRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ExportOrdersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportOrdersRequest) ProtoMessage() {}
+
+func (x *ExportOrdersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportOrdersRequest.ProtoReflect.Descriptor instead.
+func (*ExportOrdersRequest) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ExportOrdersRequest) GetFormat() OrderFileFormat {
+	if x != nil {
+		return x.Format
+	}
+	return OrderFileFormat_JSONL
+}
+
+type ExportOrdersResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (x *ExportOrdersResponse) Reset() {
+	*x = ExportOrdersResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ExportOrdersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportOrdersResponse) ProtoMessage() {}
+
+func (x *ExportOrdersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportOrdersResponse.ProtoReflect.Descriptor instead.
+func (*ExportOrdersResponse) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ExportOrdersResponse) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type ImportOptions struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Format         OrderFileFormat      `protobuf:"varint,1,opt,name=format,proto3,enum=ecommerce.OrderFileFormat" json:"format,omitempty"`
+	ConflictPolicy ImportConflictPolicy `protobuf:"varint,2,opt,name=conflictPolicy,proto3,enum=ecommerce.ImportConflictPolicy" json:"conflictPolicy,omitempty"`
+	// 为true时只校验记录并生成汇总报告，不写入任何订单。
+	DryRun bool `protobuf:"varint,3,opt,name=dryRun,proto3" json:"dryRun,omitempty"`
+}
+
+func (x *ImportOptions) Reset() {
+	*x = ImportOptions{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ImportOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportOptions) ProtoMessage() {}
+
+func (x *ImportOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportOptions.ProtoReflect.Descriptor instead.
+func (*ImportOptions) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ImportOptions) GetFormat() OrderFileFormat {
+	if x != nil {
+		return x.Format
+	}
+	return OrderFileFormat_JSONL
+}
+
+func (x *ImportOptions) GetConflictPolicy() ImportConflictPolicy {
+	if x != nil {
+		return x.ConflictPolicy
+	}
+	return ImportConflictPolicy_CONFLICT_FAIL
+}
+
+func (x *ImportOptions) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type ImportOrdersRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Request:
+	//	*ImportOrdersRequest_Options
+	//	*ImportOrdersRequest_Data
+	Request isImportOrdersRequest_Request `protobuf_oneof:"request"`
+}
+
+func (x *ImportOrdersRequest) Reset() {
+	*x = ImportOrdersRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ImportOrdersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportOrdersRequest) ProtoMessage() {}
+
+func (x *ImportOrdersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportOrdersRequest.ProtoReflect.Descriptor instead.
+func (*ImportOrdersRequest) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{17}
+}
+
+func (m *ImportOrdersRequest) GetRequest() isImportOrdersRequest_Request {
+	if m != nil {
+		return m.Request
+	}
+	return nil
+}
+
+func (x *ImportOrdersRequest) GetOptions() *ImportOptions {
+	if x, ok := x.GetRequest().(*ImportOrdersRequest_Options); ok {
+		return x.Options
+	}
+	return nil
+}
+
+func (x *ImportOrdersRequest) GetData() []byte {
+	if x, ok := x.GetRequest().(*ImportOrdersRequest_Data); ok {
+		return x.Data
+	}
+	return nil
+}
+
+type isImportOrdersRequest_Request interface {
+	isImportOrdersRequest_Request()
+}
+
+type ImportOrdersRequest_Options struct {
+	Options *ImportOptions `protobuf:"bytes,1,opt,name=options,proto3,oneof"`
+}
+
+type ImportOrdersRequest_Data struct {
+	Data []byte `protobuf:"bytes,2,opt,name=data,proto3,oneof"`
+}
+
+func (*ImportOrdersRequest_Options) isImportOrdersRequest_Request() {}
+
+func (*ImportOrdersRequest_Data) isImportOrdersRequest_Request() {}
+
+// 单条记录的导入错误。
+type ImportError struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 记录在文件中的序号，从1开始，CSV的列名行不计算在内。
+	Record  int32  `protobuf:"varint,1,opt,name=record,proto3" json:"record,omitempty"`
+	OrderId string `protobuf:"bytes,2,opt,name=orderId,proto3" json:"orderId,omitempty"`
+	// 无效的记录为InvalidArgument，冲突的记录为AlreadyExists。
+	Status *status.Status `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+}
+
+func (x *ImportError) Reset() {
+	*x = ImportError{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ImportError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportError) ProtoMessage() {}
+
+func (x *ImportError) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportError.ProtoReflect.Descriptor instead.
+func (*ImportError) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *ImportError) GetRecord() int32 {
+	if x != nil {
+		return x.Record
+	}
+	return 0
+}
+
+func (x *ImportError) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+func (x *ImportError) GetStatus() *status.Status {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+type ImportOrdersSummary struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 读取到的记录数。
+	Received int32 `protobuf:"varint,1,opt,name=received,proto3" json:"received,omitempty"`
+	// 新建的订单数。committed为false时是提交后将会新建的订单数，overwritten同理。
+	Created int32 `protobuf:"varint,2,opt,name=created,proto3" json:"created,omitempty"`
+	// 覆盖的已有订单数。
+	Overwritten int32 `protobuf:"varint,3,opt,name=overwritten,proto3" json:"overwritten,omitempty"`
+	// 因为冲突而跳过的记录数。
+	Skipped int32 `protobuf:"varint,4,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	// 无效的记录数。
+	Failed int32 `protobuf:"varint,5,opt,name=failed,proto3" json:"failed,omitempty"`
+	// 订单是否已经写入。dryRun或者CONFLICT_FAIL策略下存在错误时为false。
+	Committed bool           `protobuf:"varint,6,opt,name=committed,proto3" json:"committed,omitempty"`
+	Errors    []*ImportError `protobuf:"bytes,7,rep,name=errors,proto3" json:"errors,omitempty"`
+}
+
+func (x *ImportOrdersSummary) Reset() {
+	*x = ImportOrdersSummary{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ImportOrdersSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportOrdersSummary) ProtoMessage() {}
+
+func (x *ImportOrdersSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportOrdersSummary.ProtoReflect.Descriptor instead.
+func (*ImportOrdersSummary) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ImportOrdersSummary) GetReceived() int32 {
+	if x != nil {
+		return x.Received
+	}
+	return 0
+}
+
+func (x *ImportOrdersSummary) GetCreated() int32 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *ImportOrdersSummary) GetOverwritten() int32 {
+	if x != nil {
+		return x.Overwritten
+	}
+	return 0
+}
+
+func (x *ImportOrdersSummary) GetSkipped() int32 {
+	if x != nil {
+		return x.Skipped
+	}
+	return 0
+}
+
+func (x *ImportOrdersSummary) GetFailed() int32 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+func (x *ImportOrdersSummary) GetCommitted() bool {
+	if x != nil {
+		return x.Committed
+	}
+	return false
+}
+
+func (x *ImportOrdersSummary) GetErrors() []*ImportError {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+type AuditActor struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// TLS客户端证书的CommonName，或者元数据caller-id的值。
+	Id     string           `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Source AuditActorSource `protobuf:"varint,2,opt,name=source,proto3,enum=ecommerce.AuditActorSource" json:"source,omitempty"`
+	// 调用方的网络地址。
+	PeerAddress string `protobuf:"bytes,3,opt,name=peerAddress,proto3" json:"peerAddress,omitempty"`
+}
+
+func (x *AuditActor) Reset() {
+	*x = AuditActor{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AuditActor) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuditActor) ProtoMessage() {}
+
+func (x *AuditActor) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuditActor.ProtoReflect.Descriptor instead.
+func (*AuditActor) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *AuditActor) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *AuditActor) GetSource() AuditActorSource {
+	if x != nil {
+		return x.Source
+	}
+	return AuditActorSource_ACTOR_SYSTEM
+}
+
+func (x *AuditActor) GetPeerAddress() string {
+	if x != nil {
+		return x.PeerAddress
+	}
+	return ""
+}
+
+// 订单的一个字段在变更前后的值，值为字段的JSON表示。新增的订单的before和删除的订单的after为空字符串。
+type FieldChange struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Field  string `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	Before string `protobuf:"bytes,2,opt,name=before,proto3" json:"before,omitempty"`
+	After  string `protobuf:"bytes,3,opt,name=after,proto3" json:"after,omitempty"`
+}
+
+func (x *FieldChange) Reset() {
+	*x = FieldChange{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[21]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FieldChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FieldChange) ProtoMessage() {}
+
+func (x *FieldChange) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[21]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FieldChange.ProtoReflect.Descriptor instead.
+func (*FieldChange) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *FieldChange) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *FieldChange) GetBefore() string {
+	if x != nil {
+		return x.Before
+	}
+	return ""
+}
+
+func (x *FieldChange) GetAfter() string {
+	if x != nil {
+		return x.After
+	}
+	return ""
+}
+
+// 订单的一次变更的审计记录，写入后不再修改。
+type AuditEntry struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	OrderId string `protobuf:"bytes,1,opt,name=orderId,proto3" json:"orderId,omitempty"`
+	// 订单的第几次变更，从1开始。
+	Sequence int64          `protobuf:"varint,2,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Type     OrderEventType `protobuf:"varint,3,opt,name=type,proto3,enum=ecommerce.OrderEventType" json:"type,omitempty"`
+	Actor    *AuditActor    `protobuf:"bytes,4,opt,name=actor,proto3" json:"actor,omitempty"`
+	// 发起变更的gRPC方法，服务器端自己发起的变更为空。
+	Method string               `protobuf:"bytes,5,opt,name=method,proto3" json:"method,omitempty"`
+	Time   *timestamp.Timestamp `protobuf:"bytes,6,opt,name=time,proto3" json:"time,omitempty"`
+	// 除version以外发生变化的字段。
+	Changes []*FieldChange `protobuf:"bytes,7,rep,name=changes,proto3" json:"changes,omitempty"`
+	// 变更后订单的版本，删除时为0。
+	Version int64 `protobuf:"varint,8,opt,name=version,proto3" json:"version,omitempty"`
+	// 订单所属的租户。
+	TenantId string `protobuf:"bytes,9,opt,name=tenantId,proto3" json:"tenantId,omitempty"`
+}
+
+func (x *AuditEntry) Reset() {
+	*x = AuditEntry{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[22]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AuditEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuditEntry) ProtoMessage() {}
+
+func (x *AuditEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[22]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuditEntry.ProtoReflect.Descriptor instead.
+func (*AuditEntry) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *AuditEntry) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetSequence() int64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *AuditEntry) GetType() OrderEventType {
+	if x != nil {
+		return x.Type
+	}
+	return OrderEventType_ADDED
+}
+
+func (x *AuditEntry) GetActor() *AuditActor {
+	if x != nil {
+		return x.Actor
+	}
+	return nil
+}
+
+func (x *AuditEntry) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *AuditEntry) GetTime() *timestamp.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *AuditEntry) GetChanges() []*FieldChange {
+	if x != nil {
+		return x.Changes
+	}
+	return nil
+}
+
+func (x *AuditEntry) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *AuditEntry) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+// 订单存储的发件箱(outbox)中的领域事件。订单的事件与产生它的变更在同一个事务中写入，
+// 由服务器端的中继至少投递一次，消费者应该按sequence去重。
+type DomainEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 事件在发件箱中的序号，从1开始单调递增，服务器端重启后也不会重复。
+	Sequence int64                `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Type     DomainEventType      `protobuf:"varint,2,opt,name=type,proto3,enum=ecommerce.DomainEventType" json:"type,omitempty"`
+	Time     *timestamp.Timestamp `protobuf:"bytes,3,opt,name=time,proto3" json:"time,omitempty"`
+	// 事件所属的租户，默认租户为空。
+	TenantId string `protobuf:"bytes,4,opt,name=tenantId,proto3" json:"tenantId,omitempty"`
+	// ORDER_*事件中变更后的订单。对于ORDER_DELETED事件，是订单被删除前的状态。
+	Order *Order `protobuf:"bytes,5,opt,name=order,proto3" json:"order,omitempty"`
+	// SHIPMENT_CREATED事件中的发货组合。
+	Shipment *CombinedShipment `protobuf:"bytes,6,opt,name=shipment,proto3" json:"shipment,omitempty"`
+	// 事件多次投递失败后被放入死信队列时，最后一次投递失败的原因。
+	DeadLetterReason string `protobuf:"bytes,7,opt,name=deadLetterReason,proto3" json:"deadLetterReason,omitempty"`
+}
+
+func (x *DomainEvent) Reset() {
+	*x = DomainEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_order_management_proto_msgTypes[23]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DomainEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DomainEvent) ProtoMessage() {}
+
+func (x *DomainEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_order_management_proto_msgTypes[23]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DomainEvent.ProtoReflect.Descriptor instead.
+func (*DomainEvent) Descriptor() ([]byte, []int) {
+	return file_order_management_proto_rawDescGZIP(), []int{23}
 }
 
-func (x *CombinedShipment) GetId() string {
+func (x *DomainEvent) GetSequence() int64 {
 	if x != nil {
-		return x.Id
+		return x.Sequence
 	}
-	return ""
+	return 0
 }
 
-func (x *CombinedShipment) GetStatus() string {
+func (x *DomainEvent) GetType() DomainEventType {
 	if x != nil {
-		return x.Status
+		return x.Type
+	}
+	return DomainEventType_ORDER_PLACED
+}
+
+func (x *DomainEvent) GetTime() *timestamp.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *DomainEvent) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
 	}
 	return ""
 }
 
-func (x *CombinedShipment) GetOrdersList() []*Order {
+func (x *DomainEvent) GetOrder() *Order {
 	if x != nil {
-		return x.OrdersList
+		return x.Order
+	}
+	return nil
+}
+
+func (x *DomainEvent) GetShipment() *CombinedShipment {
+	if x != nil {
+		return x.Shipment
 	}
 	return nil
 }
 
+func (x *DomainEvent) GetDeadLetterReason() string {
+	if x != nil {
+		return x.DeadLetterReason
+	}
+	return ""
+}
+
 var File_order_management_proto protoreflect.FileDescriptor
 
 var file_order_management_proto_rawDesc = []byte{
@@ -173,46 +2595,427 @@ var file_order_management_proto_rawDesc = []byte{
 	0x6e, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x09, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65,
 	0x72, 0x63, 0x65, 0x1a, 0x1e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74,
 	0x6f, 0x62, 0x75, 0x66, 0x2f, 0x77, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x73, 0x2e, 0x70, 0x72,
-	0x6f, 0x74, 0x6f, 0x22, 0x87, 0x01, 0x0a, 0x05, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x0e, 0x0a,
-	0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x14, 0x0a,
-	0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x69, 0x74,
-	0x65, 0x6d, 0x73, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69,
-	0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69,
-	0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x72, 0x69, 0x63, 0x65, 0x18, 0x04,
-	0x20, 0x01, 0x28, 0x02, 0x52, 0x05, 0x70, 0x72, 0x69, 0x63, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x64,
-	0x65, 0x73, 0x74, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x0b, 0x64, 0x65, 0x73, 0x74, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x6c, 0x0a,
-	0x10, 0x43, 0x6f, 0x6d, 0x62, 0x69, 0x6e, 0x65, 0x64, 0x53, 0x68, 0x69, 0x70, 0x6d, 0x65, 0x6e,
-	0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69,
-	0x64, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x30, 0x0a, 0x0a, 0x6f, 0x72, 0x64,
-	0x65, 0x72, 0x73, 0x4c, 0x69, 0x73, 0x74, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e,
-	0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x52,
-	0x0a, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x73, 0x4c, 0x69, 0x73, 0x74, 0x32, 0xdd, 0x02, 0x0a, 0x0f,
-	0x4f, 0x72, 0x64, 0x65, 0x72, 0x4d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x12,
-	0x3a, 0x0a, 0x08, 0x61, 0x64, 0x64, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x10, 0x2e, 0x65, 0x63,
-	0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x1a, 0x1c, 0x2e,
+	0x6f, 0x74, 0x6f, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x20, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x5f, 0x6d, 0x61, 0x73, 0x6b,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x17, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x72,
+	0x70, 0x63, 0x2f, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22,
+	0xee, 0x04, 0x0a, 0x05, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x74, 0x65,
+	0x6d, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x12,
+	0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f,
+	0x6e, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x72, 0x69, 0x63, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x02,
+	0x52, 0x05, 0x70, 0x72, 0x69, 0x63, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x74, 0x69,
+	0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65,
+	0x73, 0x74, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x2e, 0x0a, 0x06, 0x73, 0x74, 0x61,
+	0x74, 0x75, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x16, 0x2e, 0x65, 0x63, 0x6f, 0x6d,
+	0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x53, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x3a, 0x0a, 0x0a, 0x63, 0x72, 0x65,
+	0x61, 0x74, 0x65, 0x54, 0x69, 0x6d, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
 	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
-	0x53, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x3a, 0x0a, 0x08, 0x67,
-	0x65, 0x74, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x1c, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
-	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53, 0x74, 0x72, 0x69, 0x6e, 0x67,
-	0x56, 0x61, 0x6c, 0x75, 0x65, 0x1a, 0x10, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63,
-	0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x40, 0x0a, 0x0c, 0x73, 0x65, 0x61, 0x72, 0x63,
-	0x68, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x73, 0x12, 0x1c, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
-	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53, 0x74, 0x72, 0x69, 0x6e, 0x67,
-	0x56, 0x61, 0x6c, 0x75, 0x65, 0x1a, 0x10, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63,
-	0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x30, 0x01, 0x12, 0x40, 0x0a, 0x0c, 0x75, 0x70, 0x64,
-	0x61, 0x74, 0x65, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x73, 0x12, 0x10, 0x2e, 0x65, 0x63, 0x6f, 0x6d,
-	0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x1a, 0x1c, 0x2e, 0x67, 0x6f,
-	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53, 0x74,
-	0x72, 0x69, 0x6e, 0x67, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x28, 0x01, 0x12, 0x4e, 0x0a, 0x0d, 0x70,
-	0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x73, 0x12, 0x1c, 0x2e, 0x67,
-	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53,
-	0x74, 0x72, 0x69, 0x6e, 0x67, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x1a, 0x1b, 0x2e, 0x65, 0x63, 0x6f,
+	0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0a, 0x63, 0x72, 0x65, 0x61, 0x74,
+	0x65, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x18, 0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12,
+	0x22, 0x0a, 0x0c, 0x63, 0x61, 0x6e, 0x63, 0x65, 0x6c, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18,
+	0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x63, 0x61, 0x6e, 0x63, 0x65, 0x6c, 0x52, 0x65, 0x61,
+	0x73, 0x6f, 0x6e, 0x12, 0x30, 0x0a, 0x0a, 0x65, 0x78, 0x61, 0x63, 0x74, 0x50, 0x72, 0x69, 0x63,
+	0x65, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65,
+	0x72, 0x63, 0x65, 0x2e, 0x4d, 0x6f, 0x6e, 0x65, 0x79, 0x52, 0x0a, 0x65, 0x78, 0x61, 0x63, 0x74,
+	0x50, 0x72, 0x69, 0x63, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x47,
+	0x72, 0x61, 0x6d, 0x73, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x77, 0x65, 0x69, 0x67,
+	0x68, 0x74, 0x47, 0x72, 0x61, 0x6d, 0x73, 0x12, 0x34, 0x0a, 0x08, 0x70, 0x72, 0x69, 0x6f, 0x72,
+	0x69, 0x74, 0x79, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18, 0x2e, 0x65, 0x63, 0x6f, 0x6d,
+	0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x50, 0x72, 0x69, 0x6f, 0x72,
+	0x69, 0x74, 0x79, 0x52, 0x08, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x12, 0x1e, 0x0a,
+	0x0a, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x49, 0x64, 0x73, 0x18, 0x0d, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x0a, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x49, 0x64, 0x73, 0x12, 0x24, 0x0a,
+	0x0d, 0x72, 0x65, 0x73, 0x65, 0x72, 0x76, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x18, 0x0e,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x72, 0x65, 0x73, 0x65, 0x72, 0x76, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x49, 0x64, 0x12, 0x2b, 0x0a, 0x05, 0x73, 0x74, 0x6f, 0x63, 0x6b, 0x18, 0x0f, 0x20, 0x01,
+	0x28, 0x0e, 0x32, 0x15, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x53,
+	0x74, 0x6f, 0x63, 0x6b, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73, 0x74, 0x6f, 0x63, 0x6b,
+	0x12, 0x1a, 0x0a, 0x08, 0x74, 0x65, 0x6e, 0x61, 0x6e, 0x74, 0x49, 0x64, 0x18, 0x10, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x74, 0x65, 0x6e, 0x61, 0x6e, 0x74, 0x49, 0x64, 0x12, 0x22, 0x0a, 0x0c,
+	0x73, 0x65, 0x61, 0x72, 0x63, 0x68, 0x43, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x18, 0x11, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0c, 0x73, 0x65, 0x61, 0x72, 0x63, 0x68, 0x43, 0x75, 0x72, 0x73, 0x6f, 0x72,
+	0x22, 0x57, 0x0a, 0x05, 0x4d, 0x6f, 0x6e, 0x65, 0x79, 0x12, 0x22, 0x0a, 0x0c, 0x63, 0x75, 0x72,
+	0x72, 0x65, 0x6e, 0x63, 0x79, 0x43, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0c, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63, 0x79, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x14, 0x0a,
+	0x05, 0x75, 0x6e, 0x69, 0x74, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x75, 0x6e,
+	0x69, 0x74, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x6e, 0x61, 0x6e, 0x6f, 0x73, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x05, 0x6e, 0x61, 0x6e, 0x6f, 0x73, 0x22, 0xff, 0x02, 0x0a, 0x10, 0x43, 0x6f,
+	0x6d, 0x62, 0x69, 0x6e, 0x65, 0x64, 0x53, 0x68, 0x69, 0x70, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x0e,
+	0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x16,
+	0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
+	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x30, 0x0a, 0x0a, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x73,
+	0x4c, 0x69, 0x73, 0x74, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x65, 0x63, 0x6f,
+	0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x52, 0x0a, 0x6f, 0x72,
+	0x64, 0x65, 0x72, 0x73, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x74,
+	0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64,
+	0x65, 0x73, 0x74, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x41, 0x0a, 0x0e, 0x73, 0x68,
+	0x69, 0x70, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x0e, 0x32, 0x19, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x53,
+	0x68, 0x69, 0x70, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x0e, 0x73,
+	0x68, 0x69, 0x70, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x3a, 0x0a,
+	0x0a, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x54, 0x69, 0x6d, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0a, 0x63,
+	0x72, 0x65, 0x61, 0x74, 0x65, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x3a, 0x0a, 0x0a, 0x75, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x54, 0x69, 0x6d, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0a, 0x75, 0x70, 0x64, 0x61, 0x74,
+	0x65, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x18, 0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12,
+	0x1a, 0x0a, 0x08, 0x74, 0x65, 0x6e, 0x61, 0x6e, 0x74, 0x49, 0x64, 0x18, 0x09, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x08, 0x74, 0x65, 0x6e, 0x61, 0x6e, 0x74, 0x49, 0x64, 0x22, 0x7f, 0x0a, 0x0b, 0x4f,
+	0x72, 0x64, 0x65, 0x72, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x6f, 0x72,
+	0x64, 0x65, 0x72, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6f, 0x72, 0x64,
+	0x65, 0x72, 0x49, 0x64, 0x12, 0x20, 0x0a, 0x0a, 0x73, 0x68, 0x69, 0x70, 0x6d, 0x65, 0x6e, 0x74,
+	0x49, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52, 0x0a, 0x73, 0x68, 0x69, 0x70,
+	0x6d, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x12, 0x2a, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x72,
+	0x70, 0x63, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x48, 0x00, 0x52, 0x05, 0x65, 0x72, 0x72,
+	0x6f, 0x72, 0x42, 0x08, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x22, 0x90, 0x01, 0x0a,
+	0x15, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x73, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x30, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72,
+	0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x48, 0x00,
+	0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x39, 0x0a, 0x08, 0x73, 0x68, 0x69, 0x70,
+	0x6d, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x65, 0x63, 0x6f,
 	0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x43, 0x6f, 0x6d, 0x62, 0x69, 0x6e, 0x65, 0x64, 0x53,
-	0x68, 0x69, 0x70, 0x6d, 0x65, 0x6e, 0x74, 0x28, 0x01, 0x30, 0x01, 0x42, 0x0d, 0x5a, 0x0b, 0x2e,
-	0x2f, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x33,
+	0x68, 0x69, 0x70, 0x6d, 0x65, 0x6e, 0x74, 0x48, 0x00, 0x52, 0x08, 0x73, 0x68, 0x69, 0x70, 0x6d,
+	0x65, 0x6e, 0x74, 0x42, 0x0a, 0x0a, 0x08, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22,
+	0xb3, 0x04, 0x0a, 0x13, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x12, 0x20, 0x0a, 0x0b, 0x64,
+	0x65, 0x73, 0x74, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0b, 0x64, 0x65, 0x73, 0x74, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x37, 0x0a,
+	0x08, 0x6d, 0x69, 0x6e, 0x50, 0x72, 0x69, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1b, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2e, 0x46, 0x6c, 0x6f, 0x61, 0x74, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x52, 0x08, 0x6d, 0x69,
+	0x6e, 0x50, 0x72, 0x69, 0x63, 0x65, 0x12, 0x37, 0x0a, 0x08, 0x6d, 0x61, 0x78, 0x50, 0x72, 0x69,
+	0x63, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x6c, 0x6f, 0x61, 0x74,
+	0x56, 0x61, 0x6c, 0x75, 0x65, 0x52, 0x08, 0x6d, 0x61, 0x78, 0x50, 0x72, 0x69, 0x63, 0x65, 0x12,
+	0x32, 0x0a, 0x08, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x65, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28,
+	0x0e, 0x32, 0x16, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72,
+	0x64, 0x65, 0x72, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x08, 0x73, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x65, 0x73, 0x12, 0x3e, 0x0a, 0x0c, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x41, 0x66,
+	0x74, 0x65, 0x72, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0c, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x41, 0x66,
+	0x74, 0x65, 0x72, 0x12, 0x32, 0x0a, 0x09, 0x73, 0x6f, 0x72, 0x74, 0x4f, 0x72, 0x64, 0x65, 0x72,
+	0x18, 0x07, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x14, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72,
+	0x63, 0x65, 0x2e, 0x53, 0x6f, 0x72, 0x74, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x52, 0x09, 0x73, 0x6f,
+	0x72, 0x74, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x61, 0x67, 0x65, 0x53,
+	0x69, 0x7a, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x70, 0x61, 0x67, 0x65, 0x53,
+	0x69, 0x7a, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x61, 0x67, 0x65, 0x54, 0x6f, 0x6b, 0x65, 0x6e,
+	0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x70, 0x61, 0x67, 0x65, 0x54, 0x6f, 0x6b, 0x65,
+	0x6e, 0x12, 0x22, 0x0a, 0x0c, 0x72, 0x65, 0x73, 0x75, 0x6d, 0x65, 0x43, 0x75, 0x72, 0x73, 0x6f,
+	0x72, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x72, 0x65, 0x73, 0x75, 0x6d, 0x65, 0x43,
+	0x75, 0x72, 0x73, 0x6f, 0x72, 0x12, 0x36, 0x0a, 0x0d, 0x6d, 0x69, 0x6e, 0x45, 0x78, 0x61, 0x63,
+	0x74, 0x50, 0x72, 0x69, 0x63, 0x65, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x65,
+	0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4d, 0x6f, 0x6e, 0x65, 0x79, 0x52, 0x0d,
+	0x6d, 0x69, 0x6e, 0x45, 0x78, 0x61, 0x63, 0x74, 0x50, 0x72, 0x69, 0x63, 0x65, 0x12, 0x36, 0x0a,
+	0x0d, 0x6d, 0x61, 0x78, 0x45, 0x78, 0x61, 0x63, 0x74, 0x50, 0x72, 0x69, 0x63, 0x65, 0x18, 0x0c,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65,
+	0x2e, 0x4d, 0x6f, 0x6e, 0x65, 0x79, 0x52, 0x0d, 0x6d, 0x61, 0x78, 0x45, 0x78, 0x61, 0x63, 0x74,
+	0x50, 0x72, 0x69, 0x63, 0x65, 0x22, 0x82, 0x01, 0x0a, 0x16, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x69,
+	0x74, 0x69, 0x6f, 0x6e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64,
+	0x12, 0x2e, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x16, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64,
+	0x65, 0x72, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x12, 0x28, 0x0a, 0x0f, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x56, 0x65, 0x72, 0x73,
+	0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0f, 0x65, 0x78, 0x70, 0x65, 0x63,
+	0x74, 0x65, 0x64, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x5c, 0x0a, 0x12, 0x57, 0x61,
+	0x74, 0x63, 0x68, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x26, 0x0a, 0x0e, 0x72, 0x65, 0x73, 0x75, 0x6d, 0x65, 0x52, 0x65, 0x76, 0x69, 0x73, 0x69,
+	0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0e, 0x72, 0x65, 0x73, 0x75, 0x6d, 0x65,
+	0x52, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1e, 0x0a, 0x0a, 0x66, 0x72, 0x6f, 0x6d,
+	0x4f, 0x6c, 0x64, 0x65, 0x73, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x66, 0x72,
+	0x6f, 0x6d, 0x4f, 0x6c, 0x64, 0x65, 0x73, 0x74, 0x22, 0x7f, 0x0a, 0x0a, 0x4f, 0x72, 0x64, 0x65,
+	0x72, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69,
+	0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69,
+	0x6f, 0x6e, 0x12, 0x2d, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x19, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64,
+	0x65, 0x72, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70,
+	0x65, 0x12, 0x26, 0x0a, 0x05, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x10, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64,
+	0x65, 0x72, 0x52, 0x05, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x22, 0x66, 0x0a, 0x12, 0x43, 0x61, 0x6e,
+	0x63, 0x65, 0x6c, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12,
+	0x16, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x28, 0x0a, 0x0f, 0x65, 0x78, 0x70, 0x65, 0x63,
+	0x74, 0x65, 0x64, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x0f, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x22, 0x66, 0x0a, 0x12, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x4f, 0x72, 0x64, 0x65, 0x72,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f,
+	0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12,
+	0x28, 0x0a, 0x0f, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x56, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0f, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74,
+	0x65, 0x64, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0xda, 0x01, 0x0a, 0x11, 0x50, 0x61,
+	0x74, 0x63, 0x68, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x26, 0x0a, 0x05, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10,
+	0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72,
+	0x52, 0x05, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x3a, 0x0a, 0x0a, 0x75, 0x70, 0x64, 0x61, 0x74,
+	0x65, 0x4d, 0x61, 0x73, 0x6b, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x69,
+	0x65, 0x6c, 0x64, 0x4d, 0x61, 0x73, 0x6b, 0x52, 0x0a, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x4d,
+	0x61, 0x73, 0x6b, 0x12, 0x37, 0x0a, 0x09, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x4d, 0x6f, 0x64, 0x65,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x19, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72,
+	0x63, 0x65, 0x2e, 0x49, 0x74, 0x65, 0x6d, 0x73, 0x50, 0x61, 0x74, 0x63, 0x68, 0x4d, 0x6f, 0x64,
+	0x65, 0x52, 0x09, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x4d, 0x6f, 0x64, 0x65, 0x12, 0x28, 0x0a, 0x0f,
+	0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0f, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x56,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x86, 0x01, 0x0a, 0x0e, 0x55, 0x70, 0x64, 0x61, 0x74,
+	0x65, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x41, 0x63, 0x6b, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64,
+	0x65, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12,
+	0x18, 0x0a, 0x07, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x49, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73,
+	0x69, 0x6f, 0x6e, 0x12, 0x2a, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x72, 0x70, 0x63,
+	0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x22,
+	0x6f, 0x0a, 0x14, 0x4c, 0x69, 0x73, 0x74, 0x53, 0x68, 0x69, 0x70, 0x6d, 0x65, 0x6e, 0x74, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x74, 0x69,
+	0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65,
+	0x73, 0x74, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x35, 0x0a, 0x08, 0x73, 0x74, 0x61,
+	0x74, 0x75, 0x73, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0e, 0x32, 0x19, 0x2e, 0x65, 0x63,
+	0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x53, 0x68, 0x69, 0x70, 0x6d, 0x65, 0x6e, 0x74,
+	0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x08, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x65, 0x73,
+	0x22, 0x49, 0x0a, 0x13, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x32, 0x0a, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61,
+	0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1a, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65,
+	0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x46, 0x69, 0x6c, 0x65, 0x46, 0x6f, 0x72,
+	0x6d, 0x61, 0x74, 0x52, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x22, 0x2a, 0x0a, 0x14, 0x45,
+	0x78, 0x70, 0x6f, 0x72, 0x74, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0xa4, 0x01, 0x0a, 0x0d, 0x49, 0x6d, 0x70, 0x6f,
+	0x72, 0x74, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x32, 0x0a, 0x06, 0x66, 0x6f, 0x72,
+	0x6d, 0x61, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1a, 0x2e, 0x65, 0x63, 0x6f, 0x6d,
+	0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x46, 0x69, 0x6c, 0x65, 0x46,
+	0x6f, 0x72, 0x6d, 0x61, 0x74, 0x52, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x12, 0x47, 0x0a,
+	0x0e, 0x63, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1f, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63,
+	0x65, 0x2e, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x43, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74,
+	0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x0e, 0x63, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74,
+	0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x72, 0x79, 0x52, 0x75, 0x6e,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x64, 0x72, 0x79, 0x52, 0x75, 0x6e, 0x22, 0x6c,
+	0x0a, 0x13, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x73, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x34, 0x0a, 0x07, 0x6f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72,
+	0x63, 0x65, 0x2e, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73,
+	0x48, 0x00, 0x52, 0x07, 0x6f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x14, 0x0a, 0x04, 0x64,
+	0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x48, 0x00, 0x52, 0x04, 0x64, 0x61, 0x74,
+	0x61, 0x42, 0x09, 0x0a, 0x07, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x6b, 0x0a, 0x0b,
+	0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x16, 0x0a, 0x06, 0x72,
+	0x65, 0x63, 0x6f, 0x72, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x72, 0x65, 0x63,
+	0x6f, 0x72, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x49, 0x64, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x49, 0x64, 0x12, 0x2a, 0x0a,
+	0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x72, 0x70, 0x63, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x22, 0xed, 0x01, 0x0a, 0x13, 0x49, 0x6d,
+	0x70, 0x6f, 0x72, 0x74, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x73, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72,
+	0x79, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x63, 0x65, 0x69, 0x76, 0x65, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x08, 0x72, 0x65, 0x63, 0x65, 0x69, 0x76, 0x65, 0x64, 0x12, 0x18, 0x0a,
+	0x07, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07,
+	0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x12, 0x20, 0x0a, 0x0b, 0x6f, 0x76, 0x65, 0x72, 0x77,
+	0x72, 0x69, 0x74, 0x74, 0x65, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0b, 0x6f, 0x76,
+	0x65, 0x72, 0x77, 0x72, 0x69, 0x74, 0x74, 0x65, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x6b, 0x69,
+	0x70, 0x70, 0x65, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x73, 0x6b, 0x69, 0x70,
+	0x70, 0x65, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x66, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x06, 0x66, 0x61, 0x69, 0x6c, 0x65, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x63,
+	0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x74, 0x65, 0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09,
+	0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x74, 0x65, 0x64, 0x12, 0x2e, 0x0a, 0x06, 0x65, 0x72, 0x72,
+	0x6f, 0x72, 0x73, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x65, 0x63, 0x6f, 0x6d,
+	0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x45, 0x72, 0x72, 0x6f,
+	0x72, 0x52, 0x06, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x22, 0x73, 0x0a, 0x0a, 0x41, 0x75, 0x64,
+	0x69, 0x74, 0x41, 0x63, 0x74, 0x6f, 0x72, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x33, 0x0a, 0x06, 0x73, 0x6f, 0x75, 0x72, 0x63,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1b, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65,
+	0x72, 0x63, 0x65, 0x2e, 0x41, 0x75, 0x64, 0x69, 0x74, 0x41, 0x63, 0x74, 0x6f, 0x72, 0x53, 0x6f,
+	0x75, 0x72, 0x63, 0x65, 0x52, 0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x20, 0x0a, 0x0b,
+	0x70, 0x65, 0x65, 0x72, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0b, 0x70, 0x65, 0x65, 0x72, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x22, 0x51,
+	0x0a, 0x0b, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x14, 0x0a,
+	0x05, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x66, 0x69,
+	0x65, 0x6c, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x62, 0x65, 0x66, 0x6f, 0x72, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x06, 0x62, 0x65, 0x66, 0x6f, 0x72, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x61,
+	0x66, 0x74, 0x65, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x61, 0x66, 0x74, 0x65,
+	0x72, 0x22, 0xce, 0x02, 0x0a, 0x0a, 0x41, 0x75, 0x64, 0x69, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x12, 0x18, 0x0a, 0x07, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x49, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x65,
+	0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x73, 0x65,
+	0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x2d, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0e, 0x32, 0x19, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65,
+	0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x52,
+	0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x2b, 0x0a, 0x05, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65,
+	0x2e, 0x41, 0x75, 0x64, 0x69, 0x74, 0x41, 0x63, 0x74, 0x6f, 0x72, 0x52, 0x05, 0x61, 0x63, 0x74,
+	0x6f, 0x72, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x12, 0x2e, 0x0a, 0x04, 0x74, 0x69,
+	0x6d, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73,
+	0x74, 0x61, 0x6d, 0x70, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x30, 0x0a, 0x07, 0x63, 0x68,
+	0x61, 0x6e, 0x67, 0x65, 0x73, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x65, 0x63,
+	0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x43, 0x68, 0x61,
+	0x6e, 0x67, 0x65, 0x52, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x12, 0x18, 0x0a, 0x07,
+	0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x76,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x74, 0x65, 0x6e, 0x61, 0x6e, 0x74,
+	0x49, 0x64, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x74, 0x65, 0x6e, 0x61, 0x6e, 0x74,
+	0x49, 0x64, 0x22, 0xb2, 0x02, 0x0a, 0x0b, 0x44, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x45, 0x76, 0x65,
+	0x6e, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x2e,
+	0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1a, 0x2e, 0x65,
+	0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x44, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x45,
+	0x76, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x2e,
+	0x0a, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x1a,
+	0x0a, 0x08, 0x74, 0x65, 0x6e, 0x61, 0x6e, 0x74, 0x49, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x08, 0x74, 0x65, 0x6e, 0x61, 0x6e, 0x74, 0x49, 0x64, 0x12, 0x26, 0x0a, 0x05, 0x6f, 0x72,
+	0x64, 0x65, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x65, 0x63, 0x6f, 0x6d,
+	0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x52, 0x05, 0x6f, 0x72, 0x64,
+	0x65, 0x72, 0x12, 0x37, 0x0a, 0x08, 0x73, 0x68, 0x69, 0x70, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65,
+	0x2e, 0x43, 0x6f, 0x6d, 0x62, 0x69, 0x6e, 0x65, 0x64, 0x53, 0x68, 0x69, 0x70, 0x6d, 0x65, 0x6e,
+	0x74, 0x52, 0x08, 0x73, 0x68, 0x69, 0x70, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x2a, 0x0a, 0x10, 0x64,
+	0x65, 0x61, 0x64, 0x4c, 0x65, 0x74, 0x74, 0x65, 0x72, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18,
+	0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x10, 0x64, 0x65, 0x61, 0x64, 0x4c, 0x65, 0x74, 0x74, 0x65,
+	0x72, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x2a, 0x60, 0x0a, 0x0b, 0x4f, 0x72, 0x64, 0x65, 0x72,
+	0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x0b, 0x0a, 0x07, 0x50, 0x45, 0x4e, 0x44, 0x49, 0x4e,
+	0x47, 0x10, 0x00, 0x12, 0x0d, 0x0a, 0x09, 0x43, 0x4f, 0x4e, 0x46, 0x49, 0x52, 0x4d, 0x45, 0x44,
+	0x10, 0x01, 0x12, 0x0a, 0x0a, 0x06, 0x50, 0x41, 0x43, 0x4b, 0x45, 0x44, 0x10, 0x02, 0x12, 0x0b,
+	0x0a, 0x07, 0x53, 0x48, 0x49, 0x50, 0x50, 0x45, 0x44, 0x10, 0x03, 0x12, 0x0d, 0x0a, 0x09, 0x44,
+	0x45, 0x4c, 0x49, 0x56, 0x45, 0x52, 0x45, 0x44, 0x10, 0x04, 0x12, 0x0d, 0x0a, 0x09, 0x43, 0x41,
+	0x4e, 0x43, 0x45, 0x4c, 0x4c, 0x45, 0x44, 0x10, 0x05, 0x2a, 0x59, 0x0a, 0x0a, 0x53, 0x74, 0x6f,
+	0x63, 0x6b, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x0e, 0x0a, 0x0a, 0x53, 0x54, 0x4f, 0x43, 0x4b,
+	0x5f, 0x4e, 0x4f, 0x4e, 0x45, 0x10, 0x00, 0x12, 0x12, 0x0a, 0x0e, 0x53, 0x54, 0x4f, 0x43, 0x4b,
+	0x5f, 0x52, 0x45, 0x53, 0x45, 0x52, 0x56, 0x45, 0x44, 0x10, 0x01, 0x12, 0x13, 0x0a, 0x0f, 0x53,
+	0x54, 0x4f, 0x43, 0x4b, 0x5f, 0x43, 0x4f, 0x4d, 0x4d, 0x49, 0x54, 0x54, 0x45, 0x44, 0x10, 0x02,
+	0x12, 0x12, 0x0a, 0x0e, 0x53, 0x54, 0x4f, 0x43, 0x4b, 0x5f, 0x52, 0x45, 0x4c, 0x45, 0x41, 0x53,
+	0x45, 0x44, 0x10, 0x03, 0x2a, 0x2a, 0x0a, 0x0d, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x50, 0x72, 0x69,
+	0x6f, 0x72, 0x69, 0x74, 0x79, 0x12, 0x0c, 0x0a, 0x08, 0x53, 0x54, 0x41, 0x4e, 0x44, 0x41, 0x52,
+	0x44, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x45, 0x58, 0x50, 0x52, 0x45, 0x53, 0x53, 0x10, 0x01,
+	0x2a, 0x39, 0x0a, 0x0e, 0x53, 0x68, 0x69, 0x70, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x12, 0x11, 0x0a, 0x0d, 0x53, 0x48, 0x49, 0x50, 0x4d, 0x45, 0x4e, 0x54, 0x5f, 0x4f,
+	0x50, 0x45, 0x4e, 0x10, 0x00, 0x12, 0x14, 0x0a, 0x10, 0x53, 0x48, 0x49, 0x50, 0x4d, 0x45, 0x4e,
+	0x54, 0x5f, 0x53, 0x48, 0x49, 0x50, 0x50, 0x45, 0x44, 0x10, 0x01, 0x2a, 0x61, 0x0a, 0x09, 0x53,
+	0x6f, 0x72, 0x74, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x0a, 0x0a, 0x06, 0x49, 0x44, 0x5f, 0x41,
+	0x53, 0x43, 0x10, 0x00, 0x12, 0x0d, 0x0a, 0x09, 0x50, 0x52, 0x49, 0x43, 0x45, 0x5f, 0x41, 0x53,
+	0x43, 0x10, 0x01, 0x12, 0x0e, 0x0a, 0x0a, 0x50, 0x52, 0x49, 0x43, 0x45, 0x5f, 0x44, 0x45, 0x53,
+	0x43, 0x10, 0x02, 0x12, 0x13, 0x0a, 0x0f, 0x43, 0x52, 0x45, 0x41, 0x54, 0x45, 0x5f, 0x54, 0x49,
+	0x4d, 0x45, 0x5f, 0x41, 0x53, 0x43, 0x10, 0x03, 0x12, 0x14, 0x0a, 0x10, 0x43, 0x52, 0x45, 0x41,
+	0x54, 0x45, 0x5f, 0x54, 0x49, 0x4d, 0x45, 0x5f, 0x44, 0x45, 0x53, 0x43, 0x10, 0x04, 0x2a, 0x35,
+	0x0a, 0x0e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65,
+	0x12, 0x09, 0x0a, 0x05, 0x41, 0x44, 0x44, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x55,
+	0x50, 0x44, 0x41, 0x54, 0x45, 0x44, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x44, 0x45, 0x4c, 0x45,
+	0x54, 0x45, 0x44, 0x10, 0x02, 0x2a, 0x29, 0x0a, 0x0e, 0x49, 0x74, 0x65, 0x6d, 0x73, 0x50, 0x61,
+	0x74, 0x63, 0x68, 0x4d, 0x6f, 0x64, 0x65, 0x12, 0x0b, 0x0a, 0x07, 0x52, 0x45, 0x50, 0x4c, 0x41,
+	0x43, 0x45, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x41, 0x50, 0x50, 0x45, 0x4e, 0x44, 0x10, 0x01,
+	0x2a, 0x25, 0x0a, 0x0f, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x46, 0x69, 0x6c, 0x65, 0x46, 0x6f, 0x72,
+	0x6d, 0x61, 0x74, 0x12, 0x09, 0x0a, 0x05, 0x4a, 0x53, 0x4f, 0x4e, 0x4c, 0x10, 0x00, 0x12, 0x07,
+	0x0a, 0x03, 0x43, 0x53, 0x56, 0x10, 0x01, 0x2a, 0x54, 0x0a, 0x14, 0x49, 0x6d, 0x70, 0x6f, 0x72,
+	0x74, 0x43, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12,
+	0x11, 0x0a, 0x0d, 0x43, 0x4f, 0x4e, 0x46, 0x4c, 0x49, 0x43, 0x54, 0x5f, 0x46, 0x41, 0x49, 0x4c,
+	0x10, 0x00, 0x12, 0x11, 0x0a, 0x0d, 0x43, 0x4f, 0x4e, 0x46, 0x4c, 0x49, 0x43, 0x54, 0x5f, 0x53,
+	0x4b, 0x49, 0x50, 0x10, 0x01, 0x12, 0x16, 0x0a, 0x12, 0x43, 0x4f, 0x4e, 0x46, 0x4c, 0x49, 0x43,
+	0x54, 0x5f, 0x4f, 0x56, 0x45, 0x52, 0x57, 0x52, 0x49, 0x54, 0x45, 0x10, 0x02, 0x2a, 0x5c, 0x0a,
+	0x10, 0x41, 0x75, 0x64, 0x69, 0x74, 0x41, 0x63, 0x74, 0x6f, 0x72, 0x53, 0x6f, 0x75, 0x72, 0x63,
+	0x65, 0x12, 0x10, 0x0a, 0x0c, 0x41, 0x43, 0x54, 0x4f, 0x52, 0x5f, 0x53, 0x59, 0x53, 0x54, 0x45,
+	0x4d, 0x10, 0x00, 0x12, 0x0d, 0x0a, 0x09, 0x41, 0x43, 0x54, 0x4f, 0x52, 0x5f, 0x54, 0x4c, 0x53,
+	0x10, 0x01, 0x12, 0x12, 0x0a, 0x0e, 0x41, 0x43, 0x54, 0x4f, 0x52, 0x5f, 0x4d, 0x45, 0x54, 0x41,
+	0x44, 0x41, 0x54, 0x41, 0x10, 0x02, 0x12, 0x13, 0x0a, 0x0f, 0x41, 0x43, 0x54, 0x4f, 0x52, 0x5f,
+	0x41, 0x4e, 0x4f, 0x4e, 0x59, 0x4d, 0x4f, 0x55, 0x53, 0x10, 0x03, 0x2a, 0x5f, 0x0a, 0x0f, 0x44,
+	0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x10,
+	0x0a, 0x0c, 0x4f, 0x52, 0x44, 0x45, 0x52, 0x5f, 0x50, 0x4c, 0x41, 0x43, 0x45, 0x44, 0x10, 0x00,
+	0x12, 0x11, 0x0a, 0x0d, 0x4f, 0x52, 0x44, 0x45, 0x52, 0x5f, 0x55, 0x50, 0x44, 0x41, 0x54, 0x45,
+	0x44, 0x10, 0x01, 0x12, 0x11, 0x0a, 0x0d, 0x4f, 0x52, 0x44, 0x45, 0x52, 0x5f, 0x44, 0x45, 0x4c,
+	0x45, 0x54, 0x45, 0x44, 0x10, 0x02, 0x12, 0x14, 0x0a, 0x10, 0x53, 0x48, 0x49, 0x50, 0x4d, 0x45,
+	0x4e, 0x54, 0x5f, 0x43, 0x52, 0x45, 0x41, 0x54, 0x45, 0x44, 0x10, 0x03, 0x32, 0xd8, 0x09, 0x0a,
+	0x0f, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x4d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74,
+	0x12, 0x3a, 0x0a, 0x08, 0x61, 0x64, 0x64, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x10, 0x2e, 0x65,
+	0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x1a, 0x1c,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x53, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x3a, 0x0a, 0x08,
+	0x67, 0x65, 0x74, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x1c, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53, 0x74, 0x72, 0x69, 0x6e,
+	0x67, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x1a, 0x10, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72,
+	0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x42, 0x0a, 0x0c, 0x73, 0x65, 0x61, 0x72,
+	0x63, 0x68, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x73, 0x12, 0x1e, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d,
+	0x65, 0x72, 0x63, 0x65, 0x2e, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x4f, 0x72, 0x64, 0x65, 0x72,
+	0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x10, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d,
+	0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x30, 0x01, 0x12, 0x40, 0x0a, 0x0c,
+	0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x73, 0x12, 0x10, 0x2e, 0x65,
+	0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x1a, 0x1c,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x53, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x28, 0x01, 0x12, 0x53,
+	0x0a, 0x0d, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x73, 0x12,
+	0x1c, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2e, 0x53, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x1a, 0x20, 0x2e,
+	0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73,
+	0x73, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x28,
+	0x01, 0x30, 0x01, 0x12, 0x46, 0x0a, 0x0f, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x69, 0x74, 0x69, 0x6f,
+	0x6e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x21, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72,
+	0x63, 0x65, 0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x4f, 0x72, 0x64,
+	0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x10, 0x2e, 0x65, 0x63, 0x6f, 0x6d,
+	0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x45, 0x0a, 0x0b, 0x77,
+	0x61, 0x74, 0x63, 0x68, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x73, 0x12, 0x1d, 0x2e, 0x65, 0x63, 0x6f,
+	0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x4f, 0x72, 0x64, 0x65,
+	0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x15, 0x2e, 0x65, 0x63, 0x6f, 0x6d,
+	0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x45, 0x76, 0x65, 0x6e, 0x74,
+	0x30, 0x01, 0x12, 0x3e, 0x0a, 0x0b, 0x63, 0x61, 0x6e, 0x63, 0x65, 0x6c, 0x4f, 0x72, 0x64, 0x65,
+	0x72, 0x12, 0x1d, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x43, 0x61,
+	0x6e, 0x63, 0x65, 0x6c, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x10, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64,
+	0x65, 0x72, 0x12, 0x4a, 0x0a, 0x0b, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x4f, 0x72, 0x64, 0x65,
+	0x72, 0x12, 0x1d, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x44, 0x65,
+	0x6c, 0x65, 0x74, 0x65, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x1c, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
+	0x75, 0x66, 0x2e, 0x53, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x3c,
+	0x0a, 0x0a, 0x70, 0x61, 0x74, 0x63, 0x68, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x1c, 0x2e, 0x65,
+	0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x50, 0x61, 0x74, 0x63, 0x68, 0x4f, 0x72,
+	0x64, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x10, 0x2e, 0x65, 0x63, 0x6f,
+	0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x41, 0x0a, 0x0e,
+	0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x73, 0x56, 0x32, 0x12, 0x10,
+	0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72,
+	0x1a, 0x19, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x55, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x41, 0x63, 0x6b, 0x28, 0x01, 0x30, 0x01, 0x12,
+	0x48, 0x0a, 0x0b, 0x67, 0x65, 0x74, 0x53, 0x68, 0x69, 0x70, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x1c,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x53, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x1a, 0x1b, 0x2e, 0x65,
+	0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x43, 0x6f, 0x6d, 0x62, 0x69, 0x6e, 0x65,
+	0x64, 0x53, 0x68, 0x69, 0x70, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x4f, 0x0a, 0x0d, 0x6c, 0x69, 0x73,
+	0x74, 0x53, 0x68, 0x69, 0x70, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x1f, 0x2e, 0x65, 0x63, 0x6f,
+	0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x53, 0x68, 0x69, 0x70, 0x6d,
+	0x65, 0x6e, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x65, 0x63,
+	0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x43, 0x6f, 0x6d, 0x62, 0x69, 0x6e, 0x65, 0x64,
+	0x53, 0x68, 0x69, 0x70, 0x6d, 0x65, 0x6e, 0x74, 0x30, 0x01, 0x12, 0x4c, 0x0a, 0x0d, 0x77, 0x61,
+	0x74, 0x63, 0x68, 0x53, 0x68, 0x69, 0x70, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x1c, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53, 0x74,
+	0x72, 0x69, 0x6e, 0x67, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x1a, 0x1b, 0x2e, 0x65, 0x63, 0x6f, 0x6d,
+	0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x43, 0x6f, 0x6d, 0x62, 0x69, 0x6e, 0x65, 0x64, 0x53, 0x68,
+	0x69, 0x70, 0x6d, 0x65, 0x6e, 0x74, 0x30, 0x01, 0x12, 0x51, 0x0a, 0x0c, 0x65, 0x78, 0x70, 0x6f,
+	0x72, 0x74, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x73, 0x12, 0x1e, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d,
+	0x65, 0x72, 0x63, 0x65, 0x2e, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x4f, 0x72, 0x64, 0x65, 0x72,
+	0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d,
+	0x65, 0x72, 0x63, 0x65, 0x2e, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x4f, 0x72, 0x64, 0x65, 0x72,
+	0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x12, 0x50, 0x0a, 0x0c, 0x69,
+	0x6d, 0x70, 0x6f, 0x72, 0x74, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x73, 0x12, 0x1e, 0x2e, 0x65, 0x63,
+	0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x4f, 0x72,
+	0x64, 0x65, 0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1e, 0x2e, 0x65, 0x63,
+	0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x4f, 0x72,
+	0x64, 0x65, 0x72, 0x73, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x28, 0x01, 0x12, 0x48, 0x0a,
+	0x0f, 0x67, 0x65, 0x74, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79,
+	0x12, 0x1c, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
+	0x75, 0x66, 0x2e, 0x53, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x1a, 0x15,
+	0x2e, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x2e, 0x41, 0x75, 0x64, 0x69, 0x74,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x30, 0x01, 0x42, 0x0d, 0x5a, 0x0b, 0x2e, 0x2f, 0x65, 0x63, 0x6f,
+	0x6d, 0x6d, 0x65, 0x72, 0x63, 0x65, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -227,29 +3030,132 @@ func file_order_management_proto_rawDescGZIP() []byte {
 	return file_order_management_proto_rawDescData
 }
 
-var file_order_management_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_order_management_proto_enumTypes = make([]protoimpl.EnumInfo, 11)
+var file_order_management_proto_msgTypes = make([]protoimpl.MessageInfo, 24)
 var file_order_management_proto_goTypes = []interface{}{
-	(*Order)(nil),                // 0: ecommerce.Order
-	(*CombinedShipment)(nil),     // 1: ecommerce.CombinedShipment
-	(*wrappers.StringValue)(nil), // 2: google.protobuf.StringValue
+	(OrderStatus)(0),               // 0: ecommerce.OrderStatus
+	(StockState)(0),                // 1: ecommerce.StockState
+	(OrderPriority)(0),             // 2: ecommerce.OrderPriority
+	(ShipmentStatus)(0),            // 3: ecommerce.ShipmentStatus
+	(SortOrder)(0),                 // 4: ecommerce.SortOrder
+	(OrderEventType)(0),            // 5: ecommerce.OrderEventType
+	(ItemsPatchMode)(0),            // 6: ecommerce.ItemsPatchMode
+	(OrderFileFormat)(0),           // 7: ecommerce.OrderFileFormat
+	(ImportConflictPolicy)(0),      // 8: ecommerce.ImportConflictPolicy
+	(AuditActorSource)(0),          // 9: ecommerce.AuditActorSource
+	(DomainEventType)(0),           // 10: ecommerce.DomainEventType
+	(*Order)(nil),                  // 11: ecommerce.Order
+	(*Money)(nil),                  // 12: ecommerce.Money
+	(*CombinedShipment)(nil),       // 13: ecommerce.CombinedShipment
+	(*OrderResult)(nil),            // 14: ecommerce.OrderResult
+	(*ProcessOrdersResponse)(nil),  // 15: ecommerce.ProcessOrdersResponse
+	(*SearchOrdersRequest)(nil),    // 16: ecommerce.SearchOrdersRequest
+	(*TransitionOrderRequest)(nil), // 17: ecommerce.TransitionOrderRequest
+	(*WatchOrdersRequest)(nil),     // 18: ecommerce.WatchOrdersRequest
+	(*OrderEvent)(nil),             // 19: ecommerce.OrderEvent
+	(*CancelOrderRequest)(nil),     // 20: ecommerce.CancelOrderRequest
+	(*DeleteOrderRequest)(nil),     // 21: ecommerce.DeleteOrderRequest
+	(*PatchOrderRequest)(nil),      // 22: ecommerce.PatchOrderRequest
+	(*UpdateOrderAck)(nil),         // 23: ecommerce.UpdateOrderAck
+	(*ListShipmentsRequest)(nil),   // 24: ecommerce.ListShipmentsRequest
+	(*ExportOrdersRequest)(nil),    // 25: ecommerce.ExportOrdersRequest
+	(*ExportOrdersResponse)(nil),   // 26: ecommerce.ExportOrdersResponse
+	(*ImportOptions)(nil),          // 27: ecommerce.ImportOptions
+	(*ImportOrdersRequest)(nil),    // 28: ecommerce.ImportOrdersRequest
+	(*ImportError)(nil),            // 29: ecommerce.ImportError
+	(*ImportOrdersSummary)(nil),    // 30: ecommerce.ImportOrdersSummary
+	(*AuditActor)(nil),             // 31: ecommerce.AuditActor
+	(*FieldChange)(nil),            // 32: ecommerce.FieldChange
+	(*AuditEntry)(nil),             // 33: ecommerce.AuditEntry
+	(*DomainEvent)(nil),            // 34: ecommerce.DomainEvent
+	(*timestamp.Timestamp)(nil),    // 35: google.protobuf.Timestamp
+	(*status.Status)(nil),          // 36: google.rpc.Status
+	(*wrappers.FloatValue)(nil),    // 37: google.protobuf.FloatValue
+	(*fieldmaskpb.FieldMask)(nil),  // 38: google.protobuf.FieldMask
+	(*wrappers.StringValue)(nil),   // 39: google.protobuf.StringValue
 }
 var file_order_management_proto_depIdxs = []int32{
-	0, // 0: ecommerce.CombinedShipment.ordersList:type_name -> ecommerce.Order
-	0, // 1: ecommerce.OrderManagement.addOrder:input_type -> ecommerce.Order
-	2, // 2: ecommerce.OrderManagement.getOrder:input_type -> google.protobuf.StringValue
-	2, // 3: ecommerce.OrderManagement.searchOrders:input_type -> google.protobuf.StringValue
-	0, // 4: ecommerce.OrderManagement.updateOrders:input_type -> ecommerce.Order
-	2, // 5: ecommerce.OrderManagement.processOrders:input_type -> google.protobuf.StringValue
-	2, // 6: ecommerce.OrderManagement.addOrder:output_type -> google.protobuf.StringValue
-	0, // 7: ecommerce.OrderManagement.getOrder:output_type -> ecommerce.Order
-	0, // 8: ecommerce.OrderManagement.searchOrders:output_type -> ecommerce.Order
-	2, // 9: ecommerce.OrderManagement.updateOrders:output_type -> google.protobuf.StringValue
-	1, // 10: ecommerce.OrderManagement.processOrders:output_type -> ecommerce.CombinedShipment
-	6, // [6:11] is the sub-list for method output_type
-	1, // [1:6] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	0,  // 0: ecommerce.Order.status:type_name -> ecommerce.OrderStatus
+	35, // 1: ecommerce.Order.createTime:type_name -> google.protobuf.Timestamp
+	12, // 2: ecommerce.Order.exactPrice:type_name -> ecommerce.Money
+	2,  // 3: ecommerce.Order.priority:type_name -> ecommerce.OrderPriority
+	1,  // 4: ecommerce.Order.stock:type_name -> ecommerce.StockState
+	11, // 5: ecommerce.CombinedShipment.ordersList:type_name -> ecommerce.Order
+	3,  // 6: ecommerce.CombinedShipment.shipmentStatus:type_name -> ecommerce.ShipmentStatus
+	35, // 7: ecommerce.CombinedShipment.createTime:type_name -> google.protobuf.Timestamp
+	35, // 8: ecommerce.CombinedShipment.updateTime:type_name -> google.protobuf.Timestamp
+	36, // 9: ecommerce.OrderResult.error:type_name -> google.rpc.Status
+	14, // 10: ecommerce.ProcessOrdersResponse.result:type_name -> ecommerce.OrderResult
+	13, // 11: ecommerce.ProcessOrdersResponse.shipment:type_name -> ecommerce.CombinedShipment
+	37, // 12: ecommerce.SearchOrdersRequest.minPrice:type_name -> google.protobuf.FloatValue
+	37, // 13: ecommerce.SearchOrdersRequest.maxPrice:type_name -> google.protobuf.FloatValue
+	0,  // 14: ecommerce.SearchOrdersRequest.statuses:type_name -> ecommerce.OrderStatus
+	35, // 15: ecommerce.SearchOrdersRequest.createdAfter:type_name -> google.protobuf.Timestamp
+	4,  // 16: ecommerce.SearchOrdersRequest.sortOrder:type_name -> ecommerce.SortOrder
+	12, // 17: ecommerce.SearchOrdersRequest.minExactPrice:type_name -> ecommerce.Money
+	12, // 18: ecommerce.SearchOrdersRequest.maxExactPrice:type_name -> ecommerce.Money
+	0,  // 19: ecommerce.TransitionOrderRequest.status:type_name -> ecommerce.OrderStatus
+	5,  // 20: ecommerce.OrderEvent.type:type_name -> ecommerce.OrderEventType
+	11, // 21: ecommerce.OrderEvent.order:type_name -> ecommerce.Order
+	11, // 22: ecommerce.PatchOrderRequest.order:type_name -> ecommerce.Order
+	38, // 23: ecommerce.PatchOrderRequest.updateMask:type_name -> google.protobuf.FieldMask
+	6,  // 24: ecommerce.PatchOrderRequest.itemsMode:type_name -> ecommerce.ItemsPatchMode
+	36, // 25: ecommerce.UpdateOrderAck.status:type_name -> google.rpc.Status
+	3,  // 26: ecommerce.ListShipmentsRequest.statuses:type_name -> ecommerce.ShipmentStatus
+	7,  // 27: ecommerce.ExportOrdersRequest.format:type_name -> ecommerce.OrderFileFormat
+	7,  // 28: ecommerce.ImportOptions.format:type_name -> ecommerce.OrderFileFormat
+	8,  // 29: ecommerce.ImportOptions.conflictPolicy:type_name -> ecommerce.ImportConflictPolicy
+	27, // 30: ecommerce.ImportOrdersRequest.options:type_name -> ecommerce.ImportOptions
+	36, // 31: ecommerce.ImportError.status:type_name -> google.rpc.Status
+	29, // 32: ecommerce.ImportOrdersSummary.errors:type_name -> ecommerce.ImportError
+	9,  // 33: ecommerce.AuditActor.source:type_name -> ecommerce.AuditActorSource
+	5,  // 34: ecommerce.AuditEntry.type:type_name -> ecommerce.OrderEventType
+	31, // 35: ecommerce.AuditEntry.actor:type_name -> ecommerce.AuditActor
+	35, // 36: ecommerce.AuditEntry.time:type_name -> google.protobuf.Timestamp
+	32, // 37: ecommerce.AuditEntry.changes:type_name -> ecommerce.FieldChange
+	10, // 38: ecommerce.DomainEvent.type:type_name -> ecommerce.DomainEventType
+	35, // 39: ecommerce.DomainEvent.time:type_name -> google.protobuf.Timestamp
+	11, // 40: ecommerce.DomainEvent.order:type_name -> ecommerce.Order
+	13, // 41: ecommerce.DomainEvent.shipment:type_name -> ecommerce.CombinedShipment
+	11, // 42: ecommerce.OrderManagement.addOrder:input_type -> ecommerce.Order
+	39, // 43: ecommerce.OrderManagement.getOrder:input_type -> google.protobuf.StringValue
+	16, // 44: ecommerce.OrderManagement.searchOrders:input_type -> ecommerce.SearchOrdersRequest
+	11, // 45: ecommerce.OrderManagement.updateOrders:input_type -> ecommerce.Order
+	39, // 46: ecommerce.OrderManagement.processOrders:input_type -> google.protobuf.StringValue
+	17, // 47: ecommerce.OrderManagement.transitionOrder:input_type -> ecommerce.TransitionOrderRequest
+	18, // 48: ecommerce.OrderManagement.watchOrders:input_type -> ecommerce.WatchOrdersRequest
+	20, // 49: ecommerce.OrderManagement.cancelOrder:input_type -> ecommerce.CancelOrderRequest
+	21, // 50: ecommerce.OrderManagement.deleteOrder:input_type -> ecommerce.DeleteOrderRequest
+	22, // 51: ecommerce.OrderManagement.patchOrder:input_type -> ecommerce.PatchOrderRequest
+	11, // 52: ecommerce.OrderManagement.updateOrdersV2:input_type -> ecommerce.Order
+	39, // 53: ecommerce.OrderManagement.getShipment:input_type -> google.protobuf.StringValue
+	24, // 54: ecommerce.OrderManagement.listShipments:input_type -> ecommerce.ListShipmentsRequest
+	39, // 55: ecommerce.OrderManagement.watchShipment:input_type -> google.protobuf.StringValue
+	25, // 56: ecommerce.OrderManagement.exportOrders:input_type -> ecommerce.ExportOrdersRequest
+	28, // 57: ecommerce.OrderManagement.importOrders:input_type -> ecommerce.ImportOrdersRequest
+	39, // 58: ecommerce.OrderManagement.getOrderHistory:input_type -> google.protobuf.StringValue
+	39, // 59: ecommerce.OrderManagement.addOrder:output_type -> google.protobuf.StringValue
+	11, // 60: ecommerce.OrderManagement.getOrder:output_type -> ecommerce.Order
+	11, // 61: ecommerce.OrderManagement.searchOrders:output_type -> ecommerce.Order
+	39, // 62: ecommerce.OrderManagement.updateOrders:output_type -> google.protobuf.StringValue
+	15, // 63: ecommerce.OrderManagement.processOrders:output_type -> ecommerce.ProcessOrdersResponse
+	11, // 64: ecommerce.OrderManagement.transitionOrder:output_type -> ecommerce.Order
+	19, // 65: ecommerce.OrderManagement.watchOrders:output_type -> ecommerce.OrderEvent
+	11, // 66: ecommerce.OrderManagement.cancelOrder:output_type -> ecommerce.Order
+	39, // 67: ecommerce.OrderManagement.deleteOrder:output_type -> google.protobuf.StringValue
+	11, // 68: ecommerce.OrderManagement.patchOrder:output_type -> ecommerce.Order
+	23, // 69: ecommerce.OrderManagement.updateOrdersV2:output_type -> ecommerce.UpdateOrderAck
+	13, // 70: ecommerce.OrderManagement.getShipment:output_type -> ecommerce.CombinedShipment
+	13, // 71: ecommerce.OrderManagement.listShipments:output_type -> ecommerce.CombinedShipment
+	13, // 72: ecommerce.OrderManagement.watchShipment:output_type -> ecommerce.CombinedShipment
+	26, // 73: ecommerce.OrderManagement.exportOrders:output_type -> ecommerce.ExportOrdersResponse
+	30, // 74: ecommerce.OrderManagement.importOrders:output_type -> ecommerce.ImportOrdersSummary
+	33, // 75: ecommerce.OrderManagement.getOrderHistory:output_type -> ecommerce.AuditEntry
+	59, // [59:76] is the sub-list for method output_type
+	42, // [42:59] is the sub-list for method input_type
+	42, // [42:42] is the sub-list for extension type_name
+	42, // [42:42] is the sub-list for extension extendee
+	0,  // [0:42] is the sub-list for field type_name
 }
 
 func init() { file_order_management_proto_init() }
@@ -271,6 +3177,18 @@ func file_order_management_proto_init() {
 			}
 		}
 		file_order_management_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Money); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*CombinedShipment); i {
 			case 0:
 				return &v.state
@@ -282,19 +3200,284 @@ func file_order_management_proto_init() {
 				return nil
 			}
 		}
+		file_order_management_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*OrderResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ProcessOrdersResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SearchOrdersRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TransitionOrderRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WatchOrdersRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*OrderEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CancelOrderRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteOrderRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PatchOrderRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UpdateOrderAck); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListShipmentsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ExportOrdersRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ExportOrdersResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ImportOptions); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ImportOrdersRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ImportError); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ImportOrdersSummary); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AuditActor); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FieldChange); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[22].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AuditEntry); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_order_management_proto_msgTypes[23].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DomainEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_order_management_proto_msgTypes[3].OneofWrappers = []interface{}{
+		(*OrderResult_ShipmentId)(nil),
+		(*OrderResult_Error)(nil),
+	}
+	file_order_management_proto_msgTypes[4].OneofWrappers = []interface{}{
+		(*ProcessOrdersResponse_Result)(nil),
+		(*ProcessOrdersResponse_Shipment)(nil),
+	}
+	file_order_management_proto_msgTypes[17].OneofWrappers = []interface{}{
+		(*ImportOrdersRequest_Options)(nil),
+		(*ImportOrdersRequest_Data)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_order_management_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   2,
+			NumEnums:      11,
+			NumMessages:   24,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_order_management_proto_goTypes,
 		DependencyIndexes: file_order_management_proto_depIdxs,
+		EnumInfos:         file_order_management_proto_enumTypes,
 		MessageInfos:      file_order_management_proto_msgTypes,
 	}.Build()
 	File_order_management_proto = out.File
@@ -4,9 +4,14 @@ import (
 	"context"
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"io"
 	"log"
 	pb "ordermgt/client/ecommerce"
+	"os"
 	"time"
 )
 
@@ -24,12 +29,41 @@ func main() {
 
 	// 创建到服务器端的连接并初始化调用服务的客户端存根。
 	client := pb.NewOrderManagementClient(conn)
+
+	// 带子命令运行时只执行子命令，例如：client export -out orders.csv
+	if len(os.Args) > 1 {
+		if err := runCommand(client, os.Args[1], os.Args[2:]); err != nil {
+			log.Fatalf("%s failed: %v", os.Args[1], err)
+		}
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
+	// 通过元数据声明调用方的身份，服务器端把它记录在订单的审计历史中。
+	ctx = metadata.AppendToOutgoingContext(ctx, "caller-id", "ordermgt-client")
+
+	// Watch Orders : Server streaming scenario
+	// 在后台订阅订单的变更事件，下面各个调用对订单的修改都会以事件的形式推送过来。
+	// 断线后可以把最后收到的revision作为ResumeRevision重新订阅，补齐错过的事件。
+	watchStream, err := client.WatchOrders(ctx, &pb.WatchOrdersRequest{})
+	if err == nil {
+		go func() {
+			for {
+				event, err := watchStream.Recv()
+				if err != nil {
+					return
+				}
+				log.Printf("Order Event -> : %d %s %s", event.Revision, event.Type, event.Order.GetId())
+			}
+		}()
+	}
 
 	// Add Order
 	order1 := pb.Order{Id: "101", Items: []string{"iPhone XS", "Mac Book Pro"}, Destination: "San Jose, CA", Price: 2300.00}
-	res, _ := client.AddOrder(ctx, &order1)
+	// 带上幂等键，超时后使用相同的键重试时，服务器端不会重复添加订单，而是返回第一次的响应。
+	addCtx := metadata.AppendToOutgoingContext(ctx, "idempotency-key", "add-order-101")
+	res, _ := client.AddOrder(addCtx, &order1)
 	if res != nil {
 		log.Print("AddOrder Response -> ", res.Value)
 	}
@@ -40,21 +74,68 @@ func main() {
 	retrievedOrder, err := client.GetOrder(ctx, &wrapper.StringValue{Value: "106"})
 	log.Print("GetOrder Response -> : ", retrievedOrder)
 
+	// Transition Order
+	// 按照订单生命周期确认订单，非法的状态迁移会返回FailedPrecondition错误。
+	// 带上读取到的订单版本，如果订单在此期间被其他客户端修改，会返回Aborted错误。
+	confirmedOrder, err := client.TransitionOrder(ctx, &pb.TransitionOrderRequest{Id: "106", Status: pb.OrderStatus_CONFIRMED, ExpectedVersion: retrievedOrder.GetVersion()})
+	if err != nil {
+		log.Printf("TransitionOrder Error -> : %v", err)
+	} else {
+		log.Print("TransitionOrder Response -> : ", confirmedOrder)
+	}
+
+	// Patch Order
+	// 只修改目的地，不需要重新发送订单的条目和价格。
+	patchedOrder, err := client.PatchOrder(ctx, &pb.PatchOrderRequest{
+		Order:      &pb.Order{Id: "106", Destination: "Mountain View, CA"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"destination"}},
+	})
+	if err != nil {
+		log.Printf("PatchOrder Error -> : %v", err)
+	} else {
+		log.Print("PatchOrder Response -> : ", patchedOrder)
+	}
+
+	// Cancel Order
+	// 取消订单时必须给出原因，已取消的订单不会再出现在默认的搜索结果中。
+	cancelledOrder, err := client.CancelOrder(ctx, &pb.CancelOrderRequest{Id: "105", Reason: "Customer changed their mind"})
+	if err != nil {
+		log.Printf("CancelOrder Error -> : %v", err)
+	} else {
+		log.Print("CancelOrder Response -> : ", cancelledOrder)
+	}
+
+	// Get Order History : Server streaming scenario
+	// 订单106上面的确认和部分更新都会出现在审计历史中，每条记录带有调用方和变化的字段。
+	historyStream, err := client.GetOrderHistory(ctx, &wrapper.StringValue{Value: "106"})
+	if err == nil {
+		for {
+			entry, err := historyStream.Recv()
+			if err != nil {
+				break
+			}
+			log.Printf("Order History -> : #%d %s by %s via %s : %v", entry.Sequence, entry.Type, entry.Actor.GetId(), entry.Method, entry.Changes)
+		}
+	}
+
 	// Search Order : Server streaming scenario
 	// SearchOrders 方法返回OrderManagenent_SearchOrdersClient 的客户端流，它有一个名为Recv的方法。
-	searchStream, _ := client.SearchOrders(ctx, &wrapper.StringValue{Value: "Google"})
+	// 每次请求最多返回PageSize个订单，下一页的令牌在流结束后从trailer元数据中读取。
+	// searchPage 在流中途断开时用最后收到的订单的searchCursor重新连接，从下一个订单继续。
+	searchReq := &pb.SearchOrdersRequest{Item: "Google", SortOrder: pb.SortOrder_PRICE_DESC, PageSize: 2}
 	for {
-		// 调用客户端流的Recv方法，逐个检索Order响应。
-		searchOrder, err := searchStream.Recv()
-		// 当发现流结束的时候，Recv会返回io.EOF。
-		if err == io.EOF {
-			log.Print("EOF")
+		nextPageToken, err := searchPage(ctx, client, searchReq, func(searchOrder *pb.Order) error {
+			log.Print("Search Result : ", searchOrder)
+			return nil
+		})
+		if err != nil {
+			log.Printf("SearchOrders Error -> : %v", err)
 			break
 		}
-
-		if err == nil {
-			log.Print("Search Result : ", searchOrder)
+		if nextPageToken == "" {
+			break
 		}
+		searchReq.PageToken = nextPageToken
 	}
 
 	// =========================================
@@ -97,11 +178,40 @@ func main() {
 	}
 	log.Printf("Update Orders Res : %s", updateRes)
 
+	// Update Orders V2 : Bi-di streaming scenario
+	// 每个订单都会得到一个确认，其中包含订单的新版本或者更新失败的原因，只需要重试失败的订单。
+	updateStreamV2, err := client.UpdateOrdersV2(ctx)
+	if err != nil {
+		log.Fatalf("%v.UpdateOrdersV2(_) = _, %v", client, err)
+	}
+	for _, order := range []*pb.Order{{Id: "102", Items: updOrder1.Items, Destination: "Mountain View, CA", Price: 1150.00}, {Destination: "San Jose, CA"}} {
+		if err := updateStreamV2.Send(order); err != nil {
+			log.Fatalf("%v.Send(%v) = %v", updateStreamV2, order, err)
+		}
+	}
+	updateStreamV2.CloseSend()
+	for {
+		ack, err := updateStreamV2.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatalf("%v.Recv() got error %v", updateStreamV2, err)
+		}
+		if st := status.FromProto(ack.Status); st.Code() != codes.OK {
+			log.Printf("Update Orders V2 : order #%d %q failed : %v", ack.Index, ack.OrderId, st.Err())
+		} else {
+			log.Printf("Update Orders V2 : order %s updated to version %d", ack.OrderId, ack.Version)
+		}
+	}
+
 	// =========================================
 	// Process Order : Bi-di streaming scenario
 	// 当客户端通过OrderManagement对象调用ProcessOrders方法时，它会得到一个对流的引用(streamProcOrder)，
 	// 这个引用可以用来发送消息到服务器端，也能读取来自服务器端的消息。
-	streamProcOrder, err := client.ProcessOrders(ctx)
+	// 通过元数据覆盖服务器端的批处理策略：每批最多2个订单，不足一批时最多等待500毫秒就发货。
+	procCtx := metadata.AppendToOutgoingContext(ctx, "batch-max-size", "2", "batch-max-wait", "500ms")
+	streamProcOrder, err := client.ProcessOrders(procCtx)
 	if err != nil {
 		log.Fatalf("%v.ProcessOrders(_) = _, %v", client, err)
 	}
@@ -136,6 +246,24 @@ func main() {
 	}
 	channel <- struct{}{}
 
+	// List Shipments : Server streaming scenario
+	// 发货组合发出后仍然保存在服务器端，可以按目的地和状态查询，也可以用getShipment按ID检索。
+	shipmentStream, err := client.ListShipments(ctx, &pb.ListShipmentsRequest{Destination: "Mountain View, CA"})
+	if err != nil {
+		log.Fatalf("%v.ListShipments(_) = _, %v", client, err)
+	}
+	for {
+		shipment, err := shipmentStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("ListShipments Error -> : %v", err)
+			break
+		}
+		log.Printf("Shipment %s : %s, %d orders", shipment.Id, shipment.ShipmentStatus, len(shipment.OrdersList))
+	}
+
 	/*客户端可以并发读取和写人同一个流， 输入流和输出流可以独立进行操作。
 	这里所展示的是稍微复杂的示例，它展现了双向流RPC模式的威力。
 	流的操作完全独立，客户端和服务器端可以按照任意顺序进行读取和写人，理解这一点非常重要。
@@ -145,12 +273,25 @@ func main() {
 func asncClientBidirectionalRPC(streamProcOrder pb.OrderManagement_ProcessOrdersClient, c chan struct{}) {
 	for {
 		// 在客户端读取服务的消息。
-		combinedShipment, errProcOrder := streamProcOrder.Recv()
+		procRes, errProcOrder := streamProcOrder.Recv()
 		// 该条件探测流是否已经结束。
 		if errProcOrder == io.EOF {
 			break
 		}
-		log.Printf("Combined shipment : %v", combinedShipment.OrdersList)
+		if errProcOrder != nil {
+			log.Printf("ProcessOrders Error -> : %v", errProcOrder)
+			break
+		}
+		// 每个订单ID都有一个处理结果：分配到的发货组合，或者无法处理的原因。
+		if result := procRes.GetResult(); result != nil {
+			if result.GetError() != nil {
+				log.Printf("Order %s rejected : %s", result.OrderId, status.FromProto(result.GetError()).Message())
+			} else {
+				log.Printf("Order %s -> %s", result.OrderId, result.GetShipmentId())
+			}
+			continue
+		}
+		log.Printf("Combined shipment %s : %v", procRes.GetShipment().Id, procRes.GetShipment().OrdersList)
 	}
 	<-c
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	pb "ordermgt/client/ecommerce"
+)
+
+const (
+	// maxSearchResumes 是一页搜索结果中断后最多重新连接的次数。
+	maxSearchResumes = 5
+	// searchResumeBackoff 是第一次重新连接之前等待的时间，之后每次加倍。
+	searchResumeBackoff = 100 * time.Millisecond
+	// 服务器端通过这个trailer元数据键返回下一页的令牌。
+	nextPageTokenKey = "next-page-token"
+)
+
+// searchPage 调用searchOrders读取一页结果，并把每个订单交给fn，返回下一页的令牌（没有更多结果时为空）。
+// 流因为连接断开而失败时，用最后收到的订单的searchCursor重新调用，服务器端从下一个订单继续，
+// 因此fn收到的订单与没有断线时完全相同，不会重复或遗漏。
+func searchPage(ctx context.Context, client pb.OrderManagementClient, req *pb.SearchOrdersRequest, fn func(order *pb.Order) error) (string, error) {
+	req = proto.Clone(req).(*pb.SearchOrdersRequest)
+	var opts []grpc.CallOption
+	backoff := searchResumeBackoff
+	for resumes := 0; ; resumes++ {
+		next, cursor, err := readSearchStream(ctx, client, req, fn, opts...)
+		if err == nil || status.Code(err) != codes.Unavailable || resumes == maxSearchResumes {
+			return next, err
+		}
+		if cursor != "" {
+			req.PageToken, req.ResumeCursor = "", cursor
+		}
+		log.Printf("searchOrders interrupted: %v, resuming in %v", err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return "", status.FromContextError(ctx.Err()).Err()
+		}
+		backoff *= 2
+		// 重新连接时等待连接恢复，而不是在连接断开期间立即失败。
+		opts = []grpc.CallOption{grpc.WaitForReady(true)}
+	}
+}
+
+// readSearchStream 读取一次searchOrders调用的结果，返回下一页的令牌和最后收到的订单的searchCursor。
+func readSearchStream(ctx context.Context, client pb.OrderManagementClient, req *pb.SearchOrdersRequest, fn func(order *pb.Order) error, opts ...grpc.CallOption) (string, string, error) {
+	cursor := ""
+	var trailer metadata.MD
+	stream, err := client.SearchOrders(ctx, req, append(opts, grpc.Trailer(&trailer))...)
+	if err != nil {
+		return "", cursor, err
+	}
+	for {
+		order, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", cursor, err
+		}
+		if err := fn(order); err != nil {
+			return "", cursor, err
+		}
+		cursor = order.SearchCursor
+	}
+	if tokens := trailer.Get(nextPageTokenKey); len(tokens) > 0 {
+		return tokens[0], cursor, nil
+	}
+	return "", cursor, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/grpc/status"
+	pb "ordermgt/client/ecommerce"
+)
+
+// importChunkSize 是import子命令每条消息中数据的大小。
+const importChunkSize = 32 << 10
+
+// runCommand 执行命令行子命令：
+//
+//	export -out FILE [-format jsonl|csv]
+//	import -in FILE [-format jsonl|csv] [-conflict fail|skip|overwrite] [-dry_run]
+//
+// 没有指定-format时按文件扩展名选择格式，默认为jsonl。
+func runCommand(client pb.OrderManagementClient, name string, args []string) error {
+	switch name {
+	case "export":
+		return exportCommand(client, args)
+	case "import":
+		return importCommand(client, args)
+	}
+	return fmt.Errorf("unknown command %q, expected export or import", name)
+}
+
+// parseFileFormat 解析-format参数，参数为空时按文件扩展名选择格式。
+func parseFileFormat(format, path string) (pb.OrderFileFormat, error) {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+		if format != "csv" {
+			format = "jsonl"
+		}
+	}
+	v, ok := pb.OrderFileFormat_value[strings.ToUpper(format)]
+	if !ok {
+		return 0, fmt.Errorf("unknown format %q, expected jsonl or csv", format)
+	}
+	return pb.OrderFileFormat(v), nil
+}
+
+func exportCommand(client pb.OrderManagementClient, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	out := fs.String("out", "", "file to write the exported orders to")
+	format := fs.String("format", "", "file format, jsonl or csv (default: from the file extension)")
+	fs.Parse(args)
+	if *out == "" {
+		return fmt.Errorf("export: -out is required")
+	}
+	fileFormat, err := parseFileFormat(*format, *out)
+	if err != nil {
+		return err
+	}
+
+	stream, err := client.ExportOrders(context.Background(), &pb.ExportOrdersRequest{Format: fileFormat})
+	if err != nil {
+		return err
+	}
+	// 先写入临时文件，导出完整后再改名，失败时不会留下不完整的文件。
+	tmp := *out + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	var size int
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := f.Write(res.Data); err != nil {
+			f.Close()
+			return err
+		}
+		size += len(res.Data)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, *out); err != nil {
+		return err
+	}
+	log.Printf("Exported orders to %s (%s, %d bytes)", *out, fileFormat, size)
+	return nil
+}
+
+func importCommand(client pb.OrderManagementClient, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	in := fs.String("in", "", "file to import orders from")
+	format := fs.String("format", "", "file format, jsonl or csv (default: from the file extension)")
+	conflict := fs.String("conflict", "fail", "what to do with orders that already exist: fail, skip or overwrite")
+	dryRun := fs.Bool("dry_run", false, "only validate the file and print the summary, do not store any order")
+	fs.Parse(args)
+	if *in == "" {
+		return fmt.Errorf("import: -in is required")
+	}
+	fileFormat, err := parseFileFormat(*format, *in)
+	if err != nil {
+		return err
+	}
+	policy, ok := pb.ImportConflictPolicy_value["CONFLICT_"+strings.ToUpper(*conflict)]
+	if !ok {
+		return fmt.Errorf("unknown conflict policy %q, expected fail, skip or overwrite", *conflict)
+	}
+	f, err := os.Open(*in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stream, err := client.ImportOrders(context.Background())
+	if err != nil {
+		return err
+	}
+	options := &pb.ImportOptions{Format: fileFormat, ConflictPolicy: pb.ImportConflictPolicy(policy), DryRun: *dryRun}
+	if err := stream.Send(&pb.ImportOrdersRequest{Request: &pb.ImportOrdersRequest_Options{Options: options}}); err != nil {
+		return err
+	}
+	buf := make([]byte, importChunkSize)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&pb.ImportOrdersRequest{Request: &pb.ImportOrdersRequest_Data{Data: buf[:n]}}); err != nil {
+				// 服务器端提前结束了流，真正的错误由CloseAndRecv返回。
+				break
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	summary, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Import Summary -> : received %d, created %d, overwritten %d, skipped %d, failed %d, committed %v",
+		summary.Received, summary.Created, summary.Overwritten, summary.Skipped, summary.Failed, summary.Committed)
+	for _, e := range summary.Errors {
+		log.Printf("Import Error -> : record %d, order %q : %s", e.Record, e.OrderId, status.FromProto(e.Status).Message())
+	}
+	if !summary.Committed && !*dryRun {
+		return fmt.Errorf("import aborted, no order was stored")
+	}
+	return nil
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	pb "ordermgt/service/ecommerce"
+)
+
+const (
+	auditLogFileName = "audit.log"
+	// callerIDKey 是客户端声明自己身份的元数据键。没有校验过的TLS客户端证书时，审计记录使用这个身份。
+	callerIDKey = "caller-id"
+)
+
+// orderAuditor 由记录订单审计历史的存储实现，例如auditedOrderStore。
+type orderAuditor interface {
+	// ForCaller 返回一个存储视图，通过它提交的变更以ctx中调用方的身份记录。
+	ForCaller(ctx context.Context) OrderStore
+	// History 按时间顺序返回键为key的订单的审计记录，键由orderKey生成。
+	History(key string) []*pb.AuditEntry
+}
+
+// callerFromContext 确定调用方的身份：优先使用经过校验的TLS客户端证书的CommonName，
+// 其次使用元数据caller-id中声明的身份。
+func callerFromContext(ctx context.Context) *pb.AuditActor {
+	actor := &pb.AuditActor{Source: pb.AuditActorSource_ACTOR_ANONYMOUS}
+	if p, ok := peer.FromContext(ctx); ok {
+		if p.Addr != nil {
+			actor.PeerAddress = p.Addr.String()
+		}
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 && len(info.State.VerifiedChains[0]) > 0 {
+			actor.Id = info.State.VerifiedChains[0][0].Subject.CommonName
+			actor.Source = pb.AuditActorSource_ACTOR_TLS
+			return actor
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(callerIDKey); len(ids) > 0 && ids[0] != "" {
+			actor.Id = ids[0]
+			actor.Source = pb.AuditActorSource_ACTOR_METADATA
+		}
+	}
+	return actor
+}
+
+// adminPolicy 确定哪些调用方可以调用管理员接口（例如deleteOrder）。
+// 默认只接受经过校验的TLS客户端证书中的身份，元数据caller-id是客户端自己声明的，
+// 只有trustMetadata为true（例如没有TLS的开发环境）时才被接受。
+type adminPolicy struct {
+	ids           map[string]bool
+	trustMetadata bool
+}
+
+func newAdminPolicy(ids []string, trustMetadata bool) *adminPolicy {
+	p := &adminPolicy{ids: make(map[string]bool), trustMetadata: trustMetadata}
+	for _, id := range ids {
+		if id != "" {
+			p.ids[id] = true
+		}
+	}
+	return p
+}
+
+// check 在调用方不是管理员时返回PermissionDenied。policy为nil时没有任何管理员。
+func (p *adminPolicy) check(ctx context.Context, method string) error {
+	actor := callerFromContext(ctx)
+	if p != nil && p.ids[actor.Id] && (actor.Source == pb.AuditActorSource_ACTOR_TLS || p.trustMetadata && actor.Source == pb.AuditActorSource_ACTOR_METADATA) {
+		return nil
+	}
+	return status.Errorf(codes.PermissionDenied, "%s requires an administrator, caller %q is not one", method, actor.Id)
+}
+
+// auditLog 保存所有订单的审计记录。打开了日志文件时，记录在事务提交之前追加到日志并fsync，启动时重放日志。
+// 同时提交的事务的记录由其中一个事务一次写入并fsync（组提交），写入文件时不持有任何锁。
+// 审计记录写入后不再修改，因此日志不做压缩。
+type auditLog struct {
+	mu sync.Mutex
+	// entries 按订单的键（见orderKey）保存记录，不同租户的同一个订单ID的历史互不混杂。
+	entries map[string][]*pb.AuditEntry
+
+	// fileMu 保护下面的字段，written在一批记录写入完成时广播。
+	fileMu  sync.Mutex
+	written *sync.Cond
+	file    *os.File
+	size    int64
+	// queue 是等待写入的记录，flushing为true时有一个事务正在写入和fsync日志。
+	queue    []*auditWrite
+	flushing bool
+}
+
+// auditWrite 是一个事务等待组提交的审计记录。
+type auditWrite struct {
+	data []byte
+	done bool
+	err  error
+}
+
+func newAuditLog() *auditLog {
+	l := &auditLog{entries: make(map[string][]*pb.AuditEntry)}
+	l.written = sync.NewCond(&l.fileMu)
+	return l
+}
+
+func openAuditLog(dir string) (*auditLog, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	l := newAuditLog()
+	path := filepath.Join(dir, auditLogFileName)
+	if err := l.replay(path); err != nil {
+		return nil, err
+	}
+	var err error
+	if l.file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+		return nil, err
+	}
+	info, err := l.file.Stat()
+	if err != nil {
+		l.file.Close()
+		return nil, err
+	}
+	l.size = info.Size()
+	return l, nil
+}
+
+// replay 读取日志中的所有记录。末尾残缺的记录被截断。
+// 事务在写入审计记录之后仍可能因为订单日志写入失败而放弃，之后同一个订单的记录会使用相同的序号，
+// 因此重放时按记录在日志中的位置重新编号。
+func (l *auditLog) replay(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		payload, n, err := readFrame(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err == nil {
+			entry := &pb.AuditEntry{}
+			if err = proto.Unmarshal(payload, entry); err == nil {
+				key := orderKey(entry.TenantId, entry.OrderId)
+				entry.Sequence = int64(len(l.entries[key])) + 1
+				l.entries[key] = append(l.entries[key], entry)
+				offset += n
+				continue
+			}
+		}
+		log.Printf("discarding audit log after offset %d : %v", offset, err)
+		return os.Truncate(path, offset)
+	}
+}
+
+func (l *auditLog) Close() error {
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+	for l.flushing {
+		l.written.Wait()
+	}
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// write 为记录分配订单内的序号，把记录写入日志文件并等待fsync完成，但还不加入内存中的历史。
+// 调用方持有记录涉及的订单的事务锁，同一个订单的记录不会同时写入，序号因此不会冲突。
+func (l *auditLog) write(entries []*pb.AuditEntry) error {
+	l.mu.Lock()
+	for _, entry := range entries {
+		entry.Sequence = int64(len(l.entries[orderKey(entry.TenantId, entry.OrderId)])) + 1
+	}
+	l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		data, err := proto.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf.Write(encodeFrame(data))
+	}
+	w := &auditWrite{data: buf.Bytes()}
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+	l.queue = append(l.queue, w)
+	for !w.done {
+		if l.flushing {
+			l.written.Wait()
+			continue
+		}
+		l.flushLocked()
+	}
+	return w.err
+}
+
+// flushLocked 把队列中的所有记录一次写入日志并fsync，写入期间释放fileMu。
+func (l *auditLog) flushLocked() {
+	batch := l.queue
+	l.queue = nil
+	l.flushing = true
+	var buf []byte
+	for _, w := range batch {
+		buf = append(buf, w.data...)
+	}
+	size := l.size
+	l.fileMu.Unlock()
+	_, err := l.file.Write(buf)
+	if err == nil {
+		err = l.file.Sync()
+	}
+	if err != nil {
+		// 去掉可能已经写入一半的记录，这些记录所属的事务都会被放弃。
+		l.file.Truncate(size)
+	}
+	l.fileMu.Lock()
+	l.flushing = false
+	if err == nil {
+		l.size += int64(len(buf))
+	}
+	for _, w := range batch {
+		w.done, w.err = true, err
+	}
+	l.written.Broadcast()
+}
+
+// add 把已经写入日志、所属事务已经提交的记录加入内存中的历史。
+func (l *auditLog) add(entries []*pb.AuditEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, entry := range entries {
+		key := orderKey(entry.TenantId, entry.OrderId)
+		l.entries[key] = append(l.entries[key], entry)
+	}
+}
+
+func (l *auditLog) history(key string) []*pb.AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := make([]*pb.AuditEntry, len(l.entries[key]))
+	for i, entry := range l.entries[key] {
+		entries[i] = proto.Clone(entry).(*pb.AuditEntry)
+	}
+	return entries
+}
+
+// auditedOrderStore 在OrderStore之上为每次提交的变更生成审计记录。
+// 直接使用这个存储的变更以服务器端自己的身份记录，handler通过ForCaller得到以调用方身份记录的视图。
+// 审计记录在事务提交之前写入审计日志，写入失败时事务被放弃，没有审计记录的变更不会生效；
+// 记录在事务提交之后、释放订单的锁之前加入历史（见OrderTxn.AfterCommit），同一个订单的审计记录的顺序与提交顺序一致。
+type auditedOrderStore struct {
+	OrderStore
+	log    *auditLog
+	actor  *pb.AuditActor
+	method string
+}
+
+func newAuditedOrderStore(store OrderStore, log *auditLog) *auditedOrderStore {
+	return &auditedOrderStore{
+		OrderStore: store,
+		log:        log,
+		actor:      &pb.AuditActor{Source: pb.AuditActorSource_ACTOR_SYSTEM},
+	}
+}
+
+func (s *auditedOrderStore) ForCaller(ctx context.Context) OrderStore {
+	method, _ := grpc.Method(ctx)
+	return &auditedOrderStore{OrderStore: s.OrderStore, log: s.log, actor: callerFromContext(ctx), method: method}
+}
+
+func (s *auditedOrderStore) History(key string) []*pb.AuditEntry {
+	return s.log.history(key)
+}
+
+func (s *auditedOrderStore) Put(order *pb.Order) error {
+	return s.Txn([]string{keyOf(order)}, func(tx OrderTxn) error {
+		return tx.Put(order)
+	})
+}
+
+func (s *auditedOrderStore) Delete(id string) (bool, error) {
+	existed := false
+	err := s.Txn([]string{id}, func(tx OrderTxn) error {
+		if _, existed = tx.Get(id); !existed {
+			return nil
+		}
+		return tx.Delete(id)
+	})
+	return existed, err
+}
+
+func (s *auditedOrderStore) Txn(keys []string, fn func(tx OrderTxn) error) error {
+	return s.OrderStore.Txn(keys, func(tx OrderTxn) error {
+		before := make(map[string]*pb.Order)
+		for _, key := range keys {
+			if order, ok := tx.Get(key); ok {
+				before[key] = order
+			}
+		}
+		rec := &recordingTxn{OrderTxn: tx}
+		if err := fn(rec); err != nil {
+			return err
+		}
+		entries := s.entries(netChanges(before, rec.ops))
+		if len(entries) == 0 {
+			return nil
+		}
+		// 订单日志在这之后写入失败时事务仍会被放弃，审计日志中会多出一条没有生效的记录，
+		// 这比生效的变更没有审计记录更可取。
+		if err := s.log.write(entries); err != nil {
+			return status.Errorf(codes.Unavailable, "failed to write audit log : %v", err)
+		}
+		tx.AfterCommit(func() { s.log.add(entries) })
+		return nil
+	})
+}
+
+// entries 为事务中每个订单的净变更生成一条审计记录。
+func (s *auditedOrderStore) entries(changes []orderChange) []*pb.AuditEntry {
+	now := timestamppb.Now()
+	var entries []*pb.AuditEntry
+	for _, change := range changes {
+		entry := &pb.AuditEntry{
+			Type:    pb.OrderEventType_UPDATED,
+			Actor:   s.actor,
+			Method:  s.method,
+			Time:    now,
+			Changes: diffOrders(change.before, change.after),
+		}
+		// change.id 是存储中的键，审计记录中保存订单ID和租户。
+		if change.after != nil {
+			entry.OrderId, entry.TenantId = change.after.Id, change.after.TenantId
+		} else if change.before != nil {
+			entry.OrderId, entry.TenantId = change.before.Id, change.before.TenantId
+		}
+		switch {
+		case change.after == nil && change.before == nil:
+			// 事务中新增又删除的订单没有留下任何变化。
+			continue
+		case change.after == nil:
+			entry.Type = pb.OrderEventType_DELETED
+		case change.before == nil:
+			entry.Type = pb.OrderEventType_ADDED
+		}
+		if change.after != nil {
+			entry.Version = change.after.Version
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// Match 把文本查询转发给底层存储的索引。
+func (s *auditedOrderStore) Match(query string, fn func(order *pb.Order) bool) {
+	if matcher, ok := s.OrderStore.(orderMatcher); ok {
+		matcher.Match(query, fn)
+		return
+	}
+	s.Scan(func(order *pb.Order) bool {
+		if !matchesText(order, query) {
+			return true
+		}
+		return fn(order)
+	})
+}
+
+// Revision、Oldest 和 Events 把变更事件的订阅转发给底层存储，使审计包装不影响watchOrders。
+func (s *auditedOrderStore) Revision() int64 {
+	if watcher, ok := s.OrderStore.(orderWatcher); ok {
+		return watcher.Revision()
+	}
+	return 0
+}
+
+func (s *auditedOrderStore) Oldest() int64 {
+	if watcher, ok := s.OrderStore.(orderWatcher); ok {
+		return watcher.Oldest()
+	}
+	return 0
+}
+
+func (s *auditedOrderStore) Events(after int64) ([]*pb.OrderEvent, <-chan struct{}, error) {
+	if watcher, ok := s.OrderStore.(orderWatcher); ok {
+		return watcher.Events(after)
+	}
+	return nil, nil, status.Errorf(codes.Unimplemented, "order store does not support watching")
+}
+
+// orderFieldValues 返回订单每个字段的JSON表示，包括取默认值的字段。订单为nil时返回空的结果。
+func orderFieldValues(order *pb.Order) map[string]string {
+	values := make(map[string]string)
+	if order == nil {
+		return values
+	}
+	data, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(order)
+	if err != nil {
+		return values
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return values
+	}
+	for name, raw := range fields {
+		// protojson的输出中空白是不固定的，压缩后再比较。
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, raw); err != nil {
+			buf.Reset()
+			buf.Write(raw)
+		}
+		values[name] = buf.String()
+	}
+	return values
+}
+
+// diffOrders 按字段在proto中的顺序列出变化的字段，version不算在内。
+// 新增或删除的订单只列出不是默认值的字段。
+func diffOrders(before, after *pb.Order) []*pb.FieldChange {
+	empty := orderFieldValues(&pb.Order{})
+	b, a := orderFieldValues(before), orderFieldValues(after)
+	fields := pb.File_order_management_proto.Messages().ByName("Order").Fields()
+	var changes []*pb.FieldChange
+	for i := 0; i < fields.Len(); i++ {
+		name := fields.Get(i).JSONName()
+		bv, av := b[name], a[name]
+		if before == nil {
+			bv = empty[name]
+		}
+		if after == nil {
+			av = empty[name]
+		}
+		if name == "version" || bv == av {
+			continue
+		}
+		changes = append(changes, &pb.FieldChange{Field: name, Before: b[name], After: a[name]})
+	}
+	return changes
+}
+
+// storeFor 返回处理ctx中的调用时使用的存储：只能访问调用方租户的订单。
+// 存储记录审计历史时，变更以调用方的身份记录。
+func (s *server) storeFor(ctx context.Context) OrderStore {
+	store := s.store
+	if auditor, ok := s.store.(orderAuditor); ok {
+		store = auditor.ForCaller(ctx)
+	}
+	return &tenantOrderStore{OrderStore: store, tenant: tenantOf(ctx), registry: s.tenants}
+}
+
+// Server-side Streaming RPC
+// GetOrderHistory 按时间顺序发送调用方租户的订单的审计记录。订单存在但还没有审计记录（例如在启用审计之前写入）时返回空的流。
+// 其他租户的同一个订单ID的记录不会发送，对调用方来说这样的订单不存在。
+func (s *server) GetOrderHistory(req *wrapper.StringValue, stream pb.OrderManagement_GetOrderHistoryServer) error {
+	auditor, ok := s.store.(orderAuditor)
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "order store does not record history")
+	}
+	ctx := stream.Context()
+	if err := checkOrderIDs([]string{req.Value}); err != nil {
+		return err
+	}
+	entries := auditor.History(orderKey(tenantOf(ctx), req.Value))
+	if len(entries) == 0 {
+		if _, exists := s.storeFor(ctx).Get(req.Value); !exists {
+			return orderNotFoundError(req.Value)
+		}
+	}
+	for _, entry := range entries {
+		if err := stream.Send(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	pb "ordermgt/service/ecommerce"
+)
+
+// recvHistory 读取订单的所有审计记录。
+func recvHistory(t *testing.T, client pb.OrderManagementClient, id string) []*pb.AuditEntry {
+	t.Helper()
+	stream, err := client.GetOrderHistory(context.Background(), &wrapper.StringValue{Value: id})
+	if err != nil {
+		t.Fatalf("GetOrderHistory(%s) failed: %v", id, err)
+	}
+	var entries []*pb.AuditEntry
+	for {
+		entry, err := stream.Recv()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatalf("GetOrderHistory(%s) failed: %v", id, err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// historySummary 以"序号 类型 调用方 方法 [变化的字段]"的形式概括审计记录。
+func historySummary(entries []*pb.AuditEntry) string {
+	var lines []string
+	for _, e := range entries {
+		var fields []string
+		for _, c := range e.Changes {
+			fields = append(fields, c.Field)
+		}
+		method := e.Method[strings.LastIndexByte(e.Method, '/')+1:]
+		lines = append(lines, fmt.Sprintf("%d %s %s:%s %s %v", e.Sequence, e.Type, e.Actor.Source, e.Actor.Id, method, fields))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestServer_GetOrderHistory(t *testing.T) {
+	store := newAuditedOrderStore(newWatchedOrderStore(newMemoryOrderStore(defaultShardCount), 0), newAuditLog())
+	initSampleData(store)
+	srv := newServer(store)
+	srv.batchPolicy = batchPolicy{Planner: destinationPlanner{}}
+	srv.admins = newAdminPolicy([]string{"alice"}, true)
+	client, stop := startBufConnServer(t, srv)
+	defer stop()
+	ctx, cancel := context.WithTimeout(metadata.AppendToOutgoingContext(context.Background(), callerIDKey, "alice"), 5*time.Second)
+	defer cancel()
+
+	if _, err := client.AddOrder(ctx, &pb.Order{Id: "200", Items: []string{"Pixel"}, Destination: "MV", Price: 10}); err != nil {
+		t.Fatalf("AddOrder failed: %v", err)
+	}
+	update, _ := client.UpdateOrders(ctx)
+	update.Send(&pb.Order{Id: "200", Items: []string{"Pixel", "Case"}, Destination: "MV", Price: 10})
+	if _, err := update.CloseAndRecv(); err != nil {
+		t.Fatalf("UpdateOrders failed: %v", err)
+	}
+	process, _ := client.ProcessOrders(ctx)
+	process.Send(&wrapper.StringValue{Value: "200"})
+	process.CloseSend()
+	recvShipment(t, process)
+
+	want := "1 ADDED ACTOR_METADATA:alice addOrder [id items price destination createTime exactPrice]\n" +
+		"2 UPDATED ACTOR_METADATA:alice updateOrders [items]\n" +
+		"3 UPDATED ACTOR_METADATA:alice processOrders [status]\n" +
+		"4 UPDATED ACTOR_METADATA:alice processOrders [status]"
+	entries := recvHistory(t, client, "200")
+	if got := historySummary(entries); got != want {
+		t.Fatalf("history =\n%s\nwant\n%s", got, want)
+	}
+	if c := entries[3].Changes[0]; c.Before != `"PACKED"` || c.After != `"SHIPPED"` {
+		t.Errorf("shipping changed status from %s to %s", c.Before, c.After)
+	}
+	if entries[3].Version != 4 || entries[3].Actor.PeerAddress == "" {
+		t.Errorf("entry = %v", entries[3])
+	}
+
+	// 示例数据由服务器端自己写入。
+	if got := historySummary(recvHistory(t, client, "102")); !strings.HasPrefix(got, "1 ADDED ACTOR_SYSTEM: ") {
+		t.Errorf("history of 102 = %s", got)
+	}
+	stream, _ := client.GetOrderHistory(ctx, &wrapper.StringValue{Value: "999"})
+	if _, err := stream.Recv(); status.Code(err) != codes.NotFound {
+		t.Errorf("history of unknown order: %v", err)
+	}
+
+	// 删除的订单仍然可以查询历史。
+	client.DeleteOrder(ctx, &pb.DeleteOrderRequest{Id: "200"})
+	if entries := recvHistory(t, client, "200"); len(entries) != 5 || entries[4].Type != pb.OrderEventType_DELETED || entries[4].Changes[0].After != "" {
+		t.Errorf("history after delete = %s", historySummary(entries))
+	}
+}
+
+// 审计包装在最外层时，watchOrders和searchOrders仍然可以使用内层的事件和索引。
+func TestAuditedOrderStore_Forwarding(t *testing.T) {
+	store := newAuditedOrderStore(newWatchedOrderStore(newIndexedOrderStore(newMemoryOrderStore(defaultShardCount)), 0), newAuditLog())
+	initSampleData(store)
+	var watcher orderWatcher = store
+	if watcher.Revision() != 5 {
+		t.Errorf("revision = %d, want 5", watcher.Revision())
+	}
+	var matched []string
+	store.Match("Echo", func(order *pb.Order) bool {
+		matched = append(matched, order.Id)
+		return true
+	})
+	if fmt.Sprint(matched) != "[105 106]" {
+		t.Errorf("matched %v", matched)
+	}
+}
+
+func TestAuditLog_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	audit, err := openAuditLog(dir)
+	if err != nil {
+		t.Fatalf("openAuditLog failed: %v", err)
+	}
+	store := newAuditedOrderStore(newMemoryOrderStore(defaultShardCount), audit)
+	initSampleData(store)
+	cancelOrder(store, "103", "customer request", 0)
+	audit.Close()
+
+	reopened, err := openAuditLog(dir)
+	if err != nil {
+		t.Fatalf("openAuditLog failed: %v", err)
+	}
+	defer reopened.Close()
+	entries := reopened.history("103")
+	if got, want := historySummary(entries), "1 ADDED ACTOR_SYSTEM:  [id items price destination exactPrice]\n2 UPDATED ACTOR_SYSTEM:  [status cancelReason]"; got != want {
+		t.Fatalf("history =\n%s\nwant\n%s", got, want)
+	}
+	// 序号在重新打开后继续递增。
+	newAuditedOrderStore(newMemoryOrderStore(defaultShardCount), reopened).Put(&pb.Order{Id: "103"})
+	if entries := reopened.history("103"); entries[len(entries)-1].Sequence != 3 {
+		t.Fatalf("sequence after reopen = %d", entries[len(entries)-1].Sequence)
+	}
+}
+
+// 审计日志写入失败时变更不生效。
+func TestAuditLog_WriteFailureAbortsTxn(t *testing.T) {
+	audit, err := openAuditLog(t.TempDir())
+	if err != nil {
+		t.Fatalf("openAuditLog failed: %v", err)
+	}
+	mem := newMemoryOrderStore(defaultShardCount)
+	store := newAuditedOrderStore(mem, audit)
+	store.Put(&pb.Order{Id: "102", Destination: "Mountain View, CA"})
+	audit.file.Close()
+	if err := store.Put(&pb.Order{Id: "102", Destination: "San Jose, CA"}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("Put with a broken audit log = %v, want Unavailable", err)
+	}
+	if _, err := store.Delete("102"); status.Code(err) != codes.Unavailable {
+		t.Fatalf("Delete with a broken audit log = %v, want Unavailable", err)
+	}
+	if order, ok := mem.Get("102"); !ok || order.Destination != "Mountain View, CA" || order.Version != 1 {
+		t.Fatalf("order after failed audit writes = %v", order)
+	}
+	if entries := audit.history("102"); len(entries) != 1 {
+		t.Fatalf("history = %v", entries)
+	}
+}
+
+// 不同订单的变更并发写入审计日志，重新打开后每个订单的历史完整且按提交顺序编号。
+func TestAuditLog_ConcurrentWrites(t *testing.T) {
+	dir := t.TempDir()
+	audit, err := openAuditLog(dir)
+	if err != nil {
+		t.Fatalf("openAuditLog failed: %v", err)
+	}
+	store := newAuditedOrderStore(newMemoryOrderStore(defaultShardCount), audit)
+	const orders, updates = 16, 5
+	var wg sync.WaitGroup
+	for i := 0; i < orders; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			for v := 0; v < updates; v++ {
+				if err := store.Put(&pb.Order{Id: id, Description: fmt.Sprint(v)}); err != nil {
+					t.Errorf("Put(%s) failed: %v", id, err)
+				}
+			}
+		}(fmt.Sprint(200 + i))
+	}
+	wg.Wait()
+	audit.Close()
+
+	reopened, err := openAuditLog(dir)
+	if err != nil {
+		t.Fatalf("openAuditLog failed: %v", err)
+	}
+	defer reopened.Close()
+	for i := 0; i < orders; i++ {
+		entries := reopened.history(fmt.Sprint(200 + i))
+		if len(entries) != updates {
+			t.Fatalf("order %d has %d entries, want %d", 200+i, len(entries), updates)
+		}
+		for v, entry := range entries {
+			if entry.Sequence != int64(v+1) || entry.Version != int64(v+1) {
+				t.Fatalf("entry %d of order %d has sequence %d and version %d", v, 200+i, entry.Sequence, entry.Version)
+			}
+		}
+	}
+}
+
+func TestCallerFromContext(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4000}
+	md := metadata.Pairs(callerIDKey, "bob")
+	tlsInfo := credentials.TLSInfo{State: tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "order-admin"}}}},
+	}}
+	tests := []struct {
+		ctx  context.Context
+		want string
+	}{
+		{context.Background(), "ACTOR_ANONYMOUS: "},
+		{metadata.NewIncomingContext(context.Background(), md), "ACTOR_METADATA:bob "},
+		{peer.NewContext(metadata.NewIncomingContext(context.Background(), md), &peer.Peer{Addr: addr}), "ACTOR_METADATA:bob 10.0.0.1:4000"},
+		// 经过校验的客户端证书优先于元数据中声明的身份。
+		{peer.NewContext(metadata.NewIncomingContext(context.Background(), md), &peer.Peer{Addr: addr, AuthInfo: tlsInfo}), "ACTOR_TLS:order-admin 10.0.0.1:4000"},
+		// 没有经过校验的证书不被采用。
+		{peer.NewContext(context.Background(), &peer.Peer{Addr: addr, AuthInfo: credentials.TLSInfo{}}), "ACTOR_ANONYMOUS: 10.0.0.1:4000"},
+	}
+	for i, tt := range tests {
+		actor := callerFromContext(tt.ctx)
+		if got := fmt.Sprintf("%s:%s %s", actor.Source, actor.Id, actor.PeerAddress); got != tt.want {
+			t.Errorf("%d: caller = %q, want %q", i, got, tt.want)
+		}
+	}
+}
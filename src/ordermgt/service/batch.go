@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	pb "ordermgt/service/ecommerce"
+)
+
+const (
+	// defaultBatchMaxWait 是一个批次从收到第一个订单起最多等待的时间，超时后无论批次是否已满都会发货。
+	defaultBatchMaxWait = 5 * time.Second
+
+	// 客户端可以在processOrders调用的元数据中覆盖服务器端的批处理策略。
+	batchMaxSizeKey           = "batch-max-size"
+	batchMaxWaitKey           = "batch-max-wait"
+	batchMaxPerDestinationKey = "batch-max-per-destination"
+)
+
+// batchPolicy 决定processOrders何时把发货组合发送给客户端，为0的字段表示不做该项限制。
+type batchPolicy struct {
+	// MaxBatchSize 是一个批次的订单数上限，达到后发送所有发货组合。
+	MaxBatchSize int
+	// MaxWait 是批次中第一个订单到达后最多等待的时间，超时后发送所有发货组合。
+	MaxWait time.Duration
+	// MaxOrdersPerDestination 是单个发货组合的订单数上限，达到后只发送这个发货组合。
+	MaxOrdersPerDestination int
+	// Planner 决定订单合并到哪个发货组合，为nil时按目的地合并。
+	Planner ShipmentPlanner
+}
+
+var defaultBatchPolicy = batchPolicy{MaxBatchSize: orderBatchSize, MaxWait: defaultBatchMaxWait, Planner: destinationPlanner{}}
+
+// batchPolicyFromContext 用调用元数据中的设置覆盖base，设置的值无法解析时返回InvalidArgument。
+func batchPolicyFromContext(ctx context.Context, base batchPolicy) (batchPolicy, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return base, nil
+	}
+	policy := base
+	for key, field := range map[string]*int{batchMaxSizeKey: &policy.MaxBatchSize, batchMaxPerDestinationKey: &policy.MaxOrdersPerDestination} {
+		if values := md.Get(key); len(values) > 0 {
+			n, err := strconv.Atoi(values[0])
+			if err != nil || n < 0 {
+				return base, status.Errorf(codes.InvalidArgument, "invalid %s metadata : %q", key, values[0])
+			}
+			*field = n
+		}
+	}
+	if values := md.Get(batchMaxWaitKey); len(values) > 0 {
+		d, err := time.ParseDuration(values[0])
+		if err != nil || d < 0 {
+			return base, status.Errorf(codes.InvalidArgument, "invalid %s metadata : %q", batchMaxWaitKey, values[0])
+		}
+		policy.MaxWait = d
+	}
+	if values := md.Get(shipmentPlannerKey); len(values) > 0 {
+		planner, err := parseShipmentPlanner(values[0])
+		if err != nil {
+			return base, status.Errorf(codes.InvalidArgument, "invalid %s metadata : %q", shipmentPlannerKey, values[0])
+		}
+		policy.Planner = planner
+	}
+	return policy, nil
+}
+
+// clock 抽象了批处理所用的计时器，测试中可以替换为手动推进的时钟。
+type clock interface {
+	NewTimer(d time.Duration) clockTimer
+}
+
+type clockTimer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) NewTimer(d time.Duration) clockTimer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// shipmentBatcher 按照planner把订单合并为发货组合，并根据batchPolicy决定哪些发货组合需要发送。
+// 发货组合按照分组第一次出现的顺序发送。
+type shipmentBatcher struct {
+	policy    batchPolicy
+	planner   ShipmentPlanner
+	newID     func() string
+	shipments map[string]*pb.CombinedShipment
+	keys      []string
+	size      int
+}
+
+// newShipmentBatcher 创建批处理器，newID用于生成新发货组合的唯一ID。
+func newShipmentBatcher(policy batchPolicy, newID func() string) *shipmentBatcher {
+	planner := policy.Planner
+	if planner == nil {
+		planner = destinationPlanner{}
+	}
+	return &shipmentBatcher{policy: policy, planner: planner, newID: newID, shipments: make(map[string]*pb.CombinedShipment)}
+}
+
+// add 把订单加入所属分组的发货组合，返回订单所在的发货组合，以及需要立即发送的发货组合。
+// 订单放不进分组中当前的发货组合时，当前的发货组合先被发送。不同租户的订单总是在不同的分组中。
+func (b *shipmentBatcher) add(ord *pb.Order) (*pb.CombinedShipment, []*pb.CombinedShipment) {
+	key := ord.TenantId + "/" + b.planner.Key(ord)
+	var ready []*pb.CombinedShipment
+	shipment, found := b.shipments[key]
+	if found && !b.planner.Fits(shipment, ord) {
+		b.remove(key)
+		ready = append(ready, shipment)
+		found = false
+	}
+	if !found {
+		shipment = &pb.CombinedShipment{
+			Id:             b.newID(),
+			Status:         "Processed!",
+			Destination:    ord.Destination,
+			ShipmentStatus: pb.ShipmentStatus_SHIPMENT_OPEN,
+			TenantId:       ord.TenantId,
+		}
+		b.shipments[key] = shipment
+		b.keys = append(b.keys, key)
+	}
+	shipment.OrdersList = append(shipment.OrdersList, ord)
+	b.size++
+
+	if b.policy.MaxBatchSize > 0 && b.size >= b.policy.MaxBatchSize {
+		return shipment, append(ready, b.flush()...)
+	}
+	if (b.policy.MaxOrdersPerDestination > 0 && len(shipment.OrdersList) >= b.policy.MaxOrdersPerDestination) || b.planner.Full(shipment) {
+		b.remove(key)
+		ready = append(ready, shipment)
+	}
+	return shipment, ready
+}
+
+// flush 返回并清空所有未发送的发货组合。
+func (b *shipmentBatcher) flush() []*pb.CombinedShipment {
+	shipments := make([]*pb.CombinedShipment, 0, len(b.keys))
+	for _, key := range b.keys {
+		shipments = append(shipments, b.shipments[key])
+	}
+	b.shipments = make(map[string]*pb.CombinedShipment)
+	b.keys = nil
+	b.size = 0
+	return shipments
+}
+
+func (b *shipmentBatcher) remove(key string) {
+	b.size -= len(b.shipments[key].OrdersList)
+	delete(b.shipments, key)
+	for i, k := range b.keys {
+		if k == key {
+			b.keys = append(b.keys[:i], b.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// take 取出ID为id的未发送发货组合，不存在时返回nil。
+func (b *shipmentBatcher) take(id string) *pb.CombinedShipment {
+	for _, key := range b.keys {
+		if shipment := b.shipments[key]; shipment.Id == id {
+			b.remove(key)
+			return shipment
+		}
+	}
+	return nil
+}
+
+func (b *shipmentBatcher) empty() bool {
+	return b.size == 0
+}
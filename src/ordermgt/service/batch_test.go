@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	pb "ordermgt/service/ecommerce"
+)
+
+// fakeClock 是手动推进的时钟，计时器只在Advance越过它的到期时间时触发。
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock    *fakeClock
+	deadline time.Time
+	c        chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) clockTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, deadline: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance 推进时钟并触发所有到期的计时器。
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.deadline.After(c.now) {
+			pending = append(pending, t)
+		} else {
+			t.c <- c.now
+		}
+	}
+	c.timers = pending
+}
+
+// Pending 返回尚未触发也没有停止的计时器数量。
+func (c *fakeClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, pending := range t.clock.timers {
+		if pending == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func shipmentList(shipments []*pb.CombinedShipment) string {
+	var s []string
+	for _, shipment := range shipments {
+		var ids []string
+		for _, ord := range shipment.OrdersList {
+			ids = append(ids, ord.Id)
+		}
+		s = append(s, fmt.Sprintf("%s%v", shipment.Destination, ids))
+	}
+	return fmt.Sprint(s)
+}
+
+func TestShipmentBatcher(t *testing.T) {
+	mv := func(id string) *pb.Order { return &pb.Order{Id: id, Destination: "MV"} }
+	sj := func(id string) *pb.Order { return &pb.Order{Id: id, Destination: "SJ"} }
+
+	b := newShipmentBatcher(batchPolicy{MaxBatchSize: 3}, newShipmentID)
+	first, got := b.add(mv("1"))
+	if got != nil {
+		t.Fatalf("partial batch flushed: %s", shipmentList(got))
+	}
+	b.add(sj("2"))
+	same, got := b.add(mv("3"))
+	if same != first {
+		t.Fatalf("order 3 added to %s, want %s", same.Id, first.Id)
+	}
+	if want := "[MV[1 3] SJ[2]]"; shipmentList(got) != want {
+		t.Fatalf("full batch = %s, want %s", shipmentList(got), want)
+	}
+	if !b.empty() {
+		t.Fatalf("batcher not empty after flush")
+	}
+	// 同一目的地的下一个批次使用新的发货组合ID。
+	if next, _ := b.add(mv("4")); next.Id == first.Id {
+		t.Fatalf("next batch reused shipment ID %s", first.Id)
+	}
+
+	// 单个目的地达到上限时只发送这个发货组合，其他目的地继续等待。
+	b = newShipmentBatcher(batchPolicy{MaxOrdersPerDestination: 2}, newShipmentID)
+	b.add(mv("1"))
+	b.add(sj("2"))
+	if _, got := b.add(mv("3")); shipmentList(got) != "[MV[1 3]]" {
+		t.Fatalf("per-destination flush = %s, want [MV[1 3]]", shipmentList(got))
+	}
+	if got, want := shipmentList(b.flush()), "[SJ[2]]"; got != want {
+		t.Fatalf("remaining shipments = %s, want %s", got, want)
+	}
+}
+
+func TestBatchPolicyFromContext(t *testing.T) {
+	md := metadata.Pairs(batchMaxSizeKey, "10", batchMaxWaitKey, "250ms")
+	policy, err := batchPolicyFromContext(metadata.NewIncomingContext(context.Background(), md), defaultBatchPolicy)
+	if err != nil {
+		t.Fatalf("batchPolicyFromContext failed: %v", err)
+	}
+	want := batchPolicy{MaxBatchSize: 10, MaxWait: 250 * time.Millisecond, MaxOrdersPerDestination: defaultBatchPolicy.MaxOrdersPerDestination, Planner: defaultBatchPolicy.Planner}
+	if policy != want {
+		t.Fatalf("policy = %+v, want %+v", policy, want)
+	}
+
+	md = metadata.Pairs(shipmentPlannerKey, "max-orders:2")
+	policy, err = batchPolicyFromContext(metadata.NewIncomingContext(context.Background(), md), defaultBatchPolicy)
+	if err != nil || policy.Planner != (maxOrdersPlanner{max: 2}) {
+		t.Fatalf("planner from metadata = %v, %v", policy.Planner, err)
+	}
+	md = metadata.Pairs(shipmentPlannerKey, "max-orders:0")
+	if _, err := batchPolicyFromContext(metadata.NewIncomingContext(context.Background(), md), defaultBatchPolicy); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("invalid planner returned %v, want InvalidArgument", err)
+	}
+
+	md = metadata.Pairs(batchMaxPerDestinationKey, "-1")
+	if _, err := batchPolicyFromContext(metadata.NewIncomingContext(context.Background(), md), defaultBatchPolicy); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("negative limit returned %v, want InvalidArgument", err)
+	}
+}
+
+// waitForStatus 等待服务器端处理完订单，即订单进入给定的状态。
+func waitForStatus(t *testing.T, store OrderStore, id string, want pb.OrderStatus) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if order, _ := store.Get(id); order.GetStatus() == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("order %s never reached %s", id, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// 部分批次在MaxWait到期时发送，而不是等待客户端发送更多订单或者结束流。
+func TestServer_ProcessOrdersFlushesOnTimer(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	srv := newServer(store)
+	fc := newFakeClock()
+	srv.clock = fc
+	srv.batchPolicy = batchPolicy{MaxBatchSize: 10, MaxWait: time.Second}
+	client, stop := startBufConnServer(t, srv)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.ProcessOrders(ctx)
+	if err != nil {
+		t.Fatalf("ProcessOrders failed: %v", err)
+	}
+	stream.Send(&wrapper.StringValue{Value: "102"})
+	stream.Send(&wrapper.StringValue{Value: "103"})
+	waitForStatus(t, store, "102", pb.OrderStatus_PACKED)
+	waitForStatus(t, store, "103", pb.OrderStatus_PACKED)
+
+	fc.Advance(time.Second - time.Nanosecond)
+	if fc.Pending() != 1 {
+		t.Fatalf("batch timer fired before MaxWait")
+	}
+	fc.Advance(time.Nanosecond)
+	var shipments []*pb.CombinedShipment
+	for len(shipments) < 2 {
+		shipments = append(shipments, recvShipment(t, stream))
+	}
+	if got, want := shipmentList(shipments), "[Mountain View, CA[102] San Jose, CA[103]]"; got != want {
+		t.Fatalf("shipments = %s, want %s", got, want)
+	}
+
+	// 发送后计时器从下一个批次的第一个订单重新开始。
+	stream.Send(&wrapper.StringValue{Value: "104"})
+	waitForStatus(t, store, "104", pb.OrderStatus_PACKED)
+	if fc.Pending() != 1 {
+		t.Fatalf("new batch did not start a timer")
+	}
+	stream.CloseSend()
+	shipment := recvShipment(t, stream)
+	if got, want := shipmentList([]*pb.CombinedShipment{shipment}), "[Mountain View, CA[104]]"; got != want {
+		t.Fatalf("final shipment = %s, want %s", got, want)
+	}
+	if order, _ := store.Get("102"); order.Status != pb.OrderStatus_SHIPPED {
+		t.Fatalf("order 102 is %s after its batch was shipped", order.Status)
+	}
+}
+
+// 客户端在批次等待期间取消调用：处理方法返回，批次中已经打包的订单照常发货，不会停留在PACKED状态。
+func TestServer_ProcessOrdersShipsPendingBatchOnCancel(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	srv := newServer(store)
+	srv.clock = newFakeClock()
+	srv.batchPolicy = batchPolicy{MaxBatchSize: 10, MaxWait: time.Hour}
+	client, stop := startBufConnServer(t, srv)
+	defer stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.ProcessOrders(ctx)
+	if err != nil {
+		t.Fatalf("ProcessOrders failed: %v", err)
+	}
+	stream.Send(&wrapper.StringValue{Value: "102"})
+	stream.Send(&wrapper.StringValue{Value: "103"})
+	waitForStatus(t, store, "102", pb.OrderStatus_PACKED)
+	waitForStatus(t, store, "103", pb.OrderStatus_PACKED)
+	cancel()
+	waitForStatus(t, store, "102", pb.OrderStatus_SHIPPED)
+	waitForStatus(t, store, "103", pb.OrderStatus_SHIPPED)
+	deadline := time.Now().Add(5 * time.Second)
+	open := &pb.ListShipmentsRequest{Statuses: []pb.ShipmentStatus{pb.ShipmentStatus_SHIPMENT_OPEN}}
+	for len(srv.shipments.list("", open)) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("shipments still open after the stream ended: %v", srv.shipments.list("", open))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// 调用元数据中的策略覆盖服务器端的配置。
+func TestServer_ProcessOrdersMetadataOverride(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	srv := newServer(store)
+	fc := newFakeClock()
+	srv.clock = fc
+	client, stop := startBufConnServer(t, srv)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ctx = metadata.AppendToOutgoingContext(ctx, batchMaxSizeKey, "0", batchMaxWaitKey, "0", batchMaxPerDestinationKey, "2")
+	stream, err := client.ProcessOrders(ctx)
+	if err != nil {
+		t.Fatalf("ProcessOrders failed: %v", err)
+	}
+	for _, id := range []string{"102", "103", "104"} {
+		stream.Send(&wrapper.StringValue{Value: id})
+	}
+	shipment := recvShipment(t, stream)
+	if got, want := shipmentList([]*pb.CombinedShipment{shipment}), "[Mountain View, CA[102 104]]"; got != want {
+		t.Fatalf("first shipment = %s, want %s", got, want)
+	}
+	if fc.Pending() != 0 {
+		t.Fatalf("timer started although batch-max-wait is 0")
+	}
+	stream.CloseSend()
+	shipment = recvShipment(t, stream)
+	if got, want := shipmentList([]*pb.CombinedShipment{shipment}), "[San Jose, CA[103]]"; got != want {
+		t.Fatalf("remaining shipment = %s, want %s", got, want)
+	}
+
+	stream, _ = client.ProcessOrders(metadata.AppendToOutgoingContext(context.Background(), batchMaxWaitKey, "soon"))
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("invalid batch metadata returned %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	pb "ordermgt/service/ecommerce"
+)
+
+// productLookupTimeout 是查找单个商品的超时时间，调用方的截止时间更早时以调用方为准。
+const productLookupTimeout = 2 * time.Second
+
+// productPrice 返回商品的目录价格。只设置了float价格的旧商品按USD换算。
+func productPrice(product *pb.Product) (*pb.Money, error) {
+	if product.ExactPrice != nil {
+		return product.ExactPrice, validateMoney(product.ExactPrice)
+	}
+	return moneyFromFloat(defaultCurrency, product.Price)
+}
+
+// productContext 把租户放入调用ProductInfo的元数据中，商品和库存在ProductInfo中同样按租户隔离。
+func productContext(ctx context.Context, tenant string) context.Context {
+	if tenant == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, tenantIDKey, tenant)
+}
+
+// resolveItems 通过ProductInfo把订单的每个条目按名称解析为商品，设置订单的productIds，
+// 并用目录价格的总和替换客户端设置的价格。服务器端没有配置ProductInfo时只清除客户端设置的productIds。
+// 所有无法解析的条目一起在BadRequest详情中返回；ProductInfo不可用时返回Unavailable。
+func (s *server) resolveItems(ctx context.Context, order *pb.Order) error {
+	order.ProductIds = nil
+	if s.products == nil {
+		return nil
+	}
+	var violations []*epb.BadRequest_FieldViolation
+	productIds := make([]string, len(order.Items))
+	var prices []*pb.Money
+	for i, item := range order.Items {
+		field := fmt.Sprintf("items[%d]", i)
+		lookupCtx, cancel := context.WithTimeout(productContext(ctx, tenantOf(ctx)), productLookupTimeout)
+		product, err := s.products.FindProductByName(lookupCtx, &pb.ProductName{Value: item})
+		cancel()
+		switch status.Code(err) {
+		case codes.OK:
+		case codes.NotFound:
+			violations = append(violations, &epb.BadRequest_FieldViolation{Field: field, Description: fmt.Sprintf("Unknown product %q", item)})
+			continue
+		case codes.FailedPrecondition:
+			violations = append(violations, &epb.BadRequest_FieldViolation{Field: field, Description: fmt.Sprintf("Product name %q matches more than one product", item)})
+			continue
+		default:
+			return status.Errorf(codes.Unavailable, "failed to look up product %q : %v", item, status.Convert(err).Message())
+		}
+		price, err := productPrice(product)
+		if err != nil {
+			violations = append(violations, &epb.BadRequest_FieldViolation{Field: field, Description: fmt.Sprintf("Product %q has an invalid price : %v", item, err)})
+			continue
+		}
+		productIds[i] = product.Id
+		prices = append(prices, price)
+	}
+	if len(violations) > 0 {
+		return fieldViolationsError(violations)
+	}
+
+	currency := defaultCurrency
+	if len(prices) > 0 {
+		currency = prices[0].CurrencyCode
+	}
+	total, err := sumMoney(currency, prices...)
+	if err == errCurrencyMismatch {
+		return invalidFieldError("items", "Products in one order must be priced in the same currency")
+	}
+	if err != nil {
+		return invalidFieldError("items", err.Error())
+	}
+	order.ProductIds = productIds
+	order.ExactPrice = total
+	return normalizeOrderPrice(order)
+}
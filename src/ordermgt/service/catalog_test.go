@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	pb "ordermgt/service/ecommerce"
+)
+
+// fakeProductInfo 是测试中代替ProductInfo服务的实现，按名称在固定的商品列表中查找。
+type fakeProductInfo struct {
+	products []*pb.Product
+	pb.UnimplementedProductInfoServer
+}
+
+func (f *fakeProductInfo) FindProductByName(ctx context.Context, in *pb.ProductName) (*pb.Product, error) {
+	var found *pb.Product
+	for _, product := range f.products {
+		if !strings.EqualFold(product.Name, in.Value) {
+			continue
+		}
+		if found != nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "Product name %q is ambiguous.", in.Value)
+		}
+		found = product
+	}
+	if found == nil {
+		return nil, status.Errorf(codes.NotFound, "Product does not exist. : %s", in.Value)
+	}
+	return found, nil
+}
+
+// startProductInfo 在bufconn上启动fakeProductInfo，返回客户端和清理函数。
+func startProductInfo(t *testing.T, products ...*pb.Product) (pb.ProductInfoClient, func()) {
+	t.Helper()
+	return serveProductInfo(t, &fakeProductInfo{products: products})
+}
+
+// serveProductInfo 在bufconn上启动给定的ProductInfo实现。
+func serveProductInfo(t *testing.T, srv pb.ProductInfoServer) (pb.ProductInfoClient, func()) {
+	t.Helper()
+	listener := bufconn.Listen(bufSize)
+	s := grpc.NewServer()
+	pb.RegisterProductInfoServer(s, srv)
+	go s.Serve(listener)
+
+	dialer := func(ctx context.Context, url string) (net.Conn, error) {
+		return listener.Dial()
+	}
+	conn, err := grpc.DialContext(context.Background(), "bufnet", grpc.WithContextDialer(dialer), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	return pb.NewProductInfoClient(conn), func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func catalogProducts() []*pb.Product {
+	return []*pb.Product{
+		{Id: "p-pixel", Name: "Google Pixel 3A", ExactPrice: usd("399.99")},
+		{Id: "p-mbp", Name: "Mac Book Pro", ExactPrice: usd("1400.01")},
+		// 只有float价格的旧商品按USD换算。
+		{Id: "p-echo", Name: "Amazon Echo", Price: 30},
+		{Id: "p-case-1", Name: "Case"},
+		{Id: "p-case-2", Name: "case"},
+		{Id: "p-euro", Name: "Euro Plug", ExactPrice: &pb.Money{CurrencyCode: "EUR", Units: 5}},
+	}
+}
+
+func TestServer_AddOrderResolvesItems(t *testing.T) {
+	products, stopProducts := startProductInfo(t, catalogProducts()...)
+	defer stopProducts()
+	store := newMemoryOrderStore(defaultShardCount)
+	srv := newServer(store)
+	srv.products = products
+	client, stop := startBufConnServer(t, srv)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 客户端设置的价格和商品ID都被目录中的值替换，条目名称不区分大小写。
+	_, err := client.AddOrder(ctx, &pb.Order{Id: "200", Items: []string{"google pixel 3a", "Mac Book Pro", "Amazon Echo"}, Price: 1, ProductIds: []string{"forged"}})
+	if err != nil {
+		t.Fatalf("AddOrder failed: %v", err)
+	}
+	order, _ := store.Get("200")
+	if got, want := fmt.Sprint(order.ProductIds), "[p-pixel p-mbp p-echo]"; got != want {
+		t.Errorf("productIds = %s, want %s", got, want)
+	}
+	if got := formatMoney(order.ExactPrice); got != "USD 1830.00" || order.Price != 1830 {
+		t.Errorf("price = %s (%v), want USD 1830.00", got, order.Price)
+	}
+
+	_, err = client.AddOrder(ctx, &pb.Order{Id: "201", Items: []string{"Mac Book Pro", "iPhone", "Case"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("AddOrder with unknown products: %v", err)
+	}
+	if got, want := fmt.Sprint(fieldViolations(err)), "[items[1] items[2]]"; got != want {
+		t.Errorf("violations = %s, want %s", got, want)
+	}
+	if _, err := client.AddOrder(ctx, &pb.Order{Id: "202", Items: []string{"Mac Book Pro", "Euro Plug"}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("AddOrder with mixed currencies: %v", err)
+	}
+	if _, ok := store.Get("201"); ok {
+		t.Errorf("order with unknown products was stored")
+	}
+
+	// ProductInfo不可用时不能确认条目，订单不会被写入。
+	stopProducts()
+	if _, err := client.AddOrder(ctx, &pb.Order{Id: "203", Items: []string{"Mac Book Pro"}}); status.Code(err) != codes.Unavailable {
+		t.Errorf("AddOrder without ProductInfo: %v", err)
+	}
+}
+
+func TestServer_UpdateOrdersResolvesItems(t *testing.T) {
+	products, stopProducts := startProductInfo(t, catalogProducts()...)
+	defer stopProducts()
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	srv := newServer(store)
+	srv.products = products
+	client, stop := startBufConnServer(t, srv)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.UpdateOrdersV2(ctx)
+	if err != nil {
+		t.Fatalf("UpdateOrdersV2 failed: %v", err)
+	}
+	stream.Send(&pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA"})
+	stream.Send(&pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA"})
+	stream.CloseSend()
+	for _, want := range []codes.Code{codes.OK, codes.InvalidArgument} {
+		ack, err := stream.Recv()
+		if err != nil || codes.Code(ack.Status.Code) != want {
+			t.Fatalf("ack = %v, %v, want %v", ack, err, want)
+		}
+	}
+	if order, _ := store.Get("102"); formatMoney(order.ExactPrice) != "USD 1800.00" || len(order.ProductIds) != 2 {
+		t.Errorf("order 102 = %v", order)
+	}
+
+	// updateOrders在第一个无效的订单处结束流。
+	update, _ := client.UpdateOrders(ctx)
+	update.Send(&pb.Order{Id: "104", Items: []string{"Google Home Mini"}})
+	if _, err := update.CloseAndRecv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("UpdateOrders with unknown product: %v", err)
+	}
+}
+
+func TestServer_PatchOrderResolvesItems(t *testing.T) {
+	products, stopProducts := startProductInfo(t, catalogProducts()...)
+	defer stopProducts()
+	store := newMemoryOrderStore(defaultShardCount)
+	srv := newServer(store)
+	srv.products = products
+	client, stop := startBufConnServer(t, srv)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	patch := func(order *pb.Order, mode pb.ItemsPatchMode, paths ...string) (*pb.Order, error) {
+		return client.PatchOrder(ctx, &pb.PatchOrderRequest{Order: order, UpdateMask: &fieldmaskpb.FieldMask{Paths: paths}, ItemsMode: mode})
+	}
+
+	if _, err := client.AddOrder(ctx, &pb.Order{Id: "200", Items: []string{"Google Pixel 3A"}}); err != nil {
+		t.Fatalf("AddOrder failed: %v", err)
+	}
+	order, err := patch(&pb.Order{Id: "200", Items: []string{"Mac Book Pro"}}, pb.ItemsPatchMode_APPEND, "items")
+	if err != nil {
+		t.Fatalf("appending items failed: %v", err)
+	}
+	if formatMoney(order.ExactPrice) != "USD 1800.00" || fmt.Sprint(order.ProductIds) != "[p-pixel p-mbp]" {
+		t.Errorf("order after append = %v", order)
+	}
+	order, err = patch(&pb.Order{Id: "200", Items: []string{"Amazon Echo"}}, pb.ItemsPatchMode_REPLACE, "items")
+	if err != nil {
+		t.Fatalf("replacing items failed: %v", err)
+	}
+	if formatMoney(order.ExactPrice) != "USD 30.00" || fmt.Sprint(order.ProductIds) != "[p-echo]" {
+		t.Errorf("order after replace = %v", order)
+	}
+
+	if _, err := patch(&pb.Order{Id: "200", Items: []string{"iPhone"}}, pb.ItemsPatchMode_APPEND, "items"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("patching an unknown product returned %v, want InvalidArgument", err)
+	}
+	for _, path := range []string{"price", "exactPrice"} {
+		if _, err := patch(&pb.Order{Id: "200", Price: 1, ExactPrice: usd("1.00")}, pb.ItemsPatchMode_REPLACE, path); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("patching %s returned %v, want InvalidArgument", path, err)
+		}
+	}
+	if order, _ := store.Get("200"); formatMoney(order.ExactPrice) != "USD 30.00" || fmt.Sprint(order.Items) != "[Amazon Echo]" {
+		t.Errorf("order 200 after rejected patches = %v", order)
+	}
+}
+
+func TestServer_ImportOrdersResolvesItems(t *testing.T) {
+	products, stopProducts := startProductInfo(t, catalogProducts()...)
+	defer stopProducts()
+	store := newMemoryOrderStore(defaultShardCount)
+	srv := newServer(store)
+	srv.products = products
+	client, stop := startBufConnServer(t, srv)
+	defer stop()
+
+	data := []byte(`{"id":"200","items":["Google Pixel 3A","Mac Book Pro"],"price":1}
+{"id":"201","items":["iPhone"],"price":1}
+`)
+	summary, err := importData(client, &pb.ImportOptions{ConflictPolicy: pb.ImportConflictPolicy_CONFLICT_SKIP}, data, 1<<10)
+	if err != nil {
+		t.Fatalf("ImportOrders failed: %v", err)
+	}
+	if got, want := summaryCounts(summary), "2/1/0/0/1 committed"; got != want {
+		t.Fatalf("summary = %s, want %s", got, want)
+	}
+	if order, _ := store.Get("200"); formatMoney(order.ExactPrice) != "USD 1800.00" || fmt.Sprint(order.ProductIds) != "[p-pixel p-mbp]" {
+		t.Errorf("order 200 = %v", order)
+	}
+	if _, ok := store.Get("201"); ok {
+		t.Errorf("order 201 with an unknown product was imported")
+	}
+}
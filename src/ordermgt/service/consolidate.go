@@ -0,0 +1,308 @@
+package main
+
+import (
+	"io"
+	"log"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	pb "ordermgt/service/ecommerce"
+)
+
+// consolidator 是所有processOrders流共享的发货组合批处理器。不同的流发往同一目的地的订单
+// 在同一个时间窗口内合并到同一个发货组合，发货组合发出后发送给每一个向它提供过订单的流。
+//
+// 发货组合在以下情况下发出：达到批处理策略或合并策略的限制；从批次的第一个订单起经过了
+// MaxWait；或者向它提供过订单的所有流都已经结束发送（客户端关闭发送或者流被取消）。
+// 被取消的流的订单已经进入PACKED状态，仍然会随发货组合一起发出。
+// 发货组合在mu内从批次中取出，在mu外发货和投递，发货期间其他流可以继续加入订单。
+type consolidator struct {
+	policy batchPolicy
+	clock  clock
+	// save 保存发货组合的当前状态，ship 把发货组合中的订单标记为已发货并保存，返回没能发货的订单。
+	save func(shipment *pb.CombinedShipment) error
+	ship func(shipment *pb.CombinedShipment) ([]shipFailure, error)
+
+	mu      sync.Mutex
+	batcher *shipmentBatcher
+	// contributors 记录每个未发出的发货组合中有哪些流的订单，以及每个流提供的订单ID。
+	contributors map[string]map[*consolidationStream][]string
+	timer        clockTimer
+	timerCancel  chan struct{}
+}
+
+// consolidationStream 是一个processOrders流在consolidator中的登记。
+// 发给这个流的发货组合和没能发货的订单的结果放在队列中，投递永远不会阻塞consolidator。
+type consolidationStream struct {
+	mu     sync.Mutex
+	queue  []*pb.ProcessOrdersResponse
+	notify chan struct{}
+
+	// 以下字段由consolidator.mu保护。
+	// pending 是包含这个流的订单、尚未发出和投递的发货组合。
+	pending map[string]bool
+	// finished 表示这个流已经不会再提供订单。
+	finished bool
+}
+
+func newConsolidator(policy batchPolicy, clk clock, newID func() string, save func(*pb.CombinedShipment) error, ship func(*pb.CombinedShipment) ([]shipFailure, error)) *consolidator {
+	return &consolidator{
+		policy:       policy,
+		clock:        clk,
+		save:         save,
+		ship:         ship,
+		batcher:      newShipmentBatcher(policy, newID),
+		contributors: make(map[string]map[*consolidationStream][]string),
+	}
+}
+
+// join 登记一个新的流。流结束时必须调用leave。
+func (c *consolidator) join() *consolidationStream {
+	return &consolidationStream{notify: make(chan struct{}, 1), pending: make(map[string]bool)}
+}
+
+// add 把订单加入共享的发货组合，返回订单所在的发货组合的ID。
+func (c *consolidator) add(cs *consolidationStream, ord *pb.Order) (string, error) {
+	c.mu.Lock()
+	shipment, ready := c.batcher.add(ord)
+	if c.contributors[shipment.Id] == nil {
+		c.contributors[shipment.Id] = make(map[*consolidationStream][]string)
+	}
+	c.contributors[shipment.Id][cs] = append(c.contributors[shipment.Id][cs], ord.Id)
+	cs.pending[shipment.Id] = true
+	err := c.save(shipment)
+	out := c.takeLocked(ready)
+	c.resetTimerLocked()
+	c.mu.Unlock()
+	c.dispatch(out)
+	return shipment.Id, err
+}
+
+// finish 表示流已经发送完所有订单。只包含已结束的流的订单的发货组合立即发出。
+func (c *consolidator) finish(cs *consolidationStream) {
+	c.mu.Lock()
+	cs.finished = true
+	out := c.flushFinishedLocked(cs)
+	c.mu.Unlock()
+	c.dispatch(out)
+}
+
+// leave 注销流。流的订单仍然留在发货组合中，之后不再向它投递发货组合。
+func (c *consolidator) leave(cs *consolidationStream) {
+	c.mu.Lock()
+	cs.finished = true
+	out := c.flushFinishedLocked(cs)
+	for id := range cs.pending {
+		delete(c.contributors[id], cs)
+		delete(cs.pending, id)
+	}
+	c.mu.Unlock()
+	c.dispatch(out)
+}
+
+// idle 判断流的所有订单是否都已经随发货组合发出。
+func (c *consolidator) idle(cs *consolidationStream) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(cs.pending) == 0
+}
+
+// flushFinishedLocked 取出cs参与的、所有参与的流都已结束的发货组合，由调用方在释放c.mu之后发出。
+func (c *consolidator) flushFinishedLocked(cs *consolidationStream) []outgoingShipment {
+	var ready []*pb.CombinedShipment
+	for id := range cs.pending {
+		done := true
+		for other := range c.contributors[id] {
+			done = done && other.finished
+		}
+		if !done {
+			continue
+		}
+		if shipment := c.batcher.take(id); shipment != nil {
+			ready = append(ready, shipment)
+		}
+	}
+	out := c.takeLocked(ready)
+	c.resetTimerLocked()
+	return out
+}
+
+// outgoingShipment 是已经从批次中取出、等待发出的发货组合，以及向它提供过订单的流和每个流提供的订单ID。
+type outgoingShipment struct {
+	shipment     *pb.CombinedShipment
+	contributors map[*consolidationStream][]string
+}
+
+// takeLocked 取出发货组合的提供者登记。流的pending在发货组合投递之后才清除，
+// 因此idle不会在投递之前返回true。
+func (c *consolidator) takeLocked(shipments []*pb.CombinedShipment) []outgoingShipment {
+	out := make([]outgoingShipment, 0, len(shipments))
+	for _, shipment := range shipments {
+		out = append(out, outgoingShipment{shipment: shipment, contributors: c.contributors[shipment.Id]})
+		delete(c.contributors, shipment.Id)
+	}
+	return out
+}
+
+// dispatch 在c.mu之外发出发货组合，并投递给每一个向它提供过订单的流。没能发货的订单的错误结果只投递给提供它的流，
+// 所有订单都没能发货时不投递发货组合。
+func (c *consolidator) dispatch(out []outgoingShipment) {
+	if len(out) == 0 {
+		return
+	}
+	for _, o := range out {
+		shipment := o.shipment
+		log.Printf("Shipping : %v -> %v", shipment.Id, len(shipment.OrdersList))
+		failures, err := c.ship(shipment)
+		if err != nil {
+			log.Printf("Shipment ID : %s - not stored : %v", shipment.Id, err)
+		}
+		for cs, ids := range o.contributors {
+			for _, f := range failures {
+				if containsID(ids, f.orderID) {
+					cs.deliver(orderResult(f.orderID, "", f.err))
+				}
+			}
+			if len(shipment.OrdersList) > 0 {
+				cs.deliver(&pb.ProcessOrdersResponse{Response: &pb.ProcessOrdersResponse_Shipment{Shipment: proto.Clone(shipment).(*pb.CombinedShipment)}})
+			}
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, o := range out {
+		for cs := range o.contributors {
+			delete(cs.pending, o.shipment.Id)
+		}
+	}
+}
+
+func containsID(ids []string, id string) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+// resetTimerLocked 在批次中有订单而没有计时器时启动计时器，批次为空时停止计时器。
+func (c *consolidator) resetTimerLocked() {
+	if c.batcher.empty() {
+		if c.timer != nil {
+			c.timer.Stop()
+			close(c.timerCancel)
+			c.timer, c.timerCancel = nil, nil
+		}
+		return
+	}
+	if c.timer != nil || c.policy.MaxWait <= 0 {
+		return
+	}
+	timer, cancel := c.clock.NewTimer(c.policy.MaxWait), make(chan struct{})
+	c.timer, c.timerCancel = timer, cancel
+	go func() {
+		select {
+		case <-timer.C():
+			c.timeout(timer)
+		case <-cancel:
+		}
+	}()
+}
+
+// timeout 在批次等待超时后发出所有发货组合。
+func (c *consolidator) timeout(timer clockTimer) {
+	c.mu.Lock()
+	if c.timer != timer {
+		c.mu.Unlock()
+		return
+	}
+	c.timer, c.timerCancel = nil, nil
+	out := c.takeLocked(c.batcher.flush())
+	c.mu.Unlock()
+	c.dispatch(out)
+}
+
+func (cs *consolidationStream) deliver(resp *pb.ProcessOrdersResponse) {
+	cs.mu.Lock()
+	cs.queue = append(cs.queue, resp)
+	cs.mu.Unlock()
+	select {
+	case cs.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take 取出所有已投递的响应。
+func (cs *consolidationStream) take() []*pb.ProcessOrdersResponse {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	responses := cs.queue
+	cs.queue = nil
+	return responses
+}
+
+// processOrdersConsolidated 是启用了全局合并时的processOrders：订单加入所有流共享的发货组合，
+// 包含这个流的订单的发货组合发出后发送给这个流。客户端关闭发送后，等到它的所有订单都随发货组合发出再结束流。
+func (s *server) processOrdersConsolidated(stream pb.OrderManagement_ProcessOrdersServer) error {
+	cs := s.consolidator.join()
+	store := s.storeFor(stream.Context())
+	defer s.consolidator.leave(cs)
+	received := receiveOrderIDs(stream)
+
+	sendDelivered := func() error {
+		for _, resp := range cs.take() {
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for {
+		select {
+		case <-cs.notify:
+			if err := sendDelivered(); err != nil {
+				return err
+			}
+		case res := <-received:
+			if res.err == io.EOF {
+				// 客户端已经发送完所有订单，不再读取客户端流。
+				s.consolidator.finish(cs)
+				received = nil
+				break
+			}
+			if res.err != nil {
+				return res.err
+			}
+			ord, err := packOrder(store, res.orderId.GetValue())
+			if err != nil {
+				log.Printf("Order ID : %s - skipped : %v", res.orderId.GetValue(), err)
+				if err := stream.Send(orderResult(res.orderId.GetValue(), "", err)); err != nil {
+					return err
+				}
+				continue
+			}
+			shipmentID, err := s.consolidator.add(cs, ord)
+			if err != nil {
+				return status.Errorf(codes.Internal, "failed to store shipment %s : %v", shipmentID, err)
+			}
+			if err := stream.Send(orderResult(ord.Id, shipmentID, nil)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+		if received == nil {
+			// 先检查再发送，保证检查时已经投递的发货组合都被发送。
+			idle := s.consolidator.idle(cs)
+			if err := sendDelivered(); err != nil {
+				return err
+			}
+			if idle {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc/codes"
+	pb "ordermgt/service/ecommerce"
+)
+
+// newConsolidatedServer 创建启用了全局合并的服务，批次在fakeClock上等待maxWait后发出。
+func newConsolidatedServer(store OrderStore, maxWait time.Duration) (*server, *fakeClock) {
+	srv := newServer(store)
+	fc := newFakeClock()
+	srv.clock = fc
+	srv.newShipmentID = sequentialIDs()
+	srv.batchPolicy = batchPolicy{MaxWait: maxWait, Planner: destinationPlanner{}}
+	srv.consolidator = newConsolidator(srv.batchPolicy, srv.clock, srv.newShipmentID, srv.shipments.put, srv.shipSharedShipment)
+	return srv, fc
+}
+
+// sendOrder 发送订单ID，并返回它被分配到的发货组合的ID。
+func sendOrder(t *testing.T, stream pb.OrderManagement_ProcessOrdersClient, id string) string {
+	t.Helper()
+	if err := stream.Send(&wrapper.StringValue{Value: id}); err != nil {
+		t.Fatalf("Send(%s) failed: %v", id, err)
+	}
+	res, err := stream.Recv()
+	if err != nil || res.GetResult().GetOrderId() != id {
+		t.Fatalf("result for order %s = %v, %v", id, res, err)
+	}
+	return res.GetResult().GetShipmentId()
+}
+
+// recvEOF 检查流在没有更多消息的情况下正常结束。
+func recvEOF(t *testing.T, stream pb.OrderManagement_ProcessOrdersClient) {
+	t.Helper()
+	if res, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("stream did not end: %v, %v", res, err)
+	}
+}
+
+// 两个流在时间窗口内发往同一目的地的订单合并为一个发货组合，发给两个流。
+func TestConsolidator_MergesAcrossStreams(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	srv, fc := newConsolidatedServer(store, time.Second)
+	client, stop := startBufConnServer(t, srv)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	a, _ := client.ProcessOrders(ctx)
+	b, _ := client.ProcessOrders(ctx)
+	idA := sendOrder(t, a, "102")
+	idB := sendOrder(t, b, "104")
+	if idA != idB {
+		t.Fatalf("orders for the same destination assigned to %s and %s", idA, idB)
+	}
+	sendOrder(t, b, "103")
+
+	fc.Advance(time.Second)
+	if got, want := shipmentList([]*pb.CombinedShipment{recvShipment(t, a)}), "[Mountain View, CA[102 104]]"; got != want {
+		t.Fatalf("stream a received %s, want %s", got, want)
+	}
+	got := shipmentList([]*pb.CombinedShipment{recvShipment(t, b), recvShipment(t, b)})
+	if got != "[Mountain View, CA[102 104] San Jose, CA[103]]" && got != "[San Jose, CA[103] Mountain View, CA[102 104]]" {
+		t.Fatalf("stream b received %s", got)
+	}
+	a.CloseSend()
+	b.CloseSend()
+	recvEOF(t, a)
+	recvEOF(t, b)
+	if order, _ := store.Get("104"); order.Status != pb.OrderStatus_SHIPPED {
+		t.Fatalf("order 104 is %s", order.Status)
+	}
+}
+
+// 所有参与的流都结束发送后，发货组合不再等待时间窗口。
+func TestConsolidator_ShipsWhenContributorsFinish(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	srv, fc := newConsolidatedServer(store, time.Hour)
+	client, stop := startBufConnServer(t, srv)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	a, _ := client.ProcessOrders(ctx)
+	b, _ := client.ProcessOrders(ctx)
+	sendOrder(t, a, "102")
+	sendOrder(t, b, "104")
+	a.CloseSend()
+	// b仍在发送订单，a的发货组合继续等待。
+	time.Sleep(20 * time.Millisecond)
+	if shipment, _, _ := srv.shipments.get("cmb-1"); shipment.ShipmentStatus != pb.ShipmentStatus_SHIPMENT_OPEN {
+		t.Fatalf("shipment shipped while stream b was still sending")
+	}
+	b.CloseSend()
+	for _, stream := range []pb.OrderManagement_ProcessOrdersClient{a, b} {
+		if got, want := shipmentList([]*pb.CombinedShipment{recvShipment(t, stream)}), "[Mountain View, CA[102 104]]"; got != want {
+			t.Fatalf("received %s, want %s", got, want)
+		}
+		recvEOF(t, stream)
+	}
+	if fc.Pending() != 0 {
+		t.Fatalf("window timer still pending after all shipments were sent")
+	}
+}
+
+// 被取消的流不再收到发货组合，它的订单仍然随发货组合发出。
+func TestConsolidator_CancelledStream(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	srv, _ := newConsolidatedServer(store, time.Hour)
+	client, stop := startBufConnServer(t, srv)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ctxA, cancelA := context.WithCancel(ctx)
+	a, _ := client.ProcessOrders(ctxA)
+	b, _ := client.ProcessOrders(ctx)
+	sendOrder(t, a, "102")
+	sendOrder(t, b, "104")
+	cancelA()
+	b.CloseSend()
+	if got, want := shipmentList([]*pb.CombinedShipment{recvShipment(t, b)}), "[Mountain View, CA[102 104]]"; got != want {
+		t.Fatalf("stream b received %s, want %s", got, want)
+	}
+	recvEOF(t, b)
+
+	srv.consolidator.mu.Lock()
+	defer srv.consolidator.mu.Unlock()
+	if len(srv.consolidator.contributors) != 0 || !srv.consolidator.batcher.empty() {
+		t.Fatalf("consolidator still holds %d shipments", len(srv.consolidator.contributors))
+	}
+}
+
+// 在时间窗口内被取消的订单不随发货组合发出，错误结果只发给提供它的流。
+func TestConsolidator_ReportsOrdersNotShipped(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	srv, fc := newConsolidatedServer(store, time.Second)
+	client, stop := startBufConnServer(t, srv)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	a, _ := client.ProcessOrders(ctx)
+	b, _ := client.ProcessOrders(ctx)
+	sendOrder(t, a, "102")
+	sendOrder(t, b, "104")
+	if _, err := client.CancelOrder(ctx, &pb.CancelOrderRequest{Id: "104", Reason: "customer request"}); err != nil {
+		t.Fatalf("CancelOrder failed: %v", err)
+	}
+	fc.Advance(time.Second)
+
+	if got, want := shipmentList([]*pb.CombinedShipment{recvShipment(t, a)}), "[Mountain View, CA[102]]"; got != want {
+		t.Fatalf("stream a received %s, want %s", got, want)
+	}
+	res, err := b.Recv()
+	if err != nil || res.GetResult().GetOrderId() != "104" || codes.Code(res.GetResult().GetError().GetCode()) != codes.FailedPrecondition {
+		t.Fatalf("stream b received %v, %v, want a FailedPrecondition result for 104", res, err)
+	}
+	if got, want := shipmentList([]*pb.CombinedShipment{recvShipment(t, b)}), "[Mountain View, CA[102]]"; got != want {
+		t.Fatalf("stream b received %s, want %s", got, want)
+	}
+	a.CloseSend()
+	b.CloseSend()
+	recvEOF(t, a)
+	recvEOF(t, b)
+	if shipment, _, _ := srv.shipments.get("cmb-1"); len(shipment.OrdersList) != 1 {
+		t.Fatalf("stored shipment = %v", shipment)
+	}
+}
+
+// 发货组合在consolidator的锁之外发货：一个发货组合发货期间，其他流仍然可以加入订单，
+// 发货组合投递之后流才变为空闲。
+func TestConsolidator_ShipsOutsideLock(t *testing.T) {
+	shipping, release := make(chan string, 2), make(chan struct{})
+	ship := func(shipment *pb.CombinedShipment) ([]shipFailure, error) {
+		shipping <- shipment.Id
+		if shipment.Id == "cmb-1" {
+			<-release
+		}
+		return nil, nil
+	}
+	save := func(*pb.CombinedShipment) error { return nil }
+	c := newConsolidator(batchPolicy{MaxOrdersPerDestination: 1, Planner: destinationPlanner{}}, newFakeClock(), sequentialIDs(), save, ship)
+	a, b := c.join(), c.join()
+	added := make(chan error)
+	go func() {
+		_, err := c.add(a, &pb.Order{Id: "102", Destination: "Mountain View, CA"})
+		added <- err
+	}()
+	if id := <-shipping; id != "cmb-1" {
+		t.Fatalf("shipping %s, want cmb-1", id)
+	}
+	if c.idle(a) {
+		t.Errorf("stream is idle before its shipment was delivered")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.add(b, &pb.Order{Id: "103", Destination: "San Jose, CA"})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("add blocked while another shipment was shipping")
+	}
+	if len(b.take()) != 1 || !c.idle(b) {
+		t.Errorf("second shipment was not delivered")
+	}
+
+	close(release)
+	if err := <-added; err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	if responses := a.take(); len(responses) != 1 || responses[0].GetShipment().GetId() != "cmb-1" || !c.idle(a) {
+		t.Errorf("stream a received %v", responses)
+	}
+}
@@ -23,11 +23,14 @@ const _ = grpc.SupportPackageIsVersion7
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type OrderManagementClient interface {
+	// 订单ID已经存在时返回ALREADY_EXISTS，已有的订单只能通过updateOrders和生命周期迁移修改。
+	// 服务器端配置了ProductInfo时，为订单条目预留库存，库存不足时返回FAILED_PRECONDITION，订单不会被写入。
 	AddOrder(ctx context.Context, in *Order, opts ...grpc.CallOption) (*wrappers.StringValue, error)
 	// 检索订单的远程方法。
 	GetOrder(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*Order, error)
 	// 通过返回0rder消息的stream定义服务器端流。
-	SearchOrders(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (OrderManagement_SearchOrdersClient, error)
+	// 分页时，下一页的令牌通过trailer元数据next-page-token返回。
+	SearchOrders(ctx context.Context, in *SearchOrdersRequest, opts ...grpc.CallOption) (OrderManagement_SearchOrdersClient, error)
 	// 使用strean order 作为updateOrders方法的参数，表明updateOrders会接收来自客户端的多条消息作为输入。
 	// 因为服务器端只发送一个响应，所以返回值是单一的字符串消息。
 	UpdateOrders(ctx context.Context, opts ...grpc.CallOption) (OrderManagement_UpdateOrdersClient, error)
@@ -35,7 +38,44 @@ type OrderManagementClient interface {
 	// 因此，通过将方法参数和返回参数均声明为stream，可以定义双向流的RPC方法。
 	// 发货组合的消息也是通过服务定义声明的，它包含了订单元素的列表。
 	// 在双向流RPC模式中，将方法参数和返回参数均声明为stream
+	// 订单合并为发货组合的策略可以通过元数据shipment-planner选择，例如"max-orders:5"。
+	// 服务器端为每个订单ID返回一个结果：订单被分配到的发货组合，或者无法处理该订单的原因。
+	// 发货组合在批次发送时以单独的消息返回。无法处理的订单不会中断流。
 	ProcessOrders(ctx context.Context, opts ...grpc.CallOption) (OrderManagement_ProcessOrdersClient, error)
+	// 按照订单生命周期把订单迁移到新的状态，非法的状态迁移会返回FailedPrecondition，
+	// 订单版本与expectedVersion不一致时返回Aborted。
+	TransitionOrder(ctx context.Context, in *TransitionOrderRequest, opts ...grpc.CallOption) (*Order, error)
+	// 订阅订单的变更事件流。客户端断线重连时可以带上最后收到的revision，补齐错过的事件。
+	// 如果该revision已经被移出服务器端的历史缓冲区，返回OutOfRange。
+	WatchOrders(ctx context.Context, in *WatchOrdersRequest, opts ...grpc.CallOption) (OrderManagement_WatchOrdersClient, error)
+	// 取消订单并记录取消原因。已发货或已完成的订单不能取消，返回FailedPrecondition。
+	CancelOrder(ctx context.Context, in *CancelOrderRequest, opts ...grpc.CallOption) (*Order, error)
+	// 管理员接口：删除订单并留下墓碑。在保留期内，getOrder对已删除的订单返回带有ResourceInfo详情的NotFound。
+	DeleteOrder(ctx context.Context, in *DeleteOrderRequest, opts ...grpc.CallOption) (*wrappers.StringValue, error)
+	// 部分更新订单：只修改updateMask中列出的字段，其他字段保持不变。
+	// 可以修改的字段为items、description、price、exactPrice、destination、weightGrams和priority，
+	// 未知或不能修改的字段返回InvalidArgument，BadRequest详情中列出所有无效的路径。
+	// 服务器端配置了ProductInfo时，修改后的items与addOrder一样通过ProductInfo解析，价格由目录价格计算，
+	// 修改price或exactPrice返回InvalidArgument。
+	PatchOrder(ctx context.Context, in *PatchOrderRequest, opts ...grpc.CallOption) (*Order, error)
+	// updateOrders的双向流版本：服务器端对每个收到的订单返回一个确认，说明订单是否更新成功以及更新后的版本。
+	// 单个订单更新失败不会中断流，客户端可以只重试失败的订单。
+	UpdateOrdersV2(ctx context.Context, opts ...grpc.CallOption) (OrderManagement_UpdateOrdersV2Client, error)
+	// 按ID检索发货组合。
+	GetShipment(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*CombinedShipment, error)
+	// 按目的地和状态列出发货组合，按创建时间排序。
+	ListShipments(ctx context.Context, in *ListShipmentsRequest, opts ...grpc.CallOption) (OrderManagement_ListShipmentsClient, error)
+	// 订阅一个发货组合的变化：先发送发货组合的当前状态，之后每次变化发送一次，发货组合发出后结束流。
+	WatchShipment(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (OrderManagement_WatchShipmentClient, error)
+	// 导出所有订单（包括已取消的订单）。每条消息包含编码后文件的一段数据，按顺序拼接即为完整的文件。
+	ExportOrders(ctx context.Context, in *ExportOrdersRequest, opts ...grpc.CallOption) (OrderManagement_ExportOrdersClient, error)
+	// 导入订单。第一条消息必须是导入选项，之后的消息是按顺序拼接的文件数据，可以在任意位置分段。
+	// 服务器端在读完所有数据后才写入订单，返回导入的汇总报告。
+	// 服务器端配置了ProductInfo时，每条记录的items与addOrder一样通过ProductInfo解析，价格使用目录价格，
+	// 含有未知商品的记录作为无效记录报告；ProductInfo不可用时返回UNAVAILABLE，不写入任何订单。
+	ImportOrders(ctx context.Context, opts ...grpc.CallOption) (OrderManagement_ImportOrdersClient, error)
+	// 按时间顺序返回订单的审计历史。订单被删除后仍然可以查询。
+	GetOrderHistory(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (OrderManagement_GetOrderHistoryClient, error)
 }
 
 type orderManagementClient struct {
@@ -64,7 +104,7 @@ func (c *orderManagementClient) GetOrder(ctx context.Context, in *wrappers.Strin
 	return out, nil
 }
 
-func (c *orderManagementClient) SearchOrders(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (OrderManagement_SearchOrdersClient, error) {
+func (c *orderManagementClient) SearchOrders(ctx context.Context, in *SearchOrdersRequest, opts ...grpc.CallOption) (OrderManagement_SearchOrdersClient, error) {
 	stream, err := c.cc.NewStream(ctx, &OrderManagement_ServiceDesc.Streams[0], "/ecommerce.OrderManagement/searchOrders", opts...)
 	if err != nil {
 		return nil, err
@@ -141,7 +181,7 @@ func (c *orderManagementClient) ProcessOrders(ctx context.Context, opts ...grpc.
 
 type OrderManagement_ProcessOrdersClient interface {
 	Send(*wrappers.StringValue) error
-	Recv() (*CombinedShipment, error)
+	Recv() (*ProcessOrdersResponse, error)
 	grpc.ClientStream
 }
 
@@ -153,7 +193,147 @@ func (x *orderManagementProcessOrdersClient) Send(m *wrappers.StringValue) error
 	return x.ClientStream.SendMsg(m)
 }
 
-func (x *orderManagementProcessOrdersClient) Recv() (*CombinedShipment, error) {
+func (x *orderManagementProcessOrdersClient) Recv() (*ProcessOrdersResponse, error) {
+	m := new(ProcessOrdersResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *orderManagementClient) TransitionOrder(ctx context.Context, in *TransitionOrderRequest, opts ...grpc.CallOption) (*Order, error) {
+	out := new(Order)
+	err := c.cc.Invoke(ctx, "/ecommerce.OrderManagement/transitionOrder", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderManagementClient) WatchOrders(ctx context.Context, in *WatchOrdersRequest, opts ...grpc.CallOption) (OrderManagement_WatchOrdersClient, error) {
+	stream, err := c.cc.NewStream(ctx, &OrderManagement_ServiceDesc.Streams[3], "/ecommerce.OrderManagement/watchOrders", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &orderManagementWatchOrdersClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type OrderManagement_WatchOrdersClient interface {
+	Recv() (*OrderEvent, error)
+	grpc.ClientStream
+}
+
+type orderManagementWatchOrdersClient struct {
+	grpc.ClientStream
+}
+
+func (x *orderManagementWatchOrdersClient) Recv() (*OrderEvent, error) {
+	m := new(OrderEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *orderManagementClient) CancelOrder(ctx context.Context, in *CancelOrderRequest, opts ...grpc.CallOption) (*Order, error) {
+	out := new(Order)
+	err := c.cc.Invoke(ctx, "/ecommerce.OrderManagement/cancelOrder", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderManagementClient) DeleteOrder(ctx context.Context, in *DeleteOrderRequest, opts ...grpc.CallOption) (*wrappers.StringValue, error) {
+	out := new(wrappers.StringValue)
+	err := c.cc.Invoke(ctx, "/ecommerce.OrderManagement/deleteOrder", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderManagementClient) PatchOrder(ctx context.Context, in *PatchOrderRequest, opts ...grpc.CallOption) (*Order, error) {
+	out := new(Order)
+	err := c.cc.Invoke(ctx, "/ecommerce.OrderManagement/patchOrder", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderManagementClient) UpdateOrdersV2(ctx context.Context, opts ...grpc.CallOption) (OrderManagement_UpdateOrdersV2Client, error) {
+	stream, err := c.cc.NewStream(ctx, &OrderManagement_ServiceDesc.Streams[4], "/ecommerce.OrderManagement/updateOrdersV2", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &orderManagementUpdateOrdersV2Client{stream}
+	return x, nil
+}
+
+type OrderManagement_UpdateOrdersV2Client interface {
+	Send(*Order) error
+	Recv() (*UpdateOrderAck, error)
+	grpc.ClientStream
+}
+
+type orderManagementUpdateOrdersV2Client struct {
+	grpc.ClientStream
+}
+
+func (x *orderManagementUpdateOrdersV2Client) Send(m *Order) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *orderManagementUpdateOrdersV2Client) Recv() (*UpdateOrderAck, error) {
+	m := new(UpdateOrderAck)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *orderManagementClient) GetShipment(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*CombinedShipment, error) {
+	out := new(CombinedShipment)
+	err := c.cc.Invoke(ctx, "/ecommerce.OrderManagement/getShipment", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderManagementClient) ListShipments(ctx context.Context, in *ListShipmentsRequest, opts ...grpc.CallOption) (OrderManagement_ListShipmentsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &OrderManagement_ServiceDesc.Streams[5], "/ecommerce.OrderManagement/listShipments", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &orderManagementListShipmentsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type OrderManagement_ListShipmentsClient interface {
+	Recv() (*CombinedShipment, error)
+	grpc.ClientStream
+}
+
+type orderManagementListShipmentsClient struct {
+	grpc.ClientStream
+}
+
+func (x *orderManagementListShipmentsClient) Recv() (*CombinedShipment, error) {
 	m := new(CombinedShipment)
 	if err := x.ClientStream.RecvMsg(m); err != nil {
 		return nil, err
@@ -161,15 +341,148 @@ func (x *orderManagementProcessOrdersClient) Recv() (*CombinedShipment, error) {
 	return m, nil
 }
 
+func (c *orderManagementClient) WatchShipment(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (OrderManagement_WatchShipmentClient, error) {
+	stream, err := c.cc.NewStream(ctx, &OrderManagement_ServiceDesc.Streams[6], "/ecommerce.OrderManagement/watchShipment", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &orderManagementWatchShipmentClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type OrderManagement_WatchShipmentClient interface {
+	Recv() (*CombinedShipment, error)
+	grpc.ClientStream
+}
+
+type orderManagementWatchShipmentClient struct {
+	grpc.ClientStream
+}
+
+func (x *orderManagementWatchShipmentClient) Recv() (*CombinedShipment, error) {
+	m := new(CombinedShipment)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *orderManagementClient) ExportOrders(ctx context.Context, in *ExportOrdersRequest, opts ...grpc.CallOption) (OrderManagement_ExportOrdersClient, error) {
+	stream, err := c.cc.NewStream(ctx, &OrderManagement_ServiceDesc.Streams[7], "/ecommerce.OrderManagement/exportOrders", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &orderManagementExportOrdersClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type OrderManagement_ExportOrdersClient interface {
+	Recv() (*ExportOrdersResponse, error)
+	grpc.ClientStream
+}
+
+type orderManagementExportOrdersClient struct {
+	grpc.ClientStream
+}
+
+func (x *orderManagementExportOrdersClient) Recv() (*ExportOrdersResponse, error) {
+	m := new(ExportOrdersResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *orderManagementClient) ImportOrders(ctx context.Context, opts ...grpc.CallOption) (OrderManagement_ImportOrdersClient, error) {
+	stream, err := c.cc.NewStream(ctx, &OrderManagement_ServiceDesc.Streams[8], "/ecommerce.OrderManagement/importOrders", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &orderManagementImportOrdersClient{stream}
+	return x, nil
+}
+
+type OrderManagement_ImportOrdersClient interface {
+	Send(*ImportOrdersRequest) error
+	CloseAndRecv() (*ImportOrdersSummary, error)
+	grpc.ClientStream
+}
+
+type orderManagementImportOrdersClient struct {
+	grpc.ClientStream
+}
+
+func (x *orderManagementImportOrdersClient) Send(m *ImportOrdersRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *orderManagementImportOrdersClient) CloseAndRecv() (*ImportOrdersSummary, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ImportOrdersSummary)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *orderManagementClient) GetOrderHistory(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (OrderManagement_GetOrderHistoryClient, error) {
+	stream, err := c.cc.NewStream(ctx, &OrderManagement_ServiceDesc.Streams[9], "/ecommerce.OrderManagement/getOrderHistory", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &orderManagementGetOrderHistoryClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type OrderManagement_GetOrderHistoryClient interface {
+	Recv() (*AuditEntry, error)
+	grpc.ClientStream
+}
+
+type orderManagementGetOrderHistoryClient struct {
+	grpc.ClientStream
+}
+
+func (x *orderManagementGetOrderHistoryClient) Recv() (*AuditEntry, error) {
+	m := new(AuditEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // OrderManagementServer is the server API for OrderManagement service.
 // All implementations must embed UnimplementedOrderManagementServer
 // for forward compatibility
 type OrderManagementServer interface {
+	// 订单ID已经存在时返回ALREADY_EXISTS，已有的订单只能通过updateOrders和生命周期迁移修改。
+	// 服务器端配置了ProductInfo时，为订单条目预留库存，库存不足时返回FAILED_PRECONDITION，订单不会被写入。
 	AddOrder(context.Context, *Order) (*wrappers.StringValue, error)
 	// 检索订单的远程方法。
 	GetOrder(context.Context, *wrappers.StringValue) (*Order, error)
 	// 通过返回0rder消息的stream定义服务器端流。
-	SearchOrders(*wrappers.StringValue, OrderManagement_SearchOrdersServer) error
+	// 分页时，下一页的令牌通过trailer元数据next-page-token返回。
+	SearchOrders(*SearchOrdersRequest, OrderManagement_SearchOrdersServer) error
 	// 使用strean order 作为updateOrders方法的参数，表明updateOrders会接收来自客户端的多条消息作为输入。
 	// 因为服务器端只发送一个响应，所以返回值是单一的字符串消息。
 	UpdateOrders(OrderManagement_UpdateOrdersServer) error
@@ -177,7 +490,44 @@ type OrderManagementServer interface {
 	// 因此，通过将方法参数和返回参数均声明为stream，可以定义双向流的RPC方法。
 	// 发货组合的消息也是通过服务定义声明的，它包含了订单元素的列表。
 	// 在双向流RPC模式中，将方法参数和返回参数均声明为stream
+	// 订单合并为发货组合的策略可以通过元数据shipment-planner选择，例如"max-orders:5"。
+	// 服务器端为每个订单ID返回一个结果：订单被分配到的发货组合，或者无法处理该订单的原因。
+	// 发货组合在批次发送时以单独的消息返回。无法处理的订单不会中断流。
 	ProcessOrders(OrderManagement_ProcessOrdersServer) error
+	// 按照订单生命周期把订单迁移到新的状态，非法的状态迁移会返回FailedPrecondition，
+	// 订单版本与expectedVersion不一致时返回Aborted。
+	TransitionOrder(context.Context, *TransitionOrderRequest) (*Order, error)
+	// 订阅订单的变更事件流。客户端断线重连时可以带上最后收到的revision，补齐错过的事件。
+	// 如果该revision已经被移出服务器端的历史缓冲区，返回OutOfRange。
+	WatchOrders(*WatchOrdersRequest, OrderManagement_WatchOrdersServer) error
+	// 取消订单并记录取消原因。已发货或已完成的订单不能取消，返回FailedPrecondition。
+	CancelOrder(context.Context, *CancelOrderRequest) (*Order, error)
+	// 管理员接口：删除订单并留下墓碑。在保留期内，getOrder对已删除的订单返回带有ResourceInfo详情的NotFound。
+	DeleteOrder(context.Context, *DeleteOrderRequest) (*wrappers.StringValue, error)
+	// 部分更新订单：只修改updateMask中列出的字段，其他字段保持不变。
+	// 可以修改的字段为items、description、price、exactPrice、destination、weightGrams和priority，
+	// 未知或不能修改的字段返回InvalidArgument，BadRequest详情中列出所有无效的路径。
+	// 服务器端配置了ProductInfo时，修改后的items与addOrder一样通过ProductInfo解析，价格由目录价格计算，
+	// 修改price或exactPrice返回InvalidArgument。
+	PatchOrder(context.Context, *PatchOrderRequest) (*Order, error)
+	// updateOrders的双向流版本：服务器端对每个收到的订单返回一个确认，说明订单是否更新成功以及更新后的版本。
+	// 单个订单更新失败不会中断流，客户端可以只重试失败的订单。
+	UpdateOrdersV2(OrderManagement_UpdateOrdersV2Server) error
+	// 按ID检索发货组合。
+	GetShipment(context.Context, *wrappers.StringValue) (*CombinedShipment, error)
+	// 按目的地和状态列出发货组合，按创建时间排序。
+	ListShipments(*ListShipmentsRequest, OrderManagement_ListShipmentsServer) error
+	// 订阅一个发货组合的变化：先发送发货组合的当前状态，之后每次变化发送一次，发货组合发出后结束流。
+	WatchShipment(*wrappers.StringValue, OrderManagement_WatchShipmentServer) error
+	// 导出所有订单（包括已取消的订单）。每条消息包含编码后文件的一段数据，按顺序拼接即为完整的文件。
+	ExportOrders(*ExportOrdersRequest, OrderManagement_ExportOrdersServer) error
+	// 导入订单。第一条消息必须是导入选项，之后的消息是按顺序拼接的文件数据，可以在任意位置分段。
+	// 服务器端在读完所有数据后才写入订单，返回导入的汇总报告。
+	// 服务器端配置了ProductInfo时，每条记录的items与addOrder一样通过ProductInfo解析，价格使用目录价格，
+	// 含有未知商品的记录作为无效记录报告；ProductInfo不可用时返回UNAVAILABLE，不写入任何订单。
+	ImportOrders(OrderManagement_ImportOrdersServer) error
+	// 按时间顺序返回订单的审计历史。订单被删除后仍然可以查询。
+	GetOrderHistory(*wrappers.StringValue, OrderManagement_GetOrderHistoryServer) error
 	mustEmbedUnimplementedOrderManagementServer()
 }
 
@@ -191,7 +541,7 @@ func (UnimplementedOrderManagementServer) AddOrder(context.Context, *Order) (*wr
 func (UnimplementedOrderManagementServer) GetOrder(context.Context, *wrappers.StringValue) (*Order, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetOrder not implemented")
 }
-func (UnimplementedOrderManagementServer) SearchOrders(*wrappers.StringValue, OrderManagement_SearchOrdersServer) error {
+func (UnimplementedOrderManagementServer) SearchOrders(*SearchOrdersRequest, OrderManagement_SearchOrdersServer) error {
 	return status.Errorf(codes.Unimplemented, "method SearchOrders not implemented")
 }
 func (UnimplementedOrderManagementServer) UpdateOrders(OrderManagement_UpdateOrdersServer) error {
@@ -200,6 +550,42 @@ func (UnimplementedOrderManagementServer) UpdateOrders(OrderManagement_UpdateOrd
 func (UnimplementedOrderManagementServer) ProcessOrders(OrderManagement_ProcessOrdersServer) error {
 	return status.Errorf(codes.Unimplemented, "method ProcessOrders not implemented")
 }
+func (UnimplementedOrderManagementServer) TransitionOrder(context.Context, *TransitionOrderRequest) (*Order, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TransitionOrder not implemented")
+}
+func (UnimplementedOrderManagementServer) WatchOrders(*WatchOrdersRequest, OrderManagement_WatchOrdersServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchOrders not implemented")
+}
+func (UnimplementedOrderManagementServer) CancelOrder(context.Context, *CancelOrderRequest) (*Order, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelOrder not implemented")
+}
+func (UnimplementedOrderManagementServer) DeleteOrder(context.Context, *DeleteOrderRequest) (*wrappers.StringValue, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteOrder not implemented")
+}
+func (UnimplementedOrderManagementServer) PatchOrder(context.Context, *PatchOrderRequest) (*Order, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PatchOrder not implemented")
+}
+func (UnimplementedOrderManagementServer) UpdateOrdersV2(OrderManagement_UpdateOrdersV2Server) error {
+	return status.Errorf(codes.Unimplemented, "method UpdateOrdersV2 not implemented")
+}
+func (UnimplementedOrderManagementServer) GetShipment(context.Context, *wrappers.StringValue) (*CombinedShipment, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetShipment not implemented")
+}
+func (UnimplementedOrderManagementServer) ListShipments(*ListShipmentsRequest, OrderManagement_ListShipmentsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListShipments not implemented")
+}
+func (UnimplementedOrderManagementServer) WatchShipment(*wrappers.StringValue, OrderManagement_WatchShipmentServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchShipment not implemented")
+}
+func (UnimplementedOrderManagementServer) ExportOrders(*ExportOrdersRequest, OrderManagement_ExportOrdersServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportOrders not implemented")
+}
+func (UnimplementedOrderManagementServer) ImportOrders(OrderManagement_ImportOrdersServer) error {
+	return status.Errorf(codes.Unimplemented, "method ImportOrders not implemented")
+}
+func (UnimplementedOrderManagementServer) GetOrderHistory(*wrappers.StringValue, OrderManagement_GetOrderHistoryServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetOrderHistory not implemented")
+}
 func (UnimplementedOrderManagementServer) mustEmbedUnimplementedOrderManagementServer() {}
 
 // UnsafeOrderManagementServer may be embedded to opt out of forward compatibility for this service.
@@ -250,7 +636,7 @@ func _OrderManagement_GetOrder_Handler(srv interface{}, ctx context.Context, dec
 }
 
 func _OrderManagement_SearchOrders_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(wrappers.StringValue)
+	m := new(SearchOrdersRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
@@ -301,7 +687,7 @@ func _OrderManagement_ProcessOrders_Handler(srv interface{}, stream grpc.ServerS
 }
 
 type OrderManagement_ProcessOrdersServer interface {
-	Send(*CombinedShipment) error
+	Send(*ProcessOrdersResponse) error
 	Recv() (*wrappers.StringValue, error)
 	grpc.ServerStream
 }
@@ -310,7 +696,7 @@ type orderManagementProcessOrdersServer struct {
 	grpc.ServerStream
 }
 
-func (x *orderManagementProcessOrdersServer) Send(m *CombinedShipment) error {
+func (x *orderManagementProcessOrdersServer) Send(m *ProcessOrdersResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
@@ -322,6 +708,253 @@ func (x *orderManagementProcessOrdersServer) Recv() (*wrappers.StringValue, erro
 	return m, nil
 }
 
+func _OrderManagement_TransitionOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransitionOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderManagementServer).TransitionOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.OrderManagement/transitionOrder",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderManagementServer).TransitionOrder(ctx, req.(*TransitionOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderManagement_WatchOrders_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchOrdersRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OrderManagementServer).WatchOrders(m, &orderManagementWatchOrdersServer{stream})
+}
+
+type OrderManagement_WatchOrdersServer interface {
+	Send(*OrderEvent) error
+	grpc.ServerStream
+}
+
+type orderManagementWatchOrdersServer struct {
+	grpc.ServerStream
+}
+
+func (x *orderManagementWatchOrdersServer) Send(m *OrderEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _OrderManagement_CancelOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderManagementServer).CancelOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.OrderManagement/cancelOrder",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderManagementServer).CancelOrder(ctx, req.(*CancelOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderManagement_DeleteOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderManagementServer).DeleteOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.OrderManagement/deleteOrder",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderManagementServer).DeleteOrder(ctx, req.(*DeleteOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderManagement_PatchOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PatchOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderManagementServer).PatchOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.OrderManagement/patchOrder",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderManagementServer).PatchOrder(ctx, req.(*PatchOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderManagement_UpdateOrdersV2_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(OrderManagementServer).UpdateOrdersV2(&orderManagementUpdateOrdersV2Server{stream})
+}
+
+type OrderManagement_UpdateOrdersV2Server interface {
+	Send(*UpdateOrderAck) error
+	Recv() (*Order, error)
+	grpc.ServerStream
+}
+
+type orderManagementUpdateOrdersV2Server struct {
+	grpc.ServerStream
+}
+
+func (x *orderManagementUpdateOrdersV2Server) Send(m *UpdateOrderAck) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *orderManagementUpdateOrdersV2Server) Recv() (*Order, error) {
+	m := new(Order)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _OrderManagement_GetShipment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(wrappers.StringValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderManagementServer).GetShipment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.OrderManagement/getShipment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderManagementServer).GetShipment(ctx, req.(*wrappers.StringValue))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderManagement_ListShipments_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListShipmentsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OrderManagementServer).ListShipments(m, &orderManagementListShipmentsServer{stream})
+}
+
+type OrderManagement_ListShipmentsServer interface {
+	Send(*CombinedShipment) error
+	grpc.ServerStream
+}
+
+type orderManagementListShipmentsServer struct {
+	grpc.ServerStream
+}
+
+func (x *orderManagementListShipmentsServer) Send(m *CombinedShipment) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _OrderManagement_WatchShipment_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(wrappers.StringValue)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OrderManagementServer).WatchShipment(m, &orderManagementWatchShipmentServer{stream})
+}
+
+type OrderManagement_WatchShipmentServer interface {
+	Send(*CombinedShipment) error
+	grpc.ServerStream
+}
+
+type orderManagementWatchShipmentServer struct {
+	grpc.ServerStream
+}
+
+func (x *orderManagementWatchShipmentServer) Send(m *CombinedShipment) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _OrderManagement_ExportOrders_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportOrdersRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OrderManagementServer).ExportOrders(m, &orderManagementExportOrdersServer{stream})
+}
+
+type OrderManagement_ExportOrdersServer interface {
+	Send(*ExportOrdersResponse) error
+	grpc.ServerStream
+}
+
+type orderManagementExportOrdersServer struct {
+	grpc.ServerStream
+}
+
+func (x *orderManagementExportOrdersServer) Send(m *ExportOrdersResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _OrderManagement_ImportOrders_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(OrderManagementServer).ImportOrders(&orderManagementImportOrdersServer{stream})
+}
+
+type OrderManagement_ImportOrdersServer interface {
+	SendAndClose(*ImportOrdersSummary) error
+	Recv() (*ImportOrdersRequest, error)
+	grpc.ServerStream
+}
+
+type orderManagementImportOrdersServer struct {
+	grpc.ServerStream
+}
+
+func (x *orderManagementImportOrdersServer) SendAndClose(m *ImportOrdersSummary) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *orderManagementImportOrdersServer) Recv() (*ImportOrdersRequest, error) {
+	m := new(ImportOrdersRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _OrderManagement_GetOrderHistory_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(wrappers.StringValue)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OrderManagementServer).GetOrderHistory(m, &orderManagementGetOrderHistoryServer{stream})
+}
+
+type OrderManagement_GetOrderHistoryServer interface {
+	Send(*AuditEntry) error
+	grpc.ServerStream
+}
+
+type orderManagementGetOrderHistoryServer struct {
+	grpc.ServerStream
+}
+
+func (x *orderManagementGetOrderHistoryServer) Send(m *AuditEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // OrderManagement_ServiceDesc is the grpc.ServiceDesc for OrderManagement service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -337,6 +970,26 @@ var OrderManagement_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "getOrder",
 			Handler:    _OrderManagement_GetOrder_Handler,
 		},
+		{
+			MethodName: "transitionOrder",
+			Handler:    _OrderManagement_TransitionOrder_Handler,
+		},
+		{
+			MethodName: "cancelOrder",
+			Handler:    _OrderManagement_CancelOrder_Handler,
+		},
+		{
+			MethodName: "deleteOrder",
+			Handler:    _OrderManagement_DeleteOrder_Handler,
+		},
+		{
+			MethodName: "patchOrder",
+			Handler:    _OrderManagement_PatchOrder_Handler,
+		},
+		{
+			MethodName: "getShipment",
+			Handler:    _OrderManagement_GetShipment_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -355,6 +1008,42 @@ var OrderManagement_ServiceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "watchOrders",
+			Handler:       _OrderManagement_WatchOrders_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "updateOrdersV2",
+			Handler:       _OrderManagement_UpdateOrdersV2_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "listShipments",
+			Handler:       _OrderManagement_ListShipments_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "watchShipment",
+			Handler:       _OrderManagement_WatchShipment_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "exportOrders",
+			Handler:       _OrderManagement_ExportOrders_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "importOrders",
+			Handler:       _OrderManagement_ImportOrders_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "getOrderHistory",
+			Handler:       _OrderManagement_GetOrderHistory_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "order_management.proto",
 }
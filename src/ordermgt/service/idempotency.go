@@ -0,0 +1,304 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	pb "ordermgt/service/ecommerce"
+)
+
+const (
+	// 客户端在重试时携带相同的idempotency-key元数据，服务器端保证请求只被执行一次。
+	idempotencyKeyHeader = "idempotency-key"
+	maxIdempotencyKeyLen = 256
+	// defaultIdempotencyTTL 是服务器端保留请求结果的默认时间，超过这个时间后相同的键会被当作新请求。
+	defaultIdempotencyTTL = 24 * time.Hour
+	// defaultIdempotencyMaxEntries 是服务器端默认最多保留的请求结果数，超过后最早的结果被淘汰。
+	defaultIdempotencyMaxEntries = 100000
+	// maxIdempotentStreamBytes 是流拦截器为计算请求摘要而缓存的消息的总字节数上限，
+	// 更大的客户端流不缓存，直接交给处理程序执行，不提供幂等保证。
+	maxIdempotentStreamBytes = 4 << 20
+)
+
+// idempotentMethods 列出了支持幂等键的方法，以及创建该方法请求消息的函数。
+// 客户端流方法在执行之前需要读取全部请求，因此需要知道请求的类型。
+var idempotentMethods = map[string]func() proto.Message{
+	"/ecommerce.OrderManagement/addOrder":     func() proto.Message { return &pb.Order{} },
+	"/ecommerce.OrderManagement/updateOrders": func() proto.Message { return &pb.Order{} },
+}
+
+// idempotencyEntry 是一个幂等键对应的请求结果。done在第一次执行结束后关闭。
+type idempotencyEntry struct {
+	key         string
+	fingerprint [sha256.Size]byte
+	expires     time.Time
+	done        chan struct{}
+	cached      bool
+	resp        proto.Message
+	err         error
+	// elem 是条目在idempotencyCache.order中的位置。
+	elem *list.Element
+}
+
+// idempotencyCache 按幂等键保存请求的响应或错误状态，并在ttl内对重复的请求重放结果。
+// 最多保留maxEntries个条目，达到上限时淘汰最早的已经完成的条目。
+type idempotencyCache struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	// order 按创建时间保存所有条目，用于清理过期的条目和淘汰最早的条目。
+	order *list.List
+}
+
+func newIdempotencyCache(ttl time.Duration, maxEntries int) *idempotencyCache {
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultIdempotencyMaxEntries
+	}
+	return &idempotencyCache{ttl: ttl, maxEntries: maxEntries, now: time.Now, entries: make(map[string]*idempotencyEntry), order: list.New()}
+}
+
+// fingerprint 计算方法和请求消息的摘要，用来判断重复的请求是否与原始请求相同。
+func fingerprint(method string, msgs []proto.Message) ([sha256.Size]byte, error) {
+	h := sha256.New()
+	h.Write([]byte(method))
+	for _, msg := range msgs {
+		b, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+		if err != nil {
+			return [sha256.Size]byte{}, err
+		}
+		var n [8]byte
+		binary.LittleEndian.PutUint64(n[:], uint64(len(b)))
+		h.Write(n[:])
+		h.Write(b)
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// begin 查找幂等键对应的条目。键不存在时创建新条目并返回true，调用方负责执行请求并调用finish。
+// 键已经用于不同的请求时返回AlreadyExists；条目数达到上限、并且所有条目都还在执行时返回ResourceExhausted。
+func (c *idempotencyCache) begin(key string, fp [sha256.Size]byte) (*idempotencyEntry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for front := c.order.Front(); front != nil && !front.Value.(*idempotencyEntry).expires.After(now); front = c.order.Front() {
+		c.removeLocked(front.Value.(*idempotencyEntry))
+	}
+	if e, ok := c.entries[key]; ok {
+		if e.fingerprint != fp {
+			return nil, false, status.Errorf(codes.AlreadyExists, "idempotency key %q was already used for a different request", key)
+		}
+		return e, false, nil
+	}
+	if len(c.entries) >= c.maxEntries && !c.evictLocked() {
+		return nil, false, status.Errorf(codes.ResourceExhausted, "too many requests with an %s are in progress", idempotencyKeyHeader)
+	}
+	e := &idempotencyEntry{key: key, fingerprint: fp, expires: now.Add(c.ttl), done: make(chan struct{})}
+	c.entries[key] = e
+	e.elem = c.order.PushBack(e)
+	return e, true, nil
+}
+
+// evictLocked 淘汰最早的已经完成的条目，没有可以淘汰的条目时返回false。
+// 还在执行的条目不能淘汰，否则相同键的重试会与它同时执行。
+func (c *idempotencyCache) evictLocked() bool {
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		if e := elem.Value.(*idempotencyEntry); e.cached {
+			c.removeLocked(e)
+			return true
+		}
+	}
+	return false
+}
+
+func (c *idempotencyCache) removeLocked(e *idempotencyEntry) {
+	if c.entries[e.key] == e {
+		delete(c.entries, e.key)
+	}
+	if e.elem != nil {
+		c.order.Remove(e.elem)
+		e.elem = nil
+	}
+}
+
+// replayable 判断请求的结果是否可以重放：成功的结果，以及只由请求本身决定、重试也会得到的客户端错误。
+// 其他错误（例如Unavailable、Internal、取消或超时）可能是暂时的，重试时应当重新执行；
+// FailedPrecondition取决于服务器端的状态（例如库存或订单状态），状态变化后重试可能成功，也不重放。
+func replayable(err error) bool {
+	switch status.Code(err) {
+	case codes.OK, codes.InvalidArgument, codes.AlreadyExists:
+		return true
+	}
+	return false
+}
+
+// finish 记录请求的结果并唤醒等待相同键的请求。不能重放的结果不保存，下一次重试会重新执行。
+func (c *idempotencyCache) finish(e *idempotencyEntry, resp proto.Message, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if replayable(err) {
+		e.cached, e.resp, e.err = true, resp, err
+	} else {
+		c.removeLocked(e)
+	}
+	close(e.done)
+}
+
+// do 对每个幂等键只执行一次fn，重复的请求等待第一次执行结束后得到相同的响应或错误。
+func (c *idempotencyCache) do(ctx context.Context, key string, fp [sha256.Size]byte, fn func() (proto.Message, error)) (proto.Message, error) {
+	for {
+		e, first, err := c.begin(key, fp)
+		if err != nil {
+			return nil, err
+		}
+		if first {
+			resp, err := fn()
+			c.finish(e, resp, err)
+			return resp, err
+		}
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		if e.cached {
+			return e.resp, e.err
+		}
+	}
+}
+
+// idempotencyKey 从调用的元数据中读取幂等键，没有设置时返回空字符串。
+// 不同租户的幂等键互不影响，返回的键带有租户前缀。
+func idempotencyKey(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", nil
+	}
+	values := md.Get(idempotencyKeyHeader)
+	if len(values) == 0 {
+		return "", nil
+	}
+	if len(values[0]) > maxIdempotencyKeyLen {
+		return "", status.Errorf(codes.InvalidArgument, "%s must not be longer than %d bytes", idempotencyKeyHeader, maxIdempotencyKeyLen)
+	}
+	return tenantOf(ctx) + "/" + values[0], nil
+}
+
+// Server :: Unary Interceptor
+// 服务器端一元拦截器：对带有幂等键的addOrder请求只执行一次，重复的请求重放第一次的响应。
+func (c *idempotencyCache) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	key, err := idempotencyKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	msg, ok := req.(proto.Message)
+	if _, idempotent := idempotentMethods[info.FullMethod]; !idempotent || key == "" || !ok {
+		return handler(ctx, req)
+	}
+	fp, err := fingerprint(info.FullMethod, []proto.Message{msg})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to fingerprint request : %v", err)
+	}
+	resp, err := c.do(ctx, key, fp, func() (proto.Message, error) {
+		resp, err := handler(ctx, req)
+		m, _ := resp.(proto.Message)
+		return m, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replayStream 把拦截器预先读取的请求交给处理程序，并记录处理程序发送的响应。
+// live为true时，预先读取的请求之后继续从客户端流中读取。
+type replayStream struct {
+	grpc.ServerStream
+	msgs []proto.Message
+	live bool
+	sent proto.Message
+}
+
+func (w *replayStream) RecvMsg(m interface{}) error {
+	if len(w.msgs) == 0 {
+		if w.live {
+			return w.ServerStream.RecvMsg(m)
+		}
+		return io.EOF
+	}
+	dst := m.(proto.Message)
+	proto.Reset(dst)
+	proto.Merge(dst, w.msgs[0])
+	w.msgs = w.msgs[1:]
+	return nil
+}
+
+func (w *replayStream) SendMsg(m interface{}) error {
+	if msg, ok := m.(proto.Message); ok {
+		w.sent = proto.Clone(msg)
+	}
+	return w.ServerStream.SendMsg(m)
+}
+
+// Server :: Stream Interceptor
+// 服务器端流拦截器：对带有幂等键的updateOrders请求，先读取客户端流中的全部订单，
+// 只有在这个键没有执行过时才调用处理程序，否则重放第一次的响应。
+// 客户端流超过maxIdempotentStreamBytes时不再缓存，已经读取的订单和流中剩余的订单直接交给处理程序。
+func (c *idempotencyCache) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	key, err := idempotencyKey(ss.Context())
+	if err != nil {
+		return err
+	}
+	newRequest, idempotent := idempotentMethods[info.FullMethod]
+	if !idempotent || key == "" || !info.IsClientStream || info.IsServerStream {
+		return handler(srv, ss)
+	}
+	var msgs []proto.Message
+	size := 0
+	for {
+		msg := newRequest()
+		if err := ss.RecvMsg(msg); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		msgs = append(msgs, msg)
+		if size += proto.Size(msg); size > maxIdempotentStreamBytes {
+			log.Printf("%s with %s %q exceeds %d bytes, executing without idempotency", info.FullMethod, idempotencyKeyHeader, key, maxIdempotentStreamBytes)
+			return handler(srv, &replayStream{ServerStream: ss, msgs: msgs, live: true})
+		}
+	}
+	fp, err := fingerprint(info.FullMethod, msgs)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to fingerprint request : %v", err)
+	}
+	replayed := true
+	resp, err := c.do(ss.Context(), key, fp, func() (proto.Message, error) {
+		replayed = false
+		stream := &replayStream{ServerStream: ss, msgs: msgs}
+		err := handler(srv, stream)
+		return stream.sent, err
+	})
+	if replayed && err == nil && resp != nil {
+		return ss.SendMsg(resp)
+	}
+	return err
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	pb "ordermgt/service/ecommerce"
+)
+
+func TestIdempotencyCache_Do(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newIdempotencyCache(time.Minute, 0)
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+	fp := sha256.Sum256([]byte("request"))
+
+	calls := 0
+	fn := func() (proto.Message, error) {
+		calls++
+		return &pb.Order{Id: "102"}, nil
+	}
+	c.do(ctx, "k1", fp, fn)
+	resp, err := c.do(ctx, "k1", fp, fn)
+	if err != nil || resp.(*pb.Order).Id != "102" || calls != 1 {
+		t.Fatalf("duplicate request: resp %v, err %v, calls %d", resp, err, calls)
+	}
+	if _, err := c.do(ctx, "k1", sha256.Sum256([]byte("other")), fn); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("reused key with different payload returned %v, want AlreadyExists", err)
+	}
+
+	// 原始请求的错误状态同样会被重放。
+	failed := func() (proto.Message, error) {
+		calls++
+		return nil, status.Errorf(codes.InvalidArgument, "rejected")
+	}
+	c.do(ctx, "k2", fp, failed)
+	if _, err := c.do(ctx, "k2", fp, failed); status.Code(err) != codes.InvalidArgument || calls != 2 {
+		t.Fatalf("replayed error %v after %d calls", err, calls)
+	}
+
+	// 超时的请求不保存结果，重试会重新执行。
+	timedOut := func() (proto.Message, error) {
+		calls++
+		return nil, status.Errorf(codes.DeadlineExceeded, "too slow")
+	}
+	c.do(ctx, "k3", fp, timedOut)
+	c.do(ctx, "k3", fp, fn)
+	if calls != 4 {
+		t.Fatalf("request after a timeout was not executed again, calls %d", calls)
+	}
+
+	now = now.Add(time.Minute)
+	c.do(ctx, "k1", fp, fn)
+	if calls != 5 {
+		t.Fatalf("expired key was replayed, calls %d", calls)
+	}
+	if len(c.entries) != 1 || c.order.Len() != 1 {
+		t.Fatalf("expired entries not purged: %d entries, %d queued", len(c.entries), c.order.Len())
+	}
+}
+
+// 只重放成功的结果和由请求本身决定的客户端错误，暂时性的错误在重试时重新执行。
+func TestIdempotencyCache_ReplayableErrors(t *testing.T) {
+	c := newIdempotencyCache(time.Minute, 0)
+	ctx := context.Background()
+	fp := sha256.Sum256([]byte("request"))
+	for _, tt := range []struct {
+		code  codes.Code
+		calls int
+	}{
+		{codes.InvalidArgument, 1}, {codes.AlreadyExists, 1},
+		{codes.FailedPrecondition, 2}, {codes.Unavailable, 2}, {codes.Internal, 2}, {codes.Unknown, 2}, {codes.ResourceExhausted, 2}, {codes.Canceled, 2},
+	} {
+		calls := 0
+		fn := func() (proto.Message, error) {
+			calls++
+			return nil, status.Errorf(tt.code, "failed")
+		}
+		c.do(ctx, tt.code.String(), fp, fn)
+		c.do(ctx, tt.code.String(), fp, fn)
+		if calls != tt.calls {
+			t.Errorf("%s: request executed %d times, want %d", tt.code, calls, tt.calls)
+		}
+	}
+	if len(c.entries) != 2 || c.order.Len() != 2 {
+		t.Errorf("cache keeps %d entries, %d queued, want only the 2 replayable errors", len(c.entries), c.order.Len())
+	}
+}
+
+// 条目数达到上限时淘汰最早的已完成条目，所有条目都在执行时拒绝新的键。
+func TestIdempotencyCache_MaxEntries(t *testing.T) {
+	c := newIdempotencyCache(time.Minute, 2)
+	ctx := context.Background()
+	fp := sha256.Sum256([]byte("request"))
+	calls := 0
+	fn := func() (proto.Message, error) {
+		calls++
+		return &pb.Order{Id: "102"}, nil
+	}
+	for _, key := range []string{"k1", "k2", "k3", "k2"} {
+		c.do(ctx, key, fp, fn)
+	}
+	if calls != 3 || len(c.entries) != 2 || c.entries["k1"] != nil {
+		t.Fatalf("after 3 keys: %d calls, entries %v", calls, c.entries)
+	}
+	c.do(ctx, "k1", fp, fn)
+	if calls != 4 {
+		t.Fatalf("evicted key was replayed, calls %d", calls)
+	}
+
+	// 两个条目都在执行时，新的键被拒绝，已有的键仍然等待原来的执行。
+	release := make(chan struct{})
+	done := make(chan struct{})
+	for _, key := range []string{"k4", "k5"} {
+		started := make(chan struct{})
+		go func(key string) {
+			c.do(ctx, key, fp, func() (proto.Message, error) {
+				close(started)
+				<-release
+				return &pb.Order{Id: key}, nil
+			})
+			done <- struct{}{}
+		}(key)
+		<-started
+	}
+	if _, err := c.do(ctx, "k6", fp, fn); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("new key while the cache is full of running requests: %v", err)
+	}
+	close(release)
+	<-done
+	<-done
+	if _, err := c.do(ctx, "k6", fp, fn); err != nil || len(c.entries) != 2 {
+		t.Fatalf("new key after the running requests finished: %v, %d entries", err, len(c.entries))
+	}
+}
+
+// 并发的重复请求等待第一个请求完成，而不是同时执行。
+func TestIdempotencyCache_ConcurrentDuplicates(t *testing.T) {
+	c := newIdempotencyCache(time.Minute, 0)
+	fp := sha256.Sum256([]byte("request"))
+	var mu sync.Mutex
+	calls := 0
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.do(context.Background(), "k", fp, func() (proto.Message, error) {
+				mu.Lock()
+				calls++
+				mu.Unlock()
+				<-release
+				return &pb.Order{Id: "102"}, nil
+			})
+			if err != nil || resp.(*pb.Order).Id != "102" {
+				t.Errorf("do returned %v, %v", resp, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("request executed %d times, want 1", calls)
+	}
+}
+
+func startIdempotentServer(t *testing.T, store OrderStore) (pb.OrderManagementClient, func()) {
+	c := newIdempotencyCache(time.Minute, 0)
+	return startBufConnServer(t, newServer(store), grpc.UnaryInterceptor(c.unaryInterceptor), grpc.StreamInterceptor(c.streamInterceptor))
+}
+
+func TestServer_AddOrderIdempotencyKey(t *testing.T) {
+	store := newWatchedOrderStore(newMemoryOrderStore(defaultShardCount), 16)
+	client, stop := startIdempotentServer(t, store)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	order := &pb.Order{Id: "101", Items: []string{"iPhone XS"}, Destination: "San Jose, CA", Price: 1000}
+	keyCtx := metadata.AppendToOutgoingContext(ctx, idempotencyKeyHeader, "add-101")
+	first, err := client.AddOrder(keyCtx, order)
+	if err != nil {
+		t.Fatalf("AddOrder failed: %v", err)
+	}
+	retry, err := client.AddOrder(keyCtx, order)
+	if err != nil || retry.Value != first.Value {
+		t.Fatalf("retried AddOrder returned %v, %v", retry, err)
+	}
+	if store.Revision() != 1 {
+		t.Fatalf("retried AddOrder was applied again, revision %d", store.Revision())
+	}
+
+	order.Price = 2000
+	if _, err := client.AddOrder(keyCtx, order); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("reused key with a different order returned %v, want AlreadyExists", err)
+	}
+	// 不带幂等键的请求不受影响。
+	order.Id = "102"
+	if _, err := client.AddOrder(ctx, order); err != nil || store.Revision() != 2 {
+		t.Fatalf("AddOrder without key: %v, revision %d", err, store.Revision())
+	}
+}
+
+func TestServer_UpdateOrdersIdempotencyKey(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	client, stop := startIdempotentServer(t, store)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	keyCtx := metadata.AppendToOutgoingContext(ctx, idempotencyKeyHeader, "update-1")
+
+	update := func(ctx context.Context, orders ...*pb.Order) (string, error) {
+		stream, err := client.UpdateOrders(ctx)
+		if err != nil {
+			return "", err
+		}
+		for _, order := range orders {
+			stream.Send(order)
+		}
+		res, err := stream.CloseAndRecv()
+		return res.GetValue(), err
+	}
+	upd102 := &pb.Order{Id: "102", Items: []string{"Google Pixel Book"}, Destination: "Mountain View, CA", Price: 1100}
+	upd103 := &pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 500}
+	first, err := update(keyCtx, upd102, upd103)
+	if err != nil {
+		t.Fatalf("UpdateOrders failed: %v", err)
+	}
+	// 另一个客户端在重试之前修改了订单，重试不能覆盖这次修改。
+	store.Put(&pb.Order{Id: "102", Price: 1200})
+	retry, err := update(keyCtx, upd102, upd103)
+	if err != nil || retry != first {
+		t.Fatalf("retried UpdateOrders returned %q, %v, want %q", retry, err, first)
+	}
+	if order, _ := store.Get("102"); order.Price != 1200 {
+		t.Fatalf("retried UpdateOrders was applied again, price %v", order.Price)
+	}
+	if _, err := update(keyCtx, upd102); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("reused key with different orders returned %v, want AlreadyExists", err)
+	}
+}
+
+// 超过缓存上限的客户端流不缓存：处理程序仍然收到所有订单，重试时重新执行而不是重放。
+func TestServer_UpdateOrdersIdempotencyKeyOverLimit(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	client, stop := startIdempotentServer(t, store)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	keyCtx := metadata.AppendToOutgoingContext(ctx, idempotencyKeyHeader, "update-large")
+
+	const updates = 80
+	description := strings.Repeat("x", maxIdempotentStreamBytes/updates*2)
+	for attempt := 1; attempt <= 2; attempt++ {
+		stream, err := client.UpdateOrders(keyCtx)
+		if err != nil {
+			t.Fatalf("UpdateOrders failed: %v", err)
+		}
+		for i := 0; i < updates; i++ {
+			stream.Send(&pb.Order{Id: "102", Items: []string{"Google Pixel 3A"}, Description: description, Destination: "Mountain View, CA"})
+		}
+		if _, err := stream.CloseAndRecv(); err != nil {
+			t.Fatalf("attempt %d: UpdateOrders failed: %v", attempt, err)
+		}
+		if order, _ := store.Get("102"); order.Version != int64(1+attempt*updates) {
+			t.Fatalf("attempt %d: order 102 at version %d, want %d", attempt, order.Version, 1+attempt*updates)
+		}
+	}
+}
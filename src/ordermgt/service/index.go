@@ -0,0 +1,261 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	pb "ordermgt/service/ecommerce"
+)
+
+// ngramSize 是索引中n-gram的长度（按字符计）。
+const ngramSize = 3
+
+// orderIndex 是订单条目和描述上的倒排索引：postings把每个小写的词映射到包含它的订单的键的集合，
+// grams把词中的每个三字符片段映射到包含它的词的集合，用于子串查找。orderIndex本身不是并发安全的。
+type orderIndex struct {
+	postings map[string]map[string]struct{}
+	grams    map[string]map[string]struct{}
+	docTerms map[string][]string
+}
+
+func newOrderIndex() *orderIndex {
+	return &orderIndex{
+		postings: make(map[string]map[string]struct{}),
+		grams:    make(map[string]map[string]struct{}),
+		docTerms: make(map[string][]string),
+	}
+}
+
+// tokenize 把文本按非字母数字字符切分为小写的词。
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// ngrams 返回词中去重后的所有n-gram，词的长度小于ngramSize时返回nil。
+func ngrams(term string) []string {
+	runes := []rune(term)
+	seen := make(map[string]bool)
+	var grams []string
+	for i := 0; i+ngramSize <= len(runes); i++ {
+		gram := string(runes[i : i+ngramSize])
+		if !seen[gram] {
+			seen[gram] = true
+			grams = append(grams, gram)
+		}
+	}
+	return grams
+}
+
+// orderTerms 返回订单的条目和描述中去重后的词。
+func orderTerms(order *pb.Order) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, text := range append(append([]string{}, order.Items...), order.Description) {
+		for _, term := range tokenize(text) {
+			if !seen[term] {
+				seen[term] = true
+				terms = append(terms, term)
+			}
+		}
+	}
+	return terms
+}
+
+// matchesText 判断订单是否匹配查询：查询中的每个词都必须是订单中某个词的子串，忽略大小写，
+// 例如"phone"可以匹配"iPhone"。它与orderIndex.lookup的语义一致，在没有索引时用于逐个过滤订单。
+func matchesText(order *pb.Order, query string) bool {
+	terms := orderTerms(order)
+	for _, q := range tokenize(query) {
+		found := false
+		for _, term := range terms {
+			if strings.Contains(term, q) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (idx *orderIndex) put(order *pb.Order) {
+	key := keyOf(order)
+	idx.remove(key)
+	terms := orderTerms(order)
+	idx.docTerms[key] = terms
+	for _, term := range terms {
+		ids, ok := idx.postings[term]
+		if !ok {
+			ids = make(map[string]struct{})
+			idx.postings[term] = ids
+			for _, gram := range ngrams(term) {
+				if idx.grams[gram] == nil {
+					idx.grams[gram] = make(map[string]struct{})
+				}
+				idx.grams[gram][term] = struct{}{}
+			}
+		}
+		ids[key] = struct{}{}
+	}
+}
+
+func (idx *orderIndex) remove(id string) {
+	for _, term := range idx.docTerms[id] {
+		ids := idx.postings[term]
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(idx.postings, term)
+			for _, gram := range ngrams(term) {
+				delete(idx.grams[gram], term)
+				if len(idx.grams[gram]) == 0 {
+					delete(idx.grams, gram)
+				}
+			}
+		}
+	}
+	delete(idx.docTerms, id)
+}
+
+// termsContaining 返回索引中以q为子串的所有词。q不短于ngramSize时先用q的n-gram中包含的词最少的一个缩小候选范围，
+// 再逐个验证；更短的q无法用n-gram查找，只能检查所有的词。
+func (idx *orderIndex) termsContaining(q string) []string {
+	var terms []string
+	grams := ngrams(q)
+	if len(grams) == 0 {
+		for term := range idx.postings {
+			if strings.Contains(term, q) {
+				terms = append(terms, term)
+			}
+		}
+		return terms
+	}
+	candidates := idx.grams[grams[0]]
+	for _, gram := range grams[1:] {
+		if len(idx.grams[gram]) < len(candidates) {
+			candidates = idx.grams[gram]
+		}
+	}
+	for term := range candidates {
+		if strings.Contains(term, q) {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// lookup 返回匹配查询的订单ID，按ID升序排列。查询中没有任何词时返回nil和false，表示不做过滤。
+func (idx *orderIndex) lookup(query string) ([]string, bool) {
+	queryTerms := tokenize(query)
+	if len(queryTerms) == 0 {
+		return nil, false
+	}
+	var result map[string]struct{}
+	for _, q := range queryTerms {
+		matched := make(map[string]struct{})
+		for _, term := range idx.termsContaining(q) {
+			for id := range idx.postings[term] {
+				if result == nil {
+					matched[id] = struct{}{}
+				} else if _, ok := result[id]; ok {
+					matched[id] = struct{}{}
+				}
+			}
+		}
+		result = matched
+		if len(result) == 0 {
+			break
+		}
+	}
+	ids := make([]string, 0, len(result))
+	for id := range result {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, true
+}
+
+// indexedOrderStore 在OrderStore之上维护订单的倒排索引。
+// 事务提交之后、释放订单的锁之前（见OrderTxn.AfterCommit）在索引锁内增量更新索引，
+// mu只在更新和查找索引时短暂持有，不同订单的事务可以并发提交（包括等待预写日志fsync）。
+// 订单的变更先于索引可见，因此Match对读到的订单重新检查一次查询，不会返回已经不匹配的订单；
+// 刚刚提交、索引还没有更新的订单可能暂时查不到。
+type indexedOrderStore struct {
+	OrderStore
+	mu    sync.RWMutex
+	index *orderIndex
+}
+
+func newIndexedOrderStore(store OrderStore) *indexedOrderStore {
+	s := &indexedOrderStore{OrderStore: store, index: newOrderIndex()}
+	store.Scan(func(order *pb.Order) bool {
+		s.index.put(order)
+		return true
+	})
+	return s
+}
+
+func (s *indexedOrderStore) Put(order *pb.Order) error {
+	return s.Txn([]string{keyOf(order)}, func(tx OrderTxn) error {
+		return tx.Put(order)
+	})
+}
+
+func (s *indexedOrderStore) Delete(id string) (bool, error) {
+	existed := false
+	err := s.Txn([]string{id}, func(tx OrderTxn) error {
+		if _, existed = tx.Get(id); !existed {
+			return nil
+		}
+		return tx.Delete(id)
+	})
+	return existed, err
+}
+
+func (s *indexedOrderStore) Txn(ids []string, fn func(tx OrderTxn) error) error {
+	return s.OrderStore.Txn(ids, func(tx OrderTxn) error {
+		rec := &recordingTxn{OrderTxn: tx}
+		if err := fn(rec); err != nil {
+			return err
+		}
+		ops := rec.ops
+		tx.AfterCommit(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for _, op := range ops {
+				if op.order == nil {
+					s.index.remove(op.id)
+				} else {
+					s.index.put(op.order)
+				}
+			}
+		})
+		return nil
+	})
+}
+
+// Match 按订单ID升序遍历匹配文本查询的订单，fn返回false时停止遍历。
+// 查询中没有任何词时遍历所有订单。
+func (s *indexedOrderStore) Match(query string, fn func(order *pb.Order) bool) {
+	s.mu.RLock()
+	ids, ok := s.index.lookup(query)
+	s.mu.RUnlock()
+	if !ok {
+		s.Scan(fn)
+		return
+	}
+	for _, id := range ids {
+		order, exists := s.Get(id)
+		if !exists || !matchesText(order, query) {
+			continue
+		}
+		if !fn(order) {
+			return
+		}
+	}
+}
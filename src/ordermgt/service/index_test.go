@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	pb "ordermgt/service/ecommerce"
+)
+
+func TestOrderIndex_Lookup(t *testing.T) {
+	idx := newOrderIndex()
+	idx.put(&pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}})
+	idx.put(&pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Description: "Gift for MacBook owners"})
+	idx.put(&pb.Order{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}})
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"google", "[102 104]"},
+		{"GOOG", "[102 104]"},
+		{"mac", "[102 103]"},
+		{"mac bo", "[102 103]"},
+		{"mac pro", "[102]"},
+		{"macbook", "[103]"},
+		{"book", "[102 103]"},
+		{"ixel", "[102]"},
+		{"ub", "[104]"},
+		{"oo hub", "[104]"},
+		{"google nest", "[104]"},
+		{"pixel watch", "[]"},
+		{"samsung", "[]"},
+	}
+	for _, tt := range tests {
+		ids, _ := idx.lookup(tt.query)
+		if got := fmt.Sprint(ids); got != tt.want {
+			t.Errorf("lookup(%q) = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+	if _, ok := idx.lookup(" - "); ok {
+		t.Errorf("lookup of a query without terms should not filter")
+	}
+}
+
+func TestOrderIndex_IncrementalUpdate(t *testing.T) {
+	idx := newOrderIndex()
+	idx.put(&pb.Order{Id: "102", Items: []string{"Google Pixel 3A"}})
+	idx.put(&pb.Order{Id: "102", Items: []string{"Apple iPhone XS"}})
+	if ids, _ := idx.lookup("google"); len(ids) != 0 {
+		t.Fatalf("stale term still indexed after update: %v", ids)
+	}
+	if ids, _ := idx.lookup("iphone"); fmt.Sprint(ids) != "[102]" {
+		t.Fatalf("lookup(iphone) = %v after update", ids)
+	}
+	if ids, _ := idx.lookup("phone"); fmt.Sprint(ids) != "[102]" {
+		t.Fatalf("lookup(phone) = %v, want the order with iPhone", ids)
+	}
+	idx.remove("102")
+	if len(idx.grams) != 0 || len(idx.postings) != 0 {
+		t.Fatalf("index not empty after removing its only order: %v", idx.grams)
+	}
+}
+
+func TestIndexedOrderStore_MatchesScan(t *testing.T) {
+	mem := newMemoryOrderStore(defaultShardCount)
+	initSampleData(mem)
+	store := newIndexedOrderStore(mem)
+	store.Put(&pb.Order{Id: "107", Items: []string{"Google Pixel Book"}, Description: "Amazon gift"})
+	store.Txn([]string{"102", "105"}, func(tx OrderTxn) error {
+		tx.Delete("102")
+		return tx.Put(&pb.Order{Id: "105", Items: []string{"Google Pixel 4"}})
+	})
+
+	for _, query := range []string{"google", "amazon", "pixel", "app", "echo", "apple iphone", "phone", "ook", "xs"} {
+		indexed, _, _ := searchOrders(store, &pb.SearchOrdersRequest{Item: query})
+		scanned, _, _ := searchOrders(mem, &pb.SearchOrdersRequest{Item: query})
+		if fmt.Sprint(orderIDList(indexed)) != fmt.Sprint(orderIDList(scanned)) {
+			t.Errorf("query %q: index returned %v, scan returned %v", query, orderIDList(indexed), orderIDList(scanned))
+		}
+	}
+}
+
+// 并发写入期间，Match返回的每个订单都必须与查询匹配，即索引与订单内容保持一致。
+func TestIndexedOrderStore_ConsistentUnderWrites(t *testing.T) {
+	store := newIndexedOrderStore(newMemoryOrderStore(defaultShardCount))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 500; i++ {
+			items := []string{"Google Pixel 3A"}
+			if i%2 == 1 {
+				items = []string{"Apple Watch S4"}
+			}
+			store.Put(&pb.Order{Id: fmt.Sprintf("%03d", i%10), Items: items})
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		store.Match("pixel", func(order *pb.Order) bool {
+			if !matchesText(order, "pixel") {
+				t.Errorf("Match returned order %s with items %v", order.Id, order.Items)
+			}
+			return true
+		})
+	}
+}
+
+// 一个订单的事务等待期间（例如等待预写日志fsync），其他订单的事务和文本查询不被阻塞。
+func TestIndexedOrderStore_TxnDoesNotBlock(t *testing.T) {
+	store := newIndexedOrderStore(newMemoryOrderStore(defaultShardCount))
+	store.Put(&pb.Order{Id: "101", Items: []string{"Apple Watch S4"}})
+	inTxn, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- store.Txn([]string{"101"}, func(tx OrderTxn) error {
+			tx.Put(&pb.Order{Id: "101", Items: []string{"Google Pixel 3A"}})
+			close(inTxn)
+			<-release
+			return nil
+		})
+	}()
+	<-inTxn
+	finished := make(chan string)
+	go func() {
+		store.Put(&pb.Order{Id: "102", Items: []string{"Google Pixel 4"}})
+		var ids []string
+		store.Match("pixel", func(order *pb.Order) bool {
+			ids = append(ids, order.Id)
+			return true
+		})
+		finished <- fmt.Sprint(ids)
+	}()
+	select {
+	case ids := <-finished:
+		if ids != "[102]" {
+			t.Errorf("Match during an open transaction = %s, want [102]", ids)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Put and Match blocked by a transaction on another order")
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Txn failed: %v", err)
+	}
+	if ids, _ := store.index.lookup("pixel"); fmt.Sprint(ids) != "[101 102]" {
+		t.Fatalf("index after commit = %v", ids)
+	}
+}
+
+func orderIDList(orders []*pb.Order) []string {
+	ids := make([]string, len(orders))
+	for i, order := range orders {
+		ids[i] = order.Id
+	}
+	return ids
+}
+
+var benchVocabulary = []string{
+	"Google", "Pixel", "Apple", "Watch", "iPhone", "Mac", "Book", "Pro", "Amazon", "Echo",
+	"Samsung", "Galaxy", "Nest", "Hub", "Mini", "Home", "Sony", "Headphones", "Kindle", "Surface",
+}
+
+func newBenchStores(n int) (*memoryOrderStore, *indexedOrderStore, map[string]*pb.Order) {
+	r := rand.New(rand.NewSource(1))
+	mem := newMemoryOrderStore(defaultShardCount)
+	legacy := make(map[string]*pb.Order, n)
+	for i := 0; i < n; i++ {
+		var items []string
+		for j := 0; j < 1+r.Intn(3); j++ {
+			items = append(items, benchVocabulary[r.Intn(len(benchVocabulary))]+" "+benchVocabulary[r.Intn(len(benchVocabulary))])
+		}
+		// 加入一个罕见的条目，模拟选择性高的查询。
+		if i%1000 == 0 {
+			items = append(items, "Limited Edition")
+		}
+		order := &pb.Order{Id: fmt.Sprintf("%06d", i), Items: items, Destination: "San Jose, CA"}
+		mem.Put(order)
+		legacy[order.Id] = &pb.Order{Id: order.Id, Items: order.Items, Destination: order.Destination}
+	}
+	return mem, newIndexedOrderStore(mem), legacy
+}
+
+const benchOrderCount = 100000
+
+// BenchmarkSearch_LegacyMapScan 复现索引之前的实现：遍历map中每个订单的每个条目做子串匹配。
+func BenchmarkSearch_LegacyMapScan(b *testing.B) {
+	_, _, legacy := newBenchStores(benchOrderCount)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matched := 0
+		for _, order := range legacy {
+			for _, itemStr := range order.Items {
+				if strings.Contains(itemStr, "Limited") {
+					matched++
+					break
+				}
+			}
+		}
+		if matched != benchOrderCount/1000 {
+			b.Fatalf("matched %d orders", matched)
+		}
+	}
+}
+
+func BenchmarkSearch_Scan(b *testing.B) {
+	mem, _, _ := newBenchStores(benchOrderCount)
+	req := &pb.SearchOrdersRequest{Item: "limited"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		orders, _, _ := searchOrders(mem, req)
+		if len(orders) != benchOrderCount/1000 {
+			b.Fatalf("matched %d orders", len(orders))
+		}
+	}
+}
+
+func BenchmarkSearch_Index(b *testing.B) {
+	_, indexed, _ := newBenchStores(benchOrderCount)
+	req := &pb.SearchOrdersRequest{Item: "limited"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		orders, _, _ := searchOrders(indexed, req)
+		if len(orders) != benchOrderCount/1000 {
+			b.Fatalf("matched %d orders", len(orders))
+		}
+	}
+}
+
+// BenchmarkSearch_IndexPrefix 使用匹配大量订单的短查询，衡量索引在选择性低时的开销。
+func BenchmarkSearch_IndexPrefix(b *testing.B) {
+	_, indexed, _ := newBenchStores(benchOrderCount)
+	req := &pb.SearchOrdersRequest{Item: "goo pix", PageSize: 100}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		searchOrders(indexed, req)
+	}
+}
+
+func BenchmarkIndexedOrderStore_Put(b *testing.B) {
+	_, indexed, _ := newBenchStores(benchOrderCount)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		indexed.Put(&pb.Order{Id: fmt.Sprintf("%06d", i%benchOrderCount), Items: []string{"Google Pixel 3A", "Mac Book Pro"}})
+	}
+}
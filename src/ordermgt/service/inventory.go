@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "ordermgt/service/ecommerce"
+)
+
+const (
+	// stockCallTimeout 是调用ProductInfo预留、确认或释放库存的超时时间。
+	stockCallTimeout = 2 * time.Second
+	// defaultStockReconcileInterval 是重试未完成的库存确认和释放的默认间隔。
+	defaultStockReconcileInterval = 30 * time.Second
+)
+
+// newReservationID 生成库存预留的唯一ID。每次预留都使用新的ID，订单ID被重新使用时不会与旧的预留冲突。
+func newReservationID() string {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return fmt.Sprintf("rsv-%d", time.Now().UnixNano())
+	}
+	return "rsv-" + id.String()
+}
+
+// stockItems 按商品统计订单条目的数量，同一个商品的每个条目算一件。
+func stockItems(order *pb.Order) []*pb.StockItem {
+	var items []*pb.StockItem
+	index := make(map[string]int)
+	for _, productId := range order.ProductIds {
+		if i, ok := index[productId]; ok {
+			items[i].Quantity++
+			continue
+		}
+		index[productId] = len(items)
+		items = append(items, &pb.StockItem{ProductId: productId, Quantity: 1})
+	}
+	return items
+}
+
+// stockTarget 返回订单的预留最终应该进入的状态：发货的订单确认预留，取消的订单释放预留。
+// 订单还没有发货或取消时返回STOCK_RESERVED。
+func stockTarget(order *pb.Order) pb.StockState {
+	switch order.Status {
+	case pb.OrderStatus_SHIPPED, pb.OrderStatus_DELIVERED:
+		return pb.StockState_STOCK_COMMITTED
+	case pb.OrderStatus_CANCELLED:
+		return pb.StockState_STOCK_RELEASED
+	}
+	return pb.StockState_STOCK_RESERVED
+}
+
+// reservationRef 标识一个租户的预留。
+type reservationRef struct {
+	tenant string
+	id     string
+}
+
+// stockSaga 协调订单与ProductInfo库存之间的saga：addOrder预留库存，订单发货后确认预留，
+// 订单取消后释放预留，任何一步失败时用释放预留来补偿。
+// 预留的状态保存在订单的stock字段中，调用ProductInfo失败时订单保持STOCK_RESERVED，由reconcile重试。
+// 没有对应订单的预留（订单写入失败、被删除或被替换）保存在detached中，直到确认或释放成功。
+// 打开了预留日志时，开始预留和预留脱离订单都先写入日志，重启后从日志中恢复detached。
+// ProductInfo对同一个预留ID的确认和释放都是幂等的，因此重试总是安全的。
+type stockSaga struct {
+	products pb.ProductInfoClient
+	// newID 生成新预留的ID，测试中可以替换为确定的ID。
+	newID    func() string
+	log      *reservationLog
+	mu       sync.Mutex
+	detached map[reservationRef]pb.StockState
+}
+
+func newStockSaga(products pb.ProductInfoClient) *stockSaga {
+	return &stockSaga{
+		products: products,
+		newID:    newReservationID,
+		detached: make(map[reservationRef]pb.StockState),
+	}
+}
+
+// openStockSaga 创建把预留记录在dir中的日志里的saga，重启前没有完成的预留由reconcile处理。
+func openStockSaga(products pb.ProductInfoClient, dir string) (*stockSaga, error) {
+	l, pending, err := openReservationLog(dir)
+	if err != nil {
+		return nil, err
+	}
+	g := newStockSaga(products)
+	g.log = l
+	for ref, target := range pending {
+		g.detached[ref] = target
+	}
+	return g, nil
+}
+
+func (g *stockSaga) Close() error {
+	return g.log.Close()
+}
+
+// reserve 为订单条目预留库存，成功后设置订单的reservationId和stock。没有条目的订单不预留。
+// ProductInfo拒绝预留（商品不存在、属于其他租户或库存不足）时没有扣减任何库存，返回FailedPrecondition；
+// 其他错误时无法确定预留是否已经生效，先补偿（释放这个预留）再返回Unavailable。
+func (g *stockSaga) reserve(ctx context.Context, order *pb.Order) error {
+	items := stockItems(order)
+	if len(items) == 0 {
+		return nil
+	}
+	id := g.newID()
+	ref := reservationRef{tenantOf(ctx), id}
+	// 先记录开始预留：预留生效之后、订单写入之前服务崩溃时，重启后释放这个预留。
+	if err := g.log.append(ref, pb.StockState_STOCK_RESERVED, true); err != nil {
+		return status.Errorf(codes.Unavailable, "failed to record stock reservation for order %s : %v", order.Id, err)
+	}
+	callCtx, cancel := context.WithTimeout(productContext(ctx, ref.tenant), stockCallTimeout)
+	_, err := g.products.ReserveStock(callCtx, &pb.ReserveStockRequest{ReservationId: id, Items: items})
+	cancel()
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.FailedPrecondition, codes.NotFound, codes.PermissionDenied:
+		g.resolve(ref)
+		return status.Errorf(codes.FailedPrecondition, "Stock cannot be reserved for order %s : %s", order.Id, status.Convert(err).Message())
+	default:
+		g.detach(ref, pb.StockState_STOCK_RELEASED)
+		return status.Errorf(codes.Unavailable, "failed to reserve stock for order %s : %v", order.Id, status.Convert(err).Message())
+	}
+	order.ReservationId = id
+	order.Stock = pb.StockState_STOCK_RESERVED
+	log.Printf("Order ID : %s - Stock reserved : %s", order.Id, id)
+	return nil
+}
+
+// finish 在ProductInfo中确认或释放预留。
+func (g *stockSaga) finish(ref reservationRef, target pb.StockState) error {
+	call := g.products.ReleaseStock
+	if target == pb.StockState_STOCK_COMMITTED {
+		call = g.products.CommitStock
+	}
+	ctx, cancel := context.WithTimeout(productContext(context.Background(), ref.tenant), stockCallTimeout)
+	defer cancel()
+	_, err := call(ctx, &pb.ReservationID{Value: ref.id})
+	return err
+}
+
+// resolve 记录预留已经写入订单或者已经处理完，日志不再跟踪它。记录失败时只打印日志，重启后重复处理一次。
+func (g *stockSaga) resolve(ref reservationRef) {
+	if err := g.log.append(ref, pb.StockState_STOCK_NONE, false); err != nil {
+		log.Printf("Reservation %s - failed to record completion : %v", ref.id, err)
+	}
+}
+
+// detach 确认或释放一个不再属于任何订单的预留，失败时记录下来由reconcile重试。
+func (g *stockSaga) detach(ref reservationRef, target pb.StockState) {
+	// 先在日志中记录目标状态，调用失败之后服务重启也会重试。
+	if err := g.log.append(ref, target, true); err != nil {
+		log.Printf("Reservation %s - failed to record %s : %v", ref.id, target, err)
+	}
+	if err := g.finish(ref, target); err != nil {
+		log.Printf("Reservation %s - %s failed, will retry : %v", ref.id, target, err)
+		g.mu.Lock()
+		g.detached[ref] = target
+		g.mu.Unlock()
+		return
+	}
+	g.resolve(ref)
+	log.Printf("Reservation %s - %s", ref.id, target)
+}
+
+// detachOrder 在订单被删除后处理它的预留：已经发货的订单确认预留，其他订单释放预留。
+func (g *stockSaga) detachOrder(order *pb.Order) {
+	if order.GetStock() != pb.StockState_STOCK_RESERVED {
+		return
+	}
+	target := stockTarget(order)
+	if target == pb.StockState_STOCK_RESERVED {
+		target = pb.StockState_STOCK_RELEASED
+	}
+	g.detach(reservationRef{order.TenantId, order.ReservationId}, target)
+}
+
+// settle 在订单发货或取消后确认或释放它的预留，成功后通过store在订单上记录结果。
+// 订单没有未完成的预留，或者还没有发货或取消时什么都不做。
+func (g *stockSaga) settle(store OrderStore, order *pb.Order) error {
+	target := stockTarget(order)
+	if order.Stock != pb.StockState_STOCK_RESERVED || target == pb.StockState_STOCK_RESERVED {
+		return nil
+	}
+	if err := g.finish(reservationRef{order.TenantId, order.ReservationId}, target); err != nil {
+		return err
+	}
+	err := store.Txn([]string{order.Id}, func(tx OrderTxn) error {
+		current, exists := tx.Get(order.Id)
+		// 订单在调用ProductInfo期间被删除或替换时，预留已经交给detachOrder处理。
+		if !exists || current.ReservationId != order.ReservationId || current.Stock != pb.StockState_STOCK_RESERVED {
+			return nil
+		}
+		current.Stock = target
+		return tx.Put(current)
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Order ID : %s - Stock %s : %s", order.Id, target, order.ReservationId)
+	return nil
+}
+
+// reconcile 重试所有未完成的确认和释放，返回仍未完成的数量。
+// 从日志中恢复的预留如果已经写入了订单，就交还给订单，不再释放。
+func (g *stockSaga) reconcile(store OrderStore) int {
+	var orders []*pb.Order
+	held := make(map[reservationRef]bool)
+	store.Scan(func(order *pb.Order) bool {
+		if order.ReservationId != "" {
+			held[reservationRef{order.TenantId, order.ReservationId}] = true
+		}
+		if order.Stock == pb.StockState_STOCK_RESERVED && stockTarget(order) != pb.StockState_STOCK_RESERVED {
+			orders = append(orders, order)
+		}
+		return true
+	})
+	pending := 0
+	for _, order := range orders {
+		// settle按订单ID访问订单，通过订单所属租户的视图调用。
+		if err := g.settle(&tenantOrderStore{OrderStore: store, tenant: order.TenantId}, order); err != nil {
+			log.Printf("Order ID : %s - Stock not settled : %v", order.Id, err)
+			pending++
+		}
+	}
+
+	g.mu.Lock()
+	detached := make(map[reservationRef]pb.StockState, len(g.detached))
+	for ref, target := range g.detached {
+		detached[ref] = target
+	}
+	g.mu.Unlock()
+	for ref, target := range detached {
+		if held[ref] {
+			g.mu.Lock()
+			delete(g.detached, ref)
+			g.mu.Unlock()
+			g.resolve(ref)
+			continue
+		}
+		if err := g.finish(ref, target); err != nil {
+			log.Printf("Reservation %s - %s failed : %v", ref.id, target, err)
+			pending++
+			continue
+		}
+		g.mu.Lock()
+		delete(g.detached, ref)
+		g.mu.Unlock()
+		g.resolve(ref)
+		log.Printf("Reservation %s - %s", ref.id, target)
+	}
+	return pending
+}
+
+// run 每隔interval调用一次reconcile，不会返回。
+func (g *stockSaga) run(store OrderStore, clk clock, interval time.Duration) {
+	for {
+		g.reconcile(store)
+		timer := clk.NewTimer(interval)
+		<-timer.C()
+	}
+}
+
+// settleStock 在订单发货或取消后确认或释放订单的预留。失败时只记录日志，由后台的reconcile重试。
+func (s *server) settleStock(store OrderStore, order *pb.Order) {
+	if s.inventory == nil || order == nil {
+		return
+	}
+	if err := s.inventory.settle(store, order); err != nil {
+		log.Printf("Order ID : %s - Stock not settled, will retry : %v", order.Id, err)
+	}
+}
+
+// reservedItemsError 说明订单已经预留了库存，不能修改条目。
+func reservedItemsError(id string) error {
+	return status.Errorf(codes.FailedPrecondition, "Items of order %s cannot be changed while stock is reserved for it, cancel it and add a new order instead", id)
+}
+
+// sameItems 判断两个订单的条目是否相同。
+func sameItems(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
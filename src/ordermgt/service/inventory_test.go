@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	pb "ordermgt/service/ecommerce"
+)
+
+// stockFailure 是注入到一种库存调用中的故障，在恢复之前对这种调用的每次请求都生效。
+type stockFailure int
+
+const (
+	// failBefore 在处理请求之前返回Unavailable，请求没有生效。
+	failBefore stockFailure = iota + 1
+	// failAfter 处理请求之后返回Unavailable，请求已经生效但响应丢失。
+	failAfter
+)
+
+// fakeInventory 在fakeProductInfo之上按照ProductInfo的语义维护库存和预留，并可以注入故障。
+type fakeInventory struct {
+	fakeProductInfo
+	mu           sync.Mutex
+	stock        map[string]int64
+	reservations map[string]*pb.Reservation
+	failures     map[string]stockFailure
+}
+
+func newFakeInventory(stock map[string]int64) *fakeInventory {
+	return &fakeInventory{
+		fakeProductInfo: fakeProductInfo{products: catalogProducts()},
+		stock:           stock,
+		reservations:    make(map[string]*pb.Reservation),
+		failures:        make(map[string]stockFailure),
+	}
+}
+
+func (f *fakeInventory) inject(method string, failure stockFailure) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[method] = failure
+}
+
+func (f *fakeInventory) heal() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = make(map[string]stockFailure)
+}
+
+func (f *fakeInventory) call(method string, fn func() (*pb.Reservation, error)) (*pb.Reservation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	failure := f.failures[method]
+	if failure == failBefore {
+		return nil, status.Errorf(codes.Unavailable, "injected failure before %s", method)
+	}
+	r, err := fn()
+	if err == nil && failure == failAfter {
+		return nil, status.Errorf(codes.Unavailable, "injected failure after %s", method)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(r).(*pb.Reservation), nil
+}
+
+func (f *fakeInventory) ReserveStock(ctx context.Context, in *pb.ReserveStockRequest) (*pb.Reservation, error) {
+	return f.call("reserve", func() (*pb.Reservation, error) {
+		if r, exists := f.reservations[in.ReservationId]; exists {
+			if r.State != pb.ReservationState_RESERVED {
+				return nil, status.Errorf(codes.FailedPrecondition, "Reservation %s is already %s.", r.Id, r.State)
+			}
+			return r, nil
+		}
+		for _, item := range in.Items {
+			if f.stock[item.ProductId] < item.Quantity {
+				return nil, status.Errorf(codes.FailedPrecondition, "Insufficient stock for product %s", item.ProductId)
+			}
+		}
+		for _, item := range in.Items {
+			f.stock[item.ProductId] -= item.Quantity
+		}
+		r := &pb.Reservation{Id: in.ReservationId, Items: in.Items, State: pb.ReservationState_RESERVED}
+		f.reservations[r.Id] = r
+		return r, nil
+	})
+}
+
+func (f *fakeInventory) CommitStock(ctx context.Context, in *pb.ReservationID) (*pb.Reservation, error) {
+	return f.call("commit", func() (*pb.Reservation, error) {
+		r, exists := f.reservations[in.Value]
+		if !exists {
+			return nil, status.Errorf(codes.NotFound, "Reservation does not exist. : %s", in.Value)
+		}
+		if r.State == pb.ReservationState_RELEASED {
+			return nil, status.Errorf(codes.FailedPrecondition, "Reservation %s is already RELEASED.", r.Id)
+		}
+		r.State = pb.ReservationState_COMMITTED
+		return r, nil
+	})
+}
+
+func (f *fakeInventory) ReleaseStock(ctx context.Context, in *pb.ReservationID) (*pb.Reservation, error) {
+	return f.call("release", func() (*pb.Reservation, error) {
+		r, exists := f.reservations[in.Value]
+		if !exists {
+			r = &pb.Reservation{Id: in.Value, State: pb.ReservationState_RELEASED}
+			f.reservations[r.Id] = r
+			return r, nil
+		}
+		switch r.State {
+		case pb.ReservationState_COMMITTED:
+			return nil, status.Errorf(codes.FailedPrecondition, "Reservation %s is already COMMITTED.", r.Id)
+		case pb.ReservationState_RESERVED:
+			for _, item := range r.Items {
+				f.stock[item.ProductId] += item.Quantity
+			}
+			r.State = pb.ReservationState_RELEASED
+		}
+		return r, nil
+	})
+}
+
+// snapshot 返回当前的库存和每个预留的状态。
+func (f *fakeInventory) snapshot() (map[string]int64, map[string]pb.ReservationState) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stock := make(map[string]int64)
+	for id, n := range f.stock {
+		stock[id] = n
+	}
+	states := make(map[string]pb.ReservationState)
+	for id, r := range f.reservations {
+		states[id] = r.State
+	}
+	return stock, states
+}
+
+func initialStock() map[string]int64 {
+	return map[string]int64{"p-pixel": 5, "p-mbp": 2, "p-echo": 10}
+}
+
+// failingOrderStore 在fail为true时拒绝所有写入。
+type failingOrderStore struct {
+	OrderStore
+	mu   sync.Mutex
+	fail bool
+}
+
+func (s *failingOrderStore) setFail(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = fail
+}
+
+func (s *failingOrderStore) Txn(ids []string, fn func(tx OrderTxn) error) error {
+	s.mu.Lock()
+	fail := s.fail
+	s.mu.Unlock()
+	if fail {
+		return errors.New("injected store failure")
+	}
+	return s.OrderStore.Txn(ids, fn)
+}
+
+// startInventoryServer 启动使用fakeInventory预留库存的OrderManagement服务。
+func startInventoryServer(t *testing.T) (*server, *fakeInventory, *failingOrderStore, pb.OrderManagementClient, func()) {
+	t.Helper()
+	inventory := newFakeInventory(initialStock())
+	products, stopProducts := serveProductInfo(t, inventory)
+	store := &failingOrderStore{OrderStore: newMemoryOrderStore(defaultShardCount)}
+	srv := newServer(store)
+	srv.products = products
+	srv.inventory = newStockSaga(products)
+	srv.admins = testAdminPolicy()
+	n := 0
+	srv.inventory.newID = func() string {
+		n++
+		return fmt.Sprintf("rsv-%d", n)
+	}
+	client, stop := startBufConnServer(t, srv)
+	return srv, inventory, store, client, func() {
+		stop()
+		stopProducts()
+	}
+}
+
+// shipOrder 通过transitionOrder把订单一步步迁移到SHIPPED。
+func shipOrder(ctx context.Context, client pb.OrderManagementClient, id string) error {
+	for _, st := range []pb.OrderStatus{pb.OrderStatus_CONFIRMED, pb.OrderStatus_PACKED, pb.OrderStatus_SHIPPED} {
+		if _, err := client.TransitionOrder(ctx, &pb.TransitionOrderRequest{Id: id, Status: st}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkConverged 检查库存已经收敛：没有未完成的预留，库存等于初始库存减去已发货订单的条目，
+// 每个订单的stock与它的状态一致。
+func checkConverged(t *testing.T, inventory *fakeInventory, store OrderStore) {
+	t.Helper()
+	want := initialStock()
+	committed := make(map[string]bool)
+	store.Scan(func(order *pb.Order) bool {
+		if order.Stock != pb.StockState_STOCK_NONE && order.Stock != stockTarget(order) {
+			t.Errorf("order %s is %s with stock %s", order.Id, order.Status, order.Stock)
+		}
+		if order.Stock == pb.StockState_STOCK_COMMITTED {
+			committed[order.ReservationId] = true
+			for _, id := range order.ProductIds {
+				want[id]--
+			}
+		}
+		return true
+	})
+	stock, states := inventory.snapshot()
+	if fmt.Sprint(stock) != fmt.Sprint(want) {
+		t.Errorf("stock = %v, want %v", stock, want)
+	}
+	for id, state := range states {
+		if state == pb.ReservationState_RESERVED || (state == pb.ReservationState_COMMITTED) != committed[id] {
+			t.Errorf("reservation %s is %s", id, state)
+		}
+	}
+}
+
+func TestServer_StockSaga(t *testing.T) {
+	srv, inventory, store, client, stop := startInventoryServer(t)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 客户端设置的预留会被忽略。
+	if _, err := client.AddOrder(ctx, &pb.Order{Id: "200", Items: []string{"Google Pixel 3A", "Google Pixel 3A", "Mac Book Pro"}, ReservationId: "forged", Stock: pb.StockState_STOCK_COMMITTED}); err != nil {
+		t.Fatalf("AddOrder failed: %v", err)
+	}
+	order, _ := store.Get("200")
+	if order.ReservationId != "rsv-1" || order.Stock != pb.StockState_STOCK_RESERVED {
+		t.Fatalf("order 200 has reservation %s %s", order.ReservationId, order.Stock)
+	}
+	if stock, _ := inventory.snapshot(); stock["p-pixel"] != 3 || stock["p-mbp"] != 1 {
+		t.Errorf("stock after reserving = %v", stock)
+	}
+
+	// 库存不足时订单不会被写入，也不扣减任何库存。
+	_, err := client.AddOrder(ctx, &pb.Order{Id: "201", Items: []string{"Amazon Echo", "Mac Book Pro", "Mac Book Pro"}})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("AddOrder without enough stock: %v", err)
+	}
+	if _, ok := store.Get("201"); ok {
+		t.Errorf("order without enough stock was stored")
+	}
+	if stock, _ := inventory.snapshot(); stock["p-echo"] != 10 {
+		t.Errorf("stock after rejected reservation = %v", stock)
+	}
+
+	// 预留了库存的订单不能修改条目，其他字段可以修改。
+	update, _ := client.UpdateOrders(ctx)
+	update.Send(&pb.Order{Id: "200", Items: []string{"Google Pixel 3A"}})
+	if _, err := update.CloseAndRecv(); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("UpdateOrders changing reserved items: %v", err)
+	}
+	_, err = client.PatchOrder(ctx, &pb.PatchOrderRequest{Order: &pb.Order{Id: "200", Items: []string{"Amazon Echo"}}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"items"}}, ItemsMode: pb.ItemsPatchMode_APPEND})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("PatchOrder appending to reserved items: %v", err)
+	}
+	patched, err := client.PatchOrder(ctx, &pb.PatchOrderRequest{Order: &pb.Order{Id: "200", Destination: "San Jose, CA"}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"destination"}}})
+	if err != nil || patched.Stock != pb.StockState_STOCK_RESERVED {
+		t.Errorf("PatchOrder destination = %v, %v", patched, err)
+	}
+
+	// 发货后确认预留，取消后释放预留，删除的订单也释放预留。
+	client.AddOrder(ctx, &pb.Order{Id: "202", Items: []string{"Amazon Echo"}})
+	client.AddOrder(ctx, &pb.Order{Id: "203", Items: []string{"Amazon Echo", "Amazon Echo"}})
+	if err := shipOrder(ctx, client, "200"); err != nil {
+		t.Fatalf("shipping order 200 failed: %v", err)
+	}
+	if _, err := client.CancelOrder(ctx, &pb.CancelOrderRequest{Id: "202", Reason: "customer request"}); err != nil {
+		t.Fatalf("CancelOrder failed: %v", err)
+	}
+	if _, err := client.DeleteOrder(asAdmin(ctx), &pb.DeleteOrderRequest{Id: "203"}); err != nil {
+		t.Fatalf("DeleteOrder failed: %v", err)
+	}
+	stock, states := inventory.snapshot()
+	if got, want := fmt.Sprint(stock), "map[p-echo:10 p-mbp:1 p-pixel:3]"; got != want {
+		t.Errorf("stock = %s, want %s", got, want)
+	}
+	// 被拒绝的预留rsv-2在ProductInfo中没有留下记录。
+	if got, want := fmt.Sprint(states), "map[rsv-1:COMMITTED rsv-3:RELEASED rsv-4:RELEASED]"; got != want {
+		t.Errorf("reservations = %s, want %s", got, want)
+	}
+	checkConverged(t, inventory, store)
+	if pending := srv.inventory.reconcile(srv.store); pending != 0 {
+		t.Errorf("%d settlements pending", pending)
+	}
+}
+
+// 在saga的每一步注入故障，恢复后reconcile使库存收敛：已发货订单的预留被确认，其他预留全部被释放。
+func TestServer_StockSagaFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures map[string]stockFailure
+		// failStore 为true时addOrder在预留库存之后写入订单失败。
+		failStore bool
+	}{
+		{name: "reserve fails", failures: map[string]stockFailure{"reserve": failBefore}},
+		{name: "reserve response lost", failures: map[string]stockFailure{"reserve": failAfter}},
+		{name: "reserve and compensation fail", failures: map[string]stockFailure{"reserve": failAfter, "release": failBefore}},
+		{name: "store write fails", failStore: true},
+		{name: "store write and compensation fail", failStore: true, failures: map[string]stockFailure{"release": failBefore}},
+		{name: "commit fails", failures: map[string]stockFailure{"commit": failBefore}},
+		{name: "commit response lost", failures: map[string]stockFailure{"commit": failAfter}},
+		{name: "release fails", failures: map[string]stockFailure{"release": failBefore}},
+		{name: "release response lost", failures: map[string]stockFailure{"release": failAfter}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, inventory, store, client, stop := startInventoryServer(t)
+			defer stop()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			// 先在没有故障时添加两个订单，注入故障后再添加一个，然后发货、取消和删除。
+			for _, order := range []*pb.Order{
+				{Id: "200", Items: []string{"Google Pixel 3A", "Amazon Echo"}},
+				{Id: "201", Items: []string{"Mac Book Pro", "Google Pixel 3A"}},
+			} {
+				if _, err := client.AddOrder(ctx, order); err != nil {
+					t.Fatalf("AddOrder(%s) failed: %v", order.Id, err)
+				}
+			}
+			for method, failure := range tt.failures {
+				inventory.inject(method, failure)
+			}
+			store.setFail(tt.failStore)
+			_, err := client.AddOrder(ctx, &pb.Order{Id: "202", Items: []string{"Google Pixel 3A", "Google Pixel 3A"}})
+			store.setFail(false)
+			if _, added := store.Get("202"); added != (err == nil) {
+				t.Errorf("AddOrder(202) = %v, but stored = %v", err, added)
+			}
+			if err := shipOrder(ctx, client, "200"); err != nil {
+				t.Fatalf("shipping order 200 failed: %v", err)
+			}
+			if _, err := client.CancelOrder(ctx, &pb.CancelOrderRequest{Id: "201", Reason: "customer request"}); err != nil {
+				t.Fatalf("CancelOrder failed: %v", err)
+			}
+			if _, err := client.DeleteOrder(asAdmin(ctx), &pb.DeleteOrderRequest{Id: "202"}); err != nil && status.Code(err) != codes.NotFound {
+				t.Fatalf("DeleteOrder failed: %v", err)
+			}
+
+			// 故障恢复后，reconcile完成所有没有完成的确认和释放。
+			inventory.heal()
+			if pending := srv.inventory.reconcile(srv.store); pending != 0 {
+				t.Fatalf("%d settlements pending after healing", pending)
+			}
+			checkConverged(t, inventory, store)
+			if order, _ := store.Get("200"); order.Stock != pb.StockState_STOCK_COMMITTED {
+				t.Errorf("shipped order has stock %s", order.Stock)
+			}
+			if order, _ := store.Get("201"); order.Stock != pb.StockState_STOCK_RELEASED {
+				t.Errorf("cancelled order has stock %s", order.Stock)
+			}
+		})
+	}
+}
+
+// 预留日志使重启前没有完成的预留在重启后收敛：没有写入订单的预留被释放，
+// 已经写入订单的预留交还给订单，释放失败的预留继续重试。
+func TestStockSaga_ReservationLog(t *testing.T) {
+	dir := t.TempDir()
+	inventory := newFakeInventory(initialStock())
+	products, stop := serveProductInfo(t, inventory)
+	defer stop()
+	store := newMemoryOrderStore(defaultShardCount)
+	openSaga := func() *stockSaga {
+		saga, err := openStockSaga(products, dir)
+		if err != nil {
+			t.Fatalf("openStockSaga failed: %v", err)
+		}
+		_, states := inventory.snapshot()
+		n := len(states)
+		saga.newID = func() string {
+			n++
+			return fmt.Sprintf("rsv-%d", n)
+		}
+		return saga
+	}
+	ctx := context.Background()
+	saga := openSaga()
+	// 订单200写入之后、记录完成之前崩溃；订单201预留之后、写入之前崩溃。
+	stored := &pb.Order{Id: "200", ProductIds: []string{"p-pixel"}}
+	lost := &pb.Order{Id: "201", ProductIds: []string{"p-mbp"}}
+	for _, order := range []*pb.Order{stored, lost} {
+		if err := saga.reserve(ctx, order); err != nil {
+			t.Fatalf("reserve(%s) failed: %v", order.Id, err)
+		}
+	}
+	store.Put(stored)
+	// 被删除的订单202的预留释放失败。
+	deleted := &pb.Order{Id: "202", ProductIds: []string{"p-echo"}}
+	if err := saga.reserve(ctx, deleted); err != nil {
+		t.Fatalf("reserve(202) failed: %v", err)
+	}
+	saga.resolve(reservationRef{"", deleted.ReservationId})
+	inventory.inject("release", failBefore)
+	saga.detachOrder(deleted)
+	saga.Close()
+
+	inventory.heal()
+	saga = openSaga()
+	if len(saga.detached) != 3 {
+		t.Fatalf("recovered %d reservations, want 3: %v", len(saga.detached), saga.detached)
+	}
+	if pending := saga.reconcile(store); pending != 0 {
+		t.Fatalf("%d reservations pending after restart", pending)
+	}
+	saga.Close()
+	stock, states := inventory.snapshot()
+	if got, want := fmt.Sprint(states), "map[rsv-1:RESERVED rsv-2:RELEASED rsv-3:RELEASED]"; got != want {
+		t.Errorf("reservations = %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprint(stock), "map[p-echo:10 p-mbp:2 p-pixel:4]"; got != want {
+		t.Errorf("stock = %s, want %s", got, want)
+	}
+
+	// 处理完的预留不会在下次启动时再被处理。
+	saga = openSaga()
+	defer saga.Close()
+	if len(saga.detached) != 0 {
+		t.Errorf("reservations recovered again: %v", saga.detached)
+	}
+}
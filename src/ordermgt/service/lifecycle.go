@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	pb "ordermgt/service/ecommerce"
+)
+
+// orderTransitions 列出了每个状态允许迁移到的下一个状态。
+// DELIVERED和CANCELLED是终止状态，不能再迁移。
+var orderTransitions = map[pb.OrderStatus][]pb.OrderStatus{
+	pb.OrderStatus_PENDING:   {pb.OrderStatus_CONFIRMED, pb.OrderStatus_CANCELLED},
+	pb.OrderStatus_CONFIRMED: {pb.OrderStatus_PACKED, pb.OrderStatus_CANCELLED},
+	pb.OrderStatus_PACKED:    {pb.OrderStatus_SHIPPED, pb.OrderStatus_CANCELLED},
+	pb.OrderStatus_SHIPPED:   {pb.OrderStatus_DELIVERED},
+}
+
+func canTransition(from, to pb.OrderStatus) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionError 生成FailedPrecondition错误，并用PreconditionFailure详情说明非法的状态迁移。
+func transitionError(order *pb.Order, to pb.OrderStatus) error {
+	errorStatus := status.New(codes.FailedPrecondition, fmt.Sprintf("Order %s cannot move from %s to %s", order.Id, order.Status, to))
+	ds, err := errorStatus.WithDetails(
+		&epb.PreconditionFailure{
+			Violations: []*epb.PreconditionFailure_Violation{{
+				Type:        "ORDER_STATUS",
+				Subject:     "orders/" + order.Id,
+				Description: fmt.Sprintf("Order is %s, allowed next states are %v", order.Status, orderTransitions[order.Status]),
+			}},
+		},
+	)
+	if err != nil {
+		return errorStatus.Err()
+	}
+	return ds.Err()
+}
+
+// frozenOrderFields 是订单发货、送达或取消之后不能再修改的字段。
+var frozenOrderFields = []string{"items", "destination", "price", "exactPrice"}
+
+// orderFrozen 判断订单是否已经发货、送达或被取消，这样的订单的条目、目的地和价格不能再修改。
+func orderFrozen(order *pb.Order) bool {
+	switch order.Status {
+	case pb.OrderStatus_SHIPPED, pb.OrderStatus_DELIVERED, pb.OrderStatus_CANCELLED:
+		return true
+	}
+	return false
+}
+
+// frozenOrderError 生成FailedPrecondition错误，并用PreconditionFailure详情说明订单的哪些字段因为状态不能再修改。
+func frozenOrderError(order *pb.Order, fields []string) error {
+	errorStatus := status.New(codes.FailedPrecondition, fmt.Sprintf("Order %s is %s, %v cannot be changed", order.Id, order.Status, fields))
+	ds, err := errorStatus.WithDetails(
+		&epb.PreconditionFailure{
+			Violations: []*epb.PreconditionFailure_Violation{{
+				Type:        "ORDER_STATUS",
+				Subject:     "orders/" + order.Id,
+				Description: fmt.Sprintf("Order is %s, %v of shipped, delivered or cancelled orders cannot be changed", order.Status, frozenOrderFields),
+			}},
+		},
+	)
+	if err != nil {
+		return errorStatus.Err()
+	}
+	return ds.Err()
+}
+
+// changedFrozenFields 返回updated相对于existing修改了的、在订单发货后不能修改的字段。两个订单的价格都已经规范化。
+func changedFrozenFields(existing, updated *pb.Order) []string {
+	var fields []string
+	if !sameItems(existing.Items, updated.Items) {
+		fields = append(fields, "items")
+	}
+	if existing.Destination != updated.Destination {
+		fields = append(fields, "destination")
+	}
+	if existing.Price != updated.Price || !proto.Equal(existing.ExactPrice, updated.ExactPrice) {
+		fields = append(fields, "price")
+	}
+	return fields
+}
+
+// checkVersion 在expected不为0且与订单当前版本不同时返回Aborted错误，并用PreconditionFailure详情说明版本冲突。
+// 订单不存在时当前版本视为0。
+func checkVersion(id string, current *pb.Order, expected int64) error {
+	if expected == 0 || current.GetVersion() == expected {
+		return nil
+	}
+	errorStatus := status.New(codes.Aborted, fmt.Sprintf("Order %s was modified concurrently", id))
+	ds, err := errorStatus.WithDetails(
+		&epb.PreconditionFailure{
+			Violations: []*epb.PreconditionFailure_Violation{{
+				Type:        "ORDER_VERSION",
+				Subject:     "orders/" + id,
+				Description: fmt.Sprintf("Expected version %d, current version is %d", expected, current.GetVersion()),
+			}},
+		},
+	)
+	if err != nil {
+		return errorStatus.Err()
+	}
+	return ds.Err()
+}
+
+// transitionOrder 在事务中把订单迁移到目标状态，订单不存在时返回NotFound。
+// expectedVersion不为0时，只有订单的当前版本与之相同才会迁移。
+func transitionOrder(store OrderStore, id string, to pb.OrderStatus, expectedVersion int64) (*pb.Order, error) {
+	var updated *pb.Order
+	err := store.Txn([]string{id}, func(tx OrderTxn) error {
+		order, exists := tx.Get(id)
+		if !exists {
+			return status.Errorf(codes.NotFound, "Order does not exist. : %s", id)
+		}
+		if err := checkVersion(id, order, expectedVersion); err != nil {
+			return err
+		}
+		if !canTransition(order.Status, to) {
+			return transitionError(order, to)
+		}
+		order.Status = to
+		updated = order
+		return tx.Put(order)
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); !ok {
+			err = status.Errorf(codes.Internal, "failed to store order %s : %v", id, err)
+		}
+		return nil, err
+	}
+	log.Printf("Order ID : %s - %s", id, to)
+	return updated, nil
+}
+
+// advanceOrder 沿着生命周期把订单逐步推进到目标状态，例如PENDING经过CONFIRMED到达PACKED。
+// 订单已经处于目标状态、已经越过目标状态或者已被取消时返回FailedPrecondition，
+// 例如已经PACKED的订单不能再次打包，避免它被加入两个发货组合。
+func advanceOrder(store OrderStore, id string, to pb.OrderStatus) (*pb.Order, error) {
+	var updated *pb.Order
+	err := store.Txn([]string{id}, func(tx OrderTxn) error {
+		order, exists := tx.Get(id)
+		if !exists {
+			return status.Errorf(codes.NotFound, "Order does not exist. : %s", id)
+		}
+		if order.Status == to {
+			return transitionError(order, to)
+		}
+		from := order.Status
+		for order.Status != to {
+			next, ok := nextTowards(order.Status, to)
+			if !ok {
+				order.Status = from
+				return transitionError(order, to)
+			}
+			order.Status = next
+		}
+		updated = order
+		return tx.Put(order)
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); !ok {
+			err = status.Errorf(codes.Internal, "failed to store order %s : %v", id, err)
+		}
+		return nil, err
+	}
+	return updated, nil
+}
+
+// nextTowards 返回从from出发、不经过CANCELLED到达to的路径上的下一个状态。
+func nextTowards(from, to pb.OrderStatus) (pb.OrderStatus, bool) {
+	if to == pb.OrderStatus_CANCELLED || to <= from {
+		return from, false
+	}
+	for _, next := range orderTransitions[from] {
+		if next != pb.OrderStatus_CANCELLED && next <= to {
+			return next, true
+		}
+	}
+	return from, false
+}
+
+// Simple RPC
+// TransitionOrder 只允许生命周期中定义的迁移，非法迁移返回带有PreconditionFailure详情的FailedPrecondition。
+func (s *server) TransitionOrder(ctx context.Context, req *pb.TransitionOrderRequest) (*pb.Order, error) {
+	store := s.storeFor(ctx)
+	order, err := transitionOrder(store, req.Id, req.Status, req.ExpectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	s.settleStock(store, order)
+	return order, nil
+}
+
+// cancelOrder 在事务中取消订单并记录取消原因。
+func cancelOrder(store OrderStore, id, reason string, expectedVersion int64) (*pb.Order, error) {
+	if reason == "" {
+		return nil, invalidFieldError("reason", "A cancellation reason is required")
+	}
+	var cancelled *pb.Order
+	err := store.Txn([]string{id}, func(tx OrderTxn) error {
+		order, exists := tx.Get(id)
+		if !exists {
+			return status.Errorf(codes.NotFound, "Order does not exist. : %s", id)
+		}
+		if err := checkVersion(id, order, expectedVersion); err != nil {
+			return err
+		}
+		if !canTransition(order.Status, pb.OrderStatus_CANCELLED) {
+			return transitionError(order, pb.OrderStatus_CANCELLED)
+		}
+		order.Status = pb.OrderStatus_CANCELLED
+		order.CancelReason = reason
+		cancelled = order
+		return tx.Put(order)
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); !ok {
+			err = status.Errorf(codes.Internal, "failed to store order %s : %v", id, err)
+		}
+		return nil, err
+	}
+	log.Printf("Order ID : %s - CANCELLED : %s", id, reason)
+	return cancelled, nil
+}
+
+// Simple RPC
+// CancelOrder 取消尚未发货的订单，已取消的订单不会出现在searchOrders的默认结果中，也不能再被processOrders处理。
+func (s *server) CancelOrder(ctx context.Context, req *pb.CancelOrderRequest) (*pb.Order, error) {
+	store := s.storeFor(ctx)
+	order, err := cancelOrder(store, req.Id, req.Reason, req.ExpectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	// 释放订单预留的库存。
+	s.settleStock(store, order)
+	return order, nil
+}
+
+// shipFailure 是发货组合发出时没能标记为SHIPPED的订单，例如打包之后被取消的订单。
+type shipFailure struct {
+	orderID string
+	err     error
+}
+
+// shipOrders 把发货组合中的订单标记为SHIPPED，并用更新后的订单替换发货组合中的副本。
+// 没能发货的订单从发货组合中移除，作为失败返回。
+func shipOrders(store OrderStore, shipment *pb.CombinedShipment) []shipFailure {
+	var failures []shipFailure
+	shipped := make([]*pb.Order, 0, len(shipment.OrdersList))
+	for _, ord := range shipment.OrdersList {
+		updated, err := advanceOrder(store, ord.Id, pb.OrderStatus_SHIPPED)
+		if err != nil {
+			log.Printf("Order ID : %s - not shipped : %v", ord.Id, err)
+			failures = append(failures, shipFailure{ord.Id, err})
+			continue
+		}
+		shipped = append(shipped, updated)
+	}
+	shipment.OrdersList = shipped
+	return failures
+}
+
+// shipShipment 通过store把发货组合中的订单标记为SHIPPED，确认订单预留的库存，保存已发出的发货组合并写入发件箱。
+// 没能发货的订单不在保存的发货组合和SHIPMENT_CREATED事件中，由调用方报告给提交它们的流。
+// 所有订单都没能发货时，发货组合以空的订单列表结束，不生成事件。
+func (s *server) shipShipment(store OrderStore, shipment *pb.CombinedShipment) ([]shipFailure, error) {
+	failures := shipOrders(store, shipment)
+	for _, ord := range shipment.OrdersList {
+		s.settleStock(store, ord)
+	}
+	shipment.ShipmentStatus = pb.ShipmentStatus_SHIPMENT_SHIPPED
+	// 发货组合在发出时才确定包含哪些订单，此时生成SHIPMENT_CREATED事件。
+	if s.outbox != nil && len(shipment.OrdersList) > 0 {
+		return failures, s.emitShipmentCreated(shipment)
+	}
+	return failures, s.shipments.put(shipment)
+}
+
+// shipSharedShipment 以服务器端自己的身份发出全局合并的发货组合，这种发货组合不属于单个调用。
+func (s *server) shipSharedShipment(shipment *pb.CombinedShipment) ([]shipFailure, error) {
+	return s.shipShipment(&tenantOrderStore{OrderStore: s.store, tenant: shipment.TenantId, registry: s.tenants}, shipment)
+}
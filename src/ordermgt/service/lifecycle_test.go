@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "ordermgt/service/ecommerce"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to pb.OrderStatus
+		want     bool
+	}{
+		{pb.OrderStatus_PENDING, pb.OrderStatus_CONFIRMED, true},
+		{pb.OrderStatus_PENDING, pb.OrderStatus_CANCELLED, true},
+		{pb.OrderStatus_PENDING, pb.OrderStatus_SHIPPED, false},
+		{pb.OrderStatus_CONFIRMED, pb.OrderStatus_PACKED, true},
+		{pb.OrderStatus_PACKED, pb.OrderStatus_SHIPPED, true},
+		{pb.OrderStatus_PACKED, pb.OrderStatus_CONFIRMED, false},
+		{pb.OrderStatus_SHIPPED, pb.OrderStatus_DELIVERED, true},
+		{pb.OrderStatus_SHIPPED, pb.OrderStatus_CANCELLED, false},
+		{pb.OrderStatus_DELIVERED, pb.OrderStatus_CANCELLED, false},
+		{pb.OrderStatus_CANCELLED, pb.OrderStatus_PENDING, false},
+	}
+	for _, tt := range tests {
+		if got := canTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("canTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestServer_TransitionOrder(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	client, stop := startBufConnServer(t, newServer(store))
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ord, err := client.TransitionOrder(ctx, &pb.TransitionOrderRequest{Id: "102", Status: pb.OrderStatus_CONFIRMED})
+	if err != nil {
+		t.Fatalf("TransitionOrder(102, CONFIRMED) failed: %v", err)
+	}
+	if ord.Status != pb.OrderStatus_CONFIRMED {
+		t.Fatalf("order status = %s, want CONFIRMED", ord.Status)
+	}
+
+	_, err = client.TransitionOrder(ctx, &pb.TransitionOrderRequest{Id: "102", Status: pb.OrderStatus_DELIVERED})
+	st := status.Convert(err)
+	if st.Code() != codes.FailedPrecondition {
+		t.Fatalf("illegal transition returned %v, want FailedPrecondition", err)
+	}
+	var violation *epb.PreconditionFailure_Violation
+	for _, d := range st.Details() {
+		if pf, ok := d.(*epb.PreconditionFailure); ok && len(pf.Violations) > 0 {
+			violation = pf.Violations[0]
+		}
+	}
+	if violation == nil || violation.Subject != "orders/102" {
+		t.Fatalf("missing PreconditionFailure detail: %v", st.Details())
+	}
+
+	_, err = client.TransitionOrder(ctx, &pb.TransitionOrderRequest{Id: "999", Status: pb.OrderStatus_CONFIRMED})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("TransitionOrder(999) returned %v, want NotFound", err)
+	}
+}
+
+func TestServer_ProcessOrdersShipsOrders(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	// 已取消的订单不能再被打包发货。
+	transitionOrder(store, "105", pb.OrderStatus_CANCELLED, 0)
+	client, stop := startBufConnServer(t, newServer(store))
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stream, err := client.ProcessOrders(ctx)
+	if err != nil {
+		t.Fatalf("ProcessOrders failed: %v", err)
+	}
+	for _, id := range []string{"102", "103", "105"} {
+		stream.Send(&wrapper.StringValue{Value: id})
+	}
+	stream.CloseSend()
+	shipped := 0
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		for _, ord := range res.GetShipment().GetOrdersList() {
+			if ord.Id == "105" {
+				t.Fatalf("cancelled order 105 was shipped")
+			}
+			if ord.Status != pb.OrderStatus_SHIPPED {
+				t.Fatalf("order %s in shipment has status %s, want SHIPPED", ord.Id, ord.Status)
+			}
+			shipped++
+		}
+	}
+	if shipped != 2 {
+		t.Fatalf("shipped %d orders, want 2", shipped)
+	}
+	if ord, _ := store.Get("102"); ord.Status != pb.OrderStatus_SHIPPED {
+		t.Fatalf("stored order 102 has status %s, want SHIPPED", ord.Status)
+	}
+	if ord, _ := store.Get("105"); ord.Status != pb.OrderStatus_CANCELLED {
+		t.Fatalf("stored order 105 has status %s, want CANCELLED", ord.Status)
+	}
+}
+
+// 已经打包的订单不能再次打包，否则它会被加入两个发货组合。
+func TestAdvanceOrder_AlreadyInTargetState(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	if _, err := packOrder(store, "102"); err != nil {
+		t.Fatalf("packOrder(102) failed: %v", err)
+	}
+	before, _ := store.Get("102")
+	_, err := packOrder(store, "102")
+	st := status.Convert(err)
+	if st.Code() != codes.FailedPrecondition {
+		t.Fatalf("packing a packed order returned %v, want FailedPrecondition", err)
+	}
+	var violation *epb.PreconditionFailure_Violation
+	for _, d := range st.Details() {
+		if pf, ok := d.(*epb.PreconditionFailure); ok && len(pf.Violations) > 0 {
+			violation = pf.Violations[0]
+		}
+	}
+	if violation == nil || violation.Type != "ORDER_STATUS" || violation.Subject != "orders/102" {
+		t.Fatalf("missing PreconditionFailure detail: %v", st.Details())
+	}
+	if after, _ := store.Get("102"); after.Version != before.Version || after.Status != pb.OrderStatus_PACKED {
+		t.Fatalf("order 102 after the rejected pack = %v", after)
+	}
+}
@@ -2,20 +2,26 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"github.com/golang/protobuf/ptypes/wrappers"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	/*"github.com/golang/protobuf/ptypes/wrappers"
 	"google.golang.org/grpc"*/
 	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	"go.opencensus.io/examples/exporter"
+	"go.opencensus.io/stats/view"
 	"io"
 	"log"
 	"net"
 	pb "ordermgt/service/ecommerce"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 const (
@@ -23,17 +29,144 @@ const (
 	orderBatchSize = 3
 )
 
-var orderMap = make(map[string]pb.Order)
+// 订单预写日志、快照、发货组合日志和审计日志所在的目录。为空时订单和发货组合只保存在内存中，重启后丢失。
+var dataDir = flag.String("data_dir", "", "directory for the order write-ahead log, snapshots, the shipment log, the audit log and the reservation log")
+
+// processOrders的批处理策略，为0表示不做该项限制。
+var (
+	batchMaxSize           = flag.Int("batch_max_size", defaultBatchPolicy.MaxBatchSize, "max number of orders in a processOrders batch")
+	batchMaxWait           = flag.Duration("batch_max_wait", defaultBatchPolicy.MaxWait, "max time a processOrders batch waits before it is shipped")
+	batchMaxPerDestination = flag.Int("batch_max_per_destination", defaultBatchPolicy.MaxOrdersPerDestination, "max number of orders in a combined shipment")
+	// 为true时所有processOrders流共享发货组合，在batch_max_wait的时间窗口内合并不同流中发往同一目的地的订单。
+	globalConsolidation = flag.Bool("global_consolidation", false, "consolidate orders from all processOrders streams into shared shipments")
+	// 订单合并为发货组合的策略，格式见parseShipmentPlanner。
+	shipmentPlanner = flag.String("shipment_planner", "destination", "how orders are consolidated: destination, max-orders:N, max-value:AMOUNT[:CUR], max-weight:GRAMS or priority")
+)
+
+// 带有idempotency-key的请求结果在服务器端保留的时间。
+var idempotencyTTL = flag.Duration("idempotency_ttl", defaultIdempotencyTTL, "how long results of requests with an idempotency-key are kept for replay")
+
+// 服务器端最多保留的带有idempotency-key的请求结果数。
+var idempotencyMaxKeys = flag.Int("idempotency_max_keys", defaultIdempotencyMaxEntries, "maximum number of idempotency-key results kept for replay, the oldest are evicted first")
+
+// 搜索结果快照保留的时间，在这段时间内翻页和断线后的续传看到与第一次请求相同的结果。
+var searchSnapshotTTL = flag.Duration("search_snapshot_ttl", defaultSearchSnapshotTTL, "how long searchOrders result snapshots are kept for paging and resuming")
+
+// 已删除订单的墓碑保留的时间。
+var tombstoneRetention = flag.Duration("tombstone_retention", defaultTombstoneRetention, "how long tombstones of deleted orders are kept")
+
+// ProductInfo服务的地址。设置后addOrder和updateOrders通过它解析订单条目并按目录价格计算订单价格，
+// addOrder同时为订单预留库存。
+var productInfoAddr = flag.String("product_info_addr", "", "address of the ProductInfo service used to validate order items and reserve stock, empty disables both")
+
+// 允许访问的租户，以逗号分隔，"default"表示默认租户。为空时接受任何格式正确的租户。
+var tenantList = flag.String("tenants", "", "comma separated list of tenants allowed to call the service, empty allows any tenant")
+
+// 每个租户最多保存的订单数，为0表示不限制。
+var tenantMaxOrders = flag.Int("tenant_max_orders", 0, "max number of orders stored for each tenant, 0 means unlimited")
+
+// 可以调用管理员接口的调用方，以逗号分隔，默认是TLS客户端证书的CommonName。
+var adminList = flag.String("admins", "", "comma separated list of caller identities allowed to call admin RPCs such as deleteOrder")
+
+// 为true时管理员身份也可以来自元数据caller-id。这个身份没有经过校验，只应在没有TLS的开发环境中使用。
+var trustCallerIDMetadata = flag.Bool("admin_trust_caller_id", false, "accept the unauthenticated caller-id metadata as an admin identity, for development without TLS")
+
+// 为true时把按租户划分的指标定期打印到日志中。
+var logMetrics = flag.Bool("log_metrics", false, "periodically log per-tenant metrics")
+
+// 重试未完成的库存确认和释放的间隔。
+var stockReconcileInterval = flag.Duration("stock_reconcile_interval", defaultStockReconcileInterval, "how often unfinished stock commits and releases are retried")
+
+// 领域事件的spool目录。设置后订单存储启用发件箱，中继把每个事件写入这个目录中的单独文件。
+var eventSpoolDir = flag.String("event_spool_dir", "", "directory the outbox relay delivers order events to, empty disables the outbox")
+
+// 多次投递失败的事件的目录，为空时使用event_spool_dir下的dead-letter目录。
+var eventDeadLetterDir = flag.String("event_dead_letter_dir", "", "directory for events that could not be delivered, defaults to dead-letter under -event_spool_dir")
+
+// 事件放入死信队列之前的投递次数。
+var eventMaxAttempts = flag.Int("event_max_attempts", defaultDeliveryAttempts, "delivery attempts before an event is moved to the dead-letter directory")
 
 type server struct {
-	orderMap map[string]*pb.Order
+	store       OrderStore
+	batchPolicy batchPolicy
+	clock       clock
+	tombstones  *orderTombstones
+	shipments   *shipmentStore
+	// newShipmentID 生成新发货组合的ID，测试中可以替换为确定的ID。
+	newShipmentID func() string
+	// consolidator 不为nil时，所有processOrders流共享发货组合。
+	consolidator *consolidator
+	// products 不为nil时，订单条目必须是ProductInfo中的商品。
+	products pb.ProductInfoClient
+	// inventory 不为nil时，addOrder为订单预留库存，订单发货或取消后确认或释放预留。
+	inventory *stockSaga
+	// tenants 维护每个租户的订单配额和指标。
+	tenants *tenantRegistry
+	// outbox 不为nil时，发出的发货组合生成SHIPMENT_CREATED事件。
+	outbox orderOutbox
+	// searches 保留searchOrders的结果快照。
+	searches *searchSnapshots
+	// admins 确定可以调用管理员接口的调用方，为nil时没有管理员。
+	admins *adminPolicy
 	pb.UnimplementedOrderManagementServer
 }
 
+// newServer 使用给定的订单存储、默认的批处理策略和墓碑保留期创建OrderManagement服务。
+// 发货组合默认只保存在内存中，租户没有订单配额。
+func newServer(store OrderStore) *server {
+	tenants, _ := newTenantRegistry(nil, 0)
+	return &server{
+		store:         store,
+		batchPolicy:   defaultBatchPolicy,
+		clock:         realClock{},
+		tombstones:    newOrderTombstones(defaultTombstoneRetention),
+		shipments:     newShipmentStore(),
+		newShipmentID: newShipmentID,
+		tenants:       tenants,
+		searches:      newSearchSnapshots(defaultSearchSnapshotTTL),
+	}
+}
+
 // Simple RPC
 func (s *server) AddOrder(ctx context.Context, orderReq *pb.Order) (*wrapper.StringValue, error) {
 	log.Printf("Order Added. ID : %v", orderReq.Id)
-	orderMap[orderReq.Id] = *orderReq
+	// 新订单总是从PENDING状态开始，之后只能通过生命周期迁移修改状态。
+	orderReq.Status = pb.OrderStatus_PENDING
+	orderReq.CreateTime = timestamppb.Now()
+	// 库存预留由服务器端维护，忽略客户端设置的值。
+	orderReq.ReservationId = ""
+	orderReq.Stock = pb.StockState_STOCK_NONE
+	if err := s.resolveItems(ctx, orderReq); err != nil {
+		return nil, err
+	}
+	if err := normalizeOrderPrice(orderReq); err != nil {
+		return nil, err
+	}
+	if s.inventory != nil {
+		if err := s.inventory.reserve(ctx, orderReq); err != nil {
+			return nil, err
+		}
+	}
+	// 已经存在的订单只能通过updateOrders和生命周期迁移修改，不能被addOrder覆盖。
+	err := s.storeFor(ctx).Txn([]string{orderReq.Id}, func(tx OrderTxn) error {
+		if _, exists := tx.Get(orderReq.Id); exists {
+			return status.Errorf(codes.AlreadyExists, "Order %s already exists", orderReq.Id)
+		}
+		return tx.Put(orderReq)
+	})
+	if err != nil {
+		// 补偿：订单没有写入，释放刚刚预留的库存。
+		if s.inventory != nil && orderReq.Stock == pb.StockState_STOCK_RESERVED {
+			s.inventory.detach(reservationRef{tenantOf(ctx), orderReq.ReservationId}, pb.StockState_STOCK_RELEASED)
+		}
+		if _, ok := status.FromError(err); !ok {
+			err = status.Errorf(codes.Internal, "failed to store order %s : %v", orderReq.Id, err)
+		}
+		return nil, err
+	}
+	if s.inventory != nil && orderReq.Stock == pb.StockState_STOCK_RESERVED {
+		s.inventory.resolve(reservationRef{tenantOf(ctx), orderReq.ReservationId})
+	}
 	return &wrapper.StringValue{Value: "Order Added: " + orderReq.Id}, nil
 }
 
@@ -41,38 +174,92 @@ func (s *server) AddOrder(ctx context.Context, orderReq *pb.Order) (*wrapper.Str
 // 作为GetOrder方法的输入，单个订单ID (String)用来组成请求，服务器端找到订单并以order消息(order结构体)的形式进行响应。
 // order 消息可以和nil错误一起返回，从而告诉gRPC，已经处理完RPC, 可以将Order返回到客户端了。
 func (s *server) GetOrder(ctx context.Context, orderId *wrapper.StringValue) (*pb.Order, error) {
-	ord, exists := orderMap[orderId.Value]
+	// 只能读取调用方租户的订单，其他租户的同一个订单ID对调用方来说不存在。
+	ord, exists := s.storeFor(ctx).Get(orderId.Value)
 	if exists {
-		return &ord, status.New(codes.OK, "").Err()
+		return ord, status.New(codes.OK, "").Err()
 	}
 
-	return nil, status.Errorf(codes.NotFound, "Order does not exist. : ", orderId)
+	// 订单不存在时，如果它是被删除的，错误详情中会说明删除的时间和原因。
+	return nil, s.tombstones.notFound(tenantOf(ctx), orderId.Value)
 }
 
 // Server-side Streaming RPC
-// SearchOrders 方法有两个参数，分别是字符串类型的searchQuery和用来写人响应的特殊参数OrderManagement_SearchOrdersServer。
+// SearchOrders 方法有两个参数，分别是查询条件searchQuery和用来写人响应的特殊参数OrderManagement_SearchOrdersServer。
 // OrderManagement_SearchOrdersServer 是流的引用对象，可以写入多个响应。
 // 业务逻辑是找到匹配的订单，并通过流将其依次发送出去。当找到新的订单时，使用流引用对象的Send(...)方法将其写入流。
 // 一旦所有响应都写到了流中，就可以通过返回nil来标记流已经结束，服务器端的状态和其他trailer元数据会发送给客户端。
-func (s *server) SearchOrders(searchQuery *wrappers.StringValue, stream pb.OrderManagement_SearchOrdersServer) error {
-
-	for key, order := range orderMap {
-		log.Print(key, order)
-		for _, itemStr := range order.Items {
-			log.Print(itemStr)
-			// 查找匹配的订单。
-			if strings.Contains(itemStr, searchQuery.Value) {
-				// Send the matching orders in a stream
-				// 通过流发送匹配的订单。
-				err := stream.Send(&order)
-				if err != nil {
-					// 检查在将消息以流的形式发送给客户端的过程中可能出现的错误。
-					return fmt.Errorf("error sending message to stream : %v", err)
+// 如果还有下一页结果，下一页的令牌会放在trailer元数据next-page-token中。
+// 每个订单带有searchCursor，客户端断线后用它作为resumeCursor重新调用，从下一个订单继续。
+func (s *server) SearchOrders(searchQuery *pb.SearchOrdersRequest, stream pb.OrderManagement_SearchOrdersServer) error {
+	// 查找匹配的订单。
+	ctx := stream.Context()
+	orders, nextPageToken, err := s.searches.search(s.storeFor(ctx), tenantOf(ctx), searchQuery)
+	if err != nil {
+		return err
+	}
+	if nextPageToken != "" {
+		stream.SetTrailer(metadata.Pairs(nextPageTokenKey, nextPageToken))
+	}
+	for _, order := range orders {
+		// Send the matching orders in a stream
+		// 通过流发送匹配的订单。
+		if err := stream.Send(order); err != nil {
+			// 检查在将消息以流的形式发送给客户端的过程中可能出现的错误。
+			return fmt.Errorf("error sending message to stream : %v", err)
+		}
+		log.Print("Matching Order Found : " + order.Id)
+	}
+	return nil
+}
+
+// updateOrder 用客户端发送的订单替换已有的订单，订单不存在时创建新订单。
+// 更新订单时保留订单当前的生命周期状态、创建时间和库存预留，已经预留库存的订单不能修改条目，
+// 已经发货、送达或被取消的订单不能修改条目、目的地和价格。
+// 订单带有版本时，只有版本与当前版本一致才写入，避免覆盖其他客户端的修改。
+// 写入成功后order.Version为订单的新版本。
+func updateOrder(store OrderStore, order *pb.Order) error {
+	if order.GetId() == "" {
+		return invalidFieldError("id", "Order ID must not be empty")
+	}
+	if err := normalizeOrderPrice(order); err != nil {
+		return err
+	}
+	err := store.Txn([]string{order.Id}, func(tx OrderTxn) error {
+		existing, ok := tx.Get(order.Id)
+		if err := checkVersion(order.Id, existing, order.Version); err != nil {
+			return err
+		}
+		if ok {
+			if orderFrozen(existing) {
+				// 在启用精确价格之前写入的订单没有exactPrice，规范化之后再比较。
+				if err := normalizeOrderPrice(existing); err != nil {
+					return err
+				}
+				if fields := changedFrozenFields(existing, order); len(fields) > 0 {
+					return frozenOrderError(existing, fields)
 				}
-				log.Print("Matching Order Found : " + key)
-				break
 			}
+			if existing.Stock == pb.StockState_STOCK_RESERVED && !sameItems(existing.Items, order.Items) {
+				return reservedItemsError(order.Id)
+			}
+			order.Status = existing.Status
+			order.CreateTime = existing.CreateTime
+			order.ReservationId = existing.ReservationId
+			order.Stock = existing.Stock
+		} else {
+			order.Status = pb.OrderStatus_PENDING
+			order.CreateTime = timestamppb.Now()
+			order.ReservationId = ""
+			order.Stock = pb.StockState_STOCK_NONE
 		}
+		return tx.Put(order)
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); !ok {
+			err = status.Errorf(codes.Internal, "failed to store order %s : %v", order.Id, err)
+		}
+		return err
 	}
 	return nil
 }
@@ -83,7 +270,7 @@ func (s *server) SearchOrders(searchQuery *wrappers.StringValue, stream pb.Order
 // 服务只需调用OrderManagenent_UpdateOrdersServer对象的SendAndClose方法就可以发送响应，它同时也标记服务器端消息终结了流。
 // 如果要提前停止读取客户端流，那么服务器端应该取消客户端流，这样客户端就知道停止生产消息了
 func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) error {
-
+	store := s.storeFor(stream.Context())
 	ordersStr := "Updated Order IDs : "
 	for {
 		// 从客户端流中读取消息。
@@ -97,74 +284,205 @@ func (s *server) UpdateOrders(stream pb.OrderManagement_UpdateOrdersServer) erro
 		if err != nil {
 			return err
 		}
-		// Update order
-		orderMap[order.Id] = *order
+		if err := s.resolveItems(stream.Context(), order); err != nil {
+			return err
+		}
+		if err := updateOrder(store, order); err != nil {
+			return err
+		}
 
 		log.Printf("Order ID : %s - %s", order.Id, "Updated")
 		ordersStr += order.Id + ", "
 	}
 }
 
+// Bi-directional Streaming RPC
+// UpdateOrdersV2 对每个收到的订单立即返回一个确认。与UpdateOrders不同，单个订单更新失败不会中断流，
+// 失败的原因放在确认的status中，客户端可以只重试失败的订单。
+// 成功的确认中带有订单的新版本，客户端带着这个版本重试时不会重复覆盖订单，因此这个方法不需要幂等键。
+func (s *server) UpdateOrdersV2(stream pb.OrderManagement_UpdateOrdersV2Server) error {
+	store := s.storeFor(stream.Context())
+	for index := int32(0); ; index++ {
+		order, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ack := &pb.UpdateOrderAck{Index: index, OrderId: order.GetId()}
+		err = s.resolveItems(stream.Context(), order)
+		if err == nil {
+			err = updateOrder(store, order)
+		}
+		if err != nil {
+			log.Printf("Order ID : %s - Update failed : %v", order.GetId(), err)
+			ack.Status = status.Convert(err).Proto()
+		} else {
+			log.Printf("Order ID : %s - %s", order.Id, "Updated")
+			ack.Version = order.Version
+			ack.Status = status.New(codes.OK, "").Proto()
+		}
+		if err := stream.Send(ack); err != nil {
+			return err
+		}
+	}
+}
+
+// orderIDResult 是从processOrders的客户端流中读取的一条消息。
+type orderIDResult struct {
+	orderId *wrapper.StringValue
+	err     error
+}
+
+// receiveOrderIDs 在单独的goroutine中读取客户端流，读到的消息和错误依次发送到返回的channel中。
+// 读取出错（包括io.EOF）或者流结束后goroutine退出。
+func receiveOrderIDs(stream pb.OrderManagement_ProcessOrdersServer) <-chan orderIDResult {
+	received := make(chan orderIDResult)
+	go func() {
+		for {
+			// 从传入的流中读取订单ID。
+			orderId, err := stream.Recv()
+			select {
+			case received <- orderIDResult{orderId, err}:
+			case <-stream.Context().Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return received
+}
+
+// sendShipment 把打包之后没能发货的订单的错误结果和发货组合写入流。
+func sendShipment(stream pb.OrderManagement_ProcessOrdersServer, comb *pb.CombinedShipment, failures []shipFailure) error {
+	// 打包之后没能发货的订单（例如在批次等待期间被取消）不在发货组合中，单独返回错误结果。
+	for _, f := range failures {
+		if err := stream.Send(orderResult(f.orderID, "", f.err)); err != nil {
+			return err
+		}
+	}
+	if len(comb.OrdersList) == 0 {
+		return nil
+	}
+	// 将发货组合写人流中。
+	return stream.Send(&pb.ProcessOrdersResponse{Response: &pb.ProcessOrdersResponse_Shipment{Shipment: comb}})
+}
+
 // Bi-directional Streaming RPC
 // ProcessOrders 方法有一个OrderManagement_ProcessOrdersServer参数，它是客户端和服务器端之间消息流的对象引用。
 // 借助这个流对象，服务器端可以读取客户端以流的方式发送的消息，也能写入服务器端的流消息并返回给客户端。
 // 传入的消息流可以通过该引用对象的Recv方法来读取。
 // 在ProcessOrders 方法中，服务可在持续读取传入消息流的同时，使用Send方法将消息写入同一个流中。
+// 启用了全局合并时，所有流共享服务器端的批处理策略，元数据中的批处理设置不起作用。
 func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) error {
+	if s.consolidator != nil {
+		return s.processOrdersConsolidated(stream)
+	}
+	// 批处理策略默认使用服务器端的配置，客户端可以通过元数据覆盖。
+	policy, err := batchPolicyFromContext(stream.Context(), s.batchPolicy)
+	if err != nil {
+		return err
+	}
+	batcher := newShipmentBatcher(policy, s.newShipmentID)
+	store := s.storeFor(stream.Context())
 
-	batchMarker := 1
-	var combinedShipmentMap = make(map[string]pb.CombinedShipment)
-	for {
-		// 从传入的流中读取订单ID。
-		orderId, err := stream.Recv()
-		log.Printf("Reading Proc order : %s", orderId)
-		// 持续读取，直到流结束为止。
-		if err == io.EOF {
-			// Client has sent all the messages
-			// Send remaining shipments
-			log.Printf("EOF : %s", orderId)
-			for _, shipment := range combinedShipmentMap {
-				// 当流结束时，将所有剩余的发货组合发送给客户端。
-				if err := stream.Send(&shipment); err != nil {
-					return err
-				}
+	// 在单独的goroutine中读取传入的流，这样在等待客户端的下一个订单ID时，批次也能按时发送。
+	received := receiveOrderIDs(stream)
+
+	// send 发出发货组合并把结果写入流。某个发货组合保存失败或者写入流失败后，剩下的发货组合仍然发出，
+	// 只是不再写入流，返回遇到的第一个错误；这样已经打包的订单不会停留在PACKED状态。
+	send := func(shipments []*pb.CombinedShipment) error {
+		var firstErr error
+		for _, comb := range shipments {
+			log.Printf("Shipping : %v -> %v", comb.Id, len(comb.OrdersList))
+			failures, err := s.shipShipment(store, comb)
+			if err != nil {
+				err = status.Errorf(codes.Internal, "failed to store shipment %s : %v", comb.Id, err)
+				log.Println(err)
+			} else if firstErr == nil {
+				err = sendShipment(stream, comb, failures)
+			}
+			if firstErr == nil {
+				firstErr = err
 			}
-			// 通过返回nil标记服务器端流已经结束。
-			return nil
 		}
-		if err != nil {
-			log.Println(err)
-			return err
+		return firstErr
+	}
+	// 流因为任何原因结束时（包括读取出错、客户端取消和写入失败），发出批次中剩余的订单。
+	defer func() {
+		if !batcher.empty() {
+			send(batcher.flush())
 		}
+	}()
 
-		destination := orderMap[orderId.GetValue()].Destination
-		shipment, found := combinedShipmentMap[destination]
+	var timer clockTimer
+	var timeout <-chan time.Time
+	stopTimer := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, timeout = nil, nil
+		}
+	}
+	defer stopTimer()
+	for {
+		select {
+		case <-timeout:
+			// 批次等待超时，发送所有已创建的发货组合。
+			timer, timeout = nil, nil
+			if err := send(batcher.flush()); err != nil {
+				return err
+			}
+		case res := <-received:
+			orderId, err := res.orderId, res.err
+			log.Printf("Reading Proc order : %s", orderId)
+			// 持续读取，直到流结束为止。
+			if err == io.EOF {
+				// Client has sent all the messages
+				// Send remaining shipments
+				log.Printf("EOF : %s", orderId)
+				// 当流结束时，将所有剩余的发货组合发送给客户端。
+				// 通过返回nil标记服务器端流已经结束。
+				return send(batcher.flush())
+			}
+			if err != nil {
+				log.Println(err)
+				return err
+			}
 
-		if found {
-			ord := orderMap[orderId.GetValue()]
-			shipment.OrdersList = append(shipment.OrdersList, &ord)
-			combinedShipmentMap[destination] = shipment
-		} else {
-			comShip := pb.CombinedShipment{Id: "cmb - " + (orderMap[orderId.GetValue()].Destination), Status: "Processed!"}
-			ord := orderMap[orderId.GetValue()]
-			comShip.OrdersList = append(shipment.OrdersList, &ord)
-			combinedShipmentMap[destination] = comShip
-			log.Print(len(comShip.OrdersList), comShip.GetId())
-		}
-
-		// 按批次处理订单。当达到该批次的规模时，将所有已创建的发货组合以流的形式发送给客户端。
-		if batchMarker == orderBatchSize {
-			for _, comb := range combinedShipmentMap {
-				log.Printf("Shipping : %v -> %v", comb.Id, len(comb.OrdersList))
-				// 将发货组合写人流中。
-				if err := stream.Send(&comb); err != nil {
+			// 无法处理的订单只返回一个错误结果，流继续处理后续的订单。
+			// 加入发货组合的订单进入PACKED状态，已取消或已发货的订单不能再次处理。
+			ord, err := packOrder(store, orderId.GetValue())
+			if err != nil {
+				log.Printf("Order ID : %s - skipped : %v", orderId.GetValue(), err)
+				if err := stream.Send(orderResult(orderId.GetValue(), "", err)); err != nil {
 					return err
 				}
+				continue
 			}
-			batchMarker = 0
-			combinedShipmentMap = make(map[string]pb.CombinedShipment)
-		} else {
-			batchMarker++
+			// 按批次处理订单。当达到批处理策略的限制时，将需要发送的发货组合以流的形式发送给客户端。
+			shipment, ready := batcher.add(ord)
+			if err := s.shipments.put(shipment); err != nil {
+				return status.Errorf(codes.Internal, "failed to store shipment %s : %v", shipment.Id, err)
+			}
+			if err := stream.Send(orderResult(ord.Id, shipment.Id, nil)); err != nil {
+				return err
+			}
+			if err := send(ready); err != nil {
+				return err
+			}
+			if batcher.empty() {
+				stopTimer()
+			} else if timer == nil && policy.MaxWait > 0 {
+				// 批次中的第一个订单开始计时。
+				timer = s.clock.NewTimer(policy.MaxWait)
+				timeout = timer.C()
+			}
+		case <-stream.Context().Done():
+			// 客户端取消或者连接断开，读取流的goroutine已经退出，剩余的批次由上面的defer发出。
+			return status.FromContextError(stream.Context().Err()).Err()
 		}
 	}
 
@@ -174,13 +492,136 @@ func (s *server) ProcessOrders(stream pb.OrderManagement_ProcessOrdersServer) er
 }
 
 func main() {
-	initSampleData()
+	flag.Parse()
+	memStore := newMemoryOrderStore(defaultShardCount)
+	var store OrderStore = memStore
+	enableOutbox := memStore.enableOutbox
+	shipments := newShipmentStore()
+	audit := newAuditLog()
+	if *dataDir != "" {
+		fileStore, err := openFileOrderStore(*dataDir, defaultSnapshotEvery)
+		if err != nil {
+			log.Fatalf("failed to open order store: %v", err)
+		}
+		defer fileStore.Close()
+		store = fileStore
+		enableOutbox = fileStore.enableOutbox
+		shipments, err = openShipmentStore(*dataDir)
+		if err != nil {
+			log.Fatalf("failed to open shipment store: %v", err)
+		}
+		defer shipments.Close()
+		audit, err = openAuditLog(*dataDir)
+		if err != nil {
+			log.Fatalf("failed to open audit log: %v", err)
+		}
+		defer audit.Close()
+	}
+	// 发件箱在最底层的存储中启用，领域事件与订单变更在同一个事务中写入。
+	var outbox orderOutbox
+	if *eventSpoolDir != "" {
+		outbox = enableOutbox()
+		if err := recoverShipments(outbox, shipments); err != nil {
+			log.Fatalf("failed to recover shipments from the outbox: %v", err)
+		}
+	}
+	// 在订单条目和描述上维护倒排索引，加速searchOrders的文本查询。
+	store = newIndexedOrderStore(store)
+	// 为每次变更生成带revision的事件，供watchOrders订阅。
+	store = newWatchedOrderStore(store, defaultWatchHistory)
+	// 为每次变更记录带调用方身份的审计记录，供getOrderHistory查询。
+	store = newAuditedOrderStore(store, audit)
+	// 只有在存储为空（首次启动）时才写入示例数据，避免覆盖已恢复的订单。
+	empty := true
+	store.Scan(func(order *pb.Order) bool {
+		empty = false
+		return false
+	})
+	if empty {
+		initSampleData(store)
+	}
 	lis, err := net.Listen("tcp", port)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer()
-	pb.RegisterOrderManagementServer(s, &server{})
+	// 租户拦截器确定每个调用的租户，之后的幂等键和存储访问都按租户隔离。
+	var allowedTenants []string
+	if *tenantList != "" {
+		allowedTenants = strings.Split(*tenantList, ",")
+	}
+	tenants, err := newTenantRegistry(allowedTenants, *tenantMaxOrders)
+	if err != nil {
+		log.Fatalf("invalid -tenants: %v", err)
+	}
+	// 使用拦截器处理addOrder和updateOrders的幂等键。
+	idempotency := newIdempotencyCache(*idempotencyTTL, *idempotencyMaxKeys)
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(tenants.unaryInterceptor, idempotency.unaryInterceptor),
+		grpc.ChainStreamInterceptor(tenants.streamInterceptor, idempotency.streamInterceptor),
+	)
+	srv := newServer(store)
+	srv.tenants = tenants
+	// 注册按租户划分的视图，指标带有tenant标签，可以通过任何OpenCensus导出器导出。
+	if err := view.Register(tenantViews...); err != nil {
+		log.Fatalf("failed to register views: %v", err)
+	}
+	if *logMetrics {
+		view.RegisterExporter(&exporter.PrintExporter{})
+	}
+	planner, err := parseShipmentPlanner(*shipmentPlanner)
+	if err != nil {
+		log.Fatalf("invalid -shipment_planner: %v", err)
+	}
+	srv.batchPolicy = batchPolicy{MaxBatchSize: *batchMaxSize, MaxWait: *batchMaxWait, MaxOrdersPerDestination: *batchMaxPerDestination, Planner: planner}
+	srv.tombstones = newOrderTombstones(*tombstoneRetention)
+	if *adminList != "" {
+		srv.admins = newAdminPolicy(strings.Split(*adminList, ","), *trustCallerIDMetadata)
+	}
+	srv.searches = newSearchSnapshots(*searchSnapshotTTL)
+	srv.shipments = shipments
+	if *productInfoAddr != "" {
+		conn, err := grpc.Dial(*productInfoAddr, grpc.WithInsecure())
+		if err != nil {
+			log.Fatalf("did not connect to ProductInfo: %v", err)
+		}
+		defer conn.Close()
+		srv.products = pb.NewProductInfoClient(conn)
+		// 库存saga使用服务器端自己的身份记录预留状态的变化，启动时先处理重启前没有完成的确认和释放。
+		srv.inventory = newStockSaga(srv.products)
+		if *dataDir != "" {
+			if srv.inventory, err = openStockSaga(srv.products, *dataDir); err != nil {
+				log.Fatalf("failed to open reservation log: %v", err)
+			}
+			defer srv.inventory.Close()
+		}
+		go srv.inventory.run(store, srv.clock, *stockReconcileInterval)
+	}
+	if outbox != nil {
+		if *eventMaxAttempts < 1 {
+			log.Fatalf("invalid -event_max_attempts: %d", *eventMaxAttempts)
+		}
+		sink, err := openFileSpoolSink(*eventSpoolDir)
+		if err != nil {
+			log.Fatalf("failed to open event spool: %v", err)
+		}
+		deadLetterDir := *eventDeadLetterDir
+		if deadLetterDir == "" {
+			deadLetterDir = filepath.Join(*eventSpoolDir, "dead-letter")
+		}
+		deadLetters, err := openFileSpoolSink(deadLetterDir)
+		if err != nil {
+			log.Fatalf("failed to open dead-letter spool: %v", err)
+		}
+		srv.outbox = outbox
+		relay := newOutboxRelay(outbox, sink, deadLetters, srv.clock)
+		relay.maxAttempts = *eventMaxAttempts
+		// 中继随进程一直运行，重启后从发件箱中未确认的事件继续投递。
+		go relay.run(nil)
+	}
+	if *globalConsolidation {
+		srv.consolidator = newConsolidator(srv.batchPolicy, srv.clock, srv.newShipmentID, srv.shipments.put, srv.shipSharedShipment)
+	}
+	pb.RegisterOrderManagementServer(s, srv)
 	// Register reflection service on gRPC server.
 	// reflection.Register(s)
 	if err := s.Serve(lis); err != nil {
@@ -188,10 +629,15 @@ func main() {
 	}
 }
 
-func initSampleData() {
-	orderMap["102"] = pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA", Price: 1800.00}
-	orderMap["103"] = pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 400.00}
-	orderMap["104"] = pb.Order{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}, Destination: "Mountain View, CA", Price: 400.00}
-	orderMap["105"] = pb.Order{Id: "105", Items: []string{"Amazon Echo"}, Destination: "San Jose, CA", Price: 30.00}
-	orderMap["106"] = pb.Order{Id: "106", Items: []string{"Amazon Echo", "Apple iPhone XS"}, Destination: "Mountain View, CA", Price: 300.00}
+func initSampleData(store OrderStore) {
+	for _, order := range []*pb.Order{
+		{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA", Price: 1800.00},
+		{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA", Price: 400.00},
+		{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}, Destination: "Mountain View, CA", Price: 400.00},
+		{Id: "105", Items: []string{"Amazon Echo"}, Destination: "San Jose, CA", Price: 30.00},
+		{Id: "106", Items: []string{"Amazon Echo", "Apple iPhone XS"}, Destination: "Mountain View, CA", Price: 300.00},
+	} {
+		normalizeOrderPrice(order)
+		store.Put(order)
+	}
 }
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	pb "ordermgt/service/ecommerce"
+)
+
+const bufSize = 1024 * 1024
+
+// startBufConnServer 在bufconn上启动OrderManagement服务，返回客户端和清理函数。
+func startBufConnServer(t *testing.T, srv *server, opts ...grpc.ServerOption) (pb.OrderManagementClient, func()) {
+	t.Helper()
+	conn, stop := dialBufConnServer(t, srv, opts...)
+	return pb.NewOrderManagementClient(conn), stop
+}
+
+// dialBufConnServer 在bufconn上启动OrderManagement服务，返回到它的连接和清理函数。
+func dialBufConnServer(t *testing.T, srv *server, opts ...grpc.ServerOption) (*grpc.ClientConn, func()) {
+	t.Helper()
+	listener := bufconn.Listen(bufSize)
+	s := grpc.NewServer(opts...)
+	pb.RegisterOrderManagementServer(s, srv)
+	go s.Serve(listener)
+
+	dialer := func(ctx context.Context, url string) (net.Conn, error) {
+		return listener.Dial()
+	}
+	conn, err := grpc.DialContext(context.Background(), "bufnet", grpc.WithContextDialer(dialer), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	return conn, func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func TestServer_GetOrder(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	client, stop := startBufConnServer(t, newServer(store))
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ord, err := client.GetOrder(ctx, &wrapper.StringValue{Value: "103"})
+	if err != nil {
+		t.Fatalf("GetOrder(103) failed: %v", err)
+	}
+	if ord.Destination != "San Jose, CA" {
+		t.Fatalf("GetOrder(103).Destination = %q", ord.Destination)
+	}
+}
+
+// 已经存在的订单不能被addOrder覆盖，也不会被重置为PENDING。
+func TestServer_AddOrderExisting(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	client, stop := startBufConnServer(t, newServer(store))
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := transitionOrder(store, "103", pb.OrderStatus_CONFIRMED, 0); err != nil {
+		t.Fatalf("transitionOrder(103) failed: %v", err)
+	}
+	_, err := client.AddOrder(ctx, &pb.Order{Id: "103", Items: []string{"Google Home Mini"}, Destination: "Mountain View, CA"})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("AddOrder(103) returned %v, want AlreadyExists", err)
+	}
+	if ord, _ := store.Get("103"); ord.Status != pb.OrderStatus_CONFIRMED || ord.Destination != "San Jose, CA" {
+		t.Fatalf("order 103 = %v after a rejected AddOrder", ord)
+	}
+}
+
+// 两个客户端基于同一个版本修改订单时，后提交的修改返回Aborted，而不是覆盖前一个修改。
+func TestServer_OptimisticConcurrency(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	client, stop := startBufConnServer(t, newServer(store))
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ord, err := client.GetOrder(ctx, &wrapper.StringValue{Value: "102"})
+	if err != nil || ord.Version != 1 {
+		t.Fatalf("GetOrder(102) = %v, %v, want version 1", ord, err)
+	}
+	update := func(order *pb.Order) error {
+		stream, err := client.UpdateOrders(ctx)
+		if err != nil {
+			return err
+		}
+		stream.Send(order)
+		_, err = stream.CloseAndRecv()
+		return err
+	}
+	first := &pb.Order{Id: "102", Items: ord.Items, Destination: ord.Destination, Price: 1000, Version: ord.Version}
+	second := &pb.Order{Id: "102", Items: ord.Items, Destination: ord.Destination, Price: 2000, Version: ord.Version}
+	if err := update(first); err != nil {
+		t.Fatalf("first update failed: %v", err)
+	}
+	err = update(second)
+	st := status.Convert(err)
+	if st.Code() != codes.Aborted {
+		t.Fatalf("stale update returned %v, want Aborted", err)
+	}
+	var violation *epb.PreconditionFailure_Violation
+	for _, d := range st.Details() {
+		if pf, ok := d.(*epb.PreconditionFailure); ok {
+			violation = pf.Violations[0]
+		}
+	}
+	if violation == nil || violation.Type != "ORDER_VERSION" || violation.Subject != "orders/102" {
+		t.Fatalf("missing version PreconditionFailure detail: %v", st.Details())
+	}
+	ord, _ = client.GetOrder(ctx, &wrapper.StringValue{Value: "102"})
+	if ord.Price != 1000 || ord.Version != 2 {
+		t.Fatalf("order 102 = %v, want the first update at version 2", ord)
+	}
+
+	_, err = client.TransitionOrder(ctx, &pb.TransitionOrderRequest{Id: "102", Status: pb.OrderStatus_CONFIRMED, ExpectedVersion: 1})
+	if status.Code(err) != codes.Aborted {
+		t.Fatalf("TransitionOrder with stale version returned %v, want Aborted", err)
+	}
+	ord, err = client.TransitionOrder(ctx, &pb.TransitionOrderRequest{Id: "102", Status: pb.OrderStatus_CONFIRMED, ExpectedVersion: 2})
+	if err != nil || ord.Version != 3 {
+		t.Fatalf("TransitionOrder with current version = %v, %v", ord, err)
+	}
+}
+
+// updateOrdersV2对每个订单返回确认，失败的订单不会中断流。
+func TestServer_UpdateOrdersV2Acks(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	client, stop := startBufConnServer(t, newServer(store))
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stream, err := client.UpdateOrdersV2(ctx)
+	if err != nil {
+		t.Fatalf("UpdateOrdersV2 failed: %v", err)
+	}
+	orders := []*pb.Order{
+		{Id: "102", Destination: "Mountain View, CA", Price: 1100},
+		{Id: "", Destination: "Nowhere"},
+		{Id: "103", Destination: "San Jose, CA", Price: 2800, Version: 7},
+		{Id: "107", Destination: "Austin, TX", Price: 30},
+	}
+	for _, order := range orders {
+		if err := stream.Send(order); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	stream.CloseSend()
+	var got []string
+	for {
+		ack, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		got = append(got, fmt.Sprintf("%d:%s:%d:%s", ack.Index, ack.OrderId, ack.Version, codes.Code(ack.Status.GetCode())))
+	}
+	want := "[0:102:2:OK 1::0:InvalidArgument 2:103:0:Aborted 3:107:1:OK]"
+	if fmt.Sprint(got) != want {
+		t.Fatalf("acks = %v, want %s", got, want)
+	}
+	if ord, _ := store.Get("103"); ord.Price == 2800 {
+		t.Fatalf("rejected update of order 103 was applied")
+	}
+}
+
+// 在并发的一元、客户端流、服务器端流和双向流调用下访问同一个存储，配合 go test -race 运行。
+func TestServer_ConcurrentStreams(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	client, stop := startBufConnServer(t, newServer(store))
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	const workers = 8
+	const ordersPerWorker = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*4)
+	for w := 0; w < workers; w++ {
+		wg.Add(4)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < ordersPerWorker; i++ {
+				id := fmt.Sprintf("w%d-%d", w, i)
+				order := &pb.Order{Id: id, Items: []string{"Google Pixel 3A"}, Destination: "Mountain View, CA"}
+				if _, err := client.AddOrder(ctx, order); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			stream, err := client.UpdateOrders(ctx)
+			if err != nil {
+				errs <- err
+				return
+			}
+			for i := 0; i < ordersPerWorker; i++ {
+				// 订单102同时在被发货，发货后只能修改条目、目的地和价格以外的字段。
+				order := &pb.Order{Id: "102", Items: []string{"Google Pixel 3A", "Mac Book Pro"}, Destination: "Mountain View, CA", Price: 1800, Description: fmt.Sprintf("update %d-%d", w, i)}
+				if err := stream.Send(order); err != nil {
+					errs <- err
+					return
+				}
+			}
+			if _, err := stream.CloseAndRecv(); err != nil {
+				errs <- err
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			stream, err := client.SearchOrders(ctx, &pb.SearchOrdersRequest{Item: "Google"})
+			if err != nil {
+				errs <- err
+				return
+			}
+			for {
+				if _, err := stream.Recv(); err == io.EOF {
+					return
+				} else if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			stream, err := client.ProcessOrders(ctx)
+			if err != nil {
+				errs <- err
+				return
+			}
+			for _, id := range []string{"102", "103", "104", "105", "106"} {
+				if err := stream.Send(&wrapper.StringValue{Value: id}); err != nil {
+					errs <- err
+					return
+				}
+			}
+			stream.CloseSend()
+			for {
+				if _, err := stream.Recv(); err == io.EOF {
+					return
+				} else if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent call failed: %v", err)
+	}
+
+	count := 0
+	store.Scan(func(order *pb.Order) bool {
+		count++
+		return true
+	})
+	if want := 5 + workers*ordersPerWorker; count != want {
+		t.Fatalf("store holds %d orders, want %d", count, want)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	pb "ordermgt/service/ecommerce"
+)
+
+const (
+	nanosPerUnit = 1000000000
+	// defaultCurrency 是旧客户端只设置float价格时使用的货币。
+	defaultCurrency = "USD"
+)
+
+var (
+	errCurrencyMismatch = errors.New("money values have different currencies")
+	errMoneyOverflow    = errors.New("money value overflows")
+)
+
+// validateMoney 检查货币代码是否为三个大写字母，nanos是否在范围内并且与units同号。
+func validateMoney(m *pb.Money) error {
+	if len(m.CurrencyCode) != 3 || strings.ToUpper(m.CurrencyCode) != m.CurrencyCode {
+		return fmt.Errorf("invalid currency code %q", m.CurrencyCode)
+	}
+	if m.Nanos <= -nanosPerUnit || m.Nanos >= nanosPerUnit {
+		return fmt.Errorf("nanos %d out of range", m.Nanos)
+	}
+	if (m.Units > 0 && m.Nanos < 0) || (m.Units < 0 && m.Nanos > 0) {
+		return fmt.Errorf("units %d and nanos %d have different signs", m.Units, m.Nanos)
+	}
+	return nil
+}
+
+// parseMoney 把十进制字符串（例如"1800.00"）精确地转换为金额，小数部分最多9位。
+func parseMoney(currency, s string) (*pb.Money, error) {
+	negative := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(s, "-")
+	intPart, fracPart := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		intPart, fracPart = digits[:i], digits[i+1:]
+	}
+	if intPart == "" || len(fracPart) > 9 || strings.HasPrefix(intPart, "+") {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	units, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount %q : %v", s, err)
+	}
+	var nanos int64
+	if fracPart != "" {
+		if nanos, err = strconv.ParseInt(fracPart+strings.Repeat("0", 9-len(fracPart)), 10, 32); err != nil || nanos < 0 {
+			return nil, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+	if negative {
+		units, nanos = -units, -nanos
+	}
+	return &pb.Money{CurrencyCode: currency, Units: units, Nanos: int32(nanos)}, nil
+}
+
+// moneyFromFloat 把旧客户端设置的float价格换算为金额。float32只有约7位有效数字，
+// 因此先取能还原出同一个float32的最短十进制表示，例如float32(1800.00)得到1800。
+func moneyFromFloat(currency string, f float32) (*pb.Money, error) {
+	if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+		return nil, fmt.Errorf("invalid amount %v", f)
+	}
+	return parseMoney(currency, strconv.FormatFloat(float64(f), 'f', -1, 32))
+}
+
+// moneyToFloat 把金额换算为float，只用于填充旧的price字段，会损失精度。
+func moneyToFloat(m *pb.Money) float32 {
+	return float32(float64(m.Units) + float64(m.Nanos)/nanosPerUnit)
+}
+
+// addMoney 精确地计算a+b，货币不同时返回errCurrencyMismatch。
+func addMoney(a, b *pb.Money) (*pb.Money, error) {
+	if a.CurrencyCode != b.CurrencyCode {
+		return nil, errCurrencyMismatch
+	}
+	if (b.Units > 0 && a.Units > math.MaxInt64-b.Units) || (b.Units < 0 && a.Units < math.MinInt64-b.Units) {
+		return nil, errMoneyOverflow
+	}
+	units := a.Units + b.Units
+	nanos := a.Nanos + b.Nanos
+	// 进位，并让units和nanos保持同号。
+	if nanos >= nanosPerUnit {
+		units++
+		nanos -= nanosPerUnit
+	} else if nanos <= -nanosPerUnit {
+		units--
+		nanos += nanosPerUnit
+	}
+	if units > 0 && nanos < 0 {
+		units--
+		nanos += nanosPerUnit
+	} else if units < 0 && nanos > 0 {
+		units++
+		nanos -= nanosPerUnit
+	}
+	if (a.Units > 0 && b.Units > 0 && units < 0) || (a.Units < 0 && b.Units < 0 && units > 0) {
+		return nil, errMoneyOverflow
+	}
+	return &pb.Money{CurrencyCode: a.CurrencyCode, Units: units, Nanos: nanos}, nil
+}
+
+// sumMoney 精确地计算所有金额之和，所有金额的货币必须与currency相同。
+func sumMoney(currency string, values ...*pb.Money) (*pb.Money, error) {
+	total := &pb.Money{CurrencyCode: currency}
+	for _, m := range values {
+		var err error
+		if total, err = addMoney(total, m); err != nil {
+			return nil, err
+		}
+	}
+	return total, nil
+}
+
+// compareMoney 比较两个金额，a小于、等于、大于b时分别返回-1、0、1。货币不同的金额不能比较。
+func compareMoney(a, b *pb.Money) (int, error) {
+	if a.CurrencyCode != b.CurrencyCode {
+		return 0, errCurrencyMismatch
+	}
+	switch {
+	case a.Units < b.Units:
+		return -1, nil
+	case a.Units > b.Units:
+		return 1, nil
+	case a.Nanos < b.Nanos:
+		return -1, nil
+	case a.Nanos > b.Nanos:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// formatMoney 把金额格式化为"USD 1800.00"的形式，去掉小数部分末尾多余的0，但至少保留两位小数。
+func formatMoney(m *pb.Money) string {
+	return m.CurrencyCode + " " + formatAmount(m)
+}
+
+// formatAmount 与formatMoney相同，但不带货币代码，结果可以用parseMoney解析回原来的金额。
+func formatAmount(m *pb.Money) string {
+	sign := ""
+	units, nanos := m.Units, int64(m.Nanos)
+	if units < 0 || nanos < 0 {
+		sign = "-"
+		units, nanos = -units, -nanos
+	}
+	frac := strings.TrimRight(fmt.Sprintf("%09d", nanos), "0")
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	return fmt.Sprintf("%s%d.%s", sign, uint64(units), frac)
+}
+
+// normalizeOrderPrice 是旧客户端的兼容层：只设置了float价格的订单按USD换算出精确价格，
+// 设置了精确价格的订单用它覆盖float价格，保证两个字段一致。
+func normalizeOrderPrice(order *pb.Order) error {
+	if order.ExactPrice == nil {
+		m, err := moneyFromFloat(defaultCurrency, order.Price)
+		if err != nil {
+			return invalidFieldError("price", err.Error())
+		}
+		order.ExactPrice = m
+		return nil
+	}
+	if err := validateMoney(order.ExactPrice); err != nil {
+		return invalidFieldError("exactPrice", err.Error())
+	}
+	order.Price = moneyToFloat(order.ExactPrice)
+	return nil
+}
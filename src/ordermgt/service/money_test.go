@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "ordermgt/service/ecommerce"
+)
+
+func usd(s string) *pb.Money {
+	m, err := parseMoney("USD", s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func TestParseAndFormatMoney(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"1800", "USD 1800.00"},
+		{"1800.00", "USD 1800.00"},
+		{"0.1", "USD 0.10"},
+		{"-0.5", "USD -0.50"},
+		{"-12.000000001", "USD -12.000000001"},
+	}
+	for _, tt := range tests {
+		m, err := parseMoney("USD", tt.in)
+		if err != nil {
+			t.Fatalf("parseMoney(%q) failed: %v", tt.in, err)
+		}
+		if err := validateMoney(m); err != nil {
+			t.Fatalf("parseMoney(%q) = %v is invalid: %v", tt.in, m, err)
+		}
+		if got := formatMoney(m); got != tt.want {
+			t.Errorf("formatMoney(parseMoney(%q)) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+	for _, bad := range []string{"", ".5", "1.0000000001", "1.-5", "abc"} {
+		if _, err := parseMoney("USD", bad); err == nil {
+			t.Errorf("parseMoney(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	// 十个0.1在float中累加不等于1，精确计算必须等于1。
+	var tenths []*pb.Money
+	for i := 0; i < 10; i++ {
+		tenths = append(tenths, usd("0.1"))
+	}
+	total, err := sumMoney("USD", tenths...)
+	if err != nil || formatMoney(total) != "USD 1.00" {
+		t.Fatalf("sum of ten 0.1 = %v, %v", total, err)
+	}
+	if m, _ := addMoney(usd("1.25"), usd("-3.5")); formatMoney(m) != "USD -2.25" || validateMoney(m) != nil {
+		t.Fatalf("1.25 + -3.5 = %v", m)
+	}
+	if m, _ := addMoney(usd("-0.75"), usd("1.5")); formatMoney(m) != "USD 0.75" || validateMoney(m) != nil {
+		t.Fatalf("-0.75 + 1.5 = %v", m)
+	}
+	if _, err := addMoney(&pb.Money{CurrencyCode: "USD", Units: 1 << 62}, &pb.Money{CurrencyCode: "USD", Units: 1 << 62}); err != errMoneyOverflow {
+		t.Fatalf("overflowing sum returned %v", err)
+	}
+
+	if c, _ := compareMoney(usd("400"), usd("399.999999999")); c != 1 {
+		t.Fatalf("compareMoney(400, 399.999999999) = %d", c)
+	}
+	if c, _ := compareMoney(usd("-1.5"), usd("-1.25")); c != -1 {
+		t.Fatalf("compareMoney(-1.5, -1.25) = %d", c)
+	}
+	eur := &pb.Money{CurrencyCode: "EUR", Units: 400}
+	if _, err := compareMoney(usd("400"), eur); err != errCurrencyMismatch {
+		t.Fatalf("comparing mixed currencies returned %v", err)
+	}
+	if _, err := addMoney(usd("400"), eur); err != errCurrencyMismatch {
+		t.Fatalf("adding mixed currencies returned %v", err)
+	}
+}
+
+func TestMoneyFromFloat(t *testing.T) {
+	for f, want := range map[float32]string{1800.00: "USD 1800.00", 699.99: "USD 699.99", 0.1: "USD 0.10", 30: "USD 30.00"} {
+		m, err := moneyFromFloat("USD", f)
+		if err != nil || formatMoney(m) != want {
+			t.Errorf("moneyFromFloat(%v) = %v, %v, want %s", f, m, err, want)
+		}
+	}
+}
+
+// 旧客户端只设置price，新客户端只设置exactPrice，两者读到的订单中两个字段都已填好。
+func TestServer_PriceCompatibility(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	client, stop := startBufConnServer(t, newServer(store))
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	client.AddOrder(ctx, &pb.Order{Id: "legacy", Destination: "San Jose, CA", Price: 699.99})
+	client.AddOrder(ctx, &pb.Order{Id: "exact", Destination: "San Jose, CA", ExactPrice: usd("1800.00")})
+	legacy, _ := client.GetOrder(ctx, &wrapper.StringValue{Value: "legacy"})
+	if formatMoney(legacy.GetExactPrice()) != "USD 699.99" {
+		t.Fatalf("legacy order has exact price %v", legacy.GetExactPrice())
+	}
+	exact, _ := client.GetOrder(ctx, &wrapper.StringValue{Value: "exact"})
+	if exact.Price != 1800 {
+		t.Fatalf("exact order has legacy price %v", exact.Price)
+	}
+
+	_, err := client.AddOrder(ctx, &pb.Order{Id: "bad", ExactPrice: &pb.Money{CurrencyCode: "usd", Units: 1}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("invalid currency returned %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	pb "ordermgt/service/ecommerce"
+)
+
+const (
+	// defaultRelayBatchSize 是中继每次从发件箱中取出的事件数。
+	defaultRelayBatchSize = 100
+	// defaultDeliveryAttempts 是事件放入死信队列之前的投递次数。
+	defaultDeliveryAttempts = 5
+	// defaultDeliveryBackoff 和 defaultMaxDeliveryBackoff 是两次投递之间的初始和最大等待时间，每次失败后等待时间加倍。
+	defaultDeliveryBackoff    = 100 * time.Millisecond
+	defaultMaxDeliveryBackoff = 30 * time.Second
+	// eventDeliveryTimeout 是单次投递的超时时间。
+	eventDeliveryTimeout = 5 * time.Second
+)
+
+// errOutboxDisabled 表示存储没有启用发件箱。
+var errOutboxDisabled = errors.New("order store outbox is not enabled")
+
+// EventSink 是发件箱中的领域事件的投递目标。Deliver返回nil表示事件已经被接收。
+// 中继至少投递一次，同一个事件可能被投递多次，实现应该按事件的sequence去重。
+type EventSink interface {
+	Deliver(ctx context.Context, event *pb.DomainEvent) error
+}
+
+// orderOutbox 由带有事务性发件箱的存储实现，例如启用了发件箱的memoryOrderStore和fileOrderStore。
+// 订单的事件由存储在提交变更的同一个事务中写入，中继通过Pending和Ack按序号顺序取出事件。
+type orderOutbox interface {
+	// Emit 把不对应订单变更的事件（例如SHIPMENT_CREATED）写入发件箱，并设置事件的序号。
+	// apply不为nil时在事件持久化之后、对中继可见之前调用，把事件描述的状态保存到其他存储中。
+	// 事件持久化之后apply的错误原样返回，事件仍然保留在发件箱中。
+	Emit(event *pb.DomainEvent, apply func() error) error
+	// Pending 按序号顺序返回最多limit个尚未确认的事件，以及有新事件写入时关闭的channel。
+	Pending(limit int) ([]*pb.DomainEvent, <-chan struct{})
+	// Ack 确认序号不大于sequence的事件已经处理完毕，把它们从发件箱中移除。
+	Ack(sequence int64) error
+}
+
+// outbox 保存尚未确认的领域事件。持久化由拥有它的存储负责。
+type outbox struct {
+	mu sync.Mutex
+	// last 是最后分配的序号，acked 是最后确认的序号。
+	last, acked int64
+	events      []*pb.DomainEvent
+	// arrived 在有新事件写入时关闭并替换为新的channel。
+	arrived chan struct{}
+}
+
+func newOutbox() *outbox {
+	return &outbox{arrived: make(chan struct{})}
+}
+
+func (o *outbox) lastSequence() int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.last
+}
+
+// add 加入已经分配了序号的事件，已经确认过的事件被忽略。
+func (o *outbox) add(events []*pb.DomainEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.addLocked(events)
+}
+
+// emit 为事件分配序号并加入发件箱。分配序号和加入在同一个锁内完成，发件箱中的事件总是按序号排列。
+func (o *outbox) emit(events []*pb.DomainEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, event := range events {
+		event.Sequence = o.last + 1
+		o.last = event.Sequence
+	}
+	o.addLocked(events)
+}
+
+func (o *outbox) addLocked(events []*pb.DomainEvent) {
+	if len(events) == 0 {
+		return
+	}
+	for _, event := range events {
+		if event.Sequence > o.last {
+			o.last = event.Sequence
+		}
+		if event.Sequence > o.acked {
+			o.events = append(o.events, event)
+		}
+	}
+	close(o.arrived)
+	o.arrived = make(chan struct{})
+}
+
+func (o *outbox) pending(limit int) ([]*pb.DomainEvent, <-chan struct{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := len(o.events)
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	events := make([]*pb.DomainEvent, n)
+	for i := range events {
+		events[i] = proto.Clone(o.events[i]).(*pb.DomainEvent)
+	}
+	return events, o.arrived
+}
+
+func (o *outbox) ack(sequence int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if sequence <= o.acked {
+		return
+	}
+	o.acked = sequence
+	if sequence > o.last {
+		o.last = sequence
+	}
+	i := 0
+	for i < len(o.events) && o.events[i].Sequence <= sequence {
+		i++
+	}
+	o.events = append([]*pb.DomainEvent(nil), o.events[i:]...)
+}
+
+// carry 返回重建发件箱所需的记录：所有未确认的事件，以及保证序号在重启后不会重复的确认位置。
+func (o *outbox) carry() walRecord {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	rec := walRecord{acked: o.acked, events: make([]*pb.DomainEvent, len(o.events))}
+	if len(o.events) == 0 {
+		// 没有未确认的事件时，last就是确认位置。
+		rec.acked = o.last
+	}
+	copy(rec.events, o.events)
+	return rec
+}
+
+// orderEvents 为事务中每个订单的净变更生成一个领域事件，序号由发件箱分配。
+func orderEvents(changes []orderChange) []*pb.DomainEvent {
+	now := timestamppb.Now()
+	var events []*pb.DomainEvent
+	for _, change := range changes {
+		event := &pb.DomainEvent{Time: now, Order: change.after}
+		switch {
+		case change.before == nil && change.after == nil:
+			// 事务中新增又删除的订单没有留下任何变化。
+			continue
+		case change.after == nil:
+			event.Type = pb.DomainEventType_ORDER_DELETED
+			event.Order = change.before
+		case change.before == nil:
+			event.Type = pb.DomainEventType_ORDER_PLACED
+		default:
+			event.Type = pb.DomainEventType_ORDER_UPDATED
+		}
+		event.Order = cloneOrder(event.Order)
+		event.TenantId = event.Order.TenantId
+		events = append(events, event)
+	}
+	return events
+}
+
+// outboxRelay 把发件箱中的事件按序号顺序投递给sink，每个事件确认投递后才从发件箱中移除。
+// 投递失败时按指数退避重试，达到maxAttempts次后把事件放入deadLetters，之后继续投递下一个事件。
+// 事件按顺序逐个投递，重试期间后面的事件等待，消费者看到的事件顺序与提交顺序一致。
+type outboxRelay struct {
+	outbox      orderOutbox
+	sink        EventSink
+	deadLetters EventSink
+	clock       clock
+	maxAttempts int
+	// backoff 是第一次失败后的等待时间，之后每次加倍，不超过maxBackoff。
+	backoff, maxBackoff time.Duration
+}
+
+func newOutboxRelay(outbox orderOutbox, sink, deadLetters EventSink, clk clock) *outboxRelay {
+	return &outboxRelay{
+		outbox:      outbox,
+		sink:        sink,
+		deadLetters: deadLetters,
+		clock:       clk,
+		maxAttempts: defaultDeliveryAttempts,
+		backoff:     defaultDeliveryBackoff,
+		maxBackoff:  defaultMaxDeliveryBackoff,
+	}
+}
+
+// run 持续投递事件，直到done被关闭。
+func (r *outboxRelay) run(done <-chan struct{}) {
+	for {
+		events, arrived := r.outbox.Pending(defaultRelayBatchSize)
+		if len(events) == 0 {
+			select {
+			case <-arrived:
+				continue
+			case <-done:
+				return
+			}
+		}
+		for _, event := range events {
+			if !r.deliver(event, done) {
+				return
+			}
+			if err := r.outbox.Ack(event.Sequence); err != nil {
+				// 确认没有持久化时事件会被再次投递，等待一段时间再重新取出事件。
+				log.Printf("Event %d - ack failed : %v", event.Sequence, err)
+				if !r.wait(r.maxBackoff, done) {
+					return
+				}
+				break
+			}
+		}
+	}
+}
+
+// deliver 投递一个事件，失败次数达到上限后把事件放入死信队列。
+// 死信队列也无法接收时一直重试，事件不会被丢弃。done被关闭时返回false。
+func (r *outboxRelay) deliver(event *pb.DomainEvent, done <-chan struct{}) bool {
+	backoff := r.backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		sink := r.sink
+		if attempt > r.maxAttempts {
+			sink = r.deadLetters
+			if err != nil {
+				event.DeadLetterReason = err.Error()
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), eventDeliveryTimeout)
+		deliverErr := sink.Deliver(ctx, event)
+		cancel()
+		if deliverErr == nil {
+			if attempt > r.maxAttempts {
+				log.Printf("Event %d - %s moved to dead-letter queue : %s", event.Sequence, event.Type, event.DeadLetterReason)
+			}
+			return true
+		}
+		if attempt <= r.maxAttempts {
+			err = deliverErr
+		}
+		log.Printf("Event %d - %s delivery attempt %d failed : %v", event.Sequence, event.Type, attempt, deliverErr)
+		if !r.wait(backoff, done) {
+			return false
+		}
+		if backoff *= 2; backoff > r.maxBackoff {
+			backoff = r.maxBackoff
+		}
+	}
+}
+
+// wait 等待d，done被关闭时返回false。
+func (r *outboxRelay) wait(d time.Duration, done <-chan struct{}) bool {
+	timer := r.clock.NewTimer(d)
+	select {
+	case <-timer.C():
+		return true
+	case <-done:
+		timer.Stop()
+		return false
+	}
+}
+
+// memoryEventSink 把事件保存在内存中，供同一个进程中的消费者和测试使用。
+type memoryEventSink struct {
+	mu     sync.Mutex
+	events []*pb.DomainEvent
+}
+
+func (s *memoryEventSink) Deliver(ctx context.Context, event *pb.DomainEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, proto.Clone(event).(*pb.DomainEvent))
+	return nil
+}
+
+// Events 按投递顺序返回收到的事件，重复投递的事件会出现多次。
+func (s *memoryEventSink) Events() []*pb.DomainEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := make([]*pb.DomainEvent, len(s.events))
+	copy(events, s.events)
+	return events
+}
+
+// fileSpoolSink 把每个事件以JSON格式写入目录中的单独文件，文件名以零填充的序号开头，
+// 按文件名排序就是事件的顺序。文件先写入临时文件再重命名，消费者不会读到写了一半的事件；
+// 重复投递的事件覆盖同名的文件。
+type fileSpoolSink struct {
+	dir string
+}
+
+func openFileSpoolSink(dir string) (*fileSpoolSink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileSpoolSink{dir: dir}, nil
+}
+
+// spoolFileName 返回事件在spool目录中的文件名，例如"00000000000000000042-order_placed.json"。
+func spoolFileName(event *pb.DomainEvent) string {
+	return fmt.Sprintf("%020d-%s.json", event.Sequence, strings.ToLower(event.Type.String()))
+}
+
+func (s *fileSpoolSink) Deliver(ctx context.Context, event *pb.DomainEvent) error {
+	data, err := protojson.Marshal(event)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(s.dir, ".event-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(s.dir, spoolFileName(event)))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return syncDir(s.dir)
+}
+
+// emitShipmentCreated 保存已发出的发货组合，并把SHIPMENT_CREATED事件写入发件箱。
+// 发货组合保存在单独的日志中，无法与事件写在同一条记录里，因此以事件为准：事件中带有完整的发货组合，
+// 先持久化事件，再在事件对中继可见之前保存发货组合。两步之间崩溃时，重启后由recoverShipments从发件箱中恢复发货组合。
+func (s *server) emitShipmentCreated(shipment *pb.CombinedShipment) error {
+	s.shipments.stamp(shipment)
+	event := &pb.DomainEvent{
+		Type:     pb.DomainEventType_SHIPMENT_CREATED,
+		Time:     timestamppb.Now(),
+		TenantId: shipment.TenantId,
+		Shipment: proto.Clone(shipment).(*pb.CombinedShipment),
+	}
+	return s.outbox.Emit(event, func() error {
+		return s.shipments.restore(shipment)
+	})
+}
+
+// recoverShipments 把发件箱中尚未确认的SHIPMENT_CREATED事件中的发货组合保存到shipments，
+// 补上写入事件之后、保存发货组合之前崩溃时丢失的发货组合。已经保存了相同或更新版本的发货组合不受影响。
+func recoverShipments(outbox orderOutbox, shipments *shipmentStore) error {
+	events, _ := outbox.Pending(0)
+	for _, event := range events {
+		if event.Type != pb.DomainEventType_SHIPMENT_CREATED || event.Shipment == nil {
+			continue
+		}
+		if err := shipments.restore(event.Shipment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	pb "ordermgt/service/ecommerce"
+)
+
+// eventSummary 把事件列为"序号:类型:ID"，便于比较。
+func eventSummary(events []*pb.DomainEvent) string {
+	var summary []string
+	for _, event := range events {
+		id := event.GetOrder().GetId()
+		if event.Shipment != nil {
+			id = event.Shipment.Id
+		}
+		summary = append(summary, fmt.Sprintf("%d:%s:%s", event.Sequence, event.Type, id))
+	}
+	return fmt.Sprint(summary)
+}
+
+func TestMemoryOrderStore_Outbox(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	store.Put(&pb.Order{Id: "101"})
+	outbox := store.enableOutbox()
+
+	store.Put(&pb.Order{Id: "102", TenantId: "acme"})
+	store.Put(&pb.Order{Id: "102", TenantId: "acme", Destination: "Austin, TX"})
+	store.Txn([]string{"101", "103", "104"}, func(tx OrderTxn) error {
+		tx.Delete("101")
+		tx.Put(&pb.Order{Id: "104"})
+		tx.Put(&pb.Order{Id: "103"})
+		// 事务中新增又删除的订单不生成事件。
+		tx.Put(&pb.Order{Id: "105"})
+		return nil
+	})
+	// 放弃的事务不生成事件。
+	store.Txn([]string{"106"}, func(tx OrderTxn) error {
+		tx.Put(&pb.Order{Id: "106"})
+		return errors.New("abort")
+	})
+	outbox.Emit(&pb.DomainEvent{Type: pb.DomainEventType_SHIPMENT_CREATED, Shipment: &pb.CombinedShipment{Id: "cmb-1"}}, nil)
+
+	events, _ := outbox.Pending(0)
+	want := "[1:ORDER_PLACED:102 2:ORDER_UPDATED:102 3:ORDER_DELETED:101 4:ORDER_PLACED:103 5:ORDER_PLACED:104 6:SHIPMENT_CREATED:cmb-1]"
+	if got := eventSummary(events); got != want {
+		t.Fatalf("events = %s, want %s", got, want)
+	}
+	if events[1].TenantId != "acme" || events[1].Order.Destination != "Austin, TX" || events[1].Order.Version != 2 {
+		t.Fatalf("ORDER_UPDATED event = %v", events[1])
+	}
+
+	outbox.Ack(4)
+	if events, _ := outbox.Pending(1); eventSummary(events) != "[5:ORDER_PLACED:104]" {
+		t.Fatalf("pending after ack = %s", eventSummary(events))
+	}
+	_, arrived := outbox.Pending(0)
+	store.Delete("104")
+	select {
+	case <-arrived:
+	default:
+		t.Fatalf("new event did not wake up the relay")
+	}
+}
+
+// 事件与订单变更写在同一条日志记录中，重启后未确认的事件仍在发件箱中，序号不会重复。
+func TestFileOrderStore_OutboxRecovery(t *testing.T) {
+	dir := t.TempDir()
+	store := openTestFileStore(t, dir, 100)
+	outbox := store.enableOutbox()
+	store.Put(&pb.Order{Id: "102"})
+	store.Put(&pb.Order{Id: "103"})
+	outbox.Emit(&pb.DomainEvent{Type: pb.DomainEventType_SHIPMENT_CREATED, Shipment: &pb.CombinedShipment{Id: "cmb-1"}}, nil)
+	store.Delete("102")
+	outbox.Ack(2)
+	store.Close()
+
+	store = openTestFileStore(t, dir, 3)
+	outbox = store.enableOutbox()
+	events, _ := outbox.Pending(0)
+	if got, want := eventSummary(events), "[3:SHIPMENT_CREATED:cmb-1 4:ORDER_DELETED:102]"; got != want {
+		t.Fatalf("recovered events = %s, want %s", got, want)
+	}
+
+	// 压缩日志后未确认的事件和确认位置写入新日志。
+	outbox.Ack(3)
+	for i := 0; i < 4; i++ {
+		store.Put(&pb.Order{Id: fmt.Sprintf("%d", 110+i)})
+	}
+	outbox.Ack(7)
+	store.Close()
+
+	store = openTestFileStore(t, dir, 3)
+	defer store.Close()
+	outbox = store.enableOutbox()
+	events, _ = outbox.Pending(0)
+	if got, want := eventSummary(events), "[8:ORDER_PLACED:113]"; got != want {
+		t.Fatalf("events after compaction = %s, want %s", got, want)
+	}
+	outbox.Ack(8)
+	store.Put(&pb.Order{Id: "114"})
+	if events, _ = outbox.Pending(0); eventSummary(events) != "[9:ORDER_PLACED:114]" {
+		t.Fatalf("sequence restarted after compaction: %s", eventSummary(events))
+	}
+	if got := orderIDs(store); got != "[103 110 111 112 113 114]" {
+		t.Fatalf("recovered orders %s", got)
+	}
+}
+
+// flakySink 在每个事件的前几次投递时返回错误。
+type flakySink struct {
+	memoryEventSink
+	mu       sync.Mutex
+	failures map[int64]int
+	attempts map[int64]int
+}
+
+func (s *flakySink) Deliver(ctx context.Context, event *pb.DomainEvent) error {
+	s.mu.Lock()
+	s.attempts[event.Sequence]++
+	if s.failures[event.Sequence] > 0 {
+		s.failures[event.Sequence]--
+		s.mu.Unlock()
+		return errors.New("sink unavailable")
+	}
+	s.mu.Unlock()
+	return s.memoryEventSink.Deliver(ctx, event)
+}
+
+// 失败的投递按指数退避重试，多次失败的事件进入死信队列，之后的事件继续按顺序投递。
+func TestOutboxRelay_RetryAndDeadLetter(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	outbox := store.enableOutbox()
+	sink := &flakySink{failures: map[int64]int{1: 2, 2: 100}, attempts: make(map[int64]int)}
+	deadLetters := &memoryEventSink{}
+	fc := newFakeClock()
+	relay := newOutboxRelay(outbox, sink, deadLetters, fc)
+	relay.maxAttempts = 3
+	relay.backoff, relay.maxBackoff = time.Second, 3*time.Second
+	done := make(chan struct{})
+	defer close(done)
+	go relay.run(done)
+
+	for _, id := range []string{"101", "102", "103"} {
+		store.Put(&pb.Order{Id: id})
+	}
+	// 每次等待的时间正好是预期的退避时间。
+	for i, backoff := range []time.Duration{time.Second, 2 * time.Second, time.Second, 2 * time.Second, 3 * time.Second} {
+		deadline := time.Now().Add(5 * time.Second)
+		for fc.Pending() != 1 {
+			if time.Now().After(deadline) {
+				t.Fatalf("relay is not waiting before retry %d", i+1)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		fc.Advance(backoff - time.Millisecond)
+		if fc.Pending() != 1 {
+			t.Fatalf("retry %d happened before %v", i+1, backoff)
+		}
+		fc.Advance(time.Millisecond)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if events, _ := outbox.Pending(0); len(events) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("outbox was not drained")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got, want := eventSummary(sink.Events()), "[1:ORDER_PLACED:101 3:ORDER_PLACED:103]"; got != want {
+		t.Fatalf("delivered %s, want %s", got, want)
+	}
+	sink.mu.Lock()
+	attempts := fmt.Sprint(sink.attempts)
+	sink.mu.Unlock()
+	if attempts != "map[1:3 2:3 3:1]" {
+		t.Fatalf("delivery attempts = %s", attempts)
+	}
+	dead := deadLetters.Events()
+	if eventSummary(dead) != "[2:ORDER_PLACED:102]" || dead[0].DeadLetterReason != "sink unavailable" {
+		t.Fatalf("dead letters = %v", dead)
+	}
+}
+
+func TestFileSpoolSink(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "spool")
+	sink, err := openFileSpoolSink(dir)
+	if err != nil {
+		t.Fatalf("openFileSpoolSink failed: %v", err)
+	}
+	event := &pb.DomainEvent{Sequence: 42, Type: pb.DomainEventType_ORDER_PLACED, Order: &pb.Order{Id: "102"}}
+	for i := 0; i < 2; i++ {
+		if err := sink.Deliver(context.Background(), event); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	// 重复投递覆盖同一个文件，不留下临时文件。
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 || files[0].Name() != "00000000000000000042-order_placed.json" {
+		t.Fatalf("spool contains %v", files)
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(dir, files[0].Name()))
+	got := &pb.DomainEvent{}
+	if err := protojson.Unmarshal(data, got); err != nil || got.Sequence != 42 || got.Order.GetId() != "102" {
+		t.Fatalf("spooled event = %v, %v", got, err)
+	}
+}
+
+// 发出的发货组合生成SHIPMENT_CREATED事件，在订单的ORDER_UPDATED事件之后。
+func TestServer_ShipmentCreatedEvent(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	srv := newServer(store)
+	order, _ := store.Get("103")
+	// 打包之后被取消的订单不在发出的发货组合和事件中。
+	cancelled, _ := store.Get("104")
+	transitionOrder(store, "104", pb.OrderStatus_CANCELLED, 0)
+	srv.outbox = store.enableOutbox()
+	shipment := &pb.CombinedShipment{Id: "cmb-1", Destination: order.Destination, OrdersList: []*pb.Order{order, cancelled}}
+	failures, err := srv.shipShipment(store, shipment)
+	if err != nil {
+		t.Fatalf("shipShipment failed: %v", err)
+	}
+	if len(failures) != 1 || failures[0].orderID != "104" || status.Code(failures[0].err) != codes.FailedPrecondition {
+		t.Fatalf("failures = %v, want order 104", failures)
+	}
+	if stored, _, _ := srv.shipments.get("cmb-1"); len(stored.OrdersList) != 1 || stored.OrdersList[0].Id != "103" {
+		t.Fatalf("stored shipment = %v", stored)
+	}
+	events, _ := srv.outbox.Pending(0)
+	if got, want := eventSummary(events), "[1:ORDER_UPDATED:103 2:SHIPMENT_CREATED:cmb-1]"; got != want {
+		t.Fatalf("events = %s, want %s", got, want)
+	}
+	if events[0].Order.Status != pb.OrderStatus_SHIPPED || events[1].Shipment.ShipmentStatus != pb.ShipmentStatus_SHIPMENT_SHIPPED || len(events[1].Shipment.OrdersList) != 1 {
+		t.Fatalf("shipment events = %v", events)
+	}
+}
+
+// 写入SHIPMENT_CREATED事件之后、保存发货组合之前崩溃时，重启后从发件箱中恢复发货组合。
+func TestRecoverShipments(t *testing.T) {
+	dir := t.TempDir()
+	store := openTestFileStore(t, dir, 100)
+	outbox := store.enableOutbox()
+	shipments, err := openShipmentStore(dir)
+	if err != nil {
+		t.Fatalf("openShipmentStore failed: %v", err)
+	}
+	shipment := &pb.CombinedShipment{Id: "cmb-1", Destination: "San Jose, CA", OrdersList: []*pb.Order{{Id: "103"}}}
+	shipments.put(shipment)
+	shipment.ShipmentStatus = pb.ShipmentStatus_SHIPMENT_SHIPPED
+	shipments.stamp(shipment)
+	event := &pb.DomainEvent{Type: pb.DomainEventType_SHIPMENT_CREATED, Shipment: proto.Clone(shipment).(*pb.CombinedShipment)}
+	err = outbox.Emit(event, func() error {
+		// 发货组合保存之前，中继看不到事件。
+		if events, _ := outbox.Pending(0); len(events) != 0 {
+			t.Errorf("event visible before the shipment was saved: %s", eventSummary(events))
+		}
+		return errors.New("crashed")
+	})
+	if err == nil || err.Error() != "crashed" {
+		t.Fatalf("Emit returned %v, want the error of apply", err)
+	}
+	if events, _ := outbox.Pending(0); eventSummary(events) != "[1:SHIPMENT_CREATED:cmb-1]" {
+		t.Fatalf("pending events = %s", eventSummary(events))
+	}
+	store.Close()
+	shipments.Close()
+
+	store = openTestFileStore(t, dir, 100)
+	defer store.Close()
+	if shipments, err = openShipmentStore(dir); err != nil {
+		t.Fatalf("openShipmentStore failed: %v", err)
+	}
+	defer shipments.Close()
+	if stored, _, _ := shipments.get("cmb-1"); stored.ShipmentStatus != pb.ShipmentStatus_SHIPMENT_OPEN {
+		t.Fatalf("shipment before recovery = %v", stored)
+	}
+	for i := 0; i < 2; i++ {
+		if err := recoverShipments(store.enableOutbox(), shipments); err != nil {
+			t.Fatalf("recoverShipments failed: %v", err)
+		}
+		stored, _, _ := shipments.get("cmb-1")
+		if stored.ShipmentStatus != pb.ShipmentStatus_SHIPMENT_SHIPPED || stored.Version != 2 || len(stored.OrdersList) != 1 {
+			t.Fatalf("recovered shipment = %v", stored)
+		}
+	}
+}
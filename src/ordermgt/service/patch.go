@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "ordermgt/service/ecommerce"
+)
+
+// patchablePaths 是patchOrder可以修改的字段。id、status、version等字段由服务器端维护，不能通过patchOrder修改。
+var patchablePaths = map[string]bool{
+	"items":       true,
+	"description": true,
+	"price":       true,
+	"exactPrice":  true,
+	"destination": true,
+	"weightGrams": true,
+	"priority":    true,
+}
+
+// validatePatchRequest 检查updateMask中的路径，返回去重后的路径集合。
+// 所有无效的路径一起在BadRequest详情中返回，而不是只返回第一个。
+func validatePatchRequest(req *pb.PatchOrderRequest) (map[string]bool, error) {
+	if req.GetOrder().GetId() == "" {
+		return nil, invalidFieldError("order.id", "Order ID must not be empty")
+	}
+	if len(req.GetUpdateMask().GetPaths()) == 0 {
+		return nil, invalidFieldError("updateMask", "At least one field path is required")
+	}
+	paths := make(map[string]bool)
+	var violations []*epb.BadRequest_FieldViolation
+	for i, path := range req.UpdateMask.Paths {
+		field := fmt.Sprintf("updateMask.paths[%d]", i)
+		if patchablePaths[path] {
+			paths[path] = true
+			continue
+		}
+		description := fmt.Sprintf("Unknown field path %q", path)
+		if pb.File_order_management_proto.Messages().ByName("Order").Fields().ByJSONName(path) != nil {
+			description = fmt.Sprintf("Field %q is managed by the server and cannot be patched", path)
+		}
+		violations = append(violations, &epb.BadRequest_FieldViolation{Field: field, Description: description})
+	}
+	if paths["price"] && paths["exactPrice"] {
+		violations = append(violations, &epb.BadRequest_FieldViolation{
+			Field:       "updateMask",
+			Description: "price and exactPrice cannot be patched together",
+		})
+	}
+	if len(violations) > 0 {
+		return nil, fieldViolationsError(violations)
+	}
+	return paths, nil
+}
+
+// patchedItems 返回按照mode替换或追加之后的条目。
+func patchedItems(items, patch []string, mode pb.ItemsPatchMode) []string {
+	if mode == pb.ItemsPatchMode_APPEND {
+		return append(append([]string{}, items...), patch...)
+	}
+	return patch
+}
+
+// applyPatch 把patch中列出的字段复制到订单上。items按照mode替换或追加。
+func applyPatch(order, patch *pb.Order, paths map[string]bool, mode pb.ItemsPatchMode) error {
+	if paths["items"] {
+		order.Items = patchedItems(order.Items, patch.Items, mode)
+	}
+	if paths["description"] {
+		order.Description = patch.Description
+	}
+	if paths["destination"] {
+		order.Destination = patch.Destination
+	}
+	if paths["weightGrams"] {
+		order.WeightGrams = patch.WeightGrams
+	}
+	if paths["priority"] {
+		order.Priority = patch.Priority
+	}
+	// 修改任意一个价格字段后，重新计算另一个字段，保证两者一致。
+	if paths["price"] {
+		order.Price = patch.Price
+		order.ExactPrice = nil
+		return normalizeOrderPrice(order)
+	}
+	if paths["exactPrice"] {
+		if patch.ExactPrice == nil {
+			return invalidFieldError("order.exactPrice", "exactPrice must be set when it is listed in updateMask")
+		}
+		order.ExactPrice = patch.ExactPrice
+		return normalizeOrderPrice(order)
+	}
+	return nil
+}
+
+// patchOrder 在一个事务中读取订单，只修改updateMask中列出的字段，然后写回。
+// 订单已经发货、送达或被取消时，修改条目、目的地或价格返回FailedPrecondition。
+// resolved不为nil时是事务之前通过ProductInfo解析的修改之后的条目，订单的条目在此期间发生变化时返回Aborted，
+// 否则用resolved的productIds和价格替换订单的值。
+func patchOrder(store OrderStore, req *pb.PatchOrderRequest, paths map[string]bool, resolved *pb.Order) (*pb.Order, error) {
+	id := req.Order.Id
+	var patched *pb.Order
+	err := store.Txn([]string{id}, func(tx OrderTxn) error {
+		order, exists := tx.Get(id)
+		if !exists {
+			return orderNotFoundError(id)
+		}
+		if err := checkVersion(id, order, req.ExpectedVersion); err != nil {
+			return err
+		}
+		if orderFrozen(order) {
+			var frozen []string
+			for _, path := range frozenOrderFields {
+				if paths[path] {
+					frozen = append(frozen, path)
+				}
+			}
+			if len(frozen) > 0 {
+				return frozenOrderError(order, frozen)
+			}
+		}
+		if paths["items"] && order.Stock == pb.StockState_STOCK_RESERVED {
+			return reservedItemsError(id)
+		}
+		if err := applyPatch(order, req.Order, paths, req.ItemsMode); err != nil {
+			return err
+		}
+		if resolved != nil {
+			if !sameItems(order.Items, resolved.Items) {
+				return status.Errorf(codes.Aborted, "Order %s was modified concurrently", id)
+			}
+			order.ProductIds = resolved.ProductIds
+			order.ExactPrice = resolved.ExactPrice
+			order.Price = resolved.Price
+		}
+		patched = order
+		return tx.Put(order)
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); !ok {
+			err = status.Errorf(codes.Internal, "failed to store order %s : %v", id, err)
+		}
+		return nil, err
+	}
+	log.Printf("Order ID : %s - Patched : %v", id, req.UpdateMask.Paths)
+	return patched, nil
+}
+
+// Simple RPC
+// PatchOrder 部分更新订单，客户端不需要先读取订单再重新发送所有字段。
+// 服务器端配置了ProductInfo时，修改后的条目与addOrder一样通过ProductInfo解析，价格由目录价格计算，不能直接修改。
+func (s *server) PatchOrder(ctx context.Context, req *pb.PatchOrderRequest) (*pb.Order, error) {
+	paths, err := validatePatchRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	store := s.storeFor(ctx)
+	if s.products == nil {
+		return patchOrder(store, req, paths, nil)
+	}
+	for _, path := range []string{"price", "exactPrice"} {
+		if paths[path] {
+			return nil, invalidFieldError("updateMask", fmt.Sprintf("%s is computed from the product catalog and cannot be patched", path))
+		}
+	}
+	if !paths["items"] {
+		return patchOrder(store, req, paths, nil)
+	}
+	// 在事务之外解析条目，避免在等待ProductInfo时持有订单的锁。
+	current, exists := store.Get(req.Order.Id)
+	if !exists {
+		return nil, orderNotFoundError(req.Order.Id)
+	}
+	resolved := &pb.Order{Items: patchedItems(current.Items, req.Order.Items, req.ItemsMode)}
+	if err := s.resolveItems(ctx, resolved); err != nil {
+		return nil, err
+	}
+	return patchOrder(store, req, paths, resolved)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	pb "ordermgt/service/ecommerce"
+)
+
+func fieldViolations(err error) []string {
+	var fields []string
+	for _, d := range status.Convert(err).Details() {
+		if br, ok := d.(*epb.BadRequest); ok {
+			for _, v := range br.FieldViolations {
+				fields = append(fields, v.Field)
+			}
+		}
+	}
+	return fields
+}
+
+func TestServer_PatchOrder(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	client, stop := startBufConnServer(t, newServer(store))
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	before, _ := store.Get("102")
+	patched, err := client.PatchOrder(ctx, &pb.PatchOrderRequest{
+		Order:      &pb.Order{Id: "102", Destination: "San Jose, CA", Description: "ignored"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"destination"}},
+	})
+	if err != nil {
+		t.Fatalf("PatchOrder failed: %v", err)
+	}
+	if patched.Destination != "San Jose, CA" || patched.Description != before.Description ||
+		fmt.Sprint(patched.Items) != fmt.Sprint(before.Items) || patched.Price != before.Price {
+		t.Fatalf("patched order = %v, only destination should change from %v", patched, before)
+	}
+	if patched.Version != before.Version+1 {
+		t.Fatalf("patched version = %d, want %d", patched.Version, before.Version+1)
+	}
+
+	patched, _ = client.PatchOrder(ctx, &pb.PatchOrderRequest{
+		Order:      &pb.Order{Id: "102", Items: []string{"Google Pixel Buds"}},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"items"}},
+		ItemsMode:  pb.ItemsPatchMode_APPEND,
+	})
+	if want := fmt.Sprint(append(before.Items, "Google Pixel Buds")); fmt.Sprint(patched.Items) != want {
+		t.Fatalf("appended items = %v, want %s", patched.Items, want)
+	}
+	patched, _ = client.PatchOrder(ctx, &pb.PatchOrderRequest{
+		Order:      &pb.Order{Id: "102", Items: []string{"Google Pixel Buds"}},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"items"}},
+	})
+	if fmt.Sprint(patched.Items) != "[Google Pixel Buds]" {
+		t.Fatalf("replaced items = %v", patched.Items)
+	}
+
+	// 修改exactPrice时price随之更新。
+	patched, _ = client.PatchOrder(ctx, &pb.PatchOrderRequest{
+		Order:      &pb.Order{Id: "102", ExactPrice: usd("199.99")},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"exactPrice"}},
+	})
+	if formatMoney(patched.ExactPrice) != "USD 199.99" || patched.Price != float32(199.99) {
+		t.Fatalf("patched price = %v / %v", patched.ExactPrice, patched.Price)
+	}
+
+	if _, err := client.PatchOrder(ctx, &pb.PatchOrderRequest{
+		Order:           &pb.Order{Id: "102", Destination: "Austin, TX"},
+		UpdateMask:      &fieldmaskpb.FieldMask{Paths: []string{"destination"}},
+		ExpectedVersion: before.Version,
+	}); status.Code(err) != codes.Aborted {
+		t.Fatalf("PatchOrder with stale version returned %v, want Aborted", err)
+	}
+	if _, err := client.PatchOrder(ctx, &pb.PatchOrderRequest{
+		Order:      &pb.Order{Id: "999", Destination: "Austin, TX"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"destination"}},
+	}); status.Code(err) != codes.NotFound {
+		t.Fatalf("PatchOrder of unknown order returned %v, want NotFound", err)
+	}
+}
+
+func TestServer_PatchOrderInvalidPaths(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	client, stop := startBufConnServer(t, newServer(store))
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	tests := []struct {
+		paths []string
+		want  string
+	}{
+		{nil, "[updateMask]"},
+		{[]string{"destination", "colour", "status"}, "[updateMask.paths[1] updateMask.paths[2]]"},
+		{[]string{"price", "exactPrice"}, "[updateMask]"},
+	}
+	for _, tt := range tests {
+		_, err := client.PatchOrder(ctx, &pb.PatchOrderRequest{
+			Order:      &pb.Order{Id: "102", Destination: "Austin, TX"},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: tt.paths},
+		})
+		if status.Code(err) != codes.InvalidArgument || fmt.Sprint(fieldViolations(err)) != tt.want {
+			t.Errorf("PatchOrder(%v) returned %v with violations %v, want %s", tt.paths, err, fieldViolations(err), tt.want)
+		}
+	}
+	// 无效的请求不会修改订单。
+	if order, _ := store.Get("102"); order.Destination != "Mountain View, CA" {
+		t.Fatalf("invalid patch changed destination to %q", order.Destination)
+	}
+}
+
+// 订单发货、送达或取消后，patchOrder和updateOrders都不能修改条目、目的地和价格，其他字段仍然可以修改。
+func TestServer_FrozenOrderFields(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	advanceOrder(store, "102", pb.OrderStatus_SHIPPED)
+	transitionOrder(store, "103", pb.OrderStatus_CANCELLED, 0)
+	client, stop := startBufConnServer(t, newServer(store))
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for _, id := range []string{"102", "103"} {
+		for _, path := range []string{"items", "destination", "price", "exactPrice"} {
+			_, err := client.PatchOrder(ctx, &pb.PatchOrderRequest{
+				Order:      &pb.Order{Id: id, Items: []string{"Amazon Echo"}, Destination: "Austin, TX", Price: 1, ExactPrice: usd("1.00")},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{path}},
+			})
+			if status.Code(err) != codes.FailedPrecondition {
+				t.Errorf("patching %s of order %s returned %v, want FailedPrecondition", path, id, err)
+			}
+		}
+	}
+	patched, err := client.PatchOrder(ctx, &pb.PatchOrderRequest{
+		Order:      &pb.Order{Id: "102", Description: "gift"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"description"}},
+	})
+	if err != nil || patched.Description != "gift" {
+		t.Fatalf("patching the description of a shipped order = %v, %v", patched, err)
+	}
+
+	before, _ := store.Get("102")
+	update := func(order *pb.Order) error {
+		stream, err := client.UpdateOrders(ctx)
+		if err != nil {
+			t.Fatalf("UpdateOrders failed: %v", err)
+		}
+		stream.Send(order)
+		_, err = stream.CloseAndRecv()
+		return err
+	}
+	changed := &pb.Order{Id: "102", Items: before.Items, Destination: "Austin, TX", Price: before.Price}
+	if err := update(changed); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("updating the destination of a shipped order returned %v, want FailedPrecondition", err)
+	}
+	same := &pb.Order{Id: "102", Items: before.Items, Destination: before.Destination, Price: before.Price, Description: "gift wrapped"}
+	if err := update(same); err != nil {
+		t.Fatalf("updating the description of a shipped order failed: %v", err)
+	}
+	if order, _ := store.Get("102"); order.Destination != before.Destination || order.Description != "gift wrapped" {
+		t.Fatalf("stored order 102 = %v", order)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	pb "ordermgt/service/ecommerce"
+)
+
+// shipmentPlannerKey 是processOrders调用元数据中选择合并策略的键，取值格式与parseShipmentPlanner相同。
+const shipmentPlannerKey = "shipment-planner"
+
+// ShipmentPlanner 决定processOrders如何把订单合并为发货组合。
+// 同一个分组中的订单合并到同一个发货组合；订单放不进分组中当前的发货组合时，
+// 当前的发货组合立即发送，订单进入一个新的发货组合。
+type ShipmentPlanner interface {
+	// Key 返回订单所属的分组。
+	Key(ord *pb.Order) string
+	// Fits 判断订单加入发货组合之后是否仍然不超过容量。空的发货组合总能放下一个订单，不会调用Fits。
+	Fits(shipment *pb.CombinedShipment, ord *pb.Order) bool
+	// Full 判断发货组合是否已满，已满的发货组合立即发送。
+	Full(shipment *pb.CombinedShipment) bool
+}
+
+// destinationPlanner 把发往同一目的地的订单合并在一起，不限制容量。
+type destinationPlanner struct{}
+
+func (destinationPlanner) Key(ord *pb.Order) string                  { return ord.Destination }
+func (destinationPlanner) Fits(*pb.CombinedShipment, *pb.Order) bool { return true }
+func (destinationPlanner) Full(*pb.CombinedShipment) bool            { return false }
+func (destinationPlanner) String() string                            { return "destination" }
+
+// maxOrdersPlanner 按目的地合并，每个发货组合最多max个订单。
+type maxOrdersPlanner struct {
+	max int
+}
+
+func (p maxOrdersPlanner) Key(ord *pb.Order) string { return ord.Destination }
+
+func (p maxOrdersPlanner) Fits(shipment *pb.CombinedShipment, ord *pb.Order) bool {
+	return len(shipment.OrdersList) < p.max
+}
+
+func (p maxOrdersPlanner) Full(shipment *pb.CombinedShipment) bool {
+	return len(shipment.OrdersList) >= p.max
+}
+
+func (p maxOrdersPlanner) String() string { return fmt.Sprintf("max-orders:%d", p.max) }
+
+// maxValuePlanner 按目的地和货币合并，每个发货组合中订单的总价不超过max。
+// 单个订单的价格超过max时单独发货，货币与max不同的订单无法比较，也单独发货。
+type maxValuePlanner struct {
+	max *pb.Money
+}
+
+func (p maxValuePlanner) Key(ord *pb.Order) string {
+	return ord.Destination + "|" + ord.GetExactPrice().GetCurrencyCode()
+}
+
+// total 返回发货组合加上extra之后的总价，溢出时返回错误。
+func (p maxValuePlanner) total(shipment *pb.CombinedShipment, extra ...*pb.Order) (*pb.Money, error) {
+	var prices []*pb.Money
+	for _, ord := range append(shipment.OrdersList, extra...) {
+		if ord.ExactPrice != nil {
+			prices = append(prices, ord.ExactPrice)
+		}
+	}
+	return sumMoney(p.max.CurrencyCode, prices...)
+}
+
+func (p maxValuePlanner) Fits(shipment *pb.CombinedShipment, ord *pb.Order) bool {
+	total, err := p.total(shipment, ord)
+	if err != nil {
+		return false
+	}
+	c, err := compareMoney(total, p.max)
+	return err == nil && c <= 0
+}
+
+func (p maxValuePlanner) Full(shipment *pb.CombinedShipment) bool {
+	total, err := p.total(shipment)
+	if err != nil {
+		return true
+	}
+	c, err := compareMoney(total, p.max)
+	return err != nil || c >= 0
+}
+
+func (p maxValuePlanner) String() string {
+	return "max-value:" + formatAmount(p.max) + ":" + p.max.CurrencyCode
+}
+
+// maxWeightPlanner 按目的地合并，每个发货组合中订单的总重量不超过maxGrams。
+// 单个订单的重量超过maxGrams时单独发货。
+type maxWeightPlanner struct {
+	maxGrams int64
+}
+
+func (p maxWeightPlanner) Key(ord *pb.Order) string { return ord.Destination }
+
+func shipmentWeight(shipment *pb.CombinedShipment) int64 {
+	var grams int64
+	for _, ord := range shipment.OrdersList {
+		grams += ord.WeightGrams
+	}
+	return grams
+}
+
+func (p maxWeightPlanner) Fits(shipment *pb.CombinedShipment, ord *pb.Order) bool {
+	return shipmentWeight(shipment)+ord.WeightGrams <= p.maxGrams
+}
+
+func (p maxWeightPlanner) Full(shipment *pb.CombinedShipment) bool {
+	return shipmentWeight(shipment) >= p.maxGrams
+}
+
+func (p maxWeightPlanner) String() string { return fmt.Sprintf("max-weight:%d", p.maxGrams) }
+
+// priorityPlanner 按目的地和优先级合并，加急订单不会和普通订单一起等待。
+type priorityPlanner struct{}
+
+func (priorityPlanner) Key(ord *pb.Order) string {
+	return ord.Destination + "|" + ord.Priority.String()
+}
+
+func (priorityPlanner) Fits(*pb.CombinedShipment, *pb.Order) bool { return true }
+func (priorityPlanner) Full(*pb.CombinedShipment) bool            { return false }
+func (priorityPlanner) String() string                            { return "priority" }
+
+// parseShipmentPlanner 解析合并策略，支持的格式为：
+//
+//	destination            按目的地合并
+//	max-orders:N           每个发货组合最多N个订单
+//	max-value:AMOUNT[:CUR] 每个发货组合的总价不超过AMOUNT，货币默认为USD
+//	max-weight:GRAMS       每个发货组合的总重量不超过GRAMS克
+//	priority               按目的地和优先级合并
+func parseShipmentPlanner(spec string) (ShipmentPlanner, error) {
+	name, arg := spec, ""
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		name, arg = spec[:i], spec[i+1:]
+	}
+	switch name {
+	case "destination":
+		if arg == "" {
+			return destinationPlanner{}, nil
+		}
+	case "priority":
+		if arg == "" {
+			return priorityPlanner{}, nil
+		}
+	case "max-orders":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n <= 0 {
+			break
+		}
+		return maxOrdersPlanner{max: n}, nil
+	case "max-value":
+		amount, currency := arg, defaultCurrency
+		if i := strings.IndexByte(arg, ':'); i >= 0 {
+			amount, currency = arg[:i], arg[i+1:]
+		}
+		max, err := parseMoney(currency, amount)
+		if err != nil || validateMoney(max) != nil || max.Units < 0 || max.Nanos < 0 || (max.Units == 0 && max.Nanos == 0) {
+			break
+		}
+		return maxValuePlanner{max: max}, nil
+	case "max-weight":
+		n, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || n <= 0 {
+			break
+		}
+		return maxWeightPlanner{maxGrams: n}, nil
+	}
+	return nil, fmt.Errorf("invalid shipment planner %q", spec)
+}
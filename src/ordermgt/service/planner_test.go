@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc/metadata"
+	pb "ordermgt/service/ecommerce"
+)
+
+// plannerTestOrders 是每种合并策略都使用的同一个订单流。
+func plannerTestOrders() []*pb.Order {
+	order := func(id, destination, price string, grams int64, priority pb.OrderPriority) *pb.Order {
+		return &pb.Order{Id: id, Destination: destination, ExactPrice: usd(price), WeightGrams: grams, Priority: priority}
+	}
+	return []*pb.Order{
+		order("1", "MV", "100", 500, pb.OrderPriority_STANDARD),
+		order("2", "SJ", "50", 200, pb.OrderPriority_EXPRESS),
+		order("3", "MV", "300", 1500, pb.OrderPriority_EXPRESS),
+		order("4", "MV", "700", 300, pb.OrderPriority_STANDARD),
+		order("5", "SJ", "20", 100, pb.OrderPriority_STANDARD),
+		order("6", "MV", "50", 200, pb.OrderPriority_STANDARD),
+	}
+}
+
+func TestShipmentPlanners(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"destination", "[MV[1 3 4 6] SJ[2 5]]"},
+		// 每个发货组合达到2个订单时立即发送。
+		{"max-orders:2", "[MV[1 3] SJ[2 5] MV[4 6]]"},
+		// 订单4放不进总价400的发货组合，先发送MV[1 3]；订单4本身超过上限，单独发送。
+		{"max-value:500", "[MV[1 3] MV[4] SJ[2 5] MV[6]]"},
+		// 订单3本身超过重量上限，单独发送。
+		{"max-weight:1000", "[MV[1] MV[3] SJ[2 5] MV[4 6]]"},
+		// 加急订单和普通订单分别合并。
+		{"priority", "[MV[1 4 6] SJ[2] MV[3] SJ[5]]"},
+	}
+	for _, tt := range tests {
+		planner, err := parseShipmentPlanner(tt.spec)
+		if err != nil {
+			t.Fatalf("parseShipmentPlanner(%q) failed: %v", tt.spec, err)
+		}
+		b := newShipmentBatcher(batchPolicy{Planner: planner}, newShipmentID)
+		var shipments []*pb.CombinedShipment
+		for _, ord := range plannerTestOrders() {
+			_, ready := b.add(ord)
+			shipments = append(shipments, ready...)
+		}
+		shipments = append(shipments, b.flush()...)
+		if got := shipmentList(shipments); got != tt.want {
+			t.Errorf("%s: shipments = %s, want %s", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseShipmentPlanner(t *testing.T) {
+	valid := map[string]ShipmentPlanner{
+		"destination":          destinationPlanner{},
+		"priority":             priorityPlanner{},
+		"max-orders:3":         maxOrdersPlanner{max: 3},
+		"max-weight:2500":      maxWeightPlanner{maxGrams: 2500},
+		"max-value:99.5:EUR":   nil,
+		"max-value:1000000000": nil,
+	}
+	for spec, want := range valid {
+		planner, err := parseShipmentPlanner(spec)
+		if err != nil {
+			t.Errorf("parseShipmentPlanner(%q) failed: %v", spec, err)
+			continue
+		}
+		if want != nil && planner != want {
+			t.Errorf("parseShipmentPlanner(%q) = %v, want %v", spec, planner, want)
+		}
+	}
+	if p, _ := parseShipmentPlanner("max-value:99.5:EUR"); p.(interface{ String() string }).String() != "max-value:99.50:EUR" {
+		t.Errorf("max-value planner = %v", p)
+	}
+	for _, spec := range []string{"", "nearest", "destination:1", "max-orders", "max-orders:-1", "max-value:0", "max-value:-5", "max-value:10:usd", "max-weight:abc"} {
+		if _, err := parseShipmentPlanner(spec); err == nil {
+			t.Errorf("parseShipmentPlanner(%q) succeeded", spec)
+		}
+	}
+}
+
+// 客户端通过元数据为单个流选择合并策略。
+func TestServer_ProcessOrdersPlannerOverride(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	srv := newServer(store)
+	srv.batchPolicy = batchPolicy{Planner: destinationPlanner{}}
+	client, stop := startBufConnServer(t, srv)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 102和104都发往Mountain View，总价2200超过上限2000，因此分成两个发货组合。
+	stream, err := client.ProcessOrders(metadata.AppendToOutgoingContext(ctx, shipmentPlannerKey, "max-value:2000"))
+	if err != nil {
+		t.Fatalf("ProcessOrders failed: %v", err)
+	}
+	stream.Send(&wrapper.StringValue{Value: "102"})
+	stream.Send(&wrapper.StringValue{Value: "104"})
+	stream.CloseSend()
+	shipments := []*pb.CombinedShipment{recvShipment(t, stream), recvShipment(t, stream)}
+	if got, want := shipmentList(shipments), "[Mountain View, CA[102] Mountain View, CA[104]]"; got != want {
+		t.Fatalf("shipments = %s, want %s", got, want)
+	}
+}
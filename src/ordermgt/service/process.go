@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "ordermgt/service/ecommerce"
+)
+
+// invalidFieldError 生成InvalidArgument错误，并用BadRequest详情说明哪个字段无效。
+func invalidFieldError(field, description string) error {
+	return fieldViolationsError([]*epb.BadRequest_FieldViolation{{
+		Field:       field,
+		Description: description,
+	}})
+}
+
+// fieldViolationsError 生成InvalidArgument错误，BadRequest详情中列出所有无效的字段。
+func fieldViolationsError(violations []*epb.BadRequest_FieldViolation) error {
+	errorStatus := status.New(codes.InvalidArgument, "Invalid information received")
+	ds, err := errorStatus.WithDetails(
+		&epb.BadRequest{
+			FieldViolations: violations,
+		},
+	)
+	if err != nil {
+		return errorStatus.Err()
+	}
+	return ds.Err()
+}
+
+// orderNotFoundError 生成NotFound错误，并用ResourceInfo详情说明不存在的订单。
+func orderNotFoundError(id string) error {
+	errorStatus := status.New(codes.NotFound, fmt.Sprintf("Order does not exist. : %s", id))
+	ds, err := errorStatus.WithDetails(
+		&epb.ResourceInfo{
+			ResourceType: "ecommerce.Order",
+			ResourceName: "orders/" + id,
+			Description:  "The order ID is not known to the server",
+		},
+	)
+	if err != nil {
+		return errorStatus.Err()
+	}
+	return ds.Err()
+}
+
+// packOrder 检查订单是否可以加入发货组合，并把它推进到PACKED状态。
+// 订单ID为空、订单没有目的地时返回InvalidArgument，订单不存在时返回NotFound，
+// 订单已经发货或被取消时返回FailedPrecondition。
+func packOrder(store OrderStore, id string) (*pb.Order, error) {
+	if id == "" {
+		return nil, invalidFieldError("value", "Order ID must not be empty")
+	}
+	ord, exists := store.Get(id)
+	if !exists {
+		return nil, orderNotFoundError(id)
+	}
+	if ord.Destination == "" {
+		return nil, invalidFieldError("destination", fmt.Sprintf("Order %s has no destination and cannot be shipped", id))
+	}
+	return advanceOrder(store, id, pb.OrderStatus_PACKED)
+}
+
+// orderResult 生成单个订单的处理结果，err不为nil时结果中是对应的google.rpc.Status。
+func orderResult(id, shipmentId string, err error) *pb.ProcessOrdersResponse {
+	result := &pb.OrderResult{OrderId: id}
+	if err != nil {
+		result.Result = &pb.OrderResult_Error{Error: status.Convert(err).Proto()}
+	} else {
+		result.Result = &pb.OrderResult_ShipmentId{ShipmentId: shipmentId}
+	}
+	return &pb.ProcessOrdersResponse{Response: &pb.ProcessOrdersResponse_Result{Result: result}}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "ordermgt/service/ecommerce"
+)
+
+// recvShipment 跳过单个订单的处理结果，返回流中的下一个发货组合。
+func recvShipment(t *testing.T, stream pb.OrderManagement_ProcessOrdersClient) *pb.CombinedShipment {
+	t.Helper()
+	for {
+		res, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		if shipment := res.GetShipment(); shipment != nil {
+			return shipment
+		}
+	}
+}
+
+// 无法处理的订单ID返回带有错误详情的结果，流继续处理后续订单，发货组合中不会出现空订单。
+func TestServer_ProcessOrdersPerOrderResults(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	store.Put(&pb.Order{Id: "107", Items: []string{"Gift Card"}})
+	transitionOrder(store, "105", pb.OrderStatus_CANCELLED, 0)
+	srv := newServer(store)
+	srv.batchPolicy = batchPolicy{}
+	client, stop := startBufConnServer(t, srv)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.ProcessOrders(ctx)
+	if err != nil {
+		t.Fatalf("ProcessOrders failed: %v", err)
+	}
+	for _, id := range []string{"102", "999", "", "107", "105", "103"} {
+		if err := stream.Send(&wrapper.StringValue{Value: id}); err != nil {
+			t.Fatalf("Send(%q) failed: %v", id, err)
+		}
+	}
+	stream.CloseSend()
+
+	results := make(map[string]*pb.OrderResult)
+	var shipments []*pb.CombinedShipment
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		if result := res.GetResult(); result != nil {
+			results[result.OrderId] = result
+		} else {
+			shipments = append(shipments, res.GetShipment())
+		}
+	}
+
+	// 每个订单的结果中是它所在的发货组合的ID。
+	if len(shipments) != 2 || results["102"].GetShipmentId() != shipments[0].Id || results["103"].GetShipmentId() != shipments[1].Id {
+		t.Errorf("orders assigned to %q and %q, shipments %s", results["102"].GetShipmentId(), results["103"].GetShipmentId(), shipmentList(shipments))
+	}
+	tests := []struct {
+		id     string
+		code   codes.Code
+		detail string
+	}{
+		{"999", codes.NotFound, "orders/999"},
+		{"", codes.InvalidArgument, "value"},
+		{"107", codes.InvalidArgument, "destination"},
+		{"105", codes.FailedPrecondition, "orders/105"},
+	}
+	for _, tt := range tests {
+		st := status.FromProto(results[tt.id].GetError())
+		if st.Code() != tt.code {
+			t.Errorf("order %q: code %s, want %s", tt.id, st.Code(), tt.code)
+			continue
+		}
+		var detail string
+		for _, d := range st.Details() {
+			switch info := d.(type) {
+			case *epb.ResourceInfo:
+				detail = info.ResourceName
+			case *epb.BadRequest:
+				detail = info.FieldViolations[0].Field
+			case *epb.PreconditionFailure:
+				detail = info.Violations[0].Subject
+			}
+		}
+		if detail != tt.detail {
+			t.Errorf("order %q: error detail %q, want %q", tt.id, detail, tt.detail)
+		}
+	}
+	if got, want := shipmentList(shipments), "[Mountain View, CA[102] San Jose, CA[103]]"; got != want {
+		t.Fatalf("shipments = %s, want %s", got, want)
+	}
+	if order, _ := store.Get("107"); order.Status != pb.OrderStatus_PENDING {
+		t.Fatalf("rejected order 107 moved to %s", order.Status)
+	}
+}
+
+// UpdateOrders拒绝没有ID的订单，而不是把它写入存储。
+func TestServer_UpdateOrdersRejectsEmptyID(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	client, stop := startBufConnServer(t, newServer(store))
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.UpdateOrders(ctx)
+	if err != nil {
+		t.Fatalf("UpdateOrders failed: %v", err)
+	}
+	stream.Send(&pb.Order{Items: []string{"Google Pixel 3A"}})
+	if _, err := stream.CloseAndRecv(); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("order without ID returned %v, want InvalidArgument", err)
+	}
+	count := 0
+	store.Scan(func(order *pb.Order) bool {
+		count++
+		return true
+	})
+	if count != 0 {
+		t.Fatalf("store holds %d orders, want 0", count)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	pb "ordermgt/service/ecommerce"
+)
+
+const reservationLogFileName = "reservations.log"
+
+// reservationRecord 是预留日志中的一条记录，说明一个预留接下来应该进入的状态：
+// STOCK_RESERVED表示正在为订单预留库存，订单写入之后由订单的stock字段跟踪；
+// STOCK_COMMITTED和STOCK_RELEASED表示预留已经不属于任何订单，需要确认或释放；
+// STOCK_NONE表示预留已经交给订单或者已经处理完，日志不再跟踪它。
+type reservationRecord struct {
+	Tenant string        `json:"tenant,omitempty"`
+	ID     string        `json:"id"`
+	Target pb.StockState `json:"target"`
+}
+
+// reservationLog 把预留的进展追加到日志文件中，使服务重启之前既没有写入订单、也没有确认或释放的预留不会被遗忘。
+// 开始预留和预留脱离订单的记录在调用ProductInfo之前fsync；预留完成的记录不fsync，
+// 丢失时重启后重复处理一次，ProductInfo对同一个预留ID的确认和释放是幂等的。
+// 值为nil的reservationLog不记录任何内容。
+type reservationLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// openReservationLog 打开dir中的预留日志，返回日志和重启前没有完成的预留。
+// 开始之后没有完成的预留按释放处理；其中已经写入订单的预留由reconcile识别出来，交还给订单。
+// 日志中有已经完成的记录时，把日志压缩为每个未完成的预留一条记录。
+func openReservationLog(dir string) (*reservationLog, map[reservationRef]pb.StockState, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, nil, err
+	}
+	path := filepath.Join(dir, reservationLogFileName)
+	pending := make(map[reservationRef]pb.StockState)
+	records, err := replayReservations(path, pending)
+	if err != nil {
+		return nil, nil, err
+	}
+	if records > len(pending) {
+		if err := compactReservations(dir, pending); err != nil {
+			return nil, nil, err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &reservationLog{file: f}, pending, nil
+}
+
+// replayReservations 读取日志中的所有记录，把未完成的预留放入pending，返回完整记录的数量。末尾残缺的记录被截断。
+func replayReservations(path string, pending map[reservationRef]pb.StockState) (int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var offset int64
+	records := 0
+	for {
+		payload, n, err := readFrame(r)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err == nil {
+			var rec reservationRecord
+			if err = json.Unmarshal(payload, &rec); err == nil {
+				ref := reservationRef{rec.Tenant, rec.ID}
+				switch rec.Target {
+				case pb.StockState_STOCK_NONE:
+					delete(pending, ref)
+				case pb.StockState_STOCK_RESERVED:
+					pending[ref] = pb.StockState_STOCK_RELEASED
+				default:
+					pending[ref] = rec.Target
+				}
+				records++
+				offset += n
+				continue
+			}
+		}
+		log.Printf("discarding reservation log after offset %d : %v", offset, err)
+		return records, os.Truncate(path, offset)
+	}
+}
+
+// compactReservations 把未完成的预留写入临时文件，再原子地替换日志。
+func compactReservations(dir string, pending map[reservationRef]pb.StockState) error {
+	tmpPath := filepath.Join(dir, reservationLogFileName+".tmp")
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	var writeErr error
+	for ref, target := range pending {
+		var data []byte
+		if data, writeErr = json.Marshal(reservationRecord{Tenant: ref.tenant, ID: ref.id, Target: target}); writeErr != nil {
+			break
+		}
+		if _, writeErr = w.Write(encodeFrame(data)); writeErr != nil {
+			break
+		}
+	}
+	if writeErr == nil {
+		writeErr = w.Flush()
+	}
+	if writeErr == nil {
+		writeErr = f.Sync()
+	}
+	if err := f.Close(); writeErr == nil {
+		writeErr = err
+	}
+	if writeErr != nil {
+		os.Remove(tmpPath)
+		return writeErr
+	}
+	if err := os.Rename(tmpPath, filepath.Join(dir, reservationLogFileName)); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// append 追加一条记录，sync为true时等到记录写入磁盘之后才返回。
+func (l *reservationLog) append(ref reservationRef, target pb.StockState, sync bool) error {
+	if l == nil {
+		return nil
+	}
+	data, err := json.Marshal(reservationRecord{Tenant: ref.tenant, ID: ref.id, Target: target})
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(encodeFrame(data)); err != nil {
+		return err
+	}
+	if sync {
+		return l.file.Sync()
+	}
+	return nil
+}
+
+func (l *reservationLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	pb "ordermgt/service/ecommerce"
+)
+
+const (
+	maxSearchPageSize = 1000
+	// 分页时，下一页的令牌通过这个trailer元数据键返回给客户端。
+	nextPageTokenKey = "next-page-token"
+	// defaultSearchSnapshotTTL 是服务器端保留搜索结果快照的默认时间。
+	defaultSearchSnapshotTTL = 5 * time.Minute
+	// maxSearchSnapshotsPerTenant 是每个租户同时保留的快照数，超过时丢弃这个租户最早的快照，
+	// 一个租户的大量搜索不会挤掉其他租户的快照。
+	maxSearchSnapshotsPerTenant = 16
+	// maxSearchSnapshotBytes 是单个快照中订单序列化后的总字节数上限，结果更大的搜索不保留快照，
+	// 翻页时按排序键在当前的订单上继续。
+	maxSearchSnapshotBytes = 1 << 20
+)
+
+// orderMatcher 由能够借助索引按文本查找订单的存储实现，例如indexedOrderStore。
+type orderMatcher interface {
+	Match(query string, fn func(order *pb.Order) bool)
+}
+
+// searchPageToken 是分页令牌和搜索游标的内容，记录上一页（或者客户端收到的）最后一个订单的排序键。
+// 令牌经过base64编码，对客户端是不透明的。
+type searchPageToken struct {
+	// Query 是过滤条件和排序方式的摘要，防止令牌被用于不同的查询。
+	Query  string `json:"q"`
+	LastID string `json:"id"`
+	// LastCurrency 和LastAmount 是订单的精确价格，金额用formatAmount格式化，避免float的舍入误差。
+	LastCurrency string `json:"pc,omitempty"`
+	LastAmount   string `json:"pa,omitempty"`
+	LastCreated  int64  `json:"c,omitempty"`
+	// Snapshot 和Index 是这个订单在服务器端结果快照中的位置，快照过期后按上面的排序键继续。
+	Snapshot string `json:"s,omitempty"`
+	Index    int    `json:"i,omitempty"`
+	// Left 只用于游标，是订单所在的页中它之后还有多少个订单，为nil时这一页包含所有剩余的结果。
+	Left *int `json:"l,omitempty"`
+}
+
+// searchOrders 在存储的快照上执行查询，返回一页结果和下一页的令牌（没有更多结果时为空）。
+// 分页基于上一页最后一个订单的排序键而不是偏移量，因此翻页期间新增或删除订单不会导致结果重复或遗漏。
+func searchOrders(store OrderStore, req *pb.SearchOrdersRequest) ([]*pb.Order, string, error) {
+	return (*searchSnapshots)(nil).search(store, "", req)
+}
+
+// search 与searchOrders相同，但是把排序后的全部结果作为快照保留一段时间，之后的页和断线后的续传都在同一个快照上继续，
+// 直到快照过期。c为nil时不保留快照。返回的每个订单都带有searchCursor。
+func (c *searchSnapshots) search(store OrderStore, tenant string, req *pb.SearchOrdersRequest) ([]*pb.Order, string, error) {
+	if req.PageSize < 0 {
+		return nil, "", status.Errorf(codes.InvalidArgument, "page size must not be negative : %d", req.PageSize)
+	}
+	prices, err := searchPriceRange(req)
+	if err != nil {
+		return nil, "", err
+	}
+	if req.PageToken != "" && req.ResumeCursor != "" {
+		return nil, "", status.Errorf(codes.InvalidArgument, "page token and resume cursor must not be set together")
+	}
+	digest := searchQueryDigest(req)
+	// limit 是这次返回的最大订单数，-1表示不限制。
+	limit := int(req.PageSize)
+	if limit > maxSearchPageSize {
+		limit = maxSearchPageSize
+	}
+	if limit == 0 {
+		limit = -1
+	}
+	var token *searchPageToken
+	switch {
+	case req.PageToken != "":
+		t, err := decodePageToken(req.PageToken)
+		if err != nil || t.Query != digest || t.Left != nil {
+			return nil, "", status.Errorf(codes.InvalidArgument, "invalid page token")
+		}
+		token = t
+	case req.ResumeCursor != "":
+		t, err := decodePageToken(req.ResumeCursor)
+		if err != nil || t.Query != digest || (t.Left != nil && *t.Left < 0) {
+			return nil, "", status.Errorf(codes.InvalidArgument, "invalid resume cursor")
+		}
+		// 续传到原始请求的这一页结束为止。
+		token, limit = t, -1
+		if t.Left != nil {
+			limit = *t.Left
+		}
+	}
+
+	// 令牌指向的快照仍然有效时从快照中的下一个订单继续，否则在存储的当前订单上按排序键继续，并保留新的快照。
+	var results []*pb.Order
+	snapshot, start := "", 0
+	if token != nil {
+		if snap := c.get(token.Snapshot, tenant, digest); snap != nil && token.Index >= 0 && token.Index < len(snap.orders) && snap.orders[token.Index].Id == token.LastID {
+			results, snapshot, start = snap.orders, snap.id, token.Index+1
+		}
+	}
+	if snapshot == "" {
+		var after *pb.Order
+		if token != nil {
+			// 令牌中的价格在解码时已经校验过。
+			price, _ := token.lastPrice()
+			after = &pb.Order{Id: token.LastID, ExactPrice: price}
+			if token.LastCreated != 0 {
+				after.CreateTime = nanosToTimestamp(token.LastCreated)
+			}
+		}
+		results = matchOrders(store, req, prices, after)
+		snapshot = c.put(tenant, digest, results)
+	}
+
+	end := len(results)
+	if limit >= 0 && start+limit < end {
+		end = start + limit
+	}
+	// tokenAt 返回results[i]之后的位置，i为start-1时是请求中令牌的位置。
+	tokenAt := func(i int) *searchPageToken {
+		t := &searchPageToken{Query: digest, Snapshot: snapshot, Index: i}
+		if i >= start {
+			price := orderPrice(results[i])
+			t.LastID, t.LastCreated = results[i].Id, timestampToNanos(results[i].CreateTime)
+			t.LastCurrency, t.LastAmount = price.CurrencyCode, formatAmount(price)
+		} else {
+			t.LastID, t.LastCreated = token.LastID, token.LastCreated
+			t.LastCurrency, t.LastAmount = token.LastCurrency, token.LastAmount
+		}
+		return t
+	}
+	page := make([]*pb.Order, 0, end-start)
+	for i := start; i < end; i++ {
+		// 快照中的订单在多次请求之间共享，游标设置在副本上。
+		order := cloneOrder(results[i])
+		cursor := tokenAt(i)
+		if limit >= 0 {
+			left := end - i - 1
+			cursor.Left = &left
+		}
+		order.SearchCursor = encodePageToken(cursor)
+		page = append(page, order)
+	}
+	next := ""
+	if end < len(results) {
+		next = encodePageToken(tokenAt(end - 1))
+	}
+	return page, next, nil
+}
+
+// searchSnapshot 是一次搜索排序后的全部结果。
+type searchSnapshot struct {
+	id      string
+	tenant  string
+	query   string
+	orders  []*pb.Order
+	expires time.Time
+}
+
+// searchSnapshots 按ID保存搜索结果的快照，使翻页和断线后的续传看到与第一次请求相同的结果。
+// 快照在创建ttl之后过期，之后的请求按排序键在当前的订单上继续。
+// 每个租户最多保留maxSearchSnapshotsPerTenant个快照，每个快照不超过maxSearchSnapshotBytes。
+type searchSnapshots struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	snapshots map[string]*searchSnapshot
+	// queues 按租户、按创建时间保存快照，用于清理过期的快照和淘汰租户最早的快照。
+	queues map[string][]*searchSnapshot
+}
+
+func newSearchSnapshots(ttl time.Duration) *searchSnapshots {
+	if ttl <= 0 {
+		ttl = defaultSearchSnapshotTTL
+	}
+	return &searchSnapshots{ttl: ttl, now: time.Now, snapshots: make(map[string]*searchSnapshot), queues: make(map[string][]*searchSnapshot)}
+}
+
+// expireLocked 丢弃所有租户过期的快照，以及每个租户超过maxSearchSnapshotsPerTenant的最早的快照。
+func (c *searchSnapshots) expireLocked(now time.Time) {
+	for tenant, queue := range c.queues {
+		for len(queue) > 0 && (!queue[0].expires.After(now) || len(queue) > maxSearchSnapshotsPerTenant) {
+			delete(c.snapshots, queue[0].id)
+			queue = queue[1:]
+		}
+		if len(queue) == 0 {
+			delete(c.queues, tenant)
+		} else {
+			c.queues[tenant] = queue
+		}
+	}
+}
+
+// get 返回属于租户和查询的快照，快照不存在或已经过期时返回nil。
+func (c *searchSnapshots) get(id, tenant, query string) *searchSnapshot {
+	if c == nil || id == "" {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expireLocked(c.now())
+	snap, ok := c.snapshots[id]
+	if !ok || snap.tenant != tenant || snap.query != query {
+		return nil
+	}
+	return snap
+}
+
+// put 保存搜索结果并返回快照的ID。c为nil、没有结果或者结果超过maxSearchSnapshotBytes时不保存，返回空字符串。
+func (c *searchSnapshots) put(tenant, query string, orders []*pb.Order) string {
+	if c == nil || len(orders) == 0 {
+		return ""
+	}
+	size := 0
+	for _, order := range orders {
+		if size += proto.Size(order); size > maxSearchSnapshotBytes {
+			return ""
+		}
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	snap := &searchSnapshot{id: id.String(), tenant: tenant, query: query, orders: orders, expires: now.Add(c.ttl)}
+	c.snapshots[snap.id] = snap
+	c.queues[tenant] = append(c.queues[tenant], snap)
+	c.expireLocked(now)
+	return snap.id
+}
+
+// matchOrders 返回存储中所有满足查询条件、并且按排序方式排在after之后的订单，已经排好序。
+func matchOrders(store OrderStore, req *pb.SearchOrdersRequest, prices priceRange, after *pb.Order) []*pb.Order {
+	less := searchLess(req.SortOrder)
+	scan := store.Scan
+	if matcher, ok := store.(orderMatcher); ok && req.Item != "" {
+		// 先用索引缩小候选范围，再用下面的完整条件过滤。
+		scan = func(fn func(order *pb.Order) bool) { matcher.Match(req.Item, fn) }
+	}
+	var matches []*pb.Order
+	scan(func(order *pb.Order) bool {
+		if matchesSearch(order, req, prices) && (after == nil || less(after, order)) {
+			matches = append(matches, order)
+		}
+		return true
+	})
+	sort.Slice(matches, func(i, j int) bool { return less(matches[i], matches[j]) })
+	return matches
+}
+
+// priceRange 是搜索的精确价格范围，min或max为nil时这一端不限制。
+type priceRange struct {
+	min, max *pb.Money
+}
+
+// searchPriceRange 把请求中的价格条件换算为精确的价格范围：float价格按USD精确换算，
+// 与对应的精确价格同时设置、金额无效、两端货币不同或者下限大于上限时返回InvalidArgument。
+func searchPriceRange(req *pb.SearchOrdersRequest) (priceRange, error) {
+	var r priceRange
+	bound := func(field, exactField string, legacy *wrapper.FloatValue, exact *pb.Money) (*pb.Money, error) {
+		if legacy != nil && exact != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%s and %s must not be set together", field, exactField)
+		}
+		if legacy != nil {
+			m, err := moneyFromFloat(defaultCurrency, legacy.Value)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid %s : %v", field, err)
+			}
+			return m, nil
+		}
+		if exact != nil {
+			if err := validateMoney(exact); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid %s : %v", exactField, err)
+			}
+		}
+		return exact, nil
+	}
+	var err error
+	if r.min, err = bound("minPrice", "minExactPrice", req.MinPrice, req.MinExactPrice); err != nil {
+		return r, err
+	}
+	if r.max, err = bound("maxPrice", "maxExactPrice", req.MaxPrice, req.MaxExactPrice); err != nil {
+		return r, err
+	}
+	if r.min != nil && r.max != nil {
+		c, err := compareMoney(r.min, r.max)
+		if err != nil {
+			return r, status.Errorf(codes.InvalidArgument, "min price %s and max price %s have different currencies", formatMoney(r.min), formatMoney(r.max))
+		}
+		if c > 0 {
+			return r, status.Errorf(codes.InvalidArgument, "min price %s is greater than max price %s", formatMoney(r.min), formatMoney(r.max))
+		}
+	}
+	return r, nil
+}
+
+// orderPrice 返回订单的精确价格。在启用精确价格之前写入的订单没有exactPrice，
+// 按normalizeOrderPrice的规则从float价格换算，无法换算时视为默认货币的0。
+func orderPrice(order *pb.Order) *pb.Money {
+	if order.ExactPrice != nil {
+		return order.ExactPrice
+	}
+	price, err := moneyFromFloat(defaultCurrency, order.Price)
+	if err != nil {
+		return &pb.Money{CurrencyCode: defaultCurrency}
+	}
+	return price
+}
+
+// comparePrices 按货币代码、再按金额比较两个价格，不同货币的价格也有确定的顺序。
+func comparePrices(a, b *pb.Money) int {
+	if a.CurrencyCode != b.CurrencyCode {
+		return strings.Compare(a.CurrencyCode, b.CurrencyCode)
+	}
+	c, _ := compareMoney(a, b)
+	return c
+}
+
+// contains 判断订单的精确价格是否在范围内，货币与范围不同的订单不在范围内。
+func (r priceRange) contains(order *pb.Order) bool {
+	if r.min == nil && r.max == nil {
+		return true
+	}
+	price := orderPrice(order)
+	if r.min != nil {
+		if c, err := compareMoney(price, r.min); err != nil || c < 0 {
+			return false
+		}
+	}
+	if r.max != nil {
+		if c, err := compareMoney(price, r.max); err != nil || c > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesSearch(order *pb.Order, req *pb.SearchOrdersRequest, prices priceRange) bool {
+	if req.Item != "" && !matchesText(order, req.Item) {
+		return false
+	}
+	if req.Destination != "" && !strings.EqualFold(order.Destination, req.Destination) {
+		return false
+	}
+	if !prices.contains(order) {
+		return false
+	}
+	// 没有指定状态时不返回已取消的订单。
+	if len(req.Statuses) == 0 && order.Status == pb.OrderStatus_CANCELLED {
+		return false
+	}
+	if len(req.Statuses) > 0 {
+		found := false
+		for _, st := range req.Statuses {
+			if order.Status == st {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if req.CreatedAfter != nil && timestampToNanos(order.CreateTime) <= timestampToNanos(req.CreatedAfter) {
+		return false
+	}
+	return true
+}
+
+// searchLess 返回给定排序方式下的比较函数，排序键相同时按订单ID排序，保证顺序是全序的。
+// 按价格排序时比较精确价格（见comparePrices），不同货币的订单按货币代码分组。
+func searchLess(sortOrder pb.SortOrder) func(a, b *pb.Order) bool {
+	return func(a, b *pb.Order) bool {
+		switch sortOrder {
+		case pb.SortOrder_PRICE_ASC, pb.SortOrder_PRICE_DESC:
+			if c := comparePrices(orderPrice(a), orderPrice(b)); c != 0 {
+				return (c < 0) == (sortOrder == pb.SortOrder_PRICE_ASC)
+			}
+		case pb.SortOrder_CREATE_TIME_ASC, pb.SortOrder_CREATE_TIME_DESC:
+			ac, bc := timestampToNanos(a.CreateTime), timestampToNanos(b.CreateTime)
+			if ac != bc {
+				return (ac < bc) == (sortOrder == pb.SortOrder_CREATE_TIME_ASC)
+			}
+		}
+		return a.Id < b.Id
+	}
+}
+
+// searchQueryDigest 对去掉分页和续传字段后的查询计算摘要。
+func searchQueryDigest(req *pb.SearchOrdersRequest) string {
+	query := proto.Clone(req).(*pb.SearchOrdersRequest)
+	query.PageSize = 0
+	query.PageToken = ""
+	query.ResumeCursor = ""
+	data, _ := proto.MarshalOptions{Deterministic: true}.Marshal(query)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+func encodePageToken(token *searchPageToken) string {
+	data, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodePageToken(s string) (*searchPageToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	token := &searchPageToken{}
+	if err := json.Unmarshal(data, token); err != nil {
+		return nil, err
+	}
+	if _, err := token.lastPrice(); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// lastPrice 返回令牌中最后一个订单的精确价格，令牌中没有价格时返回nil。
+func (t *searchPageToken) lastPrice() (*pb.Money, error) {
+	if t.LastAmount == "" {
+		return nil, nil
+	}
+	price, err := parseMoney(t.LastCurrency, t.LastAmount)
+	if err != nil {
+		return nil, err
+	}
+	return price, validateMoney(price)
+}
+
+func timestampToNanos(ts *timestamppb.Timestamp) int64 {
+	if ts == nil {
+		return 0
+	}
+	return ts.AsTime().UnixNano()
+}
+
+func nanosToTimestamp(nanos int64) *timestamppb.Timestamp {
+	return timestamppb.New(time.Unix(0, nanos))
+}
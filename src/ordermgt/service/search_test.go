@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	pb "ordermgt/service/ecommerce"
+)
+
+func newSearchTestStore() OrderStore {
+	store := newMemoryOrderStore(defaultShardCount)
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		destination := "Mountain View, CA"
+		if i%2 == 1 {
+			destination = "San Jose, CA"
+		}
+		store.Put(&pb.Order{
+			Id:          fmt.Sprintf("%03d", i),
+			Items:       []string{"Google Pixel 3A", fmt.Sprintf("Item %d", i)},
+			Destination: destination,
+			Price:       float32(i % 5 * 100),
+			CreateTime:  timestamppb.New(base.Add(time.Duration(20-i) * time.Hour)),
+		})
+	}
+	return store
+}
+
+// collectPages 按页读取全部结果，返回订单ID序列。
+func collectPages(t *testing.T, store OrderStore, req *pb.SearchOrdersRequest) []string {
+	t.Helper()
+	var ids []string
+	for page := 0; ; page++ {
+		orders, next, err := searchOrders(store, req)
+		if err != nil {
+			t.Fatalf("searchOrders failed: %v", err)
+		}
+		if req.PageSize > 0 && len(orders) > int(req.PageSize) {
+			t.Fatalf("page %d has %d orders, page size is %d", page, len(orders), req.PageSize)
+		}
+		for _, order := range orders {
+			ids = append(ids, order.Id)
+		}
+		if next == "" {
+			return ids
+		}
+		req.PageToken = next
+	}
+}
+
+func TestSearchOrders_PaginationIsStable(t *testing.T) {
+	store := newSearchTestStore()
+	for _, sortOrder := range []pb.SortOrder{pb.SortOrder_ID_ASC, pb.SortOrder_PRICE_ASC, pb.SortOrder_PRICE_DESC, pb.SortOrder_CREATE_TIME_ASC, pb.SortOrder_CREATE_TIME_DESC} {
+		all := collectPages(t, store, &pb.SearchOrdersRequest{Item: "Google", SortOrder: sortOrder})
+		paged := collectPages(t, store, &pb.SearchOrdersRequest{Item: "Google", SortOrder: sortOrder, PageSize: 3})
+		if fmt.Sprint(all) != fmt.Sprint(paged) {
+			t.Fatalf("%s: paged results %v differ from unpaged %v", sortOrder, paged, all)
+		}
+		if len(all) != 20 {
+			t.Fatalf("%s: got %d results, want 20", sortOrder, len(all))
+		}
+	}
+
+	ids := collectPages(t, store, &pb.SearchOrdersRequest{SortOrder: pb.SortOrder_PRICE_DESC, PageSize: 4})
+	if fmt.Sprint(ids[:4]) != "[004 009 014 019]" {
+		t.Fatalf("PRICE_DESC first page = %v, want ties broken by ID", ids[:4])
+	}
+}
+
+// 翻页期间插入的订单不会导致已返回的结果重复。
+func TestSearchOrders_PaginationWithConcurrentInsert(t *testing.T) {
+	store := newSearchTestStore()
+	req := &pb.SearchOrdersRequest{PageSize: 5}
+	first, next, _ := searchOrders(store, req)
+	store.Put(&pb.Order{Id: "000a"})
+	req.PageToken = next
+	second, _, _ := searchOrders(store, req)
+	seen := make(map[string]bool)
+	for _, order := range append(first, second...) {
+		if seen[order.Id] {
+			t.Fatalf("order %s returned twice", order.Id)
+		}
+		seen[order.Id] = true
+	}
+}
+
+func TestSearchOrders_Filters(t *testing.T) {
+	store := newSearchTestStore()
+	transitionOrder(store, "002", pb.OrderStatus_CONFIRMED, 0)
+	tests := []struct {
+		name string
+		req  *pb.SearchOrdersRequest
+		want string
+	}{
+		{"item", &pb.SearchOrdersRequest{Item: "Item 1"}, "[001 010 011 012 013 014 015 016 017 018 019]"},
+		{"destination", &pb.SearchOrdersRequest{Destination: "san jose, ca", MaxPrice: &wrapper.FloatValue{Value: 100}}, "[001 005 011 015]"},
+		{"price range", &pb.SearchOrdersRequest{MinPrice: &wrapper.FloatValue{Value: 300}, MaxPrice: &wrapper.FloatValue{Value: 300}}, "[003 008 013 018]"},
+		{"status", &pb.SearchOrdersRequest{Statuses: []pb.OrderStatus{pb.OrderStatus_CONFIRMED}}, "[002]"},
+		{"created after", &pb.SearchOrdersRequest{CreatedAfter: timestamppb.New(time.Date(2020, 1, 1, 17, 0, 0, 0, time.UTC))}, "[000 001 002]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(collectPages(t, store, tt.req)); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 价格条件按精确价格比较，float价格相同的订单也能区分开。
+func TestSearchOrders_ExactPriceFilters(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	for _, order := range []*pb.Order{
+		{Id: "101", ExactPrice: usd("400")},
+		{Id: "102", ExactPrice: usd("400.000000001")},
+		{Id: "103", ExactPrice: usd("699.99")},
+		{Id: "104", ExactPrice: &pb.Money{CurrencyCode: "EUR", Units: 400}},
+		// 在启用精确价格之前写入的订单按USD换算。
+		{Id: "105", Price: 400},
+	} {
+		if order.ExactPrice != nil {
+			order.Price = moneyToFloat(order.ExactPrice)
+		}
+		store.Put(order)
+	}
+	tests := []struct {
+		name string
+		req  *pb.SearchOrdersRequest
+		want string
+	}{
+		{"legacy max", &pb.SearchOrdersRequest{MaxPrice: &wrapper.FloatValue{Value: 400}}, "[101 105]"},
+		{"legacy min", &pb.SearchOrdersRequest{MinPrice: &wrapper.FloatValue{Value: 699.99}}, "[103]"},
+		{"exact range", &pb.SearchOrdersRequest{MinExactPrice: usd("400.000000001"), MaxExactPrice: usd("699.99")}, "[102 103]"},
+		{"exact equal", &pb.SearchOrdersRequest{MinExactPrice: usd("400"), MaxExactPrice: usd("400")}, "[101 105]"},
+		{"other currency", &pb.SearchOrdersRequest{MinExactPrice: &pb.Money{CurrencyCode: "EUR"}}, "[104]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(collectPages(t, store, tt.req)); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	for _, req := range []*pb.SearchOrdersRequest{
+		{MinPrice: &wrapper.FloatValue{Value: 1}, MinExactPrice: usd("1")},
+		{MinExactPrice: usd("2"), MaxExactPrice: usd("1.999999999")},
+		{MinExactPrice: usd("1"), MaxExactPrice: &pb.Money{CurrencyCode: "EUR", Units: 2}},
+		{MaxExactPrice: &pb.Money{CurrencyCode: "usd", Units: 2}},
+	} {
+		if _, _, err := searchOrders(store, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("searchOrders(%v) returned %v, want InvalidArgument", req, err)
+		}
+	}
+}
+
+// 按价格排序和翻页都使用精确价格：float价格相同的订单仍按精确价格排序，翻页时不会重复或遗漏；
+// 不同货币的订单按货币代码分组。
+func TestSearchOrders_SortByExactPrice(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	for _, order := range []*pb.Order{
+		{Id: "201", ExactPrice: usd("16777217")},
+		{Id: "202", ExactPrice: usd("16777216.5")},
+		{Id: "203", ExactPrice: usd("16777216")},
+		{Id: "204", ExactPrice: &pb.Money{CurrencyCode: "EUR", Units: 1}},
+	} {
+		order.Price = moneyToFloat(order.ExactPrice)
+		store.Put(order)
+	}
+	tests := []struct {
+		sortOrder pb.SortOrder
+		want      string
+	}{
+		{pb.SortOrder_PRICE_ASC, "[204 203 202 201]"},
+		{pb.SortOrder_PRICE_DESC, "[201 202 203 204]"},
+	}
+	for _, tt := range tests {
+		for _, size := range []int32{0, 1} {
+			req := &pb.SearchOrdersRequest{SortOrder: tt.sortOrder, PageSize: size}
+			if got := fmt.Sprint(collectPages(t, store, req)); got != tt.want {
+				t.Errorf("%v with page size %d: got %s, want %s", tt.sortOrder, size, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestSearchOrders_InvalidPageToken(t *testing.T) {
+	store := newSearchTestStore()
+	_, next, _ := searchOrders(store, &pb.SearchOrdersRequest{Item: "Google", PageSize: 2})
+	// 令牌只能用于生成它的查询。
+	_, _, err := searchOrders(store, &pb.SearchOrdersRequest{Item: "Pixel", PageSize: 2, PageToken: next})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("reused token returned %v, want InvalidArgument", err)
+	}
+	_, _, err = searchOrders(store, &pb.SearchOrdersRequest{PageToken: "not-a-token"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("garbage token returned %v, want InvalidArgument", err)
+	}
+}
+
+func TestServer_SearchOrdersPageToken(t *testing.T) {
+	client, stop := startBufConnServer(t, newServer(newSearchTestStore()))
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	req := &pb.SearchOrdersRequest{Item: "Google", PageSize: 8}
+	var ids []string
+	for {
+		stream, err := client.SearchOrders(ctx, req)
+		if err != nil {
+			t.Fatalf("SearchOrders failed: %v", err)
+		}
+		for {
+			order, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Recv failed: %v", err)
+			}
+			ids = append(ids, order.Id)
+		}
+		tokens := stream.Trailer().Get(nextPageTokenKey)
+		if len(tokens) == 0 {
+			break
+		}
+		req.PageToken = tokens[0]
+	}
+	if len(ids) != 20 {
+		t.Fatalf("got %d orders over all pages, want 20", len(ids))
+	}
+}
+
+// 旧客户端以google.protobuf.StringValue作为searchOrders的请求，必须仍然得到按条目搜索的结果。
+func TestServer_SearchOrdersLegacyRequest(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	conn, stop := dialBufConnServer(t, newServer(store))
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	desc := &grpc.StreamDesc{StreamName: "searchOrders", ServerStreams: true}
+	stream, err := conn.NewStream(ctx, desc, "/ecommerce.OrderManagement/searchOrders")
+	if err != nil {
+		t.Fatalf("NewStream failed: %v", err)
+	}
+	if err := stream.SendMsg(&wrapper.StringValue{Value: "Google"}); err != nil {
+		t.Fatalf("SendMsg failed: %v", err)
+	}
+	stream.CloseSend()
+	var ids []string
+	for {
+		order := &pb.Order{}
+		if err := stream.RecvMsg(order); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("RecvMsg failed: %v", err)
+		}
+		ids = append(ids, order.Id)
+	}
+	if fmt.Sprint(ids) != "[102 104]" {
+		t.Fatalf("legacy search returned %v, want [102 104]", ids)
+	}
+	var md metadata.MD = stream.Trailer()
+	if len(md.Get(nextPageTokenKey)) != 0 {
+		t.Fatalf("unpaged legacy search returned a page token")
+	}
+}
+
+// 用游标续传时从快照中的下一个订单继续，到原始请求的这一页结束，之后的修改不影响结果。
+func TestSearchOrders_ResumeFromCursor(t *testing.T) {
+	store := newSearchTestStore()
+	snapshots := newSearchSnapshots(time.Minute)
+	req := &pb.SearchOrdersRequest{Item: "Google", SortOrder: pb.SortOrder_PRICE_DESC, PageSize: 8}
+	page, next, err := snapshots.search(store, "", req)
+	if err != nil || len(page) != 8 || next == "" {
+		t.Fatalf("search = %d orders, %q, %v", len(page), next, err)
+	}
+	want := fmt.Sprint(orderIDList(page[3:]))
+	wantPages := fmt.Sprint(collectPages(t, store, &pb.SearchOrdersRequest{Item: "Google", SortOrder: pb.SortOrder_PRICE_DESC})[:16])
+
+	store.Delete(page[5].Id)
+	store.Put(&pb.Order{Id: "020", Items: []string{"Google Home"}, Price: 400})
+	resumed, resumedNext, err := snapshots.search(store, "", &pb.SearchOrdersRequest{Item: "Google", SortOrder: pb.SortOrder_PRICE_DESC, ResumeCursor: page[2].SearchCursor})
+	if err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if got := fmt.Sprint(orderIDList(resumed)); got != want || resumedNext != next {
+		t.Fatalf("resumed %s with next %q, want %s with %q", got, resumedNext, want, next)
+	}
+	// 游标指向这一页的最后一个订单时，续传返回空的流和同样的下一页令牌。
+	resumed, resumedNext, _ = snapshots.search(store, "", &pb.SearchOrdersRequest{Item: "Google", SortOrder: pb.SortOrder_PRICE_DESC, ResumeCursor: page[7].SearchCursor})
+	if len(resumed) != 0 || resumedNext != next {
+		t.Fatalf("resume after the last order returned %v, %q", orderIDList(resumed), resumedNext)
+	}
+
+	// 下一页也在同一个快照上，包含之后删除的订单，不包含之后新增的订单。
+	req.PageToken = next
+	page2, _, _ := snapshots.search(store, "", req)
+	if got := fmt.Sprint(append(orderIDList(page), orderIDList(page2)...)); got != wantPages {
+		t.Fatalf("pages on the snapshot = %s, want %s", got, wantPages)
+	}
+}
+
+// 快照过期或者属于其他租户时，游标按排序键在当前的订单上继续。
+func TestSearchOrders_ResumeWithoutSnapshot(t *testing.T) {
+	store := newSearchTestStore()
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	snapshots := newSearchSnapshots(time.Minute)
+	snapshots.now = func() time.Time { return now }
+	req := &pb.SearchOrdersRequest{PageSize: 4}
+	page, _, _ := snapshots.search(store, "", req)
+	store.Delete("002")
+	store.Put(&pb.Order{Id: "001a"})
+
+	resumeReq := &pb.SearchOrdersRequest{PageSize: 4, ResumeCursor: page[0].SearchCursor}
+	resumed, _, _ := snapshots.search(store, "acme", resumeReq)
+	if got := fmt.Sprint(orderIDList(resumed)); got != "[001 001a 003]" {
+		t.Fatalf("resume from another tenant = %s", got)
+	}
+	now = now.Add(time.Minute)
+	resumed, next, err := snapshots.search(store, "", resumeReq)
+	if got := fmt.Sprint(orderIDList(resumed)); err != nil || got != "[001 001a 003]" {
+		t.Fatalf("resume after expiry = %s, %v", got, err)
+	}
+	req.PageToken = next
+	if page2, _, _ := snapshots.search(store, "", req); fmt.Sprint(orderIDList(page2)) != "[004 005 006 007]" {
+		t.Fatalf("next page after expired snapshot = %v", orderIDList(page2))
+	}
+}
+
+// 每个租户的快照数单独限制，一个租户的大量搜索不会挤掉其他租户的快照；超过字节上限的结果不保留快照。
+func TestSearchSnapshots_Limits(t *testing.T) {
+	store := newSearchTestStore()
+	snapshots := newSearchSnapshots(time.Minute)
+	req := &pb.SearchOrdersRequest{PageSize: 4}
+	page, _, _ := snapshots.search(store, "", req)
+	first, _ := decodePageToken(page[0].SearchCursor)
+	for i := 0; i < maxSearchSnapshotsPerTenant+4; i++ {
+		snapshots.search(store, "acme", req)
+	}
+	if snapshots.get(first.Snapshot, "", searchQueryDigest(req)) == nil {
+		t.Errorf("snapshot of the default tenant was evicted by another tenant")
+	}
+	if n := len(snapshots.queues["acme"]); n != maxSearchSnapshotsPerTenant {
+		t.Errorf("acme keeps %d snapshots, want %d", n, maxSearchSnapshotsPerTenant)
+	}
+	if n := len(snapshots.snapshots); n != maxSearchSnapshotsPerTenant+1 {
+		t.Errorf("%d snapshots kept, want %d", n, maxSearchSnapshotsPerTenant+1)
+	}
+
+	large := &pb.Order{Id: "large", Items: []string{"Google Pixel 3A"}, Description: string(make([]byte, maxSearchSnapshotBytes))}
+	if id := snapshots.put("", "q", []*pb.Order{large}); id != "" {
+		t.Errorf("snapshot larger than %d bytes was kept", maxSearchSnapshotBytes)
+	}
+	// 没有快照时仍然可以按排序键翻页。
+	store.Put(large)
+	ids := collectPages(t, store, &pb.SearchOrdersRequest{Item: "Google", PageSize: 8})
+	if len(ids) != 21 || ids[20] != "large" {
+		t.Errorf("pages without a snapshot = %v", ids)
+	}
+}
+
+func TestSearchOrders_InvalidResumeCursor(t *testing.T) {
+	store := newSearchTestStore()
+	page, next, _ := searchOrders(store, &pb.SearchOrdersRequest{Item: "Google", PageSize: 2})
+	for _, req := range []*pb.SearchOrdersRequest{
+		{Item: "Google", PageToken: next, ResumeCursor: page[0].SearchCursor},
+		{Item: "Pixel", ResumeCursor: page[0].SearchCursor},
+		{Item: "Google", ResumeCursor: "not-a-cursor"},
+		// 游标不能当作下一页的令牌使用。
+		{Item: "Google", PageToken: page[0].SearchCursor},
+	} {
+		if _, _, err := searchOrders(store, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("searchOrders(%v) returned %v, want InvalidArgument", req, err)
+		}
+	}
+}
+
+// 客户端在流中途断开后用最后收到的订单的searchCursor重新调用，得到的订单与没有断开时相同。
+func TestServer_SearchOrdersResume(t *testing.T) {
+	store := newSearchTestStore()
+	client, stop := startBufConnServer(t, newServer(store))
+	defer stop()
+
+	req := &pb.SearchOrdersRequest{Item: "Google", SortOrder: pb.SortOrder_CREATE_TIME_ASC, PageSize: 10}
+	want := fmt.Sprint(collectPages(t, store, req)[:10])
+	req.PageToken = ""
+
+	var ids []string
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	stream, err := client.SearchOrders(ctx, req)
+	if err != nil {
+		t.Fatalf("SearchOrders failed: %v", err)
+	}
+	var cursor string
+	for i := 0; i < 4; i++ {
+		order, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		ids, cursor = append(ids, order.Id), order.SearchCursor
+	}
+	cancel()
+	store.Delete(ids[len(ids)-1])
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resumeReq := &pb.SearchOrdersRequest{Item: "Google", SortOrder: pb.SortOrder_CREATE_TIME_ASC, PageSize: 10, ResumeCursor: cursor}
+	stream, err = client.SearchOrders(ctx, resumeReq)
+	if err != nil {
+		t.Fatalf("SearchOrders failed: %v", err)
+	}
+	for {
+		order, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		ids = append(ids, order.Id)
+	}
+	if fmt.Sprint(ids) != want {
+		t.Fatalf("resumed stream returned %v, want %s", ids, want)
+	}
+	if len(stream.Trailer().Get(nextPageTokenKey)) != 1 {
+		t.Fatalf("resumed stream did not return the next page token")
+	}
+}
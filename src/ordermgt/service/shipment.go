@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	pb "ordermgt/service/ecommerce"
+)
+
+const shipmentLogFileName = "shipments.log"
+
+// newShipmentID 生成发货组合的唯一ID。
+func newShipmentID() string {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return fmt.Sprintf("cmb-%d", time.Now().UnixNano())
+	}
+	return "cmb-" + id.String()
+}
+
+// shipmentDone 判断发货组合是否已经不会再变化。
+func shipmentDone(st pb.ShipmentStatus) bool {
+	return st == pb.ShipmentStatus_SHIPMENT_SHIPPED
+}
+
+// shipmentStore 保存所有发货组合，供getShipment、listShipments和watchShipment查询。
+// 打开了日志文件时，每次变化都把发货组合的完整内容追加到日志并fsync，启动时重放日志，
+// 同一个发货组合以版本最大的记录为准，然后把日志压缩为每个发货组合一条记录。
+// 与预写日志一样，并发的写入组提交到日志中，写入和fsync期间不持有mu。
+type shipmentStore struct {
+	mu        sync.Mutex
+	shipments map[string]*pb.CombinedShipment
+	// stamped 是每个发货组合最近分配的版本，包括还在等待写入日志的版本。
+	stamped map[string]int64
+	// changed 在任意发货组合变化时关闭并替换为新的channel，用于唤醒watchShipment。
+	changed chan struct{}
+	log     *os.File
+	size    int64 // 日志中完整记录的总字节数
+	// queue 是等待写入日志的发货组合，flushing为true时有一个写入者正在写入和fsync日志，written在一批写入完成时广播。
+	queue    []*shipmentWrite
+	flushing bool
+	written  *sync.Cond
+}
+
+// shipmentWrite 是一个等待组提交的发货组合。
+type shipmentWrite struct {
+	shipment *pb.CombinedShipment
+	frame    []byte
+	done     bool
+	err      error
+}
+
+func newShipmentStore() *shipmentStore {
+	s := &shipmentStore{
+		shipments: make(map[string]*pb.CombinedShipment),
+		stamped:   make(map[string]int64),
+		changed:   make(chan struct{}),
+	}
+	s.written = sync.NewCond(&s.mu)
+	return s
+}
+
+func openShipmentStore(dir string) (*shipmentStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := newShipmentStore()
+	path := filepath.Join(dir, shipmentLogFileName)
+	records, err := s.replay(path)
+	if err != nil {
+		return nil, err
+	}
+	if records > len(s.shipments) {
+		if err := s.compact(dir); err != nil {
+			return nil, err
+		}
+	}
+	if s.log, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+		return nil, err
+	}
+	info, err := s.log.Stat()
+	if err != nil {
+		s.log.Close()
+		return nil, err
+	}
+	s.size = info.Size()
+	return s, nil
+}
+
+// replay 读取日志中的所有记录，返回完整记录的数量。末尾残缺的记录被截断。
+func (s *shipmentStore) replay(path string) (int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var offset int64
+	records := 0
+	for {
+		payload, n, err := readFrame(r)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err == nil {
+			shipment := &pb.CombinedShipment{}
+			if err = proto.Unmarshal(payload, shipment); err == nil {
+				// 并发的写入可能让较早的版本排在后面，保留版本最大的记录。
+				if existing, ok := s.shipments[shipment.Id]; !ok || existing.Version <= shipment.Version {
+					s.shipments[shipment.Id] = shipment
+				}
+				records++
+				offset += n
+				continue
+			}
+		}
+		log.Printf("discarding shipment log after offset %d : %v", offset, err)
+		return records, os.Truncate(path, offset)
+	}
+}
+
+// compact 把每个发货组合的最新状态写入临时文件，再原子地替换日志。
+func (s *shipmentStore) compact(dir string) error {
+	tmpPath := filepath.Join(dir, shipmentLogFileName+".tmp")
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	var writeErr error
+	for _, shipment := range s.shipments {
+		var data []byte
+		if data, writeErr = proto.Marshal(shipment); writeErr != nil {
+			break
+		}
+		if _, writeErr = w.Write(encodeFrame(data)); writeErr != nil {
+			break
+		}
+	}
+	if writeErr == nil {
+		writeErr = w.Flush()
+	}
+	if writeErr == nil {
+		writeErr = f.Sync()
+	}
+	if err := f.Close(); writeErr == nil {
+		writeErr = err
+	}
+	if writeErr != nil {
+		os.Remove(tmpPath)
+		return writeErr
+	}
+	if err := os.Rename(tmpPath, filepath.Join(dir, shipmentLogFileName)); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+func (s *shipmentStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.flushing {
+		s.written.Wait()
+	}
+	if s.log == nil {
+		return nil
+	}
+	return s.log.Close()
+}
+
+// put 保存发货组合。版本加1，并设置创建时间和更新时间，这些字段同时写回调用方的对象。
+func (s *shipmentStore) put(shipment *pb.CombinedShipment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved := proto.Clone(shipment).(*pb.CombinedShipment)
+	s.stampLocked(saved)
+	if err := s.saveLocked(saved); err != nil {
+		return err
+	}
+	shipment.CreateTime, shipment.UpdateTime, shipment.Version = saved.CreateTime, saved.UpdateTime, saved.Version
+	return nil
+}
+
+// stamp 与put一样设置发货组合的版本、创建时间和更新时间，但不保存，之后由restore保存。
+// 调用方保证在这之间没有其他调用保存同一个发货组合。
+func (s *shipmentStore) stamp(shipment *pb.CombinedShipment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stampLocked(shipment)
+}
+
+func (s *shipmentStore) stampLocked(shipment *pb.CombinedShipment) {
+	now := timestamppb.Now()
+	if shipment.CreateTime == nil {
+		shipment.CreateTime = now
+	}
+	shipment.UpdateTime = now
+	shipment.Version = s.stamped[shipment.Id] + 1
+	if existing, ok := s.shipments[shipment.Id]; ok && existing.Version >= shipment.Version {
+		shipment.Version = existing.Version + 1
+	}
+	s.stamped[shipment.Id] = shipment.Version
+}
+
+// restore 按原样保存已经设置了版本的发货组合，已经保存了相同或更新的版本时什么都不做。
+func (s *shipmentStore) restore(shipment *pb.CombinedShipment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.shipments[shipment.Id]; ok && existing.Version >= shipment.Version {
+		return nil
+	}
+	return s.saveLocked(proto.Clone(shipment).(*pb.CombinedShipment))
+}
+
+// saveLocked 把发货组合加入组提交的队列，等待它写入日志并fsync，成功后替换内存中的版本并唤醒等待的watchShipment。
+// 等待期间会暂时释放mu；同一批写入按加入队列的顺序替换内存中的版本。
+func (s *shipmentStore) saveLocked(saved *pb.CombinedShipment) error {
+	if s.log == nil {
+		s.applyLocked(saved)
+		return nil
+	}
+	data, err := proto.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	w := &shipmentWrite{shipment: saved, frame: encodeFrame(data)}
+	s.queue = append(s.queue, w)
+	for !w.done {
+		if s.flushing {
+			s.written.Wait()
+			continue
+		}
+		s.flushLocked()
+	}
+	return w.err
+}
+
+// flushLocked 把队列中的所有发货组合一次写入日志并fsync，写入期间释放mu。
+func (s *shipmentStore) flushLocked() {
+	batch := s.queue
+	s.queue = nil
+	s.flushing = true
+	var buf []byte
+	for _, w := range batch {
+		buf = append(buf, w.frame...)
+	}
+	size := s.size
+	s.mu.Unlock()
+	_, err := s.log.Write(buf)
+	if err == nil {
+		err = s.log.Sync()
+	}
+	if err != nil {
+		// 去掉可能已经写入一半的记录，这一批发货组合都没有保存。
+		s.log.Truncate(size)
+	}
+	s.mu.Lock()
+	s.flushing = false
+	if err == nil {
+		s.size += int64(len(buf))
+	}
+	for _, w := range batch {
+		w.done, w.err = true, err
+		if err == nil {
+			s.applyLocked(w.shipment)
+		}
+	}
+	s.written.Broadcast()
+}
+
+// applyLocked 替换内存中的发货组合并唤醒等待的watchShipment，已经有更新的版本时保留更新的版本。
+func (s *shipmentStore) applyLocked(saved *pb.CombinedShipment) {
+	if existing, ok := s.shipments[saved.Id]; ok && existing.Version > saved.Version {
+		return
+	}
+	s.shipments[saved.Id] = saved
+	if s.stamped[saved.Id] < saved.Version {
+		s.stamped[saved.Id] = saved.Version
+	}
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// get 返回发货组合的副本，以及在它之后的下一次变化时关闭的channel。
+func (s *shipmentStore) get(id string) (*pb.CombinedShipment, <-chan struct{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	shipment, ok := s.shipments[id]
+	if !ok {
+		return nil, s.changed, false
+	}
+	return proto.Clone(shipment).(*pb.CombinedShipment), s.changed, true
+}
+
+// list 返回租户的符合条件的发货组合的副本，按创建时间排序，创建时间相同时按ID排序。
+func (s *shipmentStore) list(tenant string, req *pb.ListShipmentsRequest) []*pb.CombinedShipment {
+	s.mu.Lock()
+	var shipments []*pb.CombinedShipment
+	for _, shipment := range s.shipments {
+		if shipment.TenantId != tenant {
+			continue
+		}
+		if req.Destination != "" && !strings.EqualFold(shipment.Destination, req.Destination) {
+			continue
+		}
+		if len(req.Statuses) > 0 && !containsShipmentStatus(req.Statuses, shipment.ShipmentStatus) {
+			continue
+		}
+		shipments = append(shipments, proto.Clone(shipment).(*pb.CombinedShipment))
+	}
+	s.mu.Unlock()
+	sort.Slice(shipments, func(i, j int) bool {
+		ti, tj := shipments[i].CreateTime.AsTime(), shipments[j].CreateTime.AsTime()
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return shipments[i].Id < shipments[j].Id
+	})
+	return shipments
+}
+
+func containsShipmentStatus(statuses []pb.ShipmentStatus, st pb.ShipmentStatus) bool {
+	for _, s := range statuses {
+		if s == st {
+			return true
+		}
+	}
+	return false
+}
+
+// shipmentNotFoundError 生成NotFound错误，并用ResourceInfo详情说明不存在的发货组合。
+func shipmentNotFoundError(id string) error {
+	errorStatus := status.New(codes.NotFound, fmt.Sprintf("Shipment does not exist. : %s", id))
+	ds, err := errorStatus.WithDetails(
+		&epb.ResourceInfo{
+			ResourceType: "ecommerce.CombinedShipment",
+			ResourceName: "shipments/" + id,
+			Description:  "The shipment ID is not known to the server",
+		},
+	)
+	if err != nil {
+		return errorStatus.Err()
+	}
+	return ds.Err()
+}
+
+// Simple RPC
+func (s *server) GetShipment(ctx context.Context, req *wrapper.StringValue) (*pb.CombinedShipment, error) {
+	// 与订单一样，其他租户的发货组合对调用方来说不存在。
+	shipment, _, ok := s.shipments.get(req.Value)
+	if !ok || shipment.TenantId != tenantOf(ctx) {
+		return nil, shipmentNotFoundError(req.Value)
+	}
+	return shipment, nil
+}
+
+// Server-side Streaming RPC
+func (s *server) ListShipments(req *pb.ListShipmentsRequest, stream pb.OrderManagement_ListShipmentsServer) error {
+	for _, shipment := range s.shipments.list(tenantOf(stream.Context()), req) {
+		if err := stream.Send(shipment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Server-side Streaming RPC
+// WatchShipment 先发送发货组合的当前状态，之后每次变化发送一次，发货组合发出后结束流。
+// 两次发送之间的多次变化只发送最新的状态。
+func (s *server) WatchShipment(req *wrapper.StringValue, stream pb.OrderManagement_WatchShipmentServer) error {
+	var sent int64
+	for {
+		shipment, changed, ok := s.shipments.get(req.Value)
+		if !ok || shipment.TenantId != tenantOf(stream.Context()) {
+			return shipmentNotFoundError(req.Value)
+		}
+		if shipment.Version > sent {
+			if err := stream.Send(shipment); err != nil {
+				return err
+			}
+			sent = shipment.Version
+		}
+		if shipmentDone(shipment.ShipmentStatus) {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "ordermgt/service/ecommerce"
+)
+
+// sequentialIDs 返回依次生成"cmb-1"、"cmb-2"……的ID生成器。
+func sequentialIDs() func() string {
+	n := 0
+	return func() string {
+		n++
+		return fmt.Sprintf("cmb-%d", n)
+	}
+}
+
+func TestShipmentStore_PersistsAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	s, err := openShipmentStore(dir)
+	if err != nil {
+		t.Fatalf("openShipmentStore failed: %v", err)
+	}
+	shipment := &pb.CombinedShipment{Id: "cmb-1", Destination: "San Jose, CA", OrdersList: []*pb.Order{{Id: "103"}}}
+	s.put(shipment)
+	shipment.ShipmentStatus = pb.ShipmentStatus_SHIPMENT_SHIPPED
+	s.put(shipment)
+	s.put(&pb.CombinedShipment{Id: "cmb-2", Destination: "Mountain View, CA"})
+	s.Close()
+	// 模拟写入最后一条记录时崩溃。
+	f, _ := os.OpenFile(filepath.Join(dir, shipmentLogFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	f.Write([]byte{42, 0, 0})
+	f.Close()
+
+	s, err = openShipmentStore(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer s.Close()
+	got, _, ok := s.get("cmb-1")
+	if !ok || got.ShipmentStatus != pb.ShipmentStatus_SHIPMENT_SHIPPED || got.Version != 2 || len(got.OrdersList) != 1 {
+		t.Fatalf("recovered shipment = %v", got)
+	}
+	if got.CreateTime.AsTime().After(got.UpdateTime.AsTime()) {
+		t.Fatalf("create time %v after update time %v", got.CreateTime.AsTime(), got.UpdateTime.AsTime())
+	}
+	if all := s.list("", &pb.ListShipmentsRequest{}); len(all) != 2 {
+		t.Fatalf("recovered %d shipments, want 2", len(all))
+	}
+}
+
+// 并发保存的发货组合组提交到日志中：同一个发货组合的版本连续递增，重新打开后每个发货组合都是最新的版本。
+func TestShipmentStore_ConcurrentPuts(t *testing.T) {
+	dir := t.TempDir()
+	s, err := openShipmentStore(dir)
+	if err != nil {
+		t.Fatalf("openShipmentStore failed: %v", err)
+	}
+	const shipments, writers, puts = 4, 4, 10
+	var wg sync.WaitGroup
+	for i := 0; i < shipments; i++ {
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				for n := 0; n < puts; n++ {
+					if err := s.put(&pb.CombinedShipment{Id: id, Destination: "San Jose, CA"}); err != nil {
+						t.Errorf("put(%s) failed: %v", id, err)
+					}
+				}
+			}(fmt.Sprintf("cmb-%d", i))
+		}
+	}
+	wg.Wait()
+	check := func(s *shipmentStore) {
+		t.Helper()
+		for i := 0; i < shipments; i++ {
+			if got, _, _ := s.get(fmt.Sprintf("cmb-%d", i)); got.GetVersion() != writers*puts {
+				t.Fatalf("shipment cmb-%d = %v, want version %d", i, got, writers*puts)
+			}
+		}
+	}
+	check(s)
+	s.Close()
+
+	s, err = openShipmentStore(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer s.Close()
+	check(s)
+}
+
+// 日志写入失败时发货组合不变，日志保持在最后一条完整记录的位置。
+func TestShipmentStore_WriteFailure(t *testing.T) {
+	s, err := openShipmentStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("openShipmentStore failed: %v", err)
+	}
+	s.put(&pb.CombinedShipment{Id: "cmb-1", Destination: "San Jose, CA"})
+	size := s.size
+	s.log.Close()
+	if err := s.put(&pb.CombinedShipment{Id: "cmb-1", ShipmentStatus: pb.ShipmentStatus_SHIPMENT_SHIPPED}); err == nil {
+		t.Fatalf("put with a broken log succeeded")
+	}
+	if got, _, _ := s.get("cmb-1"); got.Version != 1 || got.ShipmentStatus != pb.ShipmentStatus_SHIPMENT_OPEN {
+		t.Fatalf("shipment after a failed put = %v", got)
+	}
+	if s.size != size {
+		t.Fatalf("log size %d after a failed put, want %d", s.size, size)
+	}
+}
+
+func TestServer_ShipmentQueries(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	srv := newServer(store)
+	srv.newShipmentID = sequentialIDs()
+	srv.batchPolicy = batchPolicy{MaxOrdersPerDestination: 1}
+	client, stop := startBufConnServer(t, srv)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 每个发货组合只有一个订单，两个发往Mountain View的发货组合使用不同的ID。
+	stream, err := client.ProcessOrders(ctx)
+	if err != nil {
+		t.Fatalf("ProcessOrders failed: %v", err)
+	}
+	for _, id := range []string{"102", "103", "104"} {
+		stream.Send(&wrapper.StringValue{Value: id})
+	}
+	stream.CloseSend()
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+	}
+
+	shipment, err := client.GetShipment(ctx, &wrapper.StringValue{Value: "cmb-3"})
+	if err != nil {
+		t.Fatalf("GetShipment failed: %v", err)
+	}
+	if shipment.Destination != "Mountain View, CA" || shipment.ShipmentStatus != pb.ShipmentStatus_SHIPMENT_SHIPPED ||
+		shipment.CreateTime == nil || shipment.OrdersList[0].Status != pb.OrderStatus_SHIPPED {
+		t.Fatalf("GetShipment(cmb-3) = %v", shipment)
+	}
+	if _, err := client.GetShipment(ctx, &wrapper.StringValue{Value: "cmb - Mountain View, CA"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("GetShipment of unknown ID returned %v, want NotFound", err)
+	}
+
+	list := func(req *pb.ListShipmentsRequest) string {
+		res, err := client.ListShipments(ctx, req)
+		if err != nil {
+			t.Fatalf("ListShipments failed: %v", err)
+		}
+		var ids []string
+		for {
+			shipment, err := res.Recv()
+			if err == io.EOF {
+				return fmt.Sprint(ids)
+			}
+			if err != nil {
+				t.Fatalf("Recv failed: %v", err)
+			}
+			ids = append(ids, shipment.Id)
+		}
+	}
+	if got := list(&pb.ListShipmentsRequest{Destination: "mountain view, ca"}); got != "[cmb-1 cmb-3]" {
+		t.Fatalf("shipments to Mountain View = %s", got)
+	}
+	if got := list(&pb.ListShipmentsRequest{Statuses: []pb.ShipmentStatus{pb.ShipmentStatus_SHIPMENT_OPEN}}); got != "[]" {
+		t.Fatalf("open shipments = %s", got)
+	}
+	if got := list(&pb.ListShipmentsRequest{}); got != "[cmb-1 cmb-2 cmb-3]" {
+		t.Fatalf("all shipments = %s", got)
+	}
+}
+
+func TestServer_WatchShipment(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	srv := newServer(store)
+	srv.newShipmentID = sequentialIDs()
+	srv.batchPolicy = batchPolicy{}
+	client, stop := startBufConnServer(t, srv)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := func() (*pb.CombinedShipment, error) {
+		watch, _ := client.WatchShipment(ctx, &wrapper.StringValue{Value: "cmb-1"})
+		return watch.Recv()
+	}(); status.Code(err) != codes.NotFound {
+		t.Fatalf("watching an unknown shipment returned %v, want NotFound", err)
+	}
+
+	stream, err := client.ProcessOrders(ctx)
+	if err != nil {
+		t.Fatalf("ProcessOrders failed: %v", err)
+	}
+	stream.Send(&wrapper.StringValue{Value: "102"})
+	if res, err := stream.Recv(); err != nil || res.GetResult().GetShipmentId() != "cmb-1" {
+		t.Fatalf("order 102 result = %v, %v", res, err)
+	}
+
+	watch, err := client.WatchShipment(ctx, &wrapper.StringValue{Value: "cmb-1"})
+	if err != nil {
+		t.Fatalf("WatchShipment failed: %v", err)
+	}
+	open, err := watch.Recv()
+	if err != nil || open.ShipmentStatus != pb.ShipmentStatus_SHIPMENT_OPEN || len(open.OrdersList) != 1 {
+		t.Fatalf("first watched state = %v, %v", open, err)
+	}
+
+	stream.Send(&wrapper.StringValue{Value: "104"})
+	stream.CloseSend()
+	var states []string
+	for {
+		shipment, err := watch.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("watch Recv failed: %v", err)
+		}
+		states = append(states, fmt.Sprintf("%s/%d", shipment.ShipmentStatus, len(shipment.OrdersList)))
+	}
+	// 两次变化可能合并为一次发送，但最后一次总是发出后的状态。
+	if len(states) == 0 || states[len(states)-1] != "SHIPMENT_SHIPPED/2" {
+		t.Fatalf("watched states = %v, want to end with SHIPMENT_SHIPPED/2", states)
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"errors"
+	"hash/fnv"
+	"sort"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	pb "ordermgt/service/ecommerce"
+)
+
+const defaultShardCount = 16
+
+// errKeyNotInTxn 表示事务访问了未在Txn调用中声明的订单键。
+var errKeyNotInTxn = errors.New("order key not declared in transaction")
+
+// orderKey 返回租户的订单在OrderStore中的键。默认租户的键就是订单ID，其他租户的键是租户ID、NUL和订单ID，
+// 不同租户可以使用同一个订单ID。租户ID中不会出现NUL，租户视图也拒绝包含NUL的订单ID，因此键不会冲突。
+func orderKey(tenant, id string) string {
+	if tenant == "" {
+		return id
+	}
+	return tenant + "\x00" + id
+}
+
+// keyOf 返回订单在OrderStore中的键。
+func keyOf(order *pb.Order) string {
+	return orderKey(order.TenantId, order.Id)
+}
+
+// OrderStore 是OrderManagement服务读写订单所依赖的存储抽象。订单按keyOf(order)，即租户和订单ID保存，
+// 下面的key都是这样的键；handler通过tenantOrderStore按订单ID访问自己租户的订单。
+// 实现必须是并发安全的，Get和Scan返回的订单是副本，调用方可以随意修改。
+// 每次写入都会把订单的版本加1，新订单的版本为1。
+type OrderStore interface {
+	// Get 返回给定键的订单副本。
+	Get(key string) (*pb.Order, bool)
+	// Put 写入（新增或覆盖）订单，并把order.Version设置为写入后的版本。
+	Put(order *pb.Order) error
+	// Delete 删除订单，返回订单之前是否存在。
+	Delete(key string) (bool, error)
+	// Scan 按订单ID升序遍历所有租户的订单，fn返回false时停止遍历。
+	Scan(fn func(order *pb.Order) bool)
+	// Txn 锁定keys涉及的所有订单并在fn中原子地读写它们。
+	// fn返回错误时，事务中的所有写入都会被丢弃。
+	Txn(keys []string, fn func(tx OrderTxn) error) error
+}
+
+// OrderTxn 是在OrderStore.Txn中可见的事务视图，只能访问声明过的键。
+type OrderTxn interface {
+	Get(key string) (*pb.Order, bool)
+	// Put 写入订单，并把order.Version设置为写入后的版本。
+	Put(order *pb.Order) error
+	Delete(key string) error
+	// AfterCommit 注册一个在事务的写入可见之后、事务释放订单的锁之前调用的函数，事务放弃时不调用。
+	// 涉及同一个订单的事务的回调按提交顺序执行，包装存储的实现（例如watchedOrderStore）
+	// 用它按提交顺序发布变更，而不需要在整个事务期间持有自己的锁。
+	AfterCommit(fn func())
+}
+
+// orderShard 是内存存储中的一个分片。txnMu串行化修改分片的写入，在整个事务期间持有；
+// mu保护orders，只在读取和应用事务的写入时短暂持有。事务函数执行期间（例如等待预写日志fsync）读取不会被阻塞，
+// 事务只在写入全部成功之后才变得可见。
+type orderShard struct {
+	txnMu  sync.Mutex
+	mu     sync.RWMutex
+	orders map[string]*pb.Order
+}
+
+// lock 为写入锁定分片：先锁定事务锁，再锁定数据锁。
+func (sh *orderShard) lock() {
+	sh.txnMu.Lock()
+	sh.mu.Lock()
+}
+
+func (sh *orderShard) unlock() {
+	sh.mu.Unlock()
+	sh.txnMu.Unlock()
+}
+
+// memoryOrderStore 是按订单的键哈希分片、由互斥锁保护的内存OrderStore实现。
+// 启用发件箱后，每次提交的变更在分片的锁内生成领域事件，事件与变更同时可见。
+type memoryOrderStore struct {
+	shards []*orderShard
+	outbox *outbox
+}
+
+func newMemoryOrderStore(shardCount int) *memoryOrderStore {
+	if shardCount <= 0 {
+		shardCount = defaultShardCount
+	}
+	s := &memoryOrderStore{shards: make([]*orderShard, shardCount)}
+	for i := range s.shards {
+		s.shards[i] = &orderShard{orders: make(map[string]*pb.Order)}
+	}
+	return s
+}
+
+func (s *memoryOrderStore) shardIndex(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(s.shards)))
+}
+
+func (s *memoryOrderStore) shard(key string) *orderShard {
+	return s.shards[s.shardIndex(key)]
+}
+
+func (s *memoryOrderStore) Get(key string) (*pb.Order, bool) {
+	sh := s.shard(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	order, ok := sh.orders[key]
+	if !ok {
+		return nil, false
+	}
+	return cloneOrder(order), true
+}
+
+func (s *memoryOrderStore) Put(order *pb.Order) error {
+	key := keyOf(order)
+	sh := s.shard(key)
+	sh.lock()
+	defer sh.unlock()
+	before := sh.orders[key]
+	order.Version = before.GetVersion() + 1
+	sh.orders[key] = cloneOrder(order)
+	s.emitLocked([]orderChange{{id: key, before: before, after: order}})
+	return nil
+}
+
+// load 原样写入订单而不修改版本，用于从快照和预写日志中恢复。
+func (s *memoryOrderStore) load(order *pb.Order) {
+	key := keyOf(order)
+	sh := s.shard(key)
+	sh.lock()
+	defer sh.unlock()
+	sh.orders[key] = cloneOrder(order)
+}
+
+func (s *memoryOrderStore) Delete(key string) (bool, error) {
+	sh := s.shard(key)
+	sh.lock()
+	defer sh.unlock()
+	before, ok := sh.orders[key]
+	delete(sh.orders, key)
+	if ok {
+		s.emitLocked([]orderChange{{id: key, before: before}})
+	}
+	return ok, nil
+}
+
+func (s *memoryOrderStore) Scan(fn func(order *pb.Order) bool) {
+	// 先在读锁下复制所有订单，再在锁外回调，避免fn（例如写入流）长时间持有锁。
+	var orders []*pb.Order
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for _, order := range sh.orders {
+			orders = append(orders, cloneOrder(order))
+		}
+		sh.mu.RUnlock()
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if orders[i].Id != orders[j].Id {
+			return orders[i].Id < orders[j].Id
+		}
+		return orders[i].TenantId < orders[j].TenantId
+	})
+	for _, order := range orders {
+		if !fn(order) {
+			return
+		}
+	}
+}
+
+func (s *memoryOrderStore) Txn(keys []string, fn func(tx OrderTxn) error) error {
+	// 按分片下标升序加锁，保证并发事务之间不会死锁。
+	indexes := make(map[int]bool)
+	for _, key := range keys {
+		indexes[s.shardIndex(key)] = true
+	}
+	locked := make([]int, 0, len(indexes))
+	for i := range indexes {
+		locked = append(locked, i)
+	}
+	sort.Ints(locked)
+	// 事务函数只持有事务锁：修改分片的写入都持有事务锁，事务函数可以不加数据锁读取分片。
+	for _, i := range locked {
+		s.shards[i].txnMu.Lock()
+	}
+	defer func() {
+		for _, i := range locked {
+			s.shards[i].txnMu.Unlock()
+		}
+	}()
+
+	tx := &memoryOrderTxn{store: s, declared: make(map[string]bool), writes: make(map[string]*pb.Order)}
+	for _, key := range keys {
+		tx.declared[key] = true
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	for _, i := range locked {
+		s.shards[i].mu.Lock()
+	}
+	defer func() {
+		for _, i := range locked {
+			s.shards[i].mu.Unlock()
+		}
+	}()
+	var changes []orderChange
+	for id, order := range tx.writes {
+		sh := s.shard(id)
+		if s.outbox != nil {
+			changes = append(changes, orderChange{id: id, before: sh.orders[id], after: order})
+		}
+		if order == nil {
+			delete(sh.orders, id)
+		} else {
+			sh.orders[id] = order
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].id < changes[j].id })
+	s.emitLocked(changes)
+	for _, fn := range tx.afterCommit {
+		fn()
+	}
+	return nil
+}
+
+// enableOutbox 启用发件箱，之后提交的每个变更都生成领域事件。
+func (s *memoryOrderStore) enableOutbox() orderOutbox {
+	if s.outbox == nil {
+		s.outbox = newOutbox()
+	}
+	return s
+}
+
+// emitLocked 在持有变更涉及的分片的锁时为变更生成领域事件。没有启用发件箱时什么都不做。
+func (s *memoryOrderStore) emitLocked(changes []orderChange) {
+	if s.outbox != nil {
+		s.outbox.emit(orderEvents(changes))
+	}
+}
+
+func (s *memoryOrderStore) Emit(event *pb.DomainEvent, apply func() error) error {
+	if s.outbox == nil {
+		return errOutboxDisabled
+	}
+	var err error
+	if apply != nil {
+		err = apply()
+	}
+	s.outbox.emit([]*pb.DomainEvent{event})
+	return err
+}
+
+func (s *memoryOrderStore) Pending(limit int) ([]*pb.DomainEvent, <-chan struct{}) {
+	if s.outbox == nil {
+		return nil, nil
+	}
+	return s.outbox.pending(limit)
+}
+
+func (s *memoryOrderStore) Ack(sequence int64) error {
+	if s.outbox == nil {
+		return errOutboxDisabled
+	}
+	s.outbox.ack(sequence)
+	return nil
+}
+
+// memoryOrderTxn 缓存事务中的写入，直到事务函数成功返回才提交到分片。
+// 值为nil的写入表示删除。
+type memoryOrderTxn struct {
+	store       *memoryOrderStore
+	declared    map[string]bool
+	writes      map[string]*pb.Order
+	afterCommit []func()
+}
+
+func (tx *memoryOrderTxn) Get(id string) (*pb.Order, bool) {
+	if !tx.declared[id] {
+		return nil, false
+	}
+	if order, ok := tx.writes[id]; ok {
+		if order == nil {
+			return nil, false
+		}
+		return cloneOrder(order), true
+	}
+	order, ok := tx.store.shard(id).orders[id]
+	if !ok {
+		return nil, false
+	}
+	return cloneOrder(order), true
+}
+
+func (tx *memoryOrderTxn) Put(order *pb.Order) error {
+	key := keyOf(order)
+	if !tx.declared[key] {
+		return errKeyNotInTxn
+	}
+	// 一个事务中多次写入同一个订单只算一次写入，版本总是在已提交的版本上加1。
+	order.Version = tx.store.shard(key).orders[key].GetVersion() + 1
+	tx.writes[key] = cloneOrder(order)
+	return nil
+}
+
+func (tx *memoryOrderTxn) Delete(id string) error {
+	if !tx.declared[id] {
+		return errKeyNotInTxn
+	}
+	tx.writes[id] = nil
+	return nil
+}
+
+func (tx *memoryOrderTxn) AfterCommit(fn func()) {
+	tx.afterCommit = append(tx.afterCommit, fn)
+}
+
+// orderOp 是事务中的单个变更，id是订单在存储中的键，order为nil时表示删除。
+type orderOp struct {
+	id    string
+	order *pb.Order
+}
+
+// recordingTxn 记录事务中的写入，供包装其他存储的实现（例如预写日志和索引）在提交前后使用。
+type recordingTxn struct {
+	OrderTxn
+	ops []orderOp
+}
+
+func (tx *recordingTxn) Put(order *pb.Order) error {
+	if err := tx.OrderTxn.Put(order); err != nil {
+		return err
+	}
+	tx.ops = append(tx.ops, orderOp{id: keyOf(order), order: cloneOrder(order)})
+	return nil
+}
+
+func (tx *recordingTxn) Delete(id string) error {
+	if err := tx.OrderTxn.Delete(id); err != nil {
+		return err
+	}
+	tx.ops = append(tx.ops, orderOp{id: id})
+	return nil
+}
+
+// orderChange 是事务对一个订单的净变更，id是订单在存储中的键。before为nil表示订单在事务前不存在，after为nil表示订单被删除。
+type orderChange struct {
+	id            string
+	before, after *pb.Order
+}
+
+// netChanges 把事务中的写入合并为每个订单一个变更，按订单第一次被写入的顺序排列。
+// 同一个订单在事务中可能被多次写入，只按它在事务前后的状态生成变更。
+func netChanges(before map[string]*pb.Order, ops []orderOp) []orderChange {
+	after := make(map[string]*pb.Order)
+	var changed []string
+	for _, op := range ops {
+		if _, seen := after[op.id]; !seen {
+			changed = append(changed, op.id)
+		}
+		after[op.id] = op.order
+	}
+	changes := make([]orderChange, len(changed))
+	for i, id := range changed {
+		changes[i] = orderChange{id: id, before: before[id], after: after[id]}
+	}
+	return changes
+}
+
+func cloneOrder(order *pb.Order) *pb.Order {
+	return proto.Clone(order).(*pb.Order)
+}
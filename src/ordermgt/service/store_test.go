@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	pb "ordermgt/service/ecommerce"
+)
+
+func TestMemoryOrderStore_GetPutDelete(t *testing.T) {
+	store := newMemoryOrderStore(4)
+	store.Put(&pb.Order{Id: "1", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA"})
+
+	ord, ok := store.Get("1")
+	if !ok || ord.Destination != "San Jose, CA" {
+		t.Fatalf("Get(1) = %v, %v", ord, ok)
+	}
+	// 返回的订单是副本，修改它不能影响存储中的数据。
+	ord.Destination = "Mountain View, CA"
+	if ord, _ := store.Get("1"); ord.Destination != "San Jose, CA" {
+		t.Fatalf("stored order was mutated through returned copy: %v", ord)
+	}
+
+	if ok, _ := store.Delete("1"); !ok {
+		t.Fatalf("Delete(1) = false, want true")
+	}
+	if ok, _ := store.Delete("1"); ok {
+		t.Fatalf("second Delete(1) = true, want false")
+	}
+	if _, ok := store.Get("1"); ok {
+		t.Fatalf("Get(1) after Delete found the order")
+	}
+}
+
+func TestMemoryOrderStore_ScanOrdered(t *testing.T) {
+	store := newMemoryOrderStore(4)
+	for _, id := range []string{"105", "102", "104", "103"} {
+		store.Put(&pb.Order{Id: id})
+	}
+	var ids []string
+	store.Scan(func(order *pb.Order) bool {
+		ids = append(ids, order.Id)
+		return len(ids) < 3
+	})
+	if fmt.Sprint(ids) != "[102 103 104]" {
+		t.Fatalf("Scan visited %v, want [102 103 104]", ids)
+	}
+}
+
+func TestMemoryOrderStore_Txn(t *testing.T) {
+	store := newMemoryOrderStore(4)
+	store.Put(&pb.Order{Id: "a", Price: 10})
+	store.Put(&pb.Order{Id: "b", Price: 20})
+
+	err := store.Txn([]string{"a", "b"}, func(tx OrderTxn) error {
+		a, _ := tx.Get("a")
+		b, _ := tx.Get("b")
+		a.Price, b.Price = b.Price, a.Price
+		if err := tx.Put(a); err != nil {
+			return err
+		}
+		return tx.Put(b)
+	})
+	if err != nil {
+		t.Fatalf("Txn returned %v", err)
+	}
+	if a, _ := store.Get("a"); a.Price != 20 {
+		t.Fatalf("a.Price = %v, want 20", a.Price)
+	}
+
+	// 事务函数返回错误时，写入必须被丢弃。
+	abort := errors.New("abort")
+	err = store.Txn([]string{"a"}, func(tx OrderTxn) error {
+		tx.Delete("a")
+		return abort
+	})
+	if err != abort {
+		t.Fatalf("Txn returned %v, want %v", err, abort)
+	}
+	if _, ok := store.Get("a"); !ok {
+		t.Fatalf("aborted Txn deleted order a")
+	}
+
+	err = store.Txn([]string{"a"}, func(tx OrderTxn) error {
+		return tx.Put(&pb.Order{Id: "c"})
+	})
+	if err != errKeyNotInTxn {
+		t.Fatalf("Put of undeclared id returned %v, want %v", err, errKeyNotInTxn)
+	}
+}
+
+// 并发的事务在同一组订单之间转移金额，总额必须保持不变。
+func TestMemoryOrderStore_Versions(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	order := &pb.Order{Id: "102"}
+	store.Put(order)
+	if order.Version != 1 {
+		t.Fatalf("new order has version %d, want 1", order.Version)
+	}
+	store.Put(&pb.Order{Id: "102", Version: 7})
+	// 一个事务中的多次写入只算一次写入。
+	store.Txn([]string{"102"}, func(tx OrderTxn) error {
+		tx.Put(&pb.Order{Id: "102", Price: 1})
+		return tx.Put(&pb.Order{Id: "102", Price: 2})
+	})
+	if got, _ := store.Get("102"); got.Version != 3 || got.Price != 2 {
+		t.Fatalf("order after three writes = %v, want version 3", got)
+	}
+}
+
+func TestMemoryOrderStore_ConcurrentTxn(t *testing.T) {
+	store := newMemoryOrderStore(4)
+	ids := []string{"101", "102", "103", "104", "105", "106"}
+	for _, id := range ids {
+		store.Put(&pb.Order{Id: id, Price: 100})
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				from, to := ids[(w+i)%len(ids)], ids[(w+2*i+1)%len(ids)]
+				if from == to {
+					continue
+				}
+				store.Txn([]string{from, to}, func(tx OrderTxn) error {
+					f, _ := tx.Get(from)
+					d, _ := tx.Get(to)
+					f.Price--
+					d.Price++
+					tx.Put(f)
+					return tx.Put(d)
+				})
+				store.Scan(func(order *pb.Order) bool { return true })
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	var total float32
+	store.Scan(func(order *pb.Order) bool {
+		total += order.Price
+		return true
+	})
+	if total != float32(100*len(ids)) {
+		t.Fatalf("total price = %v, want %v", total, 100*len(ids))
+	}
+}
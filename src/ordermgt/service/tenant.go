@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	pb "ordermgt/service/ecommerce"
+)
+
+const (
+	// tenantIDKey 是客户端声明所属租户的元数据键。
+	tenantIDKey = "tenant-id"
+	// defaultTenantLabel 是默认租户在指标和元数据中的名称。默认租户的订单的tenantId为空。
+	defaultTenantLabel = "default"
+)
+
+// tenantIDPattern 是合法的租户ID：小写字母、数字和连字符，不超过63个字符。
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+type tenantContextKey struct{}
+
+// tenantOf 返回拦截器为调用确定的租户。没有经过拦截器的调用（例如服务器端自己发起的变更）属于默认租户。
+func tenantOf(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenant
+}
+
+// tenantLabel 返回租户在指标和错误信息中的名称。
+func tenantLabel(tenant string) string {
+	if tenant == "" {
+		return defaultTenantLabel
+	}
+	return tenant
+}
+
+// parseTenantID 检查租户ID的格式，"default"表示默认租户。
+func parseTenantID(id string) (string, error) {
+	if !tenantIDPattern.MatchString(id) {
+		return "", status.Errorf(codes.InvalidArgument, "invalid %s : %q", tenantIDKey, id)
+	}
+	if id == defaultTenantLabel {
+		return "", nil
+	}
+	return id, nil
+}
+
+// tenantFromContext 确定调用方所属的租户：经过校验的TLS客户端证书的Organization是调用方经过认证的租户，
+// 元数据tenant-id中声明的租户必须与之一致。两者都没有时属于默认租户。
+func tenantFromContext(ctx context.Context) (string, error) {
+	var declared string
+	hasDeclared := false
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(tenantIDKey); len(ids) > 0 {
+			tenant, err := parseTenantID(ids[0])
+			if err != nil {
+				return "", err
+			}
+			declared, hasDeclared = tenant, true
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 && len(info.State.VerifiedChains[0]) > 0 {
+			if orgs := info.State.VerifiedChains[0][0].Subject.Organization; len(orgs) > 0 {
+				authenticated, err := parseTenantID(orgs[0])
+				if err != nil {
+					return "", status.Errorf(codes.PermissionDenied, "client certificate does not name a valid tenant : %q", orgs[0])
+				}
+				if hasDeclared && declared != authenticated {
+					return "", status.Errorf(codes.PermissionDenied, "%s %q does not match the authenticated tenant %q", tenantIDKey, tenantLabel(declared), tenantLabel(authenticated))
+				}
+				return authenticated, nil
+			}
+		}
+	}
+	return declared, nil
+}
+
+var (
+	tenantTagKey = tag.MustNewKey("tenant")
+	methodTagKey = tag.MustNewKey("method")
+	codeTagKey   = tag.MustNewKey("code")
+
+	tenantRequests        = stats.Int64("ordermgt/tenant/requests", "Number of RPCs handled for a tenant", stats.UnitDimensionless)
+	tenantOrders          = stats.Int64("ordermgt/tenant/orders", "Number of orders stored for a tenant", stats.UnitDimensionless)
+	tenantQuotaRejections = stats.Int64("ordermgt/tenant/quota_rejections", "Number of writes rejected because a tenant reached its order quota", stats.UnitDimensionless)
+
+	// tenantViews 按租户汇总指标，在main中注册后由注册的导出器导出。
+	tenantViews = []*view.View{
+		{
+			Name:        "ordermgt/tenant/requests",
+			Description: "Number of RPCs handled for each tenant, by method and status code",
+			Measure:     tenantRequests,
+			TagKeys:     []tag.Key{tenantTagKey, methodTagKey, codeTagKey},
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "ordermgt/tenant/orders",
+			Description: "Number of orders stored for each tenant",
+			Measure:     tenantOrders,
+			TagKeys:     []tag.Key{tenantTagKey},
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "ordermgt/tenant/quota_rejections",
+			Description: "Number of writes rejected because the tenant reached its order quota",
+			Measure:     tenantQuotaRejections,
+			TagKeys:     []tag.Key{tenantTagKey},
+			Aggregation: view.Count(),
+		},
+	}
+)
+
+// recordTenant 以租户为标签记录一个度量值。
+func recordTenant(tenant string, m stats.Measurement, mutators ...tag.Mutator) {
+	mutators = append([]tag.Mutator{tag.Upsert(tenantTagKey, tenantLabel(tenant))}, mutators...)
+	stats.RecordWithTags(context.Background(), mutators, m)
+}
+
+// tenantRegistry 确定每个调用的租户，维护每个租户的订单数以执行配额，并记录按租户划分的指标。
+// 订单数在租户第一次写入时通过扫描存储得到，之后随着通过租户视图提交的变更增量更新。
+type tenantRegistry struct {
+	// allowed 不为nil时只接受其中的租户。
+	allowed map[string]bool
+	// maxOrders 是每个租户的订单数上限，为0表示不限制。
+	maxOrders int
+
+	// mu 只保护tenants本身，每个租户的订单数由租户自己的锁保护。
+	mu      sync.Mutex
+	tenants map[string]*tenantCounter
+}
+
+// tenantCounter 是一个租户的订单数，包括事务中已经预留、还没有提交的新增订单。
+// mu只在读取和更新订单数时短暂持有，同一个租户的事务可以并发提交。
+type tenantCounter struct {
+	mu     sync.Mutex
+	loaded bool
+	count  int
+}
+
+func newTenantRegistry(allowed []string, maxOrders int) (*tenantRegistry, error) {
+	r := &tenantRegistry{maxOrders: maxOrders, tenants: make(map[string]*tenantCounter)}
+	if len(allowed) > 0 {
+		r.allowed = make(map[string]bool)
+		for _, id := range allowed {
+			tenant, err := parseTenantID(id)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tenant %q", id)
+			}
+			r.allowed[tenant] = true
+		}
+	}
+	return r, nil
+}
+
+// resolve 确定调用的租户，并检查租户是否在允许的列表中。
+func (r *tenantRegistry) resolve(ctx context.Context) (string, error) {
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if r.allowed != nil && !r.allowed[tenant] {
+		return "", status.Errorf(codes.PermissionDenied, "unknown tenant %q", tenantLabel(tenant))
+	}
+	return tenant, nil
+}
+
+// Server :: Unary Interceptor
+// 服务器端一元拦截器：确定调用的租户并放入Context，按租户记录调用次数。
+func (r *tenantRegistry) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	tenant, err := r.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := handler(context.WithValue(ctx, tenantContextKey{}, tenant), req)
+	recordTenant(tenant, tenantRequests.M(1), tag.Upsert(methodTagKey, info.FullMethod), tag.Upsert(codeTagKey, status.Code(err).String()))
+	return resp, err
+}
+
+// tenantServerStream 把带有租户的Context交给流处理程序。
+type tenantServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tenantServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// Server :: Stream Interceptor
+// 服务器端流拦截器：与一元拦截器相同，流的Context中带有调用的租户。
+func (r *tenantRegistry) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	tenant, err := r.resolve(ss.Context())
+	if err != nil {
+		return err
+	}
+	err = handler(srv, &tenantServerStream{ServerStream: ss, ctx: context.WithValue(ss.Context(), tenantContextKey{}, tenant)})
+	recordTenant(tenant, tenantRequests.M(1), tag.Upsert(methodTagKey, info.FullMethod), tag.Upsert(codeTagKey, status.Code(err).String()))
+	return err
+}
+
+// counter 返回租户的订单数，租户第一次出现时创建。
+func (r *tenantRegistry) counter(tenant string) *tenantCounter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.tenants[tenant]
+	if !ok {
+		c = &tenantCounter{}
+		r.tenants[tenant] = c
+	}
+	return c
+}
+
+// countLocked 返回租户当前的订单数，第一次调用时扫描存储。调用方持有c.mu，
+// 这个租户新增或删除订单的事务在开始之前都要先获得这个锁，扫描期间订单数不会变化。
+func (c *tenantCounter) countLocked(store OrderStore, tenant string) int {
+	if !c.loaded {
+		store.Scan(func(order *pb.Order) bool {
+			if order.TenantId == tenant {
+				c.count++
+			}
+			return true
+		})
+		c.loaded = true
+		recordTenant(tenant, tenantOrders.M(int64(c.count)))
+	}
+	return c.count
+}
+
+// quotaError 生成ResourceExhausted错误，并用QuotaFailure详情说明超出的配额。
+func (r *tenantRegistry) quotaError(tenant string) error {
+	errorStatus := status.New(codes.ResourceExhausted, fmt.Sprintf("Tenant %s has reached its quota of %d orders", tenantLabel(tenant), r.maxOrders))
+	ds, err := errorStatus.WithDetails(
+		&epb.QuotaFailure{
+			Violations: []*epb.QuotaFailure_Violation{{
+				Subject:     "tenants/" + tenantLabel(tenant),
+				Description: fmt.Sprintf("At most %d orders can be stored", r.maxOrders),
+			}},
+		},
+	)
+	if err != nil {
+		return errorStatus.Err()
+	}
+	return ds.Err()
+}
+
+// tenantOrderStore 是OrderStore在一个租户上的视图：按订单ID访问这个租户的订单，写入的订单属于这个租户。
+// 订单按租户和订单ID保存，其他租户的同一个订单ID在这个视图中不存在，读写它们就像读写不存在的订单一样。
+// 新增订单超过租户的配额时返回ResourceExhausted。registry为nil时不维护订单数和配额，
+// 用于服务器端自己发起的、不会新增或删除订单的变更。
+type tenantOrderStore struct {
+	OrderStore
+	tenant   string
+	registry *tenantRegistry
+}
+
+// checkOrderIDs 拒绝包含NUL的订单ID，NUL用于在存储的键中分隔租户和订单ID。
+func checkOrderIDs(ids []string) error {
+	for _, id := range ids {
+		if strings.ContainsRune(id, 0) {
+			return invalidFieldError("id", "Order ID must not contain NUL characters")
+		}
+	}
+	return nil
+}
+
+func (s *tenantOrderStore) Get(id string) (*pb.Order, bool) {
+	if checkOrderIDs([]string{id}) != nil {
+		return nil, false
+	}
+	return s.OrderStore.Get(orderKey(s.tenant, id))
+}
+
+func (s *tenantOrderStore) Scan(fn func(order *pb.Order) bool) {
+	s.OrderStore.Scan(func(order *pb.Order) bool {
+		if order.TenantId != s.tenant {
+			return true
+		}
+		return fn(order)
+	})
+}
+
+// Match 把文本查询转发给底层存储的索引，只返回这个租户的订单。
+func (s *tenantOrderStore) Match(query string, fn func(order *pb.Order) bool) {
+	owned := func(order *pb.Order) bool {
+		if order.TenantId != s.tenant {
+			return true
+		}
+		return fn(order)
+	}
+	if matcher, ok := s.OrderStore.(orderMatcher); ok {
+		matcher.Match(query, owned)
+		return
+	}
+	s.OrderStore.Scan(func(order *pb.Order) bool {
+		if !matchesText(order, query) {
+			return true
+		}
+		return owned(order)
+	})
+}
+
+func (s *tenantOrderStore) Put(order *pb.Order) error {
+	return s.Txn([]string{order.Id}, func(tx OrderTxn) error {
+		return tx.Put(order)
+	})
+}
+
+func (s *tenantOrderStore) Delete(id string) (bool, error) {
+	existed := false
+	err := s.Txn([]string{id}, func(tx OrderTxn) error {
+		if _, existed = tx.Get(id); !existed {
+			return nil
+		}
+		return tx.Delete(id)
+	})
+	return existed, err
+}
+
+func (s *tenantOrderStore) Txn(ids []string, fn func(tx OrderTxn) error) error {
+	if err := checkOrderIDs(ids); err != nil {
+		return err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = orderKey(s.tenant, id)
+	}
+	r := s.registry
+	if r == nil {
+		return s.OrderStore.Txn(keys, func(tx OrderTxn) error {
+			return fn(&tenantOrderTxn{OrderTxn: tx, tenant: s.tenant})
+		})
+	}
+	c := r.counter(s.tenant)
+	c.mu.Lock()
+	c.countLocked(s.OrderStore, s.tenant)
+	c.mu.Unlock()
+	// 新增的订单在事务提交之前预留配额，事务失败时释放；删除的订单在事务提交之后才释放配额。
+	delta, reserved := 0, 0
+	err := s.OrderStore.Txn(keys, func(tx OrderTxn) error {
+		before := make(map[string]*pb.Order)
+		for _, key := range keys {
+			if order, ok := tx.Get(key); ok {
+				before[key] = order
+			}
+		}
+		rec := &recordingTxn{OrderTxn: tx}
+		if err := fn(&tenantOrderTxn{OrderTxn: rec, tenant: s.tenant}); err != nil {
+			return err
+		}
+		delta = 0
+		for _, change := range netChanges(before, rec.ops) {
+			switch {
+			case change.before == nil && change.after != nil:
+				delta++
+			case change.before != nil && change.after == nil:
+				delta--
+			}
+		}
+		if delta > 0 {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if r.maxOrders > 0 && c.count+delta > r.maxOrders {
+				recordTenant(s.tenant, tenantQuotaRejections.M(1))
+				return r.quotaError(s.tenant)
+			}
+			c.count += delta
+			reserved = delta
+		}
+		return nil
+	})
+	if err != nil {
+		if reserved > 0 {
+			c.mu.Lock()
+			c.count -= reserved
+			c.mu.Unlock()
+		}
+		return err
+	}
+	if delta != 0 {
+		c.mu.Lock()
+		if delta < 0 {
+			c.count += delta
+		}
+		recordTenant(s.tenant, tenantOrders.M(int64(c.count)))
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// tenantOrderTxn 把事务中的订单ID转换为租户的键，并把写入的订单标记为属于租户。
+type tenantOrderTxn struct {
+	OrderTxn
+	tenant string
+}
+
+func (tx *tenantOrderTxn) Get(id string) (*pb.Order, bool) {
+	return tx.OrderTxn.Get(orderKey(tx.tenant, id))
+}
+
+func (tx *tenantOrderTxn) Put(order *pb.Order) error {
+	order.TenantId = tx.tenant
+	return tx.OrderTxn.Put(order)
+}
+
+func (tx *tenantOrderTxn) Delete(id string) error {
+	return tx.OrderTxn.Delete(orderKey(tx.tenant, id))
+}
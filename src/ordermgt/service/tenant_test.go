@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	"go.opencensus.io/stats/view"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	pb "ordermgt/service/ecommerce"
+)
+
+// startTenantServer 在bufconn上启动带有租户拦截器和幂等拦截器的OrderManagement服务。
+func startTenantServer(t *testing.T, srv *server) (pb.OrderManagementClient, func()) {
+	idempotency := newIdempotencyCache(time.Minute, 0)
+	return startBufConnServer(t, srv,
+		grpc.ChainUnaryInterceptor(srv.tenants.unaryInterceptor, idempotency.unaryInterceptor),
+		grpc.ChainStreamInterceptor(srv.tenants.streamInterceptor, idempotency.streamInterceptor))
+}
+
+func asTenant(ctx context.Context, tenant string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, tenantIDKey, tenant)
+}
+
+// searchIDs 返回SearchOrders找到的订单ID。
+func searchIDs(t *testing.T, ctx context.Context, client pb.OrderManagementClient, item string) []string {
+	t.Helper()
+	stream, err := client.SearchOrders(ctx, &pb.SearchOrdersRequest{Item: item})
+	if err != nil {
+		t.Fatalf("SearchOrders failed: %v", err)
+	}
+	var ids []string
+	for {
+		order, err := stream.Recv()
+		if err == io.EOF {
+			return ids
+		}
+		if err != nil {
+			t.Fatalf("SearchOrders failed: %v", err)
+		}
+		ids = append(ids, order.Id)
+	}
+}
+
+func TestTenantFromContext(t *testing.T) {
+	withCert := func(ctx context.Context, org string) context.Context {
+		cert := &x509.Certificate{Subject: pkix.Name{Organization: []string{org}}}
+		info := credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}}
+		return peer.NewContext(ctx, &peer.Peer{AuthInfo: info})
+	}
+	incoming := func(tenant string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs(tenantIDKey, tenant))
+	}
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+		code codes.Code
+	}{
+		{"no tenant", context.Background(), "", codes.OK},
+		{"metadata", incoming("acme"), "acme", codes.OK},
+		{"explicit default", incoming("default"), "", codes.OK},
+		{"invalid metadata", incoming("Acme Corp"), "", codes.InvalidArgument},
+		{"certificate", withCert(context.Background(), "acme"), "acme", codes.OK},
+		{"matching metadata", withCert(incoming("acme"), "acme"), "acme", codes.OK},
+		{"mismatched metadata", withCert(incoming("globex"), "acme"), "", codes.PermissionDenied},
+		{"invalid certificate", withCert(context.Background(), "ACME"), "", codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		got, err := tenantFromContext(tt.ctx)
+		if got != tt.want || status.Code(err) != tt.code {
+			t.Errorf("%s: tenantFromContext = %q, %v, want %q, %v", tt.name, got, err, tt.want, tt.code)
+		}
+	}
+}
+
+// 一个租户看不到其他租户的订单：读取其他租户的订单ID返回NotFound，不同租户可以使用同一个订单ID。
+func TestServer_TenantIsolation(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	srv := newServer(store)
+	client, stop := startTenantServer(t, srv)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	acme, globex := asTenant(ctx, "acme"), asTenant(ctx, "globex")
+
+	if _, err := client.AddOrder(acme, &pb.Order{Id: "201", Items: []string{"Google Pixel 3A"}, Destination: "Austin, TX", Price: 400}); err != nil {
+		t.Fatalf("AddOrder as acme failed: %v", err)
+	}
+	order, err := client.GetOrder(acme, &wrapper.StringValue{Value: "201"})
+	if err != nil || order.TenantId != "acme" {
+		t.Fatalf("GetOrder as acme = %v, %v", order, err)
+	}
+	// 其他租户的订单与不存在的订单无法区分。
+	for name, ctx := range map[string]context.Context{"globex": globex, "default": ctx} {
+		if _, err := client.GetOrder(ctx, &wrapper.StringValue{Value: "201"}); status.Code(err) != codes.NotFound {
+			t.Errorf("GetOrder(201) as %s returned %v, want NotFound", name, err)
+		}
+	}
+	if _, err := client.GetOrder(acme, &wrapper.StringValue{Value: "103"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetOrder(103) as acme returned %v, want NotFound", err)
+	}
+	if _, err := client.CancelOrder(globex, &pb.CancelOrderRequest{Id: "201", Reason: "duplicate"}); status.Code(err) != codes.NotFound {
+		t.Errorf("CancelOrder(201) as globex returned %v, want NotFound", err)
+	}
+
+	if ids := searchIDs(t, acme, client, "Google"); len(ids) != 1 || ids[0] != "201" {
+		t.Errorf("acme found %v, want [201]", ids)
+	}
+	if ids := searchIDs(t, globex, client, "Google"); len(ids) != 0 {
+		t.Errorf("globex found %v, want none", ids)
+	}
+	if ids := searchIDs(t, ctx, client, "Google"); len(ids) != 2 {
+		t.Errorf("default tenant found %v, want the two sample orders", ids)
+	}
+
+	// 其他租户使用同一个ID新增的订单是另一个订单，不会替换、修改或删除acme的订单。
+	if _, err := client.AddOrder(globex, &pb.Order{Id: "201", Items: []string{"Mac Book Pro"}, Destination: "Austin, TX", Price: 1800}); err != nil {
+		t.Fatalf("AddOrder(201) as globex failed: %v", err)
+	}
+	if _, err := client.CancelOrder(globex, &pb.CancelOrderRequest{Id: "201", Reason: "duplicate"}); err != nil {
+		t.Fatalf("CancelOrder(201) as globex failed: %v", err)
+	}
+	if order, exists := store.Get(orderKey("acme", "201")); !exists || order.TenantId != "acme" || order.Status != pb.OrderStatus_PENDING {
+		t.Fatalf("acme order 201 after globex writes = %v", order)
+	}
+	if order, exists := store.Get(orderKey("globex", "201")); !exists || order.Status != pb.OrderStatus_CANCELLED || order.Items[0] != "Mac Book Pro" {
+		t.Fatalf("globex order 201 = %v", order)
+	}
+	order, err = client.GetOrder(globex, &wrapper.StringValue{Value: "201"})
+	if err != nil || order.TenantId != "globex" {
+		t.Fatalf("GetOrder as globex = %v, %v", order, err)
+	}
+	if ids := searchIDs(t, acme, client, "Mac"); len(ids) != 0 {
+		t.Errorf("acme found %v for globex's items, want none", ids)
+	}
+
+	// 其他租户的发货组合与订单一样返回NotFound。
+	if err := srv.shipments.put(&pb.CombinedShipment{Id: "cmb-acme", TenantId: "acme", Destination: "Austin, TX"}); err != nil {
+		t.Fatalf("put shipment failed: %v", err)
+	}
+	if _, err := client.GetShipment(globex, &wrapper.StringValue{Value: "cmb-acme"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetShipment as globex returned %v, want NotFound", err)
+	}
+	if shipment, err := client.GetShipment(acme, &wrapper.StringValue{Value: "cmb-acme"}); err != nil || shipment.TenantId != "acme" {
+		t.Errorf("GetShipment as acme = %v, %v", shipment, err)
+	}
+}
+
+// 同一个租户的并发新增不会超过配额，不同租户的配额互不影响。
+func TestTenantOrderStore_ConcurrentQuota(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	tenants, err := newTenantRegistry(nil, 5)
+	if err != nil {
+		t.Fatalf("newTenantRegistry failed: %v", err)
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	added := make(map[string]int)
+	for _, tenant := range []string{"acme", "globex"} {
+		view := &tenantOrderStore{OrderStore: store, tenant: tenant, registry: tenants}
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(tenant string, i int) {
+				defer wg.Done()
+				err := view.Put(&pb.Order{Id: fmt.Sprint(i), Items: []string{"Google Pixel 3A"}})
+				if status.Code(err) == codes.ResourceExhausted {
+					return
+				}
+				if err != nil {
+					t.Errorf("Put(%d) as %s failed: %v", i, tenant, err)
+					return
+				}
+				mu.Lock()
+				added[tenant]++
+				mu.Unlock()
+			}(tenant, i)
+		}
+	}
+	wg.Wait()
+	if added["acme"] != 5 || added["globex"] != 5 {
+		t.Fatalf("orders added per tenant = %v, want 5 each", added)
+	}
+	count := 0
+	store.Scan(func(*pb.Order) bool { count++; return true })
+	if count != 10 {
+		t.Fatalf("store holds %d orders, want 10", count)
+	}
+}
+
+// 同一个租户的一个事务还没有提交时，这个租户的其他订单的事务不被阻塞；
+// 事务中新增的订单预留配额，事务失败时释放。
+func TestTenantOrderStore_TxnDoesNotBlockTenant(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	tenants, err := newTenantRegistry(nil, 2)
+	if err != nil {
+		t.Fatalf("newTenantRegistry failed: %v", err)
+	}
+	view := &tenantOrderStore{OrderStore: store, tenant: "acme", registry: tenants}
+	inTxn, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- view.Txn([]string{"201"}, func(tx OrderTxn) error {
+			tx.Put(&pb.Order{Id: "201"})
+			close(inTxn)
+			<-release
+			return nil
+		})
+	}()
+	<-inTxn
+	put := make(chan error)
+	go func() { put <- view.Put(&pb.Order{Id: "202"}) }()
+	select {
+	case err := <-put:
+		if err != nil {
+			t.Fatalf("Put(202) failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Put(202) blocked by an open transaction of the same tenant")
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Txn(201) failed: %v", err)
+	}
+
+	if err := view.Put(&pb.Order{Id: "203"}); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Put(203) over quota returned %v, want ResourceExhausted", err)
+	}
+	view.Txn([]string{"201", "203"}, func(tx OrderTxn) error {
+		tx.Delete("201")
+		tx.Put(&pb.Order{Id: "203"})
+		return fmt.Errorf("abort")
+	})
+	if _, err := view.Delete("202"); err != nil {
+		t.Fatalf("Delete(202) failed: %v", err)
+	}
+	if err := view.Put(&pb.Order{Id: "203"}); err != nil {
+		t.Fatalf("Put(203) after a delete failed: %v", err)
+	}
+	if got := orderIDs(view); got != "[201 203]" {
+		t.Fatalf("acme orders %s, want [201 203]", got)
+	}
+}
+
+// 超过配额的新增订单返回带有QuotaFailure详情的ResourceExhausted，并按租户记录在指标中。
+func TestServer_TenantQuota(t *testing.T) {
+	if err := view.Register(tenantViews...); err != nil {
+		t.Fatalf("view.Register failed: %v", err)
+	}
+	defer view.Unregister(tenantViews...)
+
+	srv := newServer(newMemoryOrderStore(defaultShardCount))
+	tenants, err := newTenantRegistry([]string{"acme", "globex"}, 1)
+	if err != nil {
+		t.Fatalf("newTenantRegistry failed: %v", err)
+	}
+	srv.tenants = tenants
+	srv.admins = testAdminPolicy()
+	client, stop := startTenantServer(t, srv)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	acme, globex := asTenant(ctx, "acme"), asTenant(ctx, "globex")
+	order := func(id string) *pb.Order {
+		return &pb.Order{Id: id, Items: []string{"Google Pixel 3A"}, Destination: "Austin, TX", Price: 400}
+	}
+
+	if _, err := client.AddOrder(acme, order("301")); err != nil {
+		t.Fatalf("AddOrder(301) failed: %v", err)
+	}
+	// 已有的订单返回AlreadyExists，而不是超过配额。
+	if _, err := client.AddOrder(acme, order("301")); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("adding order 301 again returned %v, want AlreadyExists", err)
+	}
+	_, err = client.AddOrder(acme, order("302"))
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("AddOrder over quota returned %v, want ResourceExhausted", err)
+	}
+	var quota *epb.QuotaFailure
+	for _, d := range status.Convert(err).Details() {
+		if q, ok := d.(*epb.QuotaFailure); ok {
+			quota = q
+		}
+	}
+	if quota == nil || len(quota.Violations) != 1 || quota.Violations[0].Subject != "tenants/acme" {
+		t.Fatalf("quota failure details = %v", quota)
+	}
+	// 配额按租户计算。
+	if _, err := client.AddOrder(globex, order("303")); err != nil {
+		t.Fatalf("AddOrder as globex failed: %v", err)
+	}
+	if _, err := client.DeleteOrder(asAdmin(acme), &pb.DeleteOrderRequest{Id: "301"}); err != nil {
+		t.Fatalf("DeleteOrder failed: %v", err)
+	}
+	if _, err := client.AddOrder(acme, order("302")); err != nil {
+		t.Fatalf("AddOrder after delete failed: %v", err)
+	}
+	// 不在允许列表中的租户被拒绝。
+	if _, err := client.AddOrder(ctx, order("304")); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("AddOrder as default tenant returned %v, want PermissionDenied", err)
+	}
+
+	rows, err := view.RetrieveData("ordermgt/tenant/quota_rejections")
+	if err != nil || len(rows) != 1 || rows[0].Tags[0].Value != "acme" || rows[0].Data.(*view.CountData).Value != 1 {
+		t.Fatalf("quota rejections = %v, %v", rows, err)
+	}
+	rows, _ = view.RetrieveData("ordermgt/tenant/orders")
+	orders := make(map[string]float64)
+	for _, row := range rows {
+		orders[row.Tags[0].Value] = row.Data.(*view.LastValueData).Value
+	}
+	if orders["acme"] != 1 || orders["globex"] != 1 {
+		t.Fatalf("orders per tenant = %v", orders)
+	}
+	rows, _ = view.RetrieveData("ordermgt/tenant/requests")
+	requests := make(map[string]int64)
+	for _, row := range rows {
+		for _, tag := range row.Tags {
+			if tag.Key == tenantTagKey {
+				requests[tag.Value] += row.Data.(*view.CountData).Value
+			}
+		}
+	}
+	// 被拒绝的调用不属于任何租户，不计入请求数。
+	if requests["acme"] != 5 || requests["globex"] != 1 || len(requests) != 2 {
+		t.Fatalf("requests per tenant = %v", requests)
+	}
+}
+
+// 幂等键和发货组合都按租户划分。
+func TestServer_TenantScopedKeysAndShipments(t *testing.T) {
+	client, stop := startTenantServer(t, newServer(newMemoryOrderStore(defaultShardCount)))
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	acme := metadata.AppendToOutgoingContext(ctx, tenantIDKey, "acme", idempotencyKeyHeader, "add")
+	globex := metadata.AppendToOutgoingContext(ctx, tenantIDKey, "globex", idempotencyKeyHeader, "add")
+
+	if _, err := client.AddOrder(acme, &pb.Order{Id: "401", Items: []string{"Google Pixel 3A"}, Destination: "Austin, TX", Price: 400}); err != nil {
+		t.Fatalf("AddOrder as acme failed: %v", err)
+	}
+	// 另一个租户使用同一个幂等键不会重放或拒绝acme的请求。
+	if _, err := client.AddOrder(globex, &pb.Order{Id: "402", Items: []string{"Google Pixel 3A"}, Destination: "Austin, TX", Price: 400}); err != nil {
+		t.Fatalf("AddOrder as globex with the same key failed: %v", err)
+	}
+
+	b := newShipmentBatcher(batchPolicy{}, sequentialIDs())
+	first, _ := b.add(&pb.Order{Id: "401", Destination: "Austin, TX", TenantId: "acme"})
+	second, _ := b.add(&pb.Order{Id: "402", Destination: "Austin, TX", TenantId: "globex"})
+	if first == second || first.TenantId != "acme" || second.TenantId != "globex" {
+		t.Fatalf("orders of different tenants were consolidated: %v, %v", first, second)
+	}
+}
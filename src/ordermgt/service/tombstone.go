@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "ordermgt/service/ecommerce"
+)
+
+// defaultTombstoneRetention 是已删除订单的墓碑默认保留的时间。
+const defaultTombstoneRetention = 7 * 24 * time.Hour
+
+// tombstone 记录一个已删除的订单，key是订单在存储中的键（见orderKey）。
+type tombstone struct {
+	key     string
+	reason  string
+	deleted time.Time
+	expires time.Time
+}
+
+// orderTombstones 在保留期内记录已删除的订单，让getOrder能够区分“已删除”和“从未存在”。
+// 墓碑只保存在内存中，服务重启后丢失。
+type orderTombstones struct {
+	retention time.Duration
+	now       func() time.Time
+
+	mu sync.Mutex
+	// entries 按订单的键保存墓碑，不同租户删除的同一个订单ID互不影响。
+	entries map[string]*tombstone
+	// queue 按删除时间保存所有墓碑，用于清理过期的墓碑。
+	queue []*tombstone
+}
+
+func newOrderTombstones(retention time.Duration) *orderTombstones {
+	if retention <= 0 {
+		retention = defaultTombstoneRetention
+	}
+	return &orderTombstones{retention: retention, now: time.Now, entries: make(map[string]*tombstone)}
+}
+
+// purgeLocked 删除所有过期的墓碑。
+func (t *orderTombstones) purgeLocked(now time.Time) {
+	for len(t.queue) > 0 && !t.queue[0].expires.After(now) {
+		expired := t.queue[0]
+		t.queue = t.queue[1:]
+		if t.entries[expired.key] == expired {
+			delete(t.entries, expired.key)
+		}
+	}
+}
+
+func (t *orderTombstones) add(tenant, id, reason string) *tombstone {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	t.purgeLocked(now)
+	ts := &tombstone{key: orderKey(tenant, id), reason: reason, deleted: now, expires: now.Add(t.retention)}
+	t.entries[ts.key] = ts
+	t.queue = append(t.queue, ts)
+	return ts
+}
+
+// remove 撤销add添加的墓碑，用于删除订单的事务没有提交的情况。之后为同一个订单添加的墓碑不受影响。
+func (t *orderTombstones) remove(ts *tombstone) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.entries[ts.key] == ts {
+		delete(t.entries, ts.key)
+	}
+}
+
+func (t *orderTombstones) get(tenant, id string) (*tombstone, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.purgeLocked(t.now())
+	ts, ok := t.entries[orderKey(tenant, id)]
+	return ts, ok
+}
+
+// notFound 返回订单不存在的错误。订单在保留期内被tenant删除过时，ResourceInfo详情中说明删除的时间和原因。
+func (t *orderTombstones) notFound(tenant, id string) error {
+	ts, ok := t.get(tenant, id)
+	if !ok {
+		return orderNotFoundError(id)
+	}
+	errorStatus := status.New(codes.NotFound, fmt.Sprintf("Order was deleted. : %s", id))
+	ds, err := errorStatus.WithDetails(
+		&epb.ResourceInfo{
+			ResourceType: "ecommerce.Order",
+			ResourceName: "orders/" + id,
+			Description:  fmt.Sprintf("Deleted at %s : %s", ts.deleted.UTC().Format(time.RFC3339), ts.reason),
+		},
+	)
+	if err != nil {
+		return errorStatus.Err()
+	}
+	return ds.Err()
+}
+
+// Simple RPC
+// DeleteOrder 是管理员接口：删除订单并留下墓碑，订单已经不存在时返回NotFound，调用方不是管理员时返回PermissionDenied。
+// 墓碑在删除订单的事务提交之前添加，订单一旦从存储中消失，getOrder一定能看到它的墓碑。
+func (s *server) DeleteOrder(ctx context.Context, req *pb.DeleteOrderRequest) (*wrapper.StringValue, error) {
+	if err := s.admins.check(ctx, "deleteOrder"); err != nil {
+		return nil, err
+	}
+	var deleted *pb.Order
+	var ts *tombstone
+	err := s.storeFor(ctx).Txn([]string{req.Id}, func(tx OrderTxn) error {
+		order, exists := tx.Get(req.Id)
+		if !exists {
+			return s.tombstones.notFound(tenantOf(ctx), req.Id)
+		}
+		if err := checkVersion(req.Id, order, req.ExpectedVersion); err != nil {
+			return err
+		}
+		if err := tx.Delete(req.Id); err != nil {
+			return err
+		}
+		deleted = order
+		ts = s.tombstones.add(tenantOf(ctx), req.Id, req.Reason)
+		return nil
+	})
+	if err != nil {
+		// 事务没有提交，订单仍然存在，撤销已经添加的墓碑。
+		if ts != nil {
+			s.tombstones.remove(ts)
+		}
+		if _, ok := status.FromError(err); !ok {
+			err = status.Errorf(codes.Internal, "failed to delete order %s : %v", req.Id, err)
+		}
+		return nil, err
+	}
+	// 已删除订单的预留不再属于任何订单。
+	if s.inventory != nil {
+		s.inventory.detachOrder(deleted)
+	}
+	log.Printf("Order ID : %s - Deleted : %s", req.Id, req.Reason)
+	return &wrapper.StringValue{Value: "Order Deleted: " + req.Id}, nil
+}
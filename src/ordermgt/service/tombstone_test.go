@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	pb "ordermgt/service/ecommerce"
+)
+
+// testAdmin 是测试中通过元数据caller-id声明的管理员身份。
+const testAdmin = "order-admin"
+
+// testAdminPolicy 把testAdmin作为管理员，并接受元数据中声明的身份，bufconn上没有TLS。
+func testAdminPolicy() *adminPolicy {
+	return newAdminPolicy([]string{testAdmin}, true)
+}
+
+func asAdmin(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, callerIDKey, testAdmin)
+}
+
+func TestAdminPolicy(t *testing.T) {
+	withCert := func(ctx context.Context, cn string) context.Context {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		info := credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}}
+		return peer.NewContext(ctx, &peer.Peer{AuthInfo: info})
+	}
+	incoming := func(id string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs(callerIDKey, id))
+	}
+	strict, trusting := newAdminPolicy([]string{"root"}, false), newAdminPolicy([]string{"root"}, true)
+	tests := []struct {
+		name   string
+		policy *adminPolicy
+		ctx    context.Context
+		want   codes.Code
+	}{
+		{"anonymous", strict, context.Background(), codes.PermissionDenied},
+		{"certificate", strict, withCert(context.Background(), "root"), codes.OK},
+		{"other certificate", strict, withCert(incoming("root"), "alice"), codes.PermissionDenied},
+		// 没有校验过的caller-id默认不被当作管理员。
+		{"untrusted metadata", strict, incoming("root"), codes.PermissionDenied},
+		{"trusted metadata", trusting, incoming("root"), codes.OK},
+		{"trusted metadata, not an admin", trusting, incoming("alice"), codes.PermissionDenied},
+		{"no policy", nil, withCert(context.Background(), "root"), codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		if got := status.Code(tt.policy.check(tt.ctx, "deleteOrder")); got != tt.want {
+			t.Errorf("%s: check returned %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestServer_CancelOrder(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	client, stop := startBufConnServer(t, newServer(store))
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := client.CancelOrder(ctx, &pb.CancelOrderRequest{Id: "104"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("CancelOrder without reason returned %v, want InvalidArgument", err)
+	}
+	ord, err := client.CancelOrder(ctx, &pb.CancelOrderRequest{Id: "104", Reason: "customer request"})
+	if err != nil {
+		t.Fatalf("CancelOrder(104) failed: %v", err)
+	}
+	if ord.Status != pb.OrderStatus_CANCELLED || ord.CancelReason != "customer request" {
+		t.Fatalf("cancelled order = %v", ord)
+	}
+	if _, err := client.CancelOrder(ctx, &pb.CancelOrderRequest{Id: "104", Reason: "again"}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("cancelling a cancelled order returned %v, want FailedPrecondition", err)
+	}
+
+	// searchOrders默认跳过已取消的订单，明确指定CANCELLED时才返回。
+	orders, _, _ := searchOrders(store, &pb.SearchOrdersRequest{Item: "Google"})
+	if got := fmt.Sprint(orderIDList(orders)); got != "[102]" {
+		t.Fatalf("default search returned %s, want [102]", got)
+	}
+	orders, _, _ = searchOrders(store, &pb.SearchOrdersRequest{Item: "Google", Statuses: []pb.OrderStatus{pb.OrderStatus_CANCELLED}})
+	if got := fmt.Sprint(orderIDList(orders)); got != "[104]" {
+		t.Fatalf("search for cancelled orders returned %s, want [104]", got)
+	}
+
+	// processOrders不会把已取消的订单加入发货组合。
+	stream, err := client.ProcessOrders(ctx)
+	if err != nil {
+		t.Fatalf("ProcessOrders failed: %v", err)
+	}
+	stream.Send(&wrapper.StringValue{Value: "104"})
+	res, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if code := status.FromProto(res.GetResult().GetError()).Code(); code != codes.FailedPrecondition {
+		t.Fatalf("processing a cancelled order returned %s, want FailedPrecondition", code)
+	}
+	stream.CloseSend()
+}
+
+func deletionDescription(err error) string {
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*epb.ResourceInfo); ok {
+			return info.Description
+		}
+	}
+	return ""
+}
+
+func TestServer_DeleteOrderTombstone(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	initSampleData(store)
+	srv := newServer(store)
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv.tombstones = newOrderTombstones(time.Hour)
+	srv.tombstones.now = func() time.Time { return now }
+	srv.admins = testAdminPolicy()
+	client, stop := startTenantServer(t, srv)
+	defer stop()
+	user, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ctx := asAdmin(user)
+
+	// 只有管理员可以删除订单。
+	if _, err := client.DeleteOrder(user, &pb.DeleteOrderRequest{Id: "103"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("DeleteOrder as a user returned %v, want PermissionDenied", err)
+	}
+	if _, err := client.DeleteOrder(ctx, &pb.DeleteOrderRequest{Id: "103", ExpectedVersion: 5}); status.Code(err) != codes.Aborted {
+		t.Fatalf("DeleteOrder with stale version returned %v, want Aborted", err)
+	}
+	if _, err := client.DeleteOrder(ctx, &pb.DeleteOrderRequest{Id: "103", Reason: "duplicate order"}); err != nil {
+		t.Fatalf("DeleteOrder(103) failed: %v", err)
+	}
+	if _, ok := store.Get("103"); ok {
+		t.Fatalf("order 103 still stored after DeleteOrder")
+	}
+
+	_, err := client.GetOrder(ctx, &wrapper.StringValue{Value: "103"})
+	if status.Code(err) != codes.NotFound || !strings.Contains(deletionDescription(err), "duplicate order") {
+		t.Fatalf("GetOrder(deleted) returned %v with detail %q", err, deletionDescription(err))
+	}
+	if _, err := client.DeleteOrder(ctx, &pb.DeleteOrderRequest{Id: "103"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("deleting a deleted order returned %v, want NotFound", err)
+	}
+	_, err = client.GetOrder(ctx, &wrapper.StringValue{Value: "999"})
+	if status.Code(err) != codes.NotFound || strings.Contains(deletionDescription(err), "Deleted") {
+		t.Fatalf("GetOrder(never existed) returned %v with detail %q", err, deletionDescription(err))
+	}
+	// 墓碑属于删除订单的租户，其他租户的同一个订单ID不受影响。
+	_, err = client.GetOrder(asTenant(ctx, "acme"), &wrapper.StringValue{Value: "103"})
+	if status.Code(err) != codes.NotFound || strings.Contains(deletionDescription(err), "Deleted") {
+		t.Fatalf("GetOrder(103) as acme returned %v with detail %q", err, deletionDescription(err))
+	}
+
+	// 保留期过后墓碑被清理，订单就像从未存在过。
+	now = now.Add(time.Hour)
+	_, err = client.GetOrder(ctx, &wrapper.StringValue{Value: "103"})
+	if status.Code(err) != codes.NotFound || strings.Contains(deletionDescription(err), "Deleted") {
+		t.Fatalf("GetOrder after retention returned %v with detail %q", err, deletionDescription(err))
+	}
+	if len(srv.tombstones.entries) != 0 {
+		t.Fatalf("expired tombstone was not purged")
+	}
+}
@@ -0,0 +1,503 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	pb "ordermgt/service/ecommerce"
+)
+
+const (
+	// exportChunkSize 是exportOrders每条消息中数据的大小。
+	exportChunkSize = 32 << 10
+	// maxImportRecords 是一次importOrders最多导入的记录数。所有订单在一个事务中写入，
+	// 事务在预写日志中是一条记录，限制记录数可以避免日志记录过大。
+	maxImportRecords = 50000
+)
+
+// csvColumns 是CSV文件的列。items是JSON字符串数组，amount是不带货币代码的十进制金额，
+// createTime是RFC 3339格式的时间。导入时列的顺序可以不同，也可以缺少id以外的列；version列被忽略。
+var csvColumns = []string{
+	"id", "items", "description", "destination", "currency", "amount",
+	"status", "createTime", "version", "cancelReason", "weightGrams", "priority",
+}
+
+// orderEncoder 把订单编码为导出文件中的一条记录。
+type orderEncoder interface {
+	encode(order *pb.Order) error
+	// flush 写出缓冲中的数据，在最后一条记录之后调用。
+	flush() error
+}
+
+func newOrderEncoder(format pb.OrderFileFormat, w io.Writer) (orderEncoder, error) {
+	switch format {
+	case pb.OrderFileFormat_JSONL:
+		return &jsonlOrderEncoder{w: bufio.NewWriterSize(w, exportChunkSize)}, nil
+	case pb.OrderFileFormat_CSV:
+		return &csvOrderEncoder{w: csv.NewWriter(bufio.NewWriterSize(w, exportChunkSize))}, nil
+	}
+	return nil, invalidFieldError("format", fmt.Sprintf("Unsupported file format %v", format))
+}
+
+// jsonlOrderEncoder 每行写入一个订单的JSON表示。
+type jsonlOrderEncoder struct {
+	w *bufio.Writer
+}
+
+func (e *jsonlOrderEncoder) encode(order *pb.Order) error {
+	line, err := protojson.Marshal(order)
+	if err != nil {
+		return err
+	}
+	if _, err := e.w.Write(line); err != nil {
+		return err
+	}
+	return e.w.WriteByte('\n')
+}
+
+func (e *jsonlOrderEncoder) flush() error { return e.w.Flush() }
+
+// csvOrderEncoder 先写入列名行，之后每行写入一个订单。
+type csvOrderEncoder struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (e *csvOrderEncoder) encode(order *pb.Order) error {
+	if !e.headerWritten {
+		if err := e.w.Write(csvColumns); err != nil {
+			return err
+		}
+		e.headerWritten = true
+	}
+	items, err := json.Marshal(order.Items)
+	if err != nil {
+		return err
+	}
+	var currency, amount, createTime string
+	if order.ExactPrice != nil {
+		currency, amount = order.ExactPrice.CurrencyCode, formatAmount(order.ExactPrice)
+	}
+	if order.CreateTime != nil {
+		createTime = order.CreateTime.AsTime().Format(time.RFC3339Nano)
+	}
+	return e.w.Write([]string{
+		order.Id, string(items), order.Description, order.Destination, currency, amount,
+		order.Status.String(), createTime, strconv.FormatInt(order.Version, 10), order.CancelReason,
+		strconv.FormatInt(order.WeightGrams, 10), order.Priority.String(),
+	})
+}
+
+func (e *csvOrderEncoder) flush() error {
+	if !e.headerWritten {
+		// 没有订单时也写入列名行，导出的文件总是可以再导入。
+		if err := e.w.Write(csvColumns); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// recordError 表示一条记录无法解析。解码器可以跳过这条记录继续读取下一条。
+type recordError struct {
+	err error
+}
+
+func (e *recordError) Error() string { return e.err.Error() }
+
+// orderDecoder 从导入文件中逐条读取订单。
+type orderDecoder interface {
+	// decode 返回下一条记录。记录无法解析时返回*recordError，读完所有记录时返回io.EOF，
+	// 其他错误表示无法继续读取。
+	decode() (*pb.Order, error)
+}
+
+func newOrderDecoder(format pb.OrderFileFormat, r io.Reader) (orderDecoder, error) {
+	switch format {
+	case pb.OrderFileFormat_JSONL:
+		return &jsonlOrderDecoder{r: bufio.NewReader(r)}, nil
+	case pb.OrderFileFormat_CSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		return &csvOrderDecoder{r: cr}, nil
+	}
+	return nil, invalidFieldError("options.format", fmt.Sprintf("Unsupported file format %v", format))
+}
+
+// jsonlOrderDecoder 每行读取一个订单，跳过空行。未知的字段是无效的记录。
+type jsonlOrderDecoder struct {
+	r *bufio.Reader
+}
+
+func (d *jsonlOrderDecoder) decode() (*pb.Order, error) {
+	for {
+		line, err := d.r.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return nil, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		order := &pb.Order{}
+		if err := protojson.Unmarshal(line, order); err != nil {
+			return nil, &recordError{err}
+		}
+		return order, nil
+	}
+}
+
+// csvOrderDecoder 按列名行中的列名读取每一行。
+type csvOrderDecoder struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func (d *csvOrderDecoder) readHeader() error {
+	header, err := d.r.Read()
+	if err == io.EOF {
+		return err
+	}
+	if err != nil {
+		return invalidFieldError("data", fmt.Sprintf("Invalid CSV header : %v", err))
+	}
+	known := make(map[string]bool)
+	for _, c := range csvColumns {
+		known[c] = true
+	}
+	d.columns = make(map[string]int)
+	for i, c := range header {
+		if !known[c] {
+			return invalidFieldError("data", fmt.Sprintf("Unknown CSV column %q", c))
+		}
+		if _, dup := d.columns[c]; dup {
+			return invalidFieldError("data", fmt.Sprintf("Duplicate CSV column %q", c))
+		}
+		d.columns[c] = i
+	}
+	if _, ok := d.columns["id"]; !ok {
+		return invalidFieldError("data", "CSV header has no id column")
+	}
+	return nil
+}
+
+func (d *csvOrderDecoder) decode() (*pb.Order, error) {
+	if d.columns == nil {
+		if err := d.readHeader(); err != nil {
+			return nil, err
+		}
+	}
+	row, err := d.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &recordError{err}
+		}
+		return nil, err
+	}
+	if len(row) != len(d.columns) {
+		return nil, &recordError{fmt.Errorf("record has %d fields, header has %d", len(row), len(d.columns))}
+	}
+	order, err := d.parseRow(row)
+	if err != nil {
+		return nil, &recordError{err}
+	}
+	return order, nil
+}
+
+func (d *csvOrderDecoder) parseRow(row []string) (*pb.Order, error) {
+	field := func(name string) string {
+		if i, ok := d.columns[name]; ok {
+			return row[i]
+		}
+		return ""
+	}
+	order := &pb.Order{
+		Id:           field("id"),
+		Description:  field("description"),
+		Destination:  field("destination"),
+		CancelReason: field("cancelReason"),
+	}
+	if items := field("items"); items != "" {
+		if err := json.Unmarshal([]byte(items), &order.Items); err != nil {
+			return nil, fmt.Errorf("invalid items : %v", err)
+		}
+	}
+	if amount := field("amount"); amount != "" {
+		currency := field("currency")
+		if currency == "" {
+			currency = defaultCurrency
+		}
+		m, err := parseMoney(currency, amount)
+		if err != nil {
+			return nil, err
+		}
+		order.ExactPrice = m
+	}
+	if s := field("status"); s != "" {
+		v, ok := pb.OrderStatus_value[s]
+		if !ok {
+			return nil, fmt.Errorf("invalid status %q", s)
+		}
+		order.Status = pb.OrderStatus(v)
+	}
+	if s := field("createTime"); s != "" {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid createTime : %v", err)
+		}
+		order.CreateTime = timestamppb.New(t)
+	}
+	if s := field("weightGrams"); s != "" {
+		grams, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weightGrams %q", s)
+		}
+		order.WeightGrams = grams
+	}
+	if s := field("priority"); s != "" {
+		v, ok := pb.OrderPriority_value[s]
+		if !ok {
+			return nil, fmt.Errorf("invalid priority %q", s)
+		}
+		order.Priority = pb.OrderPriority(v)
+	}
+	return order, nil
+}
+
+// exportStreamWriter 把写入的数据作为一条exportOrders消息发送。
+type exportStreamWriter struct {
+	stream pb.OrderManagement_ExportOrdersServer
+}
+
+func (w exportStreamWriter) Write(p []byte) (int, error) {
+	// Send返回后p可能被重用，因此发送副本。
+	if err := w.stream.Send(&pb.ExportOrdersResponse{Data: append([]byte(nil), p...)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Server-side Streaming RPC
+// ExportOrders 按订单ID顺序导出调用方租户的所有订单。数据先写入缓冲，缓冲满时作为一条消息发送。
+func (s *server) ExportOrders(req *pb.ExportOrdersRequest, stream pb.OrderManagement_ExportOrdersServer) error {
+	enc, err := newOrderEncoder(req.Format, exportStreamWriter{stream})
+	if err != nil {
+		return err
+	}
+	// 先复制所有订单，避免发送数据时阻塞存储。
+	var orders []*pb.Order
+	s.storeFor(stream.Context()).Scan(func(order *pb.Order) bool {
+		orders = append(orders, order)
+		return true
+	})
+	for _, order := range orders {
+		if err := enc.encode(order); err != nil {
+			return exportError(err)
+		}
+	}
+	if err := enc.flush(); err != nil {
+		return exportError(err)
+	}
+	log.Printf("Exported %d orders as %v", len(orders), req.Format)
+	return nil
+}
+
+// exportError 保留发送数据时的gRPC错误，其他错误是编码失败。
+func exportError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Errorf(codes.Internal, "failed to encode orders : %v", err)
+}
+
+// importStreamReader 把importOrders消息中的数据连接成一个io.Reader。
+type importStreamReader struct {
+	stream pb.OrderManagement_ImportOrdersServer
+	buf    []byte
+}
+
+func (r *importStreamReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		data, ok := req.Request.(*pb.ImportOrdersRequest_Data)
+		if !ok {
+			return 0, invalidFieldError("options", "Import options must only be sent in the first message")
+		}
+		r.buf = data.Data
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// importRecord 是通过校验的一条记录。
+type importRecord struct {
+	index int32
+	order *pb.Order
+}
+
+// validateImportedOrder 检查导入的订单并补全价格和创建时间。订单的状态和创建时间保持文件中的值。
+func validateImportedOrder(order *pb.Order) error {
+	if order.Id == "" {
+		return invalidFieldError("id", "Order ID must not be empty")
+	}
+	if err := normalizeOrderPrice(order); err != nil {
+		return err
+	}
+	if _, ok := pb.OrderStatus_name[int32(order.Status)]; !ok {
+		return invalidFieldError("status", fmt.Sprintf("Unknown order status %d", order.Status))
+	}
+	if _, ok := pb.OrderPriority_name[int32(order.Priority)]; !ok {
+		return invalidFieldError("priority", fmt.Sprintf("Unknown order priority %d", order.Priority))
+	}
+	if order.WeightGrams < 0 {
+		return invalidFieldError("weightGrams", "Weight must not be negative")
+	}
+	if order.CreateTime == nil {
+		order.CreateTime = timestamppb.Now()
+	} else if err := order.CreateTime.CheckValid(); err != nil {
+		return invalidFieldError("createTime", err.Error())
+	}
+	// 版本由存储维护。
+	order.Version = 0
+	return nil
+}
+
+// importOrders 校验所有记录，并按照冲突策略在一个事务中写入订单。
+// CONFLICT_FAIL策略下存在任何无效或冲突的记录时不写入任何订单，dryRun时只生成汇总报告。
+func importOrders(store OrderStore, opts *pb.ImportOptions, records []importRecord, summary *pb.ImportOrdersSummary) error {
+	ids := make([]string, len(records))
+	for i, rec := range records {
+		ids[i] = rec.order.Id
+	}
+	return store.Txn(ids, func(tx OrderTxn) error {
+		var writes []*pb.Order
+		for _, rec := range records {
+			if _, exists := tx.Get(rec.order.Id); !exists {
+				summary.Created++
+				writes = append(writes, rec.order)
+				continue
+			}
+			if opts.ConflictPolicy == pb.ImportConflictPolicy_CONFLICT_OVERWRITE {
+				summary.Overwritten++
+				writes = append(writes, rec.order)
+				continue
+			}
+			summary.Skipped++
+			summary.Errors = append(summary.Errors, &pb.ImportError{
+				Record:  rec.index,
+				OrderId: rec.order.Id,
+				Status:  status.Newf(codes.AlreadyExists, "Order already exists. : %s", rec.order.Id).Proto(),
+			})
+		}
+		if opts.DryRun || (opts.ConflictPolicy == pb.ImportConflictPolicy_CONFLICT_FAIL && len(summary.Errors) > 0) {
+			return nil
+		}
+		for _, order := range writes {
+			if err := tx.Put(order); err != nil {
+				return err
+			}
+		}
+		summary.Committed = true
+		return nil
+	})
+}
+
+// Client-side Streaming RPC
+// ImportOrders 读取客户端上传的文件，校验所有记录之后再写入订单，返回导入的汇总报告。
+// 单条记录无效不会中断导入，原因记录在汇总报告的errors中。
+func (s *server) ImportOrders(stream pb.OrderManagement_ImportOrdersServer) error {
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return invalidFieldError("options", "Import options must be sent in the first message")
+	}
+	if err != nil {
+		return err
+	}
+	opts := first.GetOptions()
+	if opts == nil {
+		return invalidFieldError("options", "Import options must be sent in the first message")
+	}
+	if _, ok := pb.ImportConflictPolicy_name[int32(opts.ConflictPolicy)]; !ok {
+		return invalidFieldError("options.conflictPolicy", fmt.Sprintf("Unknown conflict policy %v", opts.ConflictPolicy))
+	}
+	dec, err := newOrderDecoder(opts.Format, &importStreamReader{stream: stream})
+	if err != nil {
+		return err
+	}
+
+	summary := &pb.ImportOrdersSummary{}
+	var records []importRecord
+	seen := make(map[string]int32)
+	recordFailed := func(index int32, id string, err error) {
+		summary.Failed++
+		summary.Errors = append(summary.Errors, &pb.ImportError{Record: index, OrderId: id, Status: status.Convert(err).Proto()})
+	}
+	for index := int32(1); ; index++ {
+		order, err := dec.decode()
+		if err == io.EOF {
+			break
+		}
+		var recErr *recordError
+		if err != nil && !errors.As(err, &recErr) {
+			if _, ok := status.FromError(err); !ok {
+				err = status.Errorf(codes.InvalidArgument, "failed to read import data : %v", err)
+			}
+			return err
+		}
+		if summary.Received++; summary.Received > maxImportRecords {
+			return status.Errorf(codes.ResourceExhausted, "Import is limited to %d records, split the file", maxImportRecords)
+		}
+		if recErr != nil {
+			recordFailed(index, "", status.Errorf(codes.InvalidArgument, "Invalid record : %v", recErr))
+			continue
+		}
+		if err := validateImportedOrder(order); err != nil {
+			recordFailed(index, order.Id, err)
+			continue
+		}
+		// 与addOrder一样通过ProductInfo解析条目，价格使用目录价格。ProductInfo不可用时无法校验任何记录，中止导入。
+		if err := s.resolveItems(stream.Context(), order); err != nil {
+			if status.Code(err) != codes.InvalidArgument {
+				return err
+			}
+			recordFailed(index, order.Id, err)
+			continue
+		}
+		if first, dup := seen[order.Id]; dup {
+			recordFailed(index, order.Id, invalidFieldError("id", fmt.Sprintf("Order ID %s already appears in record %d", order.Id, first)))
+			continue
+		}
+		seen[order.Id] = index
+		records = append(records, importRecord{index: index, order: order})
+	}
+
+	if err := importOrders(s.storeFor(stream.Context()), opts, records, summary); err != nil {
+		if _, ok := status.FromError(err); !ok {
+			err = status.Errorf(codes.Internal, "failed to store imported orders : %v", err)
+		}
+		return err
+	}
+	sort.SliceStable(summary.Errors, func(i, j int) bool { return summary.Errors[i].Record < summary.Errors[j].Record })
+	log.Printf("Imported orders : received %d, created %d, overwritten %d, skipped %d, failed %d, committed %v",
+		summary.Received, summary.Created, summary.Overwritten, summary.Skipped, summary.Failed, summary.Committed)
+	return stream.SendAndClose(summary)
+}
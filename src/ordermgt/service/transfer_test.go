@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	pb "ordermgt/service/ecommerce"
+)
+
+// exportAll 调用exportOrders并拼接所有数据。
+func exportAll(t *testing.T, client pb.OrderManagementClient, format pb.OrderFileFormat) []byte {
+	t.Helper()
+	stream, err := client.ExportOrders(context.Background(), &pb.ExportOrdersRequest{Format: format})
+	if err != nil {
+		t.Fatalf("ExportOrders failed: %v", err)
+	}
+	var data []byte
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return data
+		}
+		if err != nil {
+			t.Fatalf("ExportOrders failed: %v", err)
+		}
+		data = append(data, res.Data...)
+	}
+}
+
+// importData 调用importOrders，把data按chunkSize分段发送。
+func importData(client pb.OrderManagementClient, opts *pb.ImportOptions, data []byte, chunkSize int) (*pb.ImportOrdersSummary, error) {
+	stream, err := client.ImportOrders(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(&pb.ImportOrdersRequest{Request: &pb.ImportOrdersRequest_Options{Options: opts}}); err != nil {
+		return nil, err
+	}
+	for len(data) > 0 {
+		n := chunkSize
+		if n > len(data) {
+			n = len(data)
+		}
+		if err := stream.Send(&pb.ImportOrdersRequest{Request: &pb.ImportOrdersRequest_Data{Data: data[:n]}}); err != nil {
+			return nil, err
+		}
+		data = data[n:]
+	}
+	return stream.CloseAndRecv()
+}
+
+// summaryCounts 以"received/created/overwritten/skipped/failed committed"的形式概括汇总报告。
+func summaryCounts(s *pb.ImportOrdersSummary) string {
+	counts := fmt.Sprintf("%d/%d/%d/%d/%d", s.Received, s.Created, s.Overwritten, s.Skipped, s.Failed)
+	if s.Committed {
+		counts += " committed"
+	}
+	return counts
+}
+
+// 导出的文件导入到空的服务中得到相同的订单，数据可以在任意位置分段。
+func TestServer_ExportImportRoundTrip(t *testing.T) {
+	for _, format := range []pb.OrderFileFormat{pb.OrderFileFormat_JSONL, pb.OrderFileFormat_CSV} {
+		src := newMemoryOrderStore(defaultShardCount)
+		initSampleData(src)
+		cancelOrder(src, "105", "customer request", 0)
+		order := &pb.Order{Id: "107", Items: []string{"a,\"b\"", "c"}, Description: "multi\nline", Destination: "Paris",
+			ExactPrice: &pb.Money{CurrencyCode: "EUR", Units: 12, Nanos: 340000000}, WeightGrams: 250, Priority: pb.OrderPriority_EXPRESS}
+		normalizeOrderPrice(order)
+		src.Put(order)
+		srcClient, stopSrc := startBufConnServer(t, newServer(src))
+		data := exportAll(t, srcClient, format)
+		stopSrc()
+
+		dst := newMemoryOrderStore(defaultShardCount)
+		dstClient, stopDst := startBufConnServer(t, newServer(dst))
+		summary, err := importData(dstClient, &pb.ImportOptions{Format: format}, data, 7)
+		stopDst()
+		if err != nil {
+			t.Fatalf("%v: ImportOrders failed: %v", format, err)
+		}
+		if got, want := summaryCounts(summary), "6/6/0/0/0 committed"; got != want {
+			t.Fatalf("%v: summary = %s, want %s (errors %v)", format, got, want, summary.Errors)
+		}
+		src.Scan(func(want *pb.Order) bool {
+			got, _ := dst.Get(want.Id)
+			if want.CreateTime == nil {
+				// 直接写入存储的订单没有创建时间，导入时补全。
+				want.CreateTime = got.GetCreateTime()
+			}
+			// 版本由目标存储重新分配。
+			want.Version = got.GetVersion()
+			if !proto.Equal(got, want) {
+				t.Errorf("%v: imported order %v, want %v", format, got, want)
+			}
+			return true
+		})
+	}
+}
+
+func TestServer_ExportOrdersCSV(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	store.Put(&pb.Order{Id: "1", Items: []string{"Pixel"}, Destination: "MV", ExactPrice: usd("10.5")})
+	client, stop := startBufConnServer(t, newServer(store))
+	defer stop()
+
+	want := "id,items,description,destination,currency,amount,status,createTime,version,cancelReason,weightGrams,priority\n" +
+		"1,\"[\"\"Pixel\"\"]\",,MV,USD,10.50,PENDING,,1,,0,STANDARD\n"
+	if got := string(exportAll(t, client, pb.OrderFileFormat_CSV)); got != want {
+		t.Fatalf("export = %q, want %q", got, want)
+	}
+}
+
+func TestServer_ImportOrdersConflictPolicies(t *testing.T) {
+	data := []byte(`{"id":"102","description":"imported","price":1}
+{"id":"200","description":"new","price":2}
+`)
+	tests := []struct {
+		policy  pb.ImportConflictPolicy
+		dryRun  bool
+		want    string
+		want102 string
+		has200  bool
+	}{
+		{pb.ImportConflictPolicy_CONFLICT_FAIL, false, "2/1/0/1/0", "", false},
+		{pb.ImportConflictPolicy_CONFLICT_SKIP, false, "2/1/0/1/0 committed", "", true},
+		{pb.ImportConflictPolicy_CONFLICT_OVERWRITE, false, "2/1/1/0/0 committed", "imported", true},
+		{pb.ImportConflictPolicy_CONFLICT_OVERWRITE, true, "2/1/1/0/0", "", false},
+	}
+	for _, tt := range tests {
+		store := newMemoryOrderStore(defaultShardCount)
+		initSampleData(store)
+		client, stop := startBufConnServer(t, newServer(store))
+		summary, err := importData(client, &pb.ImportOptions{ConflictPolicy: tt.policy, DryRun: tt.dryRun}, data, 1<<10)
+		stop()
+		if err != nil {
+			t.Fatalf("%v: ImportOrders failed: %v", tt.policy, err)
+		}
+		if got := summaryCounts(summary); got != tt.want {
+			t.Errorf("%v dryRun=%v: summary = %s, want %s", tt.policy, tt.dryRun, got, tt.want)
+		}
+		if order, _ := store.Get("102"); order.Description != tt.want102 {
+			t.Errorf("%v dryRun=%v: order 102 = %q, want %q", tt.policy, tt.dryRun, order.Description, tt.want102)
+		}
+		if _, ok := store.Get("200"); ok != tt.has200 {
+			t.Errorf("%v dryRun=%v: order 200 stored = %v", tt.policy, tt.dryRun, ok)
+		}
+		if tt.policy != pb.ImportConflictPolicy_CONFLICT_OVERWRITE {
+			if len(summary.Errors) != 1 || summary.Errors[0].Record != 1 || summary.Errors[0].Status.Code != int32(codes.AlreadyExists) {
+				t.Errorf("%v: errors = %v", tt.policy, summary.Errors)
+			}
+		}
+	}
+}
+
+// 无效的记录不会中断导入，错误中记录了记录的序号。
+func TestServer_ImportOrdersInvalidRecords(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	client, stop := startBufConnServer(t, newServer(store))
+	defer stop()
+
+	data := []byte("id,amount,status\n" +
+		"1,10,PENDING\n" +
+		",5,PENDING\n" +
+		"3,abc,PENDING\n" +
+		"4,1,LOST\n" +
+		"1,2,PENDING\n" +
+		"6,1\n" +
+		"7,3.25,SHIPPED\n")
+	summary, err := importData(client, &pb.ImportOptions{Format: pb.OrderFileFormat_CSV, ConflictPolicy: pb.ImportConflictPolicy_CONFLICT_SKIP}, data, 5)
+	if err != nil {
+		t.Fatalf("ImportOrders failed: %v", err)
+	}
+	if got, want := summaryCounts(summary), "7/2/0/0/5 committed"; got != want {
+		t.Fatalf("summary = %s, want %s", got, want)
+	}
+	var records []int32
+	for _, e := range summary.Errors {
+		if e.Status.Code != int32(codes.InvalidArgument) {
+			t.Errorf("record %d: status %v", e.Record, e.Status)
+		}
+		records = append(records, e.Record)
+	}
+	if got, want := records, []int32{2, 3, 4, 5, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("failed records = %v, want %v", got, want)
+	}
+	if order, _ := store.Get("7"); order.Status != pb.OrderStatus_SHIPPED || formatMoney(order.ExactPrice) != "USD 3.25" {
+		t.Errorf("order 7 = %v", order)
+	}
+
+	// CONFLICT_FAIL策略下存在无效记录时不写入任何订单。
+	summary, err = importData(client, &pb.ImportOptions{Format: pb.OrderFileFormat_CSV}, []byte("id\n8\n\"9\n"), 64)
+	if err != nil {
+		t.Fatalf("ImportOrders failed: %v", err)
+	}
+	if _, ok := store.Get("8"); ok || summary.Committed || summary.Failed != 1 {
+		t.Errorf("invalid record did not abort import: %v", summary)
+	}
+}
+
+func TestServer_ImportOrdersRequiresOptions(t *testing.T) {
+	client, stop := startBufConnServer(t, newServer(newMemoryOrderStore(defaultShardCount)))
+	defer stop()
+
+	stream, _ := client.ImportOrders(context.Background())
+	stream.Send(&pb.ImportOrdersRequest{Request: &pb.ImportOrdersRequest_Data{Data: []byte("{}")}})
+	if _, err := stream.CloseAndRecv(); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("import without options: %v", err)
+	}
+	if _, err := importData(client, &pb.ImportOptions{Format: pb.OrderFileFormat_CSV}, []byte("id,color\n1,red\n"), 64); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("import with unknown CSV column: %v", err)
+	}
+}
+
+// 导出的数据按exportChunkSize分成多条消息。
+func TestServer_ExportOrdersChunks(t *testing.T) {
+	store := newMemoryOrderStore(defaultShardCount)
+	description := strings.Repeat("x", 1000)
+	for i := 0; i < 100; i++ {
+		store.Put(&pb.Order{Id: string(rune('A'+i/26)) + string(rune('a'+i%26)), Description: description})
+	}
+	client, stop := startBufConnServer(t, newServer(store))
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.ExportOrders(ctx, &pb.ExportOrdersRequest{})
+	if err != nil {
+		t.Fatalf("ExportOrders failed: %v", err)
+	}
+	var messages, lines int
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ExportOrders failed: %v", err)
+		}
+		if len(res.Data) > exportChunkSize {
+			t.Fatalf("message with %d bytes", len(res.Data))
+		}
+		messages++
+		lines += bytes.Count(res.Data, []byte("\n"))
+	}
+	if messages < 3 || lines != 100 {
+		t.Fatalf("received %d messages with %d lines", messages, lines)
+	}
+}
@@ -0,0 +1,626 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	pb "ordermgt/service/ecommerce"
+)
+
+const (
+	walFileName          = "orders.wal"
+	snapshotFileName     = "orders.snapshot"
+	defaultSnapshotEvery = 1000
+
+	walOpPut    byte = 1
+	walOpDelete byte = 2
+	// walOpEvent 是写入发件箱的领域事件，walOpAck 是发件箱的确认位置。
+	walOpEvent byte = 3
+	walOpAck   byte = 4
+
+	// 每条记录的帧头：4字节的负载长度和4字节的CRC32校验和（小端序）。
+	frameHeaderSize = 8
+	maxFrameSize    = 64 << 20
+)
+
+var (
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+	// errCorruptFrame 表示读到了不完整或校验失败的记录，通常是写入过程中崩溃留下的。
+	errCorruptFrame = errors.New("corrupt or truncated record")
+)
+
+// fileOrderStore 是基于本地文件系统的持久化OrderStore。
+// 每次变更先追加到预写日志（WAL）并fsync，成功后才应用到内存中并返回给调用方；
+// 每写入snapshotEvery条记录，就把内存状态压缩成快照并清空日志。
+// 启动时先加载快照，再重放日志，丢弃末尾因崩溃而残缺的记录。
+// 事务在内存存储的分片事务锁内写入日志，同一个订单的事务按提交顺序写入；读取不等待日志写入。
+// 不同订单的事务同时提交时，记录排队由一个写入者一次写入并fsync（组提交），而不是每个事务fsync一次。
+// 启用发件箱后，事务生成的领域事件与订单变更写在同一条日志记录中，二者要么都持久化，要么都没有；
+// 快照不包含事件，压缩日志时未确认的事件被写入新日志的第一条记录。
+type fileOrderStore struct {
+	mem           *memoryOrderStore
+	dir           string
+	snapshotEvery int
+
+	// mu 保护下面的字段，写入日志和fsync时不持有。cond在一批记录写入完成、记录应用到内存或者快照完成时广播。
+	mu      sync.Mutex
+	cond    *sync.Cond
+	wal     *os.File
+	walSize int64 // 日志中完整记录的总字节数
+	records int   // 自上次快照以来写入日志的记录数
+	// queue 是等待写入日志的记录，flushing为true时有一个写入者正在写入和fsync日志，
+	// snapshotting为true时正在写快照和替换日志，这期间不写入新的记录。
+	queue        []*walWrite
+	flushing     bool
+	snapshotting bool
+	// applying 是已经写入日志的记录，按日志中的顺序排列。前面的记录都应用到内存之后，记录中的事件才加入发件箱，
+	// 发件箱中的事件因此按序号排列，并且在对应的订单变更可见之后才可见。
+	applying []*walWrite
+	// sequence 是最后分配的事件序号，包括还没有写入日志的事件。
+	sequence int64
+
+	// outbox 总是从日志中重建，emitting 为true时事务才生成新的事件。
+	outbox   *outbox
+	emitting bool
+}
+
+// walWrite 是一条等待组提交的日志记录。
+type walWrite struct {
+	frame  []byte
+	events []*pb.DomainEvent
+	acked  int64
+	// done 在记录写入日志或写入失败之后为true，err是写入的结果。
+	done bool
+	err  error
+	// applied 在记录中的订单变更应用到内存之后为true。
+	applied bool
+}
+
+func openFileOrderStore(dir string, snapshotEvery int) (*fileOrderStore, error) {
+	if snapshotEvery <= 0 {
+		snapshotEvery = defaultSnapshotEvery
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &fileOrderStore{
+		mem:           newMemoryOrderStore(defaultShardCount),
+		dir:           dir,
+		snapshotEvery: snapshotEvery,
+		outbox:        newOutbox(),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	if err := s.loadSnapshot(); err != nil {
+		return nil, fmt.Errorf("loading snapshot: %v", err)
+	}
+	if err := s.replayWAL(); err != nil {
+		return nil, fmt.Errorf("replaying write-ahead log: %v", err)
+	}
+	s.sequence = s.outbox.lastSequence()
+	wal, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s.wal = wal
+	return s, nil
+}
+
+func (s *fileOrderStore) Get(id string) (*pb.Order, bool) {
+	return s.mem.Get(id)
+}
+
+func (s *fileOrderStore) Scan(fn func(order *pb.Order) bool) {
+	s.mem.Scan(fn)
+}
+
+func (s *fileOrderStore) Put(order *pb.Order) error {
+	return s.Txn([]string{keyOf(order)}, func(tx OrderTxn) error {
+		return tx.Put(order)
+	})
+}
+
+func (s *fileOrderStore) Delete(id string) (bool, error) {
+	existed := false
+	err := s.Txn([]string{id}, func(tx OrderTxn) error {
+		if _, existed = tx.Get(id); !existed {
+			return nil
+		}
+		return tx.Delete(id)
+	})
+	return existed, err
+}
+
+func (s *fileOrderStore) Txn(keys []string, fn func(tx OrderTxn) error) error {
+	s.mu.Lock()
+	emitting := s.emitting
+	s.mu.Unlock()
+	err := s.mem.Txn(keys, func(tx OrderTxn) error {
+		before := make(map[string]*pb.Order)
+		if emitting {
+			for _, key := range keys {
+				if order, ok := tx.Get(key); ok {
+					before[key] = order
+				}
+			}
+		}
+		rec := &recordingTxn{OrderTxn: tx}
+		if err := fn(rec); err != nil {
+			return err
+		}
+		var events []*pb.DomainEvent
+		if emitting {
+			events = orderEvents(netChanges(before, rec.ops))
+		}
+		// 日志写入失败时返回错误，内存事务随之放弃，保证未持久化的变更和事件都不可见。
+		w, err := s.commit(walRecord{ops: rec.ops, events: events}, false)
+		if err != nil {
+			return err
+		}
+		if w != nil {
+			tx.AfterCommit(func() { s.applied(w) })
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.maybeSnapshot()
+	return nil
+}
+
+// commit 为记录中的事件分配序号，把记录写入日志并等待fsync完成，记录为空时返回nil。
+// 同时等待的记录由其中一个调用方一次写入（组提交）。applied为false时，调用方在把记录中的变更应用到内存之后
+// 调用s.applied，在这之前记录中的事件不会加入发件箱。
+func (s *fileOrderStore) commit(rec walRecord, applied bool) (*walWrite, error) {
+	if len(rec.ops) == 0 && len(rec.events) == 0 && rec.acked == 0 {
+		return nil, nil
+	}
+	// 订单变更在锁外编码；事件在锁内分配序号并入队，日志中的事件总是按序号排列。
+	payload, err := encodeWALRecord(walRecord{ops: rec.ops})
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sequenceLocked(rec.events)
+	tail, err := encodeWALRecord(walRecord{events: rec.events, acked: rec.acked})
+	if err != nil {
+		return nil, err
+	}
+	w := &walWrite{frame: encodeFrame(append(payload, tail...)), events: rec.events, acked: rec.acked, applied: applied}
+	s.queue = append(s.queue, w)
+	for !w.done {
+		if s.flushing || s.snapshotting {
+			s.cond.Wait()
+			continue
+		}
+		s.flushLocked()
+	}
+	return w, w.err
+}
+
+// flushLocked 把队列中的所有记录一次写入日志并fsync。写入期间释放s.mu，新的记录继续入队，由下一批写入。
+func (s *fileOrderStore) flushLocked() {
+	batch := s.queue
+	s.queue = nil
+	s.flushing = true
+	var buf []byte
+	for _, w := range batch {
+		buf = append(buf, w.frame...)
+	}
+	wal, size := s.wal, s.walSize
+	s.mu.Unlock()
+	_, err := wal.Write(buf)
+	if err == nil {
+		err = wal.Sync()
+	}
+	if err != nil {
+		// 去掉可能已经写入一半的记录，否则之后追加的记录在重放时会被一起丢弃。
+		wal.Truncate(size)
+	}
+	s.mu.Lock()
+	s.flushing = false
+	if err == nil {
+		s.walSize += int64(len(buf))
+		s.records += len(batch)
+	}
+	for _, w := range batch {
+		w.done, w.err = true, err
+		if err != nil {
+			continue
+		}
+		if w.acked > 0 {
+			s.outbox.ack(w.acked)
+		}
+		s.applying = append(s.applying, w)
+	}
+	s.publishLocked()
+	s.cond.Broadcast()
+}
+
+// applied 记录w中的变更已经应用到内存。
+func (s *fileOrderStore) applied(w *walWrite) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.applied = true
+	s.publishLocked()
+	s.cond.Broadcast()
+}
+
+// publishLocked 按日志顺序把已经应用到内存的记录中的事件加入发件箱。
+func (s *fileOrderStore) publishLocked() {
+	for len(s.applying) > 0 && s.applying[0].applied {
+		s.outbox.add(s.applying[0].events)
+		s.applying = s.applying[1:]
+	}
+}
+
+// maybeSnapshot 在日志中的记录数达到snapshotEvery时压缩日志。
+// 压缩期间不写入新的记录；已经写入日志的记录都应用到内存之后才写快照，快照因此包含被替换的日志中的所有变更。
+func (s *fileOrderStore) maybeSnapshot() {
+	s.mu.Lock()
+	if s.records < s.snapshotEvery || s.snapshotting {
+		s.mu.Unlock()
+		return
+	}
+	s.snapshotting = true
+	for s.flushing || len(s.applying) > 0 {
+		s.cond.Wait()
+	}
+	s.mu.Unlock()
+	err := s.snapshot()
+	s.mu.Lock()
+	s.snapshotting = false
+	s.cond.Broadcast()
+	s.mu.Unlock()
+	if err != nil {
+		// 快照失败不影响已经写入日志的变更，下次写入时会重试。
+		log.Printf("failed to snapshot orders : %v", err)
+	}
+}
+
+// sequenceLocked 为即将写入日志的事件分配序号。写入失败的事件的序号不会被重新使用。
+func (s *fileOrderStore) sequenceLocked(events []*pb.DomainEvent) {
+	for _, event := range events {
+		s.sequence++
+		event.Sequence = s.sequence
+	}
+}
+
+// enableOutbox 启用发件箱，之后提交的每个变更都生成领域事件。
+func (s *fileOrderStore) enableOutbox() orderOutbox {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.emitting = true
+	return s
+}
+
+// Emit 写入事件之后、调用s.applied之前调用apply，在这之前事件不会加入发件箱。
+func (s *fileOrderStore) Emit(event *pb.DomainEvent, apply func() error) error {
+	w, err := s.commit(walRecord{events: []*pb.DomainEvent{event}}, false)
+	if err != nil {
+		return err
+	}
+	if apply != nil {
+		err = apply()
+	}
+	s.applied(w)
+	s.maybeSnapshot()
+	return err
+}
+
+func (s *fileOrderStore) Pending(limit int) ([]*pb.DomainEvent, <-chan struct{}) {
+	return s.outbox.pending(limit)
+}
+
+// Ack 先把确认位置写入日志再移除事件，确认没有持久化时重启后事件会被再次投递。
+func (s *fileOrderStore) Ack(sequence int64) error {
+	if _, err := s.commit(walRecord{acked: sequence}, true); err != nil {
+		return err
+	}
+	s.maybeSnapshot()
+	return nil
+}
+
+// Close 等待正在进行的写入完成，然后关闭预写日志文件。
+func (s *fileOrderStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.flushing || s.snapshotting {
+		s.cond.Wait()
+	}
+	return s.wal.Close()
+}
+
+// snapshot 把当前内存状态写入临时文件，原子地重命名为快照，然后用只包含发件箱的新日志替换旧日志。
+// 如果在重命名快照之后、替换日志之前崩溃，重放旧日志得到的仍是相同的最终状态和相同的未确认事件。
+// 只在snapshotting为true时调用，这期间没有其他调用方访问日志文件。
+func (s *fileOrderStore) snapshot() error {
+	tmpPath := filepath.Join(s.dir, snapshotFileName+".tmp")
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	var writeErr error
+	s.mem.Scan(func(order *pb.Order) bool {
+		var data []byte
+		if data, writeErr = proto.Marshal(order); writeErr != nil {
+			return false
+		}
+		_, writeErr = w.Write(encodeFrame(data))
+		return writeErr == nil
+	})
+	if writeErr == nil {
+		writeErr = w.Flush()
+	}
+	if writeErr == nil {
+		writeErr = f.Sync()
+	}
+	if err := f.Close(); writeErr == nil {
+		writeErr = err
+	}
+	if writeErr != nil {
+		os.Remove(tmpPath)
+		return writeErr
+	}
+	if err := os.Rename(tmpPath, filepath.Join(s.dir, snapshotFileName)); err != nil {
+		return err
+	}
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+
+	return s.resetWAL()
+}
+
+// resetWAL 用只包含发件箱中未确认事件和确认位置的新日志替换当前日志。
+// 新日志先写入临时文件再重命名，重命名之前崩溃时旧日志仍然完整。
+func (s *fileOrderStore) resetWAL() error {
+	var frame []byte
+	if rec := s.outbox.carry(); len(rec.events) > 0 || rec.acked > 0 {
+		payload, err := encodeWALRecord(rec)
+		if err != nil {
+			return err
+		}
+		frame = encodeFrame(payload)
+	}
+	path := filepath.Join(s.dir, walFileName)
+	wal, err := os.OpenFile(path+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = wal.Write(frame)
+	if err == nil {
+		err = wal.Sync()
+	}
+	if err == nil {
+		err = os.Rename(wal.Name(), path)
+	}
+	if err != nil {
+		wal.Close()
+		os.Remove(wal.Name())
+		return err
+	}
+	// 重命名后旧的文件描述符指向已经被替换的日志，之后的记录追加到新日志的末尾。
+	s.wal.Close()
+	s.wal = wal
+	s.walSize = int64(len(frame))
+	s.records = 0
+	return syncDir(s.dir)
+}
+
+func (s *fileOrderStore) loadSnapshot() error {
+	f, err := os.Open(filepath.Join(s.dir, snapshotFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		payload, _, err := readFrame(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// 快照是先写临时文件再重命名的，所以这里的损坏不是崩溃造成的，不能静默忽略。
+			return err
+		}
+		order := &pb.Order{}
+		if err := proto.Unmarshal(payload, order); err != nil {
+			return err
+		}
+		s.mem.load(order)
+	}
+}
+
+func (s *fileOrderStore) replayWAL() error {
+	path := filepath.Join(s.dir, walFileName)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		payload, n, err := readFrame(r)
+		if err == io.EOF {
+			s.walSize = offset
+			return nil
+		}
+		if err == nil {
+			var rec walRecord
+			if rec, err = decodeWALRecord(payload); err == nil {
+				s.applyOps(rec.ops)
+				s.outbox.add(rec.events)
+				if rec.acked > 0 {
+					s.outbox.ack(rec.acked)
+				}
+				s.records++
+				offset += n
+				continue
+			}
+		}
+		// 末尾残缺的记录从未被确认给客户端，截断到最后一条完整记录之后即可。
+		log.Printf("discarding write-ahead log after offset %d : %v", offset, err)
+		s.walSize = offset
+		return os.Truncate(path, offset)
+	}
+}
+
+func (s *fileOrderStore) applyOps(ops []orderOp) {
+	for _, op := range ops {
+		if op.order == nil {
+			s.mem.Delete(op.id)
+		} else {
+			s.mem.load(op.order)
+		}
+	}
+}
+
+// walRecord 是日志中的一条记录：一个事务的订单变更、与变更一起写入发件箱的事件，以及发件箱的确认位置。
+type walRecord struct {
+	ops    []orderOp
+	events []*pb.DomainEvent
+	acked  int64
+}
+
+// encodeWALRecord 把一个事务的所有变更编码为一条记录：
+// 每个变更依次为1字节操作类型、uvarint长度和数据（Put为序列化的订单，Delete为订单的键），
+// 之后是序列化的事件和uvarint编码的确认位置。
+func encodeWALRecord(rec walRecord) ([]byte, error) {
+	var buf []byte
+	appendOp := func(opType byte, data []byte) {
+		var size [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(size[:], uint64(len(data)))
+		buf = append(buf, opType)
+		buf = append(buf, size[:n]...)
+		buf = append(buf, data...)
+	}
+	for _, op := range rec.ops {
+		if op.order == nil {
+			appendOp(walOpDelete, []byte(op.id))
+			continue
+		}
+		data, err := proto.Marshal(op.order)
+		if err != nil {
+			return nil, err
+		}
+		appendOp(walOpPut, data)
+	}
+	for _, event := range rec.events {
+		data, err := proto.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		appendOp(walOpEvent, data)
+	}
+	if rec.acked > 0 {
+		var acked [binary.MaxVarintLen64]byte
+		appendOp(walOpAck, acked[:binary.PutUvarint(acked[:], uint64(rec.acked))])
+	}
+	return buf, nil
+}
+
+func decodeWALRecord(payload []byte) (walRecord, error) {
+	var rec walRecord
+	for len(payload) > 0 {
+		opType := payload[0]
+		size, n := binary.Uvarint(payload[1:])
+		if n <= 0 || uint64(len(payload)-1-n) < size {
+			return walRecord{}, errCorruptFrame
+		}
+		data := payload[1+n : 1+n+int(size)]
+		payload = payload[1+n+int(size):]
+		switch opType {
+		case walOpPut:
+			order := &pb.Order{}
+			if err := proto.Unmarshal(data, order); err != nil {
+				return walRecord{}, err
+			}
+			rec.ops = append(rec.ops, orderOp{id: keyOf(order), order: order})
+		case walOpDelete:
+			rec.ops = append(rec.ops, orderOp{id: string(data)})
+		case walOpEvent:
+			event := &pb.DomainEvent{}
+			if err := proto.Unmarshal(data, event); err != nil {
+				return walRecord{}, err
+			}
+			rec.events = append(rec.events, event)
+		case walOpAck:
+			acked, n := binary.Uvarint(data)
+			if n <= 0 {
+				return walRecord{}, errCorruptFrame
+			}
+			rec.acked = int64(acked)
+		default:
+			return walRecord{}, fmt.Errorf("unknown write-ahead log operation %d", opType)
+		}
+	}
+	return rec, nil
+}
+
+func encodeFrame(payload []byte) []byte {
+	frame := make([]byte, frameHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	copy(frame[frameHeaderSize:], payload)
+	return frame
+}
+
+// readFrame 读取一帧，返回负载和该帧占用的字节数。
+// 恰好在帧边界处结束时返回io.EOF，帧不完整或校验失败时返回errCorruptFrame。
+func readFrame(r io.Reader) ([]byte, int64, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return nil, 0, io.EOF
+		}
+		if err == io.ErrUnexpectedEOF {
+			return nil, 0, errCorruptFrame
+		}
+		return nil, 0, err
+	}
+	size := binary.LittleEndian.Uint32(header[0:4])
+	if size > maxFrameSize {
+		return nil, 0, errCorruptFrame
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, 0, errCorruptFrame
+		}
+		return nil, 0, err
+	}
+	if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(header[4:8]) {
+		return nil, 0, errCorruptFrame
+	}
+	return payload, int64(frameHeaderSize) + int64(size), nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	pb "ordermgt/service/ecommerce"
+)
+
+func openTestFileStore(t *testing.T, dir string, snapshotEvery int) *fileOrderStore {
+	t.Helper()
+	store, err := openFileOrderStore(dir, snapshotEvery)
+	if err != nil {
+		t.Fatalf("openFileOrderStore(%s) failed: %v", dir, err)
+	}
+	return store
+}
+
+func orderIDs(store OrderStore) string {
+	var ids []string
+	store.Scan(func(order *pb.Order) bool {
+		ids = append(ids, order.Id)
+		return true
+	})
+	return fmt.Sprint(ids)
+}
+
+func TestFileOrderStore_Recover(t *testing.T) {
+	dir := t.TempDir()
+	store := openTestFileStore(t, dir, 100)
+	store.Put(&pb.Order{Id: "102", Items: []string{"Google Pixel 3A"}, Destination: "Mountain View, CA"})
+	store.Put(&pb.Order{Id: "103", Items: []string{"Apple Watch S4"}, Destination: "San Jose, CA"})
+	store.Put(&pb.Order{Id: "104", Items: []string{"Google Home Mini"}, Destination: "Mountain View, CA"})
+	store.Put(&pb.Order{Id: "103", Items: []string{"Apple Watch S4", "iPad Pro"}, Destination: "San Jose, CA"})
+	store.Delete("104")
+	store.Close()
+
+	store = openTestFileStore(t, dir, 100)
+	defer store.Close()
+	if got := orderIDs(store); got != "[102 103]" {
+		t.Fatalf("recovered orders %s, want [102 103]", got)
+	}
+	if ord, _ := store.Get("103"); len(ord.Items) != 2 || ord.Version != 2 {
+		t.Fatalf("recovered order 103 = %v, want the updated items at version 2", ord)
+	}
+}
+
+func TestFileOrderStore_SnapshotCompaction(t *testing.T) {
+	dir := t.TempDir()
+	store := openTestFileStore(t, dir, 3)
+	for i := 0; i < 7; i++ {
+		store.Put(&pb.Order{Id: fmt.Sprintf("%d", 100+i)})
+	}
+	store.Delete("100")
+	store.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, snapshotFileName)); err != nil {
+		t.Fatalf("snapshot was not written: %v", err)
+	}
+	// 8条记录，每3条压缩一次，日志中只应剩下最后2条记录。
+	if store.records != 2 {
+		t.Fatalf("write-ahead log holds %d records after compaction, want 2", store.records)
+	}
+
+	store = openTestFileStore(t, dir, 3)
+	defer store.Close()
+	if got := orderIDs(store); got != "[101 102 103 104 105 106]" {
+		t.Fatalf("recovered orders %s", got)
+	}
+}
+
+// 在最后一条记录中间的任意位置截断日志，模拟写入时崩溃。
+// 恢复后必须保留之前的所有记录、丢弃残缺的记录，并且之后的追加仍能被正确恢复。
+func TestFileOrderStore_TruncatedRecord(t *testing.T) {
+	base := t.TempDir()
+	src := filepath.Join(base, "src")
+	store := openTestFileStore(t, src, 100)
+	store.Put(&pb.Order{Id: "102", Destination: "Mountain View, CA"})
+	store.Put(&pb.Order{Id: "103", Destination: "San Jose, CA"})
+	lastStart := store.walSize
+	store.Put(&pb.Order{Id: "104", Items: []string{"Google Home Mini", "Google Nest Hub"}, Destination: "Mountain View, CA"})
+	store.Close()
+
+	wal, err := ioutil.ReadFile(filepath.Join(src, walFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for cut := lastStart + 1; cut < int64(len(wal)); cut++ {
+		dir := filepath.Join(base, fmt.Sprintf("cut-%d", cut))
+		os.MkdirAll(dir, 0755)
+		if err := ioutil.WriteFile(filepath.Join(dir, walFileName), wal[:cut], 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		store := openTestFileStore(t, dir, 100)
+		if got := orderIDs(store); got != "[102 103]" {
+			t.Fatalf("cut at %d: recovered orders %s, want [102 103]", cut, got)
+		}
+		store.Put(&pb.Order{Id: "105"})
+		store.Close()
+
+		store = openTestFileStore(t, dir, 100)
+		if got := orderIDs(store); got != "[102 103 105]" {
+			t.Fatalf("cut at %d: orders after append and reopen %s, want [102 103 105]", cut, got)
+		}
+		store.Close()
+	}
+}
+
+func TestFileOrderStore_CorruptChecksum(t *testing.T) {
+	dir := t.TempDir()
+	store := openTestFileStore(t, dir, 100)
+	store.Put(&pb.Order{Id: "102"})
+	store.Put(&pb.Order{Id: "103"})
+	store.Close()
+
+	path := filepath.Join(dir, walFileName)
+	wal, _ := ioutil.ReadFile(path)
+	wal[len(wal)-1] ^= 0xff
+	ioutil.WriteFile(path, wal, 0644)
+
+	store = openTestFileStore(t, dir, 100)
+	defer store.Close()
+	if got := orderIDs(store); got != "[102]" {
+		t.Fatalf("recovered orders %s, want [102]", got)
+	}
+}
+
+func TestFileOrderStore_TxnAbortNotLogged(t *testing.T) {
+	dir := t.TempDir()
+	store := openTestFileStore(t, dir, 100)
+	store.Put(&pb.Order{Id: "102"})
+	store.Txn([]string{"102"}, func(tx OrderTxn) error {
+		tx.Delete("102")
+		return fmt.Errorf("abort")
+	})
+	store.Close()
+
+	store = openTestFileStore(t, dir, 100)
+	defer store.Close()
+	if got := orderIDs(store); got != "[102]" {
+		t.Fatalf("recovered orders %s, want [102]", got)
+	}
+}
+
+// 并发的事务组提交到日志中：不同订单的事务同时写入，事件按序号依次进入发件箱，
+// 快照和日志替换与写入交错进行，重启后订单和未确认的事件都完整。
+func TestFileOrderStore_GroupCommit(t *testing.T) {
+	dir := t.TempDir()
+	store := openTestFileStore(t, dir, 7)
+	store.enableOutbox()
+	const writers, perWriter = 8, 20
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if err := store.Put(&pb.Order{Id: fmt.Sprintf("%d-%02d", w, i)}); err != nil {
+					t.Errorf("Put failed: %v", err)
+				}
+				store.Get(fmt.Sprintf("%d-%02d", (w+1)%writers, i))
+			}
+		}(w)
+	}
+	wg.Wait()
+	checkEvents := func(store *fileOrderStore) {
+		t.Helper()
+		events, _ := store.Pending(0)
+		if len(events) != writers*perWriter {
+			t.Fatalf("%d events in the outbox, want %d", len(events), writers*perWriter)
+		}
+		for i, event := range events {
+			if event.Sequence != int64(i+1) {
+				t.Fatalf("event %d has sequence %d", i, event.Sequence)
+			}
+			if order, ok := store.Get(event.Order.Id); !ok || order.Version != 1 {
+				t.Fatalf("order of event %d = %v", event.Sequence, order)
+			}
+		}
+	}
+	checkEvents(store)
+	store.Close()
+
+	store = openTestFileStore(t, dir, 7)
+	defer store.Close()
+	store.enableOutbox()
+	checkEvents(store)
+}
+
+// 事务等待期间（例如等待日志写入）读取不被阻塞，读到的是事务之前的订单。
+func TestFileOrderStore_ReadDuringTxn(t *testing.T) {
+	store := openTestFileStore(t, t.TempDir(), 100)
+	defer store.Close()
+	store.Put(&pb.Order{Id: "102", Destination: "Mountain View, CA"})
+	inTxn, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- store.Txn([]string{"102"}, func(tx OrderTxn) error {
+			order, _ := tx.Get("102")
+			order.Destination = "San Jose, CA"
+			tx.Put(order)
+			close(inTxn)
+			<-release
+			return nil
+		})
+	}()
+	<-inTxn
+	read := make(chan *pb.Order)
+	go func() {
+		order, _ := store.Get("102")
+		read <- order
+	}()
+	select {
+	case order := <-read:
+		if order.Destination != "Mountain View, CA" {
+			t.Errorf("read uncommitted destination %s", order.Destination)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Get blocked by an open transaction")
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Txn failed: %v", err)
+	}
+	if order, _ := store.Get("102"); order.Destination != "San Jose, CA" || order.Version != 2 {
+		t.Fatalf("committed order = %v", order)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "ordermgt/service/ecommerce"
+)
+
+// defaultWatchHistory 是服务器端保留的最近变更事件数，重连的客户端只能补齐这个范围内的事件。
+const defaultWatchHistory = 4096
+
+// orderWatcher 由能够产生订单变更事件的存储实现，例如watchedOrderStore。
+type orderWatcher interface {
+	// Revision 返回最新事件的revision，还没有任何事件时为0。
+	Revision() int64
+	// Events 返回revision大于after的所有历史事件，以及一个在下一个事件产生时关闭的通道。
+	// after已经被移出历史缓冲区或者大于当前revision时返回OutOfRange。
+	Events(after int64) ([]*pb.OrderEvent, <-chan struct{}, error)
+	// Oldest 返回历史缓冲区中最早的事件的revision减1，从它之后开始订阅可以得到保留的全部事件。
+	Oldest() int64
+}
+
+// watchedOrderStore 在OrderStore之上为每次提交的变更生成带revision的事件，
+// 并把最近的事件保存在有界的环形缓冲区中。事件在事务提交之后、释放订单的锁之前（见OrderTxn.AfterCommit）追加，
+// mu只在分配revision和追加事件时持有，不同订单的事务可以并发提交；同一个订单的事件的顺序与提交顺序一致。
+// revision只保存在内存中，服务重启后从0开始。
+type watchedOrderStore struct {
+	OrderStore
+	mu       sync.Mutex
+	history  []*pb.OrderEvent
+	revision int64
+	notify   chan struct{}
+}
+
+func newWatchedOrderStore(store OrderStore, history int) *watchedOrderStore {
+	if history <= 0 {
+		history = defaultWatchHistory
+	}
+	return &watchedOrderStore{
+		OrderStore: store,
+		history:    make([]*pb.OrderEvent, history),
+		notify:     make(chan struct{}),
+	}
+}
+
+func (s *watchedOrderStore) Put(order *pb.Order) error {
+	return s.Txn([]string{keyOf(order)}, func(tx OrderTxn) error {
+		return tx.Put(order)
+	})
+}
+
+func (s *watchedOrderStore) Delete(id string) (bool, error) {
+	existed := false
+	err := s.Txn([]string{id}, func(tx OrderTxn) error {
+		if _, existed = tx.Get(id); !existed {
+			return nil
+		}
+		return tx.Delete(id)
+	})
+	return existed, err
+}
+
+func (s *watchedOrderStore) Txn(keys []string, fn func(tx OrderTxn) error) error {
+	return s.OrderStore.Txn(keys, func(tx OrderTxn) error {
+		before := make(map[string]*pb.Order)
+		for _, key := range keys {
+			if order, ok := tx.Get(key); ok {
+				before[key] = order
+			}
+		}
+		rec := &recordingTxn{OrderTxn: tx}
+		if err := fn(rec); err != nil {
+			return err
+		}
+		changes := netChanges(before, rec.ops)
+		tx.AfterCommit(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for _, change := range changes {
+				switch {
+				case change.after == nil && change.before != nil:
+					s.appendLocked(pb.OrderEventType_DELETED, change.before)
+				case change.after != nil && change.before != nil:
+					s.appendLocked(pb.OrderEventType_UPDATED, change.after)
+				case change.after != nil:
+					s.appendLocked(pb.OrderEventType_ADDED, change.after)
+				}
+			}
+		})
+		return nil
+	})
+}
+
+// Match 把文本查询转发给底层存储的索引，使外层包装不影响searchOrders使用索引。
+func (s *watchedOrderStore) Match(query string, fn func(order *pb.Order) bool) {
+	if matcher, ok := s.OrderStore.(orderMatcher); ok {
+		matcher.Match(query, fn)
+		return
+	}
+	s.Scan(func(order *pb.Order) bool {
+		if !matchesText(order, query) {
+			return true
+		}
+		return fn(order)
+	})
+}
+
+func (s *watchedOrderStore) appendLocked(eventType pb.OrderEventType, order *pb.Order) {
+	s.revision++
+	s.history[s.revision%int64(len(s.history))] = &pb.OrderEvent{Revision: s.revision, Type: eventType, Order: order}
+	// 关闭通道唤醒所有等待的订阅者，再换上一个新的通道等待下一个事件。
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+func (s *watchedOrderStore) Revision() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revision
+}
+
+func (s *watchedOrderStore) Oldest() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.oldestLocked() - 1
+}
+
+// oldestLocked 返回历史缓冲区中最早的事件的revision，还没有事件时为1。
+func (s *watchedOrderStore) oldestLocked() int64 {
+	oldest := s.revision - int64(len(s.history)) + 1
+	if oldest < 1 {
+		oldest = 1
+	}
+	return oldest
+}
+
+func (s *watchedOrderStore) Events(after int64) ([]*pb.OrderEvent, <-chan struct{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	oldest := s.oldestLocked()
+	if after < oldest-1 {
+		return nil, nil, status.Errorf(codes.OutOfRange, "revision %d has been evicted from the watch history, oldest available revision is %d", after, oldest)
+	}
+	if after > s.revision {
+		return nil, nil, status.Errorf(codes.OutOfRange, "revision %d is ahead of the current revision %d", after, s.revision)
+	}
+	events := make([]*pb.OrderEvent, 0, s.revision-after)
+	for rev := after + 1; rev <= s.revision; rev++ {
+		events = append(events, s.history[rev%int64(len(s.history))])
+	}
+	return events, s.notify, nil
+}
+
+// watchOrders 从after之后的revision开始，把事件依次交给send，直到send返回错误或者done被关闭。
+// 订阅者读取得太慢、需要的事件已经被移出历史缓冲区时返回OutOfRange。
+func watchOrders(watcher orderWatcher, after int64, done <-chan struct{}, send func(event *pb.OrderEvent) error) error {
+	for {
+		events, wait, err := watcher.Events(after)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if err := send(event); err != nil {
+				return err
+			}
+			after = event.Revision
+		}
+		if len(events) > 0 {
+			continue
+		}
+		select {
+		case <-wait:
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// Server-side Streaming RPC
+// WatchOrders 把订单的变更事件以流的形式持续发送给客户端，直到客户端取消调用。
+func (s *server) WatchOrders(req *pb.WatchOrdersRequest, stream pb.OrderManagement_WatchOrdersServer) error {
+	watcher, ok := s.store.(orderWatcher)
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "order store does not support watching")
+	}
+	// resumeRevision为0表示从现在开始，需要保留的全部事件时使用fromOldest。
+	after := req.ResumeRevision
+	switch {
+	case req.FromOldest:
+		after = watcher.Oldest()
+	case after == 0:
+		after = watcher.Revision()
+	}
+	// 只发送调用方租户的订单的事件。revision是所有租户共享的，因此客户端看到的revision不一定连续。
+	tenant := tenantOf(stream.Context())
+	err := watchOrders(watcher, after, stream.Context().Done(), func(event *pb.OrderEvent) error {
+		if event.Order.GetTenantId() != tenant {
+			return nil
+		}
+		return stream.Send(event)
+	})
+	if err != nil {
+		return err
+	}
+	return stream.Context().Err()
+}
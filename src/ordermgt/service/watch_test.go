@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "ordermgt/service/ecommerce"
+)
+
+func eventList(events []*pb.OrderEvent) string {
+	var s []string
+	for _, event := range events {
+		s = append(s, fmt.Sprintf("%d:%s:%s", event.Revision, event.Type, event.Order.Id))
+	}
+	return fmt.Sprint(s)
+}
+
+func TestWatchedOrderStore_Events(t *testing.T) {
+	store := newWatchedOrderStore(newMemoryOrderStore(defaultShardCount), 16)
+	store.Put(&pb.Order{Id: "102"})
+	store.Put(&pb.Order{Id: "102", Price: 10})
+	store.Delete("102")
+	store.Delete("102")
+	// 事务中先新增再删除的订单不产生事件，多次写入同一订单只产生一个事件。
+	store.Txn([]string{"103", "104"}, func(tx OrderTxn) error {
+		tx.Put(&pb.Order{Id: "103"})
+		tx.Delete("103")
+		tx.Put(&pb.Order{Id: "104"})
+		return tx.Put(&pb.Order{Id: "104", Price: 20})
+	})
+	// 失败的事务不产生事件。
+	store.Txn([]string{"105"}, func(tx OrderTxn) error {
+		tx.Put(&pb.Order{Id: "105"})
+		return status.Errorf(codes.Aborted, "abort")
+	})
+
+	events, _, err := store.Events(0)
+	if err != nil {
+		t.Fatalf("Events(0) failed: %v", err)
+	}
+	if got, want := eventList(events), "[1:ADDED:102 2:UPDATED:102 3:DELETED:102 4:ADDED:104]"; got != want {
+		t.Fatalf("events = %s, want %s", got, want)
+	}
+	if events[3].Order.Price != 20 {
+		t.Fatalf("ADDED event carries price %v, want the committed value 20", events[3].Order.Price)
+	}
+	events, _, _ = store.Events(2)
+	if got, want := eventList(events), "[3:DELETED:102 4:ADDED:104]"; got != want {
+		t.Fatalf("Events(2) = %s, want %s", got, want)
+	}
+}
+
+func TestWatchedOrderStore_EvictedRevision(t *testing.T) {
+	store := newWatchedOrderStore(newMemoryOrderStore(defaultShardCount), 4)
+	for i := 0; i < 10; i++ {
+		store.Put(&pb.Order{Id: fmt.Sprint(i)})
+	}
+	if events, _, err := store.Events(6); err != nil || eventList(events) != "[7:ADDED:6 8:ADDED:7 9:ADDED:8 10:ADDED:9]" {
+		t.Fatalf("Events(6) = %s, %v", eventList(events), err)
+	}
+	if _, _, err := store.Events(5); status.Code(err) != codes.OutOfRange {
+		t.Fatalf("evicted revision returned %v, want OutOfRange", err)
+	}
+	if _, _, err := store.Events(11); status.Code(err) != codes.OutOfRange {
+		t.Fatalf("future revision returned %v, want OutOfRange", err)
+	}
+}
+
+func TestServer_WatchOrdersResume(t *testing.T) {
+	store := newWatchedOrderStore(newMemoryOrderStore(defaultShardCount), 4)
+	initSampleData(store)
+	client, stop := startBufConnServer(t, newServer(store))
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	// 示例数据产生了revision 1到5的事件，从5之后开始订阅。
+	stream, err := client.WatchOrders(watchCtx, &pb.WatchOrdersRequest{ResumeRevision: store.Revision()})
+	if err != nil {
+		t.Fatalf("WatchOrders failed: %v", err)
+	}
+	client.AddOrder(ctx, &pb.Order{Id: "107", Items: []string{"Google Pixel 4"}})
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if event.Type != pb.OrderEventType_ADDED || event.Order.Id != "107" || event.Revision != 6 {
+		t.Fatalf("first event = %v", event)
+	}
+	stopWatch()
+
+	// 断线期间发生的变更在重连后补齐。
+	client.TransitionOrder(ctx, &pb.TransitionOrderRequest{Id: "107", Status: pb.OrderStatus_CONFIRMED})
+	client.AddOrder(ctx, &pb.Order{Id: "108"})
+	stream, err = client.WatchOrders(ctx, &pb.WatchOrdersRequest{ResumeRevision: event.Revision})
+	if err != nil {
+		t.Fatalf("WatchOrders failed: %v", err)
+	}
+	var missed []*pb.OrderEvent
+	for len(missed) < 2 {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		missed = append(missed, event)
+	}
+	if got, want := eventList(missed), "[7:UPDATED:107 8:ADDED:108]"; got != want {
+		t.Fatalf("resumed events = %s, want %s", got, want)
+	}
+	if missed[0].Order.Status != pb.OrderStatus_CONFIRMED {
+		t.Fatalf("UPDATED event has status %s", missed[0].Order.Status)
+	}
+
+	// 容量为4的历史缓冲区只保留revision 5到8。
+	stream, err = client.WatchOrders(ctx, &pb.WatchOrdersRequest{ResumeRevision: 3})
+	if err != nil {
+		t.Fatalf("WatchOrders failed: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.OutOfRange {
+		t.Fatalf("evicted resume revision returned %v, want OutOfRange", err)
+	}
+
+	// fromOldest从保留的最早的事件开始发送。
+	stream, err = client.WatchOrders(ctx, &pb.WatchOrdersRequest{FromOldest: true})
+	if err != nil {
+		t.Fatalf("WatchOrders failed: %v", err)
+	}
+	var retained []*pb.OrderEvent
+	for len(retained) < 4 {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		retained = append(retained, event)
+	}
+	if retained[0].Revision != 5 || retained[3].Revision != 8 {
+		t.Fatalf("events from the oldest = %s, want revisions 5 to 8", eventList(retained))
+	}
+}
+
+// 不同订单的事务并发提交，每个订单的事件的顺序与它的提交顺序一致，revision没有空缺。
+func TestWatchedOrderStore_ConcurrentTxns(t *testing.T) {
+	store := newWatchedOrderStore(newMemoryOrderStore(defaultShardCount), 1024)
+	const orders, writes = 8, 20
+	var wg sync.WaitGroup
+	for i := 0; i < orders; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			for n := 1; n <= writes; n++ {
+				store.Put(&pb.Order{Id: id, WeightGrams: int64(n)})
+			}
+		}(fmt.Sprint(i))
+	}
+	wg.Wait()
+	events, _, err := store.Events(0)
+	if err != nil || len(events) != orders*writes {
+		t.Fatalf("Events(0) returned %d events, %v", len(events), err)
+	}
+	last := make(map[string]int64)
+	for i, event := range events {
+		if event.Revision != int64(i+1) {
+			t.Fatalf("event %d has revision %d", i, event.Revision)
+		}
+		if event.Order.WeightGrams != last[event.Order.Id]+1 {
+			t.Fatalf("order %s: write %d follows write %d", event.Order.Id, event.Order.WeightGrams, last[event.Order.Id])
+		}
+		last[event.Order.Id] = event.Order.WeightGrams
+	}
+}
@@ -7,6 +7,7 @@ import (
     // 导入protobuf编译器生成代码所在的包
 	pb "productinfo/client/ecommerce"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 const (
@@ -36,7 +37,7 @@ func main() {
 	defer cancel()
 
 	// 使用商品的详情信息调用AddProduct方法。如果操作成功完成，就会返回-一个商品ID，否则将返回一个错误。
-	r, err := c.AddProduct(ctx, &pb.Product{Name: name, Description: description, Price: price})
+	r, err := c.AddProduct(ctx, &pb.Product{Name: name, Description: description, Price: price, Stock: 10})
 	if err != nil {
 		log.Fatalf("Could not add product: %v", err)
 	}
@@ -48,4 +49,55 @@ func main() {
 		log.Fatalf("Could not get product: %v", err)
 	}
 	log.Printf("Product: %v", product.String())
+
+	// 按名称查找刚刚添加的商品，OrderManagement就是这样把订单条目解析为商品的。
+	found, err := c.FindProductByName(ctx, &pb.ProductName{Value: name})
+	if err != nil {
+		log.Fatalf("Could not find product: %v", err)
+	}
+	log.Printf("Product found by name: %s", found.Id)
+
+	// 为一个订单预留两件商品，然后释放预留，库存恢复原样。
+	reservation, err := c.ReserveStock(ctx, &pb.ReserveStockRequest{ReservationId: "demo-reservation", Items: []*pb.StockItem{{ProductId: r.Value, Quantity: 2}}})
+	if err != nil {
+		log.Fatalf("Could not reserve stock: %v", err)
+	}
+	log.Printf("Reservation: %v", reservation.String())
+	reservation, err = c.ReleaseStock(ctx, &pb.ReservationID{Value: reservation.Id})
+	if err != nil {
+		log.Fatalf("Could not release stock: %v", err)
+	}
+	log.Printf("Reservation: %v", reservation.String())
+
+	// 只修改商品描述，带上GetProduct返回的etag：商品在这期间被其他客户端修改时返回ABORTED，不会覆盖别人的修改。
+	updated, err := c.UpdateProduct(ctx, &pb.UpdateProductRequest{
+		Product:    &pb.Product{Id: r.Value, Description: "Apple iPhone 11 with dual-camera system.", Etag: product.Etag},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"description"}},
+	})
+	if err != nil {
+		log.Fatalf("Could not update product: %v", err)
+	}
+	log.Printf("Product updated, etag %s -> %s", product.Etag, updated.Etag)
+
+	// 按价格从高到低分页列出名称或描述中包含"apple"的商品。
+	listReq := &pb.ListProductsRequest{Query: "apple", OrderBy: pb.ProductSortOrder_PRODUCT_PRICE_DESC, PageSize: 10}
+	for {
+		page, err := c.ListProducts(ctx, listReq)
+		if err != nil {
+			log.Fatalf("Could not list products: %v", err)
+		}
+		for _, p := range page.Products {
+			log.Printf("Listed product: %s %s %v", p.Id, p.Name, p.Price)
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		listReq.PageToken = page.NextPageToken
+	}
+
+	// 删除商品，使用的是更新后的etag。
+	if _, err := c.DeleteProduct(ctx, &pb.DeleteProductRequest{Id: r.Value, Etag: updated.Etag}); err != nil {
+		log.Fatalf("Could not delete product: %v", err)
+	}
+	log.Printf("Product ID: %s deleted", r.Value)
 }
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	wrapper "github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	pb "productinfo/service/ecommerce"
+)
+
+// newCatalogServer 创建一个有三个默认租户的商品和一个acme租户的商品的服务器，返回服务器和按名称索引的商品ID。
+func newCatalogServer(t *testing.T) (*server, map[string]string) {
+	s := &server{
+		productMap:         make(map[string]*pb.Product),
+		reservations:       make(map[string]*pb.Reservation),
+		productTenants:     make(map[string]string),
+		reservationTenants: make(map[string]string),
+	}
+	ids := make(map[string]string)
+	acme := context.WithValue(context.Background(), tenantContextKey{}, "acme")
+	for _, p := range []struct {
+		ctx     context.Context
+		product *pb.Product
+	}{
+		{context.Background(), &pb.Product{Name: "Pixel 3A", Description: "Google phone", Price: 300, Stock: 5}},
+		{context.Background(), &pb.Product{Name: "MacBook Pro", Description: "Apple laptop", Price: 100}},
+		{context.Background(), &pb.Product{Name: "Pixel Buds", Description: "Google earbuds", Price: 200}},
+		{acme, &pb.Product{Name: "Pixel 4", Price: 150}},
+	} {
+		id, err := s.AddProduct(p.ctx, p.product)
+		if err != nil {
+			t.Fatalf("AddProduct(%s) failed: %v", p.product.Name, err)
+		}
+		ids[p.product.Name] = id.Value
+	}
+	return s, ids
+}
+
+func TestServer_ProductETag(t *testing.T) {
+	s, ids := newCatalogServer(t)
+	ctx := context.Background()
+	id := ids["Pixel 3A"]
+
+	product, err := s.GetProduct(ctx, &pb.ProductID{Value: id})
+	if err != nil || product.Etag == "" {
+		t.Fatalf("GetProduct = %v, %v", product, err)
+	}
+	// 商品没有变化时etag不变。
+	if again, _ := s.GetProduct(ctx, &pb.ProductID{Value: id}); again.Etag != product.Etag {
+		t.Fatalf("etag changed from %s to %s without an update", product.Etag, again.Etag)
+	}
+	updated, err := s.UpdateProduct(ctx, &pb.UpdateProductRequest{
+		Product:    &pb.Product{Id: id, Name: "Pixel 3A XL", Etag: product.Etag},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name"}},
+	})
+	if err != nil || updated.Etag == product.Etag {
+		t.Fatalf("UpdateProduct = %v, %v", updated, err)
+	}
+	// 使用过期的etag更新或删除商品都会失败，空的etag不做检查。
+	_, err = s.UpdateProduct(ctx, &pb.UpdateProductRequest{
+		Product:    &pb.Product{Id: id, Name: "Pixel", Etag: product.Etag},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name"}},
+	})
+	if status.Code(err) != codes.Aborted {
+		t.Fatalf("update with stale etag returned %v, want Aborted", err)
+	}
+	if _, err := s.DeleteProduct(ctx, &pb.DeleteProductRequest{Id: id, Etag: product.Etag}); status.Code(err) != codes.Aborted {
+		t.Fatalf("delete with stale etag returned %v, want Aborted", err)
+	}
+	// 预留库存修改了stock，etag随之变化。
+	if _, err := s.ReserveStock(ctx, &pb.ReserveStockRequest{ReservationId: "rsv-1", Items: []*pb.StockItem{{ProductId: id, Quantity: 1}}}); err != nil {
+		t.Fatalf("ReserveStock failed: %v", err)
+	}
+	if _, err := s.ReleaseStock(ctx, &pb.ReservationID{Value: "rsv-1"}); err != nil {
+		t.Fatalf("ReleaseStock failed: %v", err)
+	}
+	current, _ := s.GetProduct(ctx, &pb.ProductID{Value: id})
+	if current.Etag != updated.Etag {
+		t.Fatalf("etag after reserving and releasing = %s, want %s", current.Etag, updated.Etag)
+	}
+	if _, err := s.DeleteProduct(ctx, &pb.DeleteProductRequest{Id: id, Etag: current.Etag}); err != nil {
+		t.Fatalf("DeleteProduct failed: %v", err)
+	}
+
+	// 不存在的商品返回NotFound，其他租户的商品返回PermissionDenied。
+	if _, err := s.GetProduct(ctx, &pb.ProductID{Value: id}); status.Code(err) != codes.NotFound {
+		t.Fatalf("GetProduct of a deleted product returned %v, want NotFound", err)
+	}
+	if _, err := s.UpdateProduct(ctx, &pb.UpdateProductRequest{Product: &pb.Product{Id: id}}); status.Code(err) != codes.NotFound {
+		t.Fatalf("UpdateProduct of a deleted product returned %v, want NotFound", err)
+	}
+	if _, err := s.DeleteProduct(ctx, &pb.DeleteProductRequest{Id: id}); status.Code(err) != codes.NotFound {
+		t.Fatalf("DeleteProduct of a deleted product returned %v, want NotFound", err)
+	}
+	if _, err := s.DeleteProduct(ctx, &pb.DeleteProductRequest{Id: ids["Pixel 4"]}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("DeleteProduct of another tenant's product returned %v, want PermissionDenied", err)
+	}
+}
+
+func TestServer_UpdateProductMask(t *testing.T) {
+	s, ids := newCatalogServer(t)
+	ctx := context.Background()
+	id := ids["MacBook Pro"]
+	usd := func(units int64, nanos int32) *pb.Money {
+		return &pb.Money{CurrencyCode: "USD", Units: units, Nanos: nanos}
+	}
+
+	// 只修改updateMask中列出的字段，修改exactPrice后price随之更新。
+	updated, err := s.UpdateProduct(ctx, &pb.UpdateProductRequest{
+		Product:    &pb.Product{Id: id, Name: "ignored", ExactPrice: usd(1799, 990000000)},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"exactPrice"}},
+	})
+	if err != nil || updated.Name != "MacBook Pro" || updated.Description != "Apple laptop" || updated.Price != float32(1799.99) {
+		t.Fatalf("UpdateProduct(exactPrice) = %v, %v", updated, err)
+	}
+	// 修改price后exactPrice按USD换算。
+	updated, err = s.UpdateProduct(ctx, &pb.UpdateProductRequest{
+		Product:    &pb.Product{Id: id, Price: 1500},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"price"}},
+	})
+	if err != nil || !proto.Equal(updated.ExactPrice, usd(1500, 0)) {
+		t.Fatalf("UpdateProduct(price) = %v, %v", updated, err)
+	}
+	// updateMask为空时替换所有可以修改的字段，价格以exactPrice为准。
+	updated, err = s.UpdateProduct(ctx, &pb.UpdateProductRequest{
+		Product: &pb.Product{Id: id, Name: "MacBook Air", Price: 1, ExactPrice: usd(999, 0), Stock: 7},
+	})
+	if err != nil || updated.Name != "MacBook Air" || updated.Description != "" || updated.Price != 999 || updated.Stock != 7 {
+		t.Fatalf("UpdateProduct without mask = %v, %v", updated, err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		patch *pb.Product
+		paths []string
+	}{
+		{"id", &pb.Product{Id: id}, []string{"id"}},
+		{"etag", &pb.Product{Id: id}, []string{"etag"}},
+		{"both prices", &pb.Product{Id: id, Price: 1, ExactPrice: usd(1, 0)}, []string{"price", "exactPrice"}},
+		{"missing exactPrice", &pb.Product{Id: id}, []string{"exactPrice"}},
+		{"invalid exactPrice", &pb.Product{Id: id, ExactPrice: &pb.Money{CurrencyCode: "usd", Units: 1}}, []string{"exactPrice"}},
+		{"negative stock", &pb.Product{Id: id, Stock: -1}, []string{"stock"}},
+		{"empty id", &pb.Product{}, []string{"name"}},
+	} {
+		_, err := s.UpdateProduct(ctx, &pb.UpdateProductRequest{Product: tt.patch, UpdateMask: &fieldmaskpb.FieldMask{Paths: tt.paths}})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: UpdateProduct returned %v, want InvalidArgument", tt.name, err)
+		}
+	}
+	// 失败的更新不修改商品。
+	if product, _ := s.GetProduct(ctx, &pb.ProductID{Value: id}); product.Name != "MacBook Air" || product.Stock != 7 || product.Price != 999 {
+		t.Fatalf("product after rejected updates = %v", product)
+	}
+}
+
+// listNames 按listProducts的分页依次取出所有的页，返回商品的名称和页数。
+func listNames(t *testing.T, s *server, ctx context.Context, req *pb.ListProductsRequest, between func()) ([]string, int) {
+	var names []string
+	pages := 0
+	for {
+		page, err := s.ListProducts(ctx, req)
+		if err != nil {
+			t.Fatalf("ListProducts(%v) failed: %v", req, err)
+		}
+		pages++
+		for _, p := range page.Products {
+			if p.Etag == "" {
+				t.Fatalf("listed product %s has no etag", p.Id)
+			}
+			names = append(names, p.Name)
+		}
+		if page.NextPageToken == "" {
+			return names, pages
+		}
+		req = proto.Clone(req).(*pb.ListProductsRequest)
+		req.PageToken = page.NextPageToken
+		if between != nil {
+			between()
+		}
+	}
+}
+
+func TestServer_ListProductsPaging(t *testing.T) {
+	s, _ := newCatalogServer(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		req  *pb.ListProductsRequest
+		want string
+	}{
+		{"price asc", &pb.ListProductsRequest{OrderBy: pb.ProductSortOrder_PRODUCT_PRICE_ASC, PageSize: 1}, "[MacBook Pro Pixel Buds Pixel 3A]"},
+		{"price desc", &pb.ListProductsRequest{OrderBy: pb.ProductSortOrder_PRODUCT_PRICE_DESC, PageSize: 2}, "[Pixel 3A Pixel Buds MacBook Pro]"},
+		{"name desc", &pb.ListProductsRequest{OrderBy: pb.ProductSortOrder_PRODUCT_NAME_DESC, PageSize: 2}, "[Pixel Buds Pixel 3A MacBook Pro]"},
+		{"query", &pb.ListProductsRequest{Query: "GOOGLE", OrderBy: pb.ProductSortOrder_PRODUCT_NAME_ASC, PageSize: 1}, "[Pixel 3A Pixel Buds]"},
+		{"price range", &pb.ListProductsRequest{MinPrice: &wrapper.FloatValue{Value: 150}, MaxPrice: &wrapper.FloatValue{Value: 300}}, "[Pixel 3A Pixel Buds]"},
+	}
+	for _, tt := range tests {
+		names, _ := listNames(t, s, ctx, tt.req, nil)
+		if got := fmt.Sprint(names); got != tt.want {
+			t.Errorf("%s: listed %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// 翻页期间在已经返回的位置之前新增商品，不会导致结果重复或遗漏。
+	added := false
+	names, pages := listNames(t, s, ctx, &pb.ListProductsRequest{OrderBy: pb.ProductSortOrder_PRODUCT_PRICE_ASC, PageSize: 1}, func() {
+		if !added {
+			added = true
+			s.AddProduct(ctx, &pb.Product{Name: "Chromecast", Price: 50})
+		}
+	})
+	if fmt.Sprint(names) != "[MacBook Pro Pixel Buds Pixel 3A]" || pages != 3 {
+		t.Fatalf("listed %v in %d pages while adding a product", names, pages)
+	}
+
+	// acme租户只能看到自己的商品。
+	acme := context.WithValue(ctx, tenantContextKey{}, "acme")
+	if names, _ := listNames(t, s, acme, &pb.ListProductsRequest{}, nil); fmt.Sprint(names) != "[Pixel 4]" {
+		t.Fatalf("acme listed %v", names)
+	}
+
+	first, _ := s.ListProducts(ctx, &pb.ListProductsRequest{OrderBy: pb.ProductSortOrder_PRODUCT_PRICE_ASC, PageSize: 1})
+	for _, req := range []*pb.ListProductsRequest{
+		// 令牌只能用于生成它的查询。
+		{OrderBy: pb.ProductSortOrder_PRODUCT_NAME_ASC, PageSize: 1, PageToken: first.NextPageToken},
+		{PageToken: "not-a-token"},
+		{PageSize: -1},
+		{MinPrice: &wrapper.FloatValue{Value: 300}, MaxPrice: &wrapper.FloatValue{Value: 200}},
+	} {
+		if _, err := s.ListProducts(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ListProducts(%v) returned %v, want InvalidArgument", req, err)
+		}
+	}
+}
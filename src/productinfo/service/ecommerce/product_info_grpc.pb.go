@@ -11,6 +11,7 @@ import (
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
 
 // This is a compile-time assertion to ensure that this generated file
@@ -26,6 +27,25 @@ type ProductInfoClient interface {
 	AddProduct(ctx context.Context, in *Product, opts ...grpc.CallOption) (*ProductID, error)
 	// 基于商品ID获取商品的远程方法。
 	GetProduct(ctx context.Context, in *ProductID, opts ...grpc.CallOption) (*Product, error)
+	// 更新商品，只修改updateMask中列出的字段，updateMask为空时修改所有可以修改的字段。
+	// product.etag不为空并且与商品当前的etag不同时返回ABORTED，商品不存在时返回NOT_FOUND。
+	UpdateProduct(ctx context.Context, in *UpdateProductRequest, opts ...grpc.CallOption) (*Product, error)
+	// 删除商品，etag不为空并且与商品当前的etag不同时返回ABORTED。
+	// 商品还有没有确认或释放的库存预留时返回FAILED_PRECONDITION。
+	DeleteProduct(ctx context.Context, in *DeleteProductRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// 分页列出调用方租户的商品，可以按名称、描述和价格过滤，并指定排序方式。
+	ListProducts(ctx context.Context, in *ListProductsRequest, opts ...grpc.CallOption) (*ListProductsResponse, error)
+	// 按名称查找商品，名称不区分大小写。没有这个名称的商品时返回NOT_FOUND，
+	// 有多个商品使用这个名称时返回FAILED_PRECONDITION。
+	FindProductByName(ctx context.Context, in *ProductName, opts ...grpc.CallOption) (*Product, error)
+	// 为一次预留扣减库存。reservationId由调用方生成，用同一个ID和相同的条目重试是幂等的。
+	// 有商品不存在时返回NOT_FOUND，库存不足时返回FAILED_PRECONDITION，两种情况下都不扣减任何库存。
+	ReserveStock(ctx context.Context, in *ReserveStockRequest, opts ...grpc.CallOption) (*Reservation, error)
+	// 确认预留，预留的库存被视为已经售出，之后不能再释放。预留不存在时返回NOT_FOUND。
+	CommitStock(ctx context.Context, in *ReservationID, opts ...grpc.CallOption) (*Reservation, error)
+	// 释放预留，把预留的库存加回商品。释放不存在的预留也会成功并留下RELEASED的记录，
+	// 这样即使reserveStock请求在释放之后才到达，也不会再扣减库存。
+	ReleaseStock(ctx context.Context, in *ReservationID, opts ...grpc.CallOption) (*Reservation, error)
 }
 
 type productInfoClient struct {
@@ -54,6 +74,69 @@ func (c *productInfoClient) GetProduct(ctx context.Context, in *ProductID, opts
 	return out, nil
 }
 
+func (c *productInfoClient) UpdateProduct(ctx context.Context, in *UpdateProductRequest, opts ...grpc.CallOption) (*Product, error) {
+	out := new(Product)
+	err := c.cc.Invoke(ctx, "/ecommerce.ProductInfo/updateProduct", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productInfoClient) DeleteProduct(ctx context.Context, in *DeleteProductRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/ecommerce.ProductInfo/deleteProduct", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productInfoClient) ListProducts(ctx context.Context, in *ListProductsRequest, opts ...grpc.CallOption) (*ListProductsResponse, error) {
+	out := new(ListProductsResponse)
+	err := c.cc.Invoke(ctx, "/ecommerce.ProductInfo/listProducts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productInfoClient) FindProductByName(ctx context.Context, in *ProductName, opts ...grpc.CallOption) (*Product, error) {
+	out := new(Product)
+	err := c.cc.Invoke(ctx, "/ecommerce.ProductInfo/findProductByName", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productInfoClient) ReserveStock(ctx context.Context, in *ReserveStockRequest, opts ...grpc.CallOption) (*Reservation, error) {
+	out := new(Reservation)
+	err := c.cc.Invoke(ctx, "/ecommerce.ProductInfo/reserveStock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productInfoClient) CommitStock(ctx context.Context, in *ReservationID, opts ...grpc.CallOption) (*Reservation, error) {
+	out := new(Reservation)
+	err := c.cc.Invoke(ctx, "/ecommerce.ProductInfo/commitStock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productInfoClient) ReleaseStock(ctx context.Context, in *ReservationID, opts ...grpc.CallOption) (*Reservation, error) {
+	out := new(Reservation)
+	err := c.cc.Invoke(ctx, "/ecommerce.ProductInfo/releaseStock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ProductInfoServer is the server API for ProductInfo service.
 // All implementations must embed UnimplementedProductInfoServer
 // for forward compatibility
@@ -62,6 +145,25 @@ type ProductInfoServer interface {
 	AddProduct(context.Context, *Product) (*ProductID, error)
 	// 基于商品ID获取商品的远程方法。
 	GetProduct(context.Context, *ProductID) (*Product, error)
+	// 更新商品，只修改updateMask中列出的字段，updateMask为空时修改所有可以修改的字段。
+	// product.etag不为空并且与商品当前的etag不同时返回ABORTED，商品不存在时返回NOT_FOUND。
+	UpdateProduct(context.Context, *UpdateProductRequest) (*Product, error)
+	// 删除商品，etag不为空并且与商品当前的etag不同时返回ABORTED。
+	// 商品还有没有确认或释放的库存预留时返回FAILED_PRECONDITION。
+	DeleteProduct(context.Context, *DeleteProductRequest) (*emptypb.Empty, error)
+	// 分页列出调用方租户的商品，可以按名称、描述和价格过滤，并指定排序方式。
+	ListProducts(context.Context, *ListProductsRequest) (*ListProductsResponse, error)
+	// 按名称查找商品，名称不区分大小写。没有这个名称的商品时返回NOT_FOUND，
+	// 有多个商品使用这个名称时返回FAILED_PRECONDITION。
+	FindProductByName(context.Context, *ProductName) (*Product, error)
+	// 为一次预留扣减库存。reservationId由调用方生成，用同一个ID和相同的条目重试是幂等的。
+	// 有商品不存在时返回NOT_FOUND，库存不足时返回FAILED_PRECONDITION，两种情况下都不扣减任何库存。
+	ReserveStock(context.Context, *ReserveStockRequest) (*Reservation, error)
+	// 确认预留，预留的库存被视为已经售出，之后不能再释放。预留不存在时返回NOT_FOUND。
+	CommitStock(context.Context, *ReservationID) (*Reservation, error)
+	// 释放预留，把预留的库存加回商品。释放不存在的预留也会成功并留下RELEASED的记录，
+	// 这样即使reserveStock请求在释放之后才到达，也不会再扣减库存。
+	ReleaseStock(context.Context, *ReservationID) (*Reservation, error)
 	mustEmbedUnimplementedProductInfoServer()
 }
 
@@ -75,6 +177,27 @@ func (UnimplementedProductInfoServer) AddProduct(context.Context, *Product) (*Pr
 func (UnimplementedProductInfoServer) GetProduct(context.Context, *ProductID) (*Product, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetProduct not implemented")
 }
+func (UnimplementedProductInfoServer) UpdateProduct(context.Context, *UpdateProductRequest) (*Product, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateProduct not implemented")
+}
+func (UnimplementedProductInfoServer) DeleteProduct(context.Context, *DeleteProductRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteProduct not implemented")
+}
+func (UnimplementedProductInfoServer) ListProducts(context.Context, *ListProductsRequest) (*ListProductsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListProducts not implemented")
+}
+func (UnimplementedProductInfoServer) FindProductByName(context.Context, *ProductName) (*Product, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindProductByName not implemented")
+}
+func (UnimplementedProductInfoServer) ReserveStock(context.Context, *ReserveStockRequest) (*Reservation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReserveStock not implemented")
+}
+func (UnimplementedProductInfoServer) CommitStock(context.Context, *ReservationID) (*Reservation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CommitStock not implemented")
+}
+func (UnimplementedProductInfoServer) ReleaseStock(context.Context, *ReservationID) (*Reservation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReleaseStock not implemented")
+}
 func (UnimplementedProductInfoServer) mustEmbedUnimplementedProductInfoServer() {}
 
 // UnsafeProductInfoServer may be embedded to opt out of forward compatibility for this service.
@@ -124,6 +247,132 @@ func _ProductInfo_GetProduct_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ProductInfo_UpdateProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductInfoServer).UpdateProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.ProductInfo/updateProduct",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductInfoServer).UpdateProduct(ctx, req.(*UpdateProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductInfo_DeleteProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductInfoServer).DeleteProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.ProductInfo/deleteProduct",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductInfoServer).DeleteProduct(ctx, req.(*DeleteProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductInfo_ListProducts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListProductsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductInfoServer).ListProducts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.ProductInfo/listProducts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductInfoServer).ListProducts(ctx, req.(*ListProductsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductInfo_FindProductByName_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProductName)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductInfoServer).FindProductByName(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.ProductInfo/findProductByName",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductInfoServer).FindProductByName(ctx, req.(*ProductName))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductInfo_ReserveStock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReserveStockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductInfoServer).ReserveStock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.ProductInfo/reserveStock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductInfoServer).ReserveStock(ctx, req.(*ReserveStockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductInfo_CommitStock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReservationID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductInfoServer).CommitStock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.ProductInfo/commitStock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductInfoServer).CommitStock(ctx, req.(*ReservationID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductInfo_ReleaseStock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReservationID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductInfoServer).ReleaseStock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ecommerce.ProductInfo/releaseStock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductInfoServer).ReleaseStock(ctx, req.(*ReservationID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ProductInfo_ServiceDesc is the grpc.ServiceDesc for ProductInfo service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -139,6 +388,34 @@ var ProductInfo_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "getProduct",
 			Handler:    _ProductInfo_GetProduct_Handler,
 		},
+		{
+			MethodName: "updateProduct",
+			Handler:    _ProductInfo_UpdateProduct_Handler,
+		},
+		{
+			MethodName: "deleteProduct",
+			Handler:    _ProductInfo_DeleteProduct_Handler,
+		},
+		{
+			MethodName: "listProducts",
+			Handler:    _ProductInfo_ListProducts_Handler,
+		},
+		{
+			MethodName: "findProductByName",
+			Handler:    _ProductInfo_FindProductByName_Handler,
+		},
+		{
+			MethodName: "reserveStock",
+			Handler:    _ProductInfo_ReserveStock_Handler,
+		},
+		{
+			MethodName: "commitStock",
+			Handler:    _ProductInfo_CommitStock_Handler,
+		},
+		{
+			MethodName: "releaseStock",
+			Handler:    _ProductInfo_ReleaseStock_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "product_info.proto",
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "productinfo/service/ecommerce"
+)
+
+const (
+	nanosPerUnit = 1000000000
+	// defaultCurrency 是旧客户端只设置float价格时使用的货币。
+	defaultCurrency = "USD"
+)
+
+// validateMoney 检查货币代码是否为三个大写字母，nanos是否在范围内并且与units同号。
+func validateMoney(m *pb.Money) error {
+	if len(m.CurrencyCode) != 3 || strings.ToUpper(m.CurrencyCode) != m.CurrencyCode {
+		return fmt.Errorf("invalid currency code %q", m.CurrencyCode)
+	}
+	if m.Nanos <= -nanosPerUnit || m.Nanos >= nanosPerUnit {
+		return fmt.Errorf("nanos %d out of range", m.Nanos)
+	}
+	if (m.Units > 0 && m.Nanos < 0) || (m.Units < 0 && m.Nanos > 0) {
+		return fmt.Errorf("units %d and nanos %d have different signs", m.Units, m.Nanos)
+	}
+	return nil
+}
+
+// moneyFromFloat 把旧客户端设置的float价格换算为金额。先取能还原出同一个float32的最短十进制表示，
+// 例如float32(699.00)得到699，再把整数部分和小数部分分别解析，避免再次经过float运算。
+func moneyFromFloat(currency string, f float32) (*pb.Money, error) {
+	if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+		return nil, fmt.Errorf("invalid amount %v", f)
+	}
+	s := strconv.FormatFloat(float64(f), 'f', -1, 32)
+	negative := strings.HasPrefix(s, "-")
+	intPart, fracPart := strings.TrimPrefix(s, "-"), ""
+	if i := strings.IndexByte(intPart, '.'); i >= 0 {
+		intPart, fracPart = intPart[:i], intPart[i+1:]
+	}
+	if len(fracPart) > 9 {
+		fracPart = fracPart[:9]
+	}
+	units, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount %v : %v", f, err)
+	}
+	var nanos int64
+	if fracPart != "" {
+		nanos, _ = strconv.ParseInt(fracPart+strings.Repeat("0", 9-len(fracPart)), 10, 32)
+	}
+	if negative {
+		units, nanos = -units, -nanos
+	}
+	return &pb.Money{CurrencyCode: currency, Units: units, Nanos: int32(nanos)}, nil
+}
+
+// moneyToFloat 把金额换算为float，只用于填充旧的price字段，会损失精度。
+func moneyToFloat(m *pb.Money) float32 {
+	return float32(float64(m.Units) + float64(m.Nanos)/nanosPerUnit)
+}
+
+// normalizeProductPrice 是旧客户端的兼容层：只设置了float价格的商品按USD换算出精确价格，
+// 设置了精确价格的商品用它覆盖float价格，保证两个字段一致。
+func normalizeProductPrice(product *pb.Product) error {
+	if product.ExactPrice == nil {
+		m, err := moneyFromFloat(defaultCurrency, product.Price)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "Invalid price : %v", err)
+		}
+		product.ExactPrice = m
+		return nil
+	}
+	if err := validateMoney(product.ExactPrice); err != nil {
+		return status.Errorf(codes.InvalidArgument, "Invalid exactPrice : %v", err)
+	}
+	product.Price = moneyToFloat(product.ExactPrice)
+	return nil
+}
@@ -2,12 +2,16 @@ package main
 
 import (
 	"context"
+	"flag"
 	"github.com/gofrs/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"log"
 	"net"
+	"strings"
+	"sync"
+	"time"
 
 	// 导入刚刚通过protobuf编译器所生成的代码所在的包
 	pb "productinfo/service/ecommerce"
@@ -17,25 +21,54 @@ const (
 	port = ":50051"
 )
 
+// reservationTTL 为0时预留一直保留到确认或释放。大于0时，超过这个时间没有确认的预留被自动释放，
+// 调用方崩溃后留下的预留不会永远占用库存；应当长于订单从下单到发货的时间。
+var reservationTTL = flag.Duration("reservation_ttl", 0, "release stock reservations that are not committed within this duration, 0 keeps them until released")
+
 // server is used to implement ecommerce/product_info.
 // server结构体是对服务器的抽象。可以通过它将服务方法附加到服务器上。
 type server struct {
-	productMap map[string]*pb.Product
+	// mu 保护productMap和reservations，预留库存需要同时检查和修改多个商品，
+	// updateProduct和deleteProduct也在同一个锁内检查etag并修改商品。
+	mu           sync.Mutex
+	productMap   map[string]*pb.Product
+	reservations map[string]*pb.Reservation
+	// productTenants 和 reservationTenants 记录商品和预留所属的租户，默认租户为空字符串。
+	productTenants     map[string]string
+	reservationTenants map[string]string
+	// reservationTTL 大于0时，reservationExpires记录每个RESERVED预留的过期时间。
+	reservationTTL     time.Duration
+	reservationExpires map[string]time.Time
+	// now 返回当前时间，为nil时使用time.Now，测试中可以替换。
+	now func() time.Time
 	pb.UnimplementedProductInfoServer
 }
 
 // AddProduct implements ecommerce.AddProduct
 // AddProduct方法以Product作为参数并返回一个ProductID。Product和ProductID结构体定义在product_info.pb.go 文件中，该文件是通过product_info.proto定义自动生成的。
 func (s *server) AddProduct(ctx context.Context, in *pb.Product) (*pb.ProductID, error) {
+	// 兼容只设置float价格的旧客户端，保证price和exactPrice一致。
+	if err := normalizeProductPrice(in); err != nil {
+		return nil, err
+	}
+	if in.Stock < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Stock must not be negative : %d", in.Stock)
+	}
 	out, err := uuid.NewV4()
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Error while generating Product ID", err)
+		return nil, status.Errorf(codes.Internal, "Error while generating Product ID : %v", err)
 	}
 	in.Id = out.String()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.productMap == nil {
 		s.productMap = make(map[string]*pb.Product)
 	}
+	if s.productTenants == nil {
+		s.productTenants = make(map[string]string)
+	}
 	s.productMap[in.Id] = in
+	s.productTenants[in.Id] = tenantOf(ctx)
 	log.Printf("Product %v : %v - Added.", in.Id, in.Name)
 	return &pb.ProductID{Value: in.Id}, status.New(codes.OK, "").Err()
 }
@@ -43,18 +76,49 @@ func (s *server) AddProduct(ctx context.Context, in *pb.Product) (*pb.ProductID,
 // GetProduct implements ecommerce.GetProduct
 // GetProduct 方法以ProductID作为参数并返回product
 func (s *server) GetProduct(ctx context.Context, in *pb.ProductID) (*pb.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	product, exists := s.productMap[in.Value]
 	if exists && product != nil {
+		if err := checkTenant(ctx, "Product", in.Value, s.productTenants[in.Value]); err != nil {
+			return nil, err
+		}
 		log.Printf("Product %v : %v - Retrieved.", product.Id, product.Name)
-		return product, status.New(codes.OK, "").Err()
+		// 返回带有etag的副本，预留库存时修改的是保存的商品。
+		return withETag(product), status.New(codes.OK, "").Err()
 	}
-	return nil, status.Errorf(codes.NotFound, "Product does not exist.", in.Value)
+	return nil, status.Errorf(codes.NotFound, "Product does not exist. : %s", in.Value)
+}
+
+// FindProductByName implements ecommerce.FindProductByName
+// FindProductByName 方法按名称查找商品，供OrderManagement等其他服务把订单条目解析为商品。
+func (s *server) FindProductByName(ctx context.Context, in *pb.ProductName) (*pb.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var found *pb.Product
+	tenant := tenantOf(ctx)
+	for id, product := range s.productMap {
+		// 只查找调用方租户的商品。
+		if s.productTenants[id] != tenant || !strings.EqualFold(product.Name, in.Value) {
+			continue
+		}
+		if found != nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "Product name %q is ambiguous.", in.Value)
+		}
+		found = product
+	}
+	if found == nil {
+		return nil, status.Errorf(codes.NotFound, "Product does not exist. : %s", in.Value)
+	}
+	log.Printf("Product %v : %v - Found by name.", found.Id, found.Name)
+	return withETag(found), nil
 }
 
 /*这两个方法都有一个 context参数。Context 对象包含些元数据，比如终端用户授权令牌的标识和请求的截止时间。这些元数据会在请求的生命周期内一直存在。
 这两个方法都会返回一个错误以及远程方法的返回值(方法有多种返回类型)。这些错误会传播给消费者，用来进行消费者端的错误处理。*/
 
 func main() {
+	flag.Parse()
 	// 由gRPC服务器所绑定的TCP监听器在给定的端口(50051)上创建。
 	lis, err := net.Listen("tcp", port)
 	if err != nil {
@@ -62,11 +126,18 @@ func main() {
 	}
 
 	// 通过调用gRPC Go API创建新的gRPC服务器实例。
-	s := grpc.NewServer()
+	// 租户拦截器从元数据tenant-id中确定调用方的租户，商品和预留按租户隔离。
+	s := grpc.NewServer(grpc.UnaryInterceptor(tenantInterceptor))
 	// 通过调用生成的API, 将之前生成的服务注册到新创建的gRPC服务器上。
-	pb.RegisterProductInfoServer(s, &server{})
+	pb.RegisterProductInfoServer(s, &server{
+		productMap:         make(map[string]*pb.Product),
+		reservations:       make(map[string]*pb.Reservation),
+		productTenants:     make(map[string]string),
+		reservationTenants: make(map[string]string),
+		reservationTTL:     *reservationTTL,
+	})
 	// 在指定端口（50051）上开始监听传入的消息
 	if err := s.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	pb "productinfo/service/ecommerce"
+)
+
+// mergeStockItems 按商品合并数量，结果按商品第一次出现的顺序排列。每个条目的数量必须为正数。
+func mergeStockItems(items []*pb.StockItem) ([]*pb.StockItem, error) {
+	if len(items) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "At least one item is required.")
+	}
+	var merged []*pb.StockItem
+	index := make(map[string]int)
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "Quantity of product %s must be positive : %d", item.ProductId, item.Quantity)
+		}
+		if i, ok := index[item.ProductId]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.ProductId] = len(merged)
+		merged = append(merged, &pb.StockItem{ProductId: item.ProductId, Quantity: item.Quantity})
+	}
+	return merged, nil
+}
+
+func sameStockItems(a, b []*pb.StockItem) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !proto.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// expireReservationsLocked 释放所有已经过期的RESERVED预留，把库存加回商品。reservationTTL为0时预留不会过期。
+func (s *server) expireReservationsLocked() {
+	if s.reservationTTL <= 0 {
+		return
+	}
+	now := time.Now()
+	if s.now != nil {
+		now = s.now()
+	}
+	for id, expires := range s.reservationExpires {
+		if now.Before(expires) {
+			continue
+		}
+		delete(s.reservationExpires, id)
+		if r := s.reservations[id]; r.State == pb.ReservationState_RESERVED {
+			s.releaseLocked(r)
+			log.Printf("Reservation %v - Expired.", r.Id)
+		}
+	}
+}
+
+// releaseLocked 把RESERVED预留的库存加回商品并把预留标记为RELEASED。
+func (s *server) releaseLocked(r *pb.Reservation) {
+	for _, item := range r.Items {
+		s.productMap[item.ProductId].Stock += item.Quantity
+	}
+	r.State = pb.ReservationState_RELEASED
+	delete(s.reservationExpires, r.Id)
+}
+
+// ReserveStock implements ecommerce.ReserveStock
+// ReserveStock 方法为一次预留扣减所有条目的库存：要么全部扣减，要么一个都不扣减。
+// 用同一个ID重试时返回已有的预留，不会重复扣减。设置了reservationTTL时，超时没有确认的预留被自动释放。
+func (s *server) ReserveStock(ctx context.Context, in *pb.ReserveStockRequest) (*pb.Reservation, error) {
+	if in.ReservationId == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Reservation ID must not be empty.")
+	}
+	items, err := mergeStockItems(in.Items)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireReservationsLocked()
+	if r, exists := s.reservations[in.ReservationId]; exists {
+		if err := checkTenant(ctx, "Reservation", r.Id, s.reservationTenants[r.Id]); err != nil {
+			return nil, err
+		}
+		if r.State != pb.ReservationState_RESERVED {
+			return nil, status.Errorf(codes.FailedPrecondition, "Reservation %s is already %s.", r.Id, r.State)
+		}
+		if !sameStockItems(r.Items, items) {
+			return nil, status.Errorf(codes.AlreadyExists, "Reservation %s was made for different items.", r.Id)
+		}
+		return proto.Clone(r).(*pb.Reservation), nil
+	}
+	for _, item := range items {
+		product, exists := s.productMap[item.ProductId]
+		if !exists {
+			return nil, status.Errorf(codes.NotFound, "Product does not exist. : %s", item.ProductId)
+		}
+		if err := checkTenant(ctx, "Product", item.ProductId, s.productTenants[item.ProductId]); err != nil {
+			return nil, err
+		}
+		if product.Stock < item.Quantity {
+			return nil, status.Errorf(codes.FailedPrecondition, "Insufficient stock for product %s : %d available, %d requested.", product.Id, product.Stock, item.Quantity)
+		}
+	}
+	for _, item := range items {
+		s.productMap[item.ProductId].Stock -= item.Quantity
+	}
+	r := &pb.Reservation{Id: in.ReservationId, Items: items, State: pb.ReservationState_RESERVED}
+	s.reservations[r.Id] = r
+	s.reservationTenants[r.Id] = tenantOf(ctx)
+	if s.reservationTTL > 0 {
+		if s.reservationExpires == nil {
+			s.reservationExpires = make(map[string]time.Time)
+		}
+		now := time.Now()
+		if s.now != nil {
+			now = s.now()
+		}
+		s.reservationExpires[r.Id] = now.Add(s.reservationTTL)
+	}
+	log.Printf("Reservation %v - Reserved %v.", r.Id, items)
+	return proto.Clone(r).(*pb.Reservation), nil
+}
+
+// CommitStock implements ecommerce.CommitStock
+// CommitStock 方法确认预留。重复确认是幂等的，已经释放（包括过期）的预留不能再确认。
+func (s *server) CommitStock(ctx context.Context, in *pb.ReservationID) (*pb.Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireReservationsLocked()
+	r, exists := s.reservations[in.Value]
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "Reservation does not exist. : %s", in.Value)
+	}
+	if err := checkTenant(ctx, "Reservation", r.Id, s.reservationTenants[r.Id]); err != nil {
+		return nil, err
+	}
+	switch r.State {
+	case pb.ReservationState_RELEASED:
+		return nil, status.Errorf(codes.FailedPrecondition, "Reservation %s is already RELEASED.", r.Id)
+	case pb.ReservationState_RESERVED:
+		r.State = pb.ReservationState_COMMITTED
+		delete(s.reservationExpires, r.Id)
+		log.Printf("Reservation %v - Committed.", r.Id)
+	}
+	return proto.Clone(r).(*pb.Reservation), nil
+}
+
+// ReleaseStock implements ecommerce.ReleaseStock
+// ReleaseStock 方法释放预留并把库存加回商品。重复释放是幂等的，已经确认的预留不能再释放。
+func (s *server) ReleaseStock(ctx context.Context, in *pb.ReservationID) (*pb.Reservation, error) {
+	if in.Value == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Reservation ID must not be empty.")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireReservationsLocked()
+	r, exists := s.reservations[in.Value]
+	if !exists {
+		// 调用方在不确定reserveStock是否成功时也会释放预留，留下记录使之后到达的reserveStock被拒绝。
+		r = &pb.Reservation{Id: in.Value, State: pb.ReservationState_RELEASED}
+		s.reservations[r.Id] = r
+		s.reservationTenants[r.Id] = tenantOf(ctx)
+		log.Printf("Reservation %v - Released before it was made.", r.Id)
+		return proto.Clone(r).(*pb.Reservation), nil
+	}
+	if err := checkTenant(ctx, "Reservation", r.Id, s.reservationTenants[r.Id]); err != nil {
+		return nil, err
+	}
+	switch r.State {
+	case pb.ReservationState_COMMITTED:
+		return nil, status.Errorf(codes.FailedPrecondition, "Reservation %s is already COMMITTED.", r.Id)
+	case pb.ReservationState_RESERVED:
+		s.releaseLocked(r)
+		log.Printf("Reservation %v - Released %v.", r.Id, r.Items)
+	}
+	return proto.Clone(r).(*pb.Reservation), nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "productinfo/service/ecommerce"
+)
+
+// newStockServer 创建一个有两个默认租户的商品和一个acme租户的商品的服务器。
+func newStockServer() *server {
+	s := &server{
+		productMap:         make(map[string]*pb.Product),
+		reservations:       make(map[string]*pb.Reservation),
+		productTenants:     make(map[string]string),
+		reservationTenants: make(map[string]string),
+	}
+	for _, p := range []struct {
+		id, tenant string
+		stock      int64
+	}{{"p-pixel", "", 5}, {"p-mbp", "", 2}, {"p-acme", "acme", 3}} {
+		s.productMap[p.id] = &pb.Product{Id: p.id, Name: p.id, Stock: p.stock}
+		s.productTenants[p.id] = p.tenant
+	}
+	return s
+}
+
+// stockOf 返回每个商品的库存。
+func stockOf(s *server) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stock := make(map[string]int64)
+	for id, product := range s.productMap {
+		stock[id] = product.Stock
+	}
+	return fmt.Sprint(stock)
+}
+
+func reserveRequest(id string, items ...*pb.StockItem) *pb.ReserveStockRequest {
+	return &pb.ReserveStockRequest{ReservationId: id, Items: items}
+}
+
+func TestServer_ReserveStock(t *testing.T) {
+	s := newStockServer()
+	ctx := context.Background()
+
+	// 同一个商品的条目合并后扣减。
+	r, err := s.ReserveStock(ctx, reserveRequest("rsv-1", &pb.StockItem{ProductId: "p-pixel", Quantity: 1}, &pb.StockItem{ProductId: "p-mbp", Quantity: 1}, &pb.StockItem{ProductId: "p-pixel", Quantity: 2}))
+	if err != nil || r.State != pb.ReservationState_RESERVED || len(r.Items) != 2 || r.Items[0].Quantity != 3 {
+		t.Fatalf("ReserveStock = %v, %v", r, err)
+	}
+	// 用同一个ID重试返回已有的预留，不会重复扣减。
+	if _, err := s.ReserveStock(ctx, reserveRequest("rsv-1", &pb.StockItem{ProductId: "p-pixel", Quantity: 3}, &pb.StockItem{ProductId: "p-mbp", Quantity: 1})); err != nil {
+		t.Fatalf("retrying ReserveStock failed: %v", err)
+	}
+	if got, want := stockOf(s), "map[p-acme:3 p-mbp:1 p-pixel:2]"; got != want {
+		t.Fatalf("stock = %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		name string
+		req  *pb.ReserveStockRequest
+		code codes.Code
+	}{
+		{"empty ID", reserveRequest("", &pb.StockItem{ProductId: "p-pixel", Quantity: 1}), codes.InvalidArgument},
+		{"no items", reserveRequest("rsv-2"), codes.InvalidArgument},
+		{"non-positive quantity", reserveRequest("rsv-2", &pb.StockItem{ProductId: "p-pixel", Quantity: 0}), codes.InvalidArgument},
+		{"unknown product", reserveRequest("rsv-2", &pb.StockItem{ProductId: "p-none", Quantity: 1}), codes.NotFound},
+		{"other tenant's product", reserveRequest("rsv-2", &pb.StockItem{ProductId: "p-acme", Quantity: 1}), codes.PermissionDenied},
+		// 库存不足时一个商品都不扣减。
+		{"out of stock", reserveRequest("rsv-2", &pb.StockItem{ProductId: "p-pixel", Quantity: 1}, &pb.StockItem{ProductId: "p-mbp", Quantity: 2}), codes.FailedPrecondition},
+		{"same ID for different items", reserveRequest("rsv-1", &pb.StockItem{ProductId: "p-pixel", Quantity: 1}), codes.AlreadyExists},
+	}
+	for _, tt := range tests {
+		if _, err := s.ReserveStock(ctx, tt.req); status.Code(err) != tt.code {
+			t.Errorf("%s: ReserveStock = %v, want %s", tt.name, err, tt.code)
+		}
+	}
+	if got, want := stockOf(s), "map[p-acme:3 p-mbp:1 p-pixel:2]"; got != want {
+		t.Errorf("stock after rejected reservations = %s, want %s", got, want)
+	}
+}
+
+func TestServer_CommitAndReleaseStock(t *testing.T) {
+	s := newStockServer()
+	ctx := context.Background()
+	s.ReserveStock(ctx, reserveRequest("rsv-1", &pb.StockItem{ProductId: "p-pixel", Quantity: 2}))
+	s.ReserveStock(ctx, reserveRequest("rsv-2", &pb.StockItem{ProductId: "p-mbp", Quantity: 2}))
+
+	// 确认和释放都是幂等的。
+	for i := 0; i < 2; i++ {
+		if r, err := s.CommitStock(ctx, &pb.ReservationID{Value: "rsv-1"}); err != nil || r.State != pb.ReservationState_COMMITTED {
+			t.Fatalf("CommitStock = %v, %v", r, err)
+		}
+		if r, err := s.ReleaseStock(ctx, &pb.ReservationID{Value: "rsv-2"}); err != nil || r.State != pb.ReservationState_RELEASED {
+			t.Fatalf("ReleaseStock = %v, %v", r, err)
+		}
+	}
+	if got, want := stockOf(s), "map[p-acme:3 p-mbp:2 p-pixel:3]"; got != want {
+		t.Fatalf("stock = %s, want %s", got, want)
+	}
+	if _, err := s.ReleaseStock(ctx, &pb.ReservationID{Value: "rsv-1"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("releasing a committed reservation: %v", err)
+	}
+	if _, err := s.CommitStock(ctx, &pb.ReservationID{Value: "rsv-2"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("committing a released reservation: %v", err)
+	}
+	if _, err := s.CommitStock(ctx, &pb.ReservationID{Value: "rsv-none"}); status.Code(err) != codes.NotFound {
+		t.Errorf("committing an unknown reservation: %v", err)
+	}
+	// 释放还没有到达的预留留下记录，之后到达的预留被拒绝。
+	if r, err := s.ReleaseStock(ctx, &pb.ReservationID{Value: "rsv-3"}); err != nil || r.State != pb.ReservationState_RELEASED {
+		t.Fatalf("releasing before reserving = %v, %v", r, err)
+	}
+	if _, err := s.ReserveStock(ctx, reserveRequest("rsv-3", &pb.StockItem{ProductId: "p-pixel", Quantity: 1})); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("reserving after release: %v", err)
+	}
+	if got, want := stockOf(s), "map[p-acme:3 p-mbp:2 p-pixel:3]"; got != want {
+		t.Errorf("stock = %s, want %s", got, want)
+	}
+	// 其他租户不能确认或释放预留。
+	acme := context.WithValue(ctx, tenantContextKey{}, "acme")
+	s.ReserveStock(ctx, reserveRequest("rsv-4", &pb.StockItem{ProductId: "p-pixel", Quantity: 1}))
+	if _, err := s.CommitStock(acme, &pb.ReservationID{Value: "rsv-4"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("committing another tenant's reservation: %v", err)
+	}
+	if _, err := s.ReleaseStock(acme, &pb.ReservationID{Value: "rsv-4"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("releasing another tenant's reservation: %v", err)
+	}
+}
+
+// 超过reservationTTL没有确认的预留被自动释放，库存加回商品，之后不能再确认。
+func TestServer_ReservationExpiry(t *testing.T) {
+	s := newStockServer()
+	now := time.Unix(1600000000, 0)
+	s.now = func() time.Time { return now }
+	s.reservationTTL = time.Minute
+	ctx := context.Background()
+	s.ReserveStock(ctx, reserveRequest("rsv-1", &pb.StockItem{ProductId: "p-mbp", Quantity: 2}))
+	s.ReserveStock(ctx, reserveRequest("rsv-2", &pb.StockItem{ProductId: "p-pixel", Quantity: 1}))
+	if _, err := s.CommitStock(ctx, &pb.ReservationID{Value: "rsv-2"}); err != nil {
+		t.Fatalf("CommitStock failed: %v", err)
+	}
+
+	now = now.Add(time.Minute - time.Second)
+	if _, err := s.ReserveStock(ctx, reserveRequest("rsv-3", &pb.StockItem{ProductId: "p-mbp", Quantity: 1})); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("reserving before expiry: %v", err)
+	}
+	now = now.Add(time.Second)
+	// 过期的预留释放的库存可以被新的预留使用，已经确认的预留不受影响。
+	if _, err := s.ReserveStock(ctx, reserveRequest("rsv-3", &pb.StockItem{ProductId: "p-mbp", Quantity: 1})); err != nil {
+		t.Fatalf("reserving after expiry failed: %v", err)
+	}
+	if got, want := stockOf(s), "map[p-acme:3 p-mbp:1 p-pixel:4]"; got != want {
+		t.Errorf("stock = %s, want %s", got, want)
+	}
+	if _, err := s.CommitStock(ctx, &pb.ReservationID{Value: "rsv-1"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("committing an expired reservation: %v", err)
+	}
+	if r, err := s.ReleaseStock(ctx, &pb.ReservationID{Value: "rsv-1"}); err != nil || r.State != pb.ReservationState_RELEASED {
+		t.Errorf("releasing an expired reservation = %v, %v", r, err)
+	}
+	// 重试过期的预留不会重新扣减库存。
+	if _, err := s.ReserveStock(ctx, reserveRequest("rsv-1", &pb.StockItem{ProductId: "p-mbp", Quantity: 2})); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("retrying an expired reservation: %v", err)
+	}
+	if got, want := stockOf(s), "map[p-acme:3 p-mbp:1 p-pixel:4]"; got != want {
+		t.Errorf("stock = %s, want %s", got, want)
+	}
+}
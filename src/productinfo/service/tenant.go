@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"regexp"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// tenantIDKey 是客户端声明所属租户的元数据键，与OrderManagement使用的相同。
+	tenantIDKey = "tenant-id"
+	// defaultTenantLabel 表示默认租户，没有设置tenant-id的调用也属于默认租户。
+	defaultTenantLabel = "default"
+)
+
+// tenantIDPattern 是合法的租户ID：小写字母、数字和连字符，不超过63个字符。
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+type tenantContextKey struct{}
+
+// tenantOf 返回拦截器为调用确定的租户，默认租户为空字符串。
+func tenantOf(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenant
+}
+
+// Server :: Unary Interceptor
+// 服务器端一元拦截器：从元数据tenant-id中读取调用方的租户并放入Context。
+func tenantInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	tenant := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(tenantIDKey); len(ids) > 0 {
+			if !tenantIDPattern.MatchString(ids[0]) {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid %s : %q", tenantIDKey, ids[0])
+			}
+			if ids[0] != defaultTenantLabel {
+				tenant = ids[0]
+			}
+		}
+	}
+	return handler(context.WithValue(ctx, tenantContextKey{}, tenant), req)
+}
+
+// checkTenant 在资源不属于调用方的租户时返回PermissionDenied。
+func checkTenant(ctx context.Context, resourceType, id, owner string) error {
+	if owner != tenantOf(ctx) {
+		return status.Errorf(codes.PermissionDenied, "%s %s belongs to another tenant.", resourceType, id)
+	}
+	return nil
+}
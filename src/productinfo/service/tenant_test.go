@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestTenantInterceptor(t *testing.T) {
+	tests := []struct {
+		name   string
+		md     metadata.MD
+		tenant string
+		code   codes.Code
+	}{
+		{name: "no metadata"},
+		{name: "default tenant label", md: metadata.Pairs(tenantIDKey, defaultTenantLabel)},
+		{name: "tenant", md: metadata.Pairs(tenantIDKey, "acme-2"), tenant: "acme-2"},
+		{name: "upper case", md: metadata.Pairs(tenantIDKey, "Acme"), code: codes.InvalidArgument},
+		{name: "empty", md: metadata.Pairs(tenantIDKey, ""), code: codes.InvalidArgument},
+		{name: "leading hyphen", md: metadata.Pairs(tenantIDKey, "-acme"), code: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.md != nil {
+			ctx = metadata.NewIncomingContext(ctx, tt.md)
+		}
+		var got string
+		_, err := tenantInterceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			got = tenantOf(ctx)
+			return nil, nil
+		})
+		if status.Code(err) != tt.code {
+			t.Errorf("%s: error = %v, want %s", tt.name, err, tt.code)
+			continue
+		}
+		if err == nil && got != tt.tenant {
+			t.Errorf("%s: tenant = %q, want %q", tt.name, got, tt.tenant)
+		}
+	}
+}
+
+func TestCheckTenant(t *testing.T) {
+	acme := context.WithValue(context.Background(), tenantContextKey{}, "acme")
+	if err := checkTenant(acme, "Product", "p-1", "acme"); err != nil {
+		t.Errorf("checkTenant for the owner: %v", err)
+	}
+	if err := checkTenant(acme, "Product", "p-1", ""); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("checkTenant for the default tenant's product: %v", err)
+	}
+	if err := checkTenant(context.Background(), "Product", "p-1", "acme"); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("checkTenant from the default tenant: %v", err)
+	}
+}